@@ -61,6 +61,8 @@ func NewCmd() *cobra.Command {
 
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupRegistryThrottling(&commonCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
 
 	common.SetupStagesStorageOptions(&commonCmdData, cmd) // FIXME
 
@@ -68,6 +68,8 @@ Currently supported only GitLab (gitlab) and GitHub (github) CI systems`,
 	common.SetupHomeDir(&commonCmdData, cmd)
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command will copy specified or default (~/.docker) config to the temporary directory and may perform additional login with new config.")
 
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
+
 	common.SetupLogOptions(&commonCmdData, cmd)
 
 	cmd.Flags().BoolVarP(&cmdData.AsFile, "as-file", "", common.GetBoolEnvironmentDefaultFalse("WERF_AS_FILE"), "Create the script and print the path for sourcing (default $WERF_AS_FILE).")
@@ -118,6 +120,14 @@ func runCIEnv(cmd *cobra.Command, args []string) error {
 	}
 	ctx = ctxWithDockerCli
 
+	if err := common.RegistryCredentialsInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.LoginRegistriesFromCredentialsFile(ctx); err != nil {
+		return err
+	}
+
 	switch cmdData.Shell {
 	case "", "default", "cmdexe", "powershell":
 	default:
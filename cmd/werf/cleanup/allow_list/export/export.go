@@ -0,0 +1,139 @@
+package export
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/cleaning/allow_list"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	To string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "export",
+		DisableFlagsInUseLine: true,
+		Short:                 "Export the images used in Kubernetes into the allow list file",
+		Long: common.GetLongCommandDescription(`Export the images used in Kubernetes into the allow list file.
+
+The command scans the same Kubernetes resources as werf cleanup command does, including the resources and helm release revisions from meta.cleanup.allowList of werf.yaml, thus it should be run in the project directory. Run the command inside the cluster (in-cluster config is used automatically) or against the kube-config of the cluster which is not reachable from the cleanup job, then pass the file to werf cleanup with --allow-list-file option.`),
+		Example: `  # Export the images used in all namespaces of the current cluster
+  $ werf cleanup allow-list export --to production.json
+
+  # Use the images from the exported file during cleanup
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --allow-list-file production.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if cmdData.To == "" {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--to FILE param required")
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismInspectorOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+	common.SetupScanContextNamespaceOnly(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.To, "to", "", os.Getenv("WERF_TO"), "Write the allow list into the specified file (default $WERF_TO)")
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.InitGiterminismInspector(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := git_repo.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	kubernetesContextClients, err := common.GetKubernetesContextClients(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
+	}
+	if len(kubernetesContextClients) == 0 {
+		return fmt.Errorf("no Kubernetes contexts found: in-cluster config or kube-config is required")
+	}
+
+	kubernetesNamespaceRestrictionByContext := common.GetKubernetesNamespaceRestrictionByContext(&commonCmdData, kubernetesContextClients)
+
+	var contexts, images []string
+	for _, contextClient := range kubernetesContextClients {
+		if err := logboek.Context(ctx).LogProcessInline("Getting deployed docker images (context %s)", contextClient.ContextName).
+			DoError(func() error {
+				contextImages, err := allow_list.DeployedDockerImages(ctx, contextClient.Client, kubernetesNamespaceRestrictionByContext[contextClient.ContextName], werfConfig.Meta.Cleanup.AllowList)
+				if err != nil {
+					return fmt.Errorf("cannot get deployed images: %s", err)
+				}
+
+				contexts = append(contexts, contextClient.ContextName)
+				images = append(images, contextImages...)
+
+				return nil
+			}); err != nil {
+			return err
+		}
+	}
+
+	file := allow_list.NewFile(contexts, images)
+	if err := file.Save(cmdData.To); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogF("Allow list with %d images has been written into %s\n", len(file.Images), cmdData.To)
+
+	return nil
+}
@@ -2,6 +2,7 @@ package cleanup
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 
@@ -9,6 +10,8 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/cleaning"
+	"github.com/werf/werf/pkg/cleaning/allow_list"
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
@@ -21,6 +24,11 @@ import (
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
+var cmdData struct {
+	PlanOut   string
+	ApplyPlan string
+}
+
 var commonCmdData common.CmdData
 
 func NewCmd() *cobra.Command {
@@ -33,7 +41,17 @@ func NewCmd() *cobra.Command {
 The command works according to special rules called cleanup policies, which the user defines in werf.yaml (https://werf.io/documentation/reference/werf_yaml.html#configuring-cleanup-policies).
 
 It is safe to run this command periodically (daily is enough) by automated cleanup job in parallel with other werf commands such as build, converge and host cleanup.`),
-		Example: `  $ werf cleanup --repo registry.mydomain.com/myproject/werf`,
+		Example: `  $ werf cleanup --repo registry.mydomain.com/myproject/werf
+
+  # Write the cleanup plan for review without deleting anything
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --plan-out plan.json
+
+  # Delete only the items of the reviewed plan which still qualify for deletion
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --apply-plan plan.json
+
+  # Keep the images used in the cluster which is not reachable from the cleanup job
+  $ werf cleanup allow-list export --to production.json  # inside the production cluster
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --allow-list-file production.json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
 
@@ -43,6 +61,11 @@ It is safe to run this command periodically (daily is enough) by automated clean
 			}
 			common.LogVersion()
 
+			if cmdData.PlanOut != "" && cmdData.ApplyPlan != "" {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--plan-out and --apply-plan options cannot be used together")
+			}
+
 			return common.LogRunningTime(runCleanup)
 		},
 	}
@@ -60,11 +83,14 @@ It is safe to run this command periodically (daily is enough) by automated clean
 
 	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupArchiveStagesStorage(&commonCmdData, cmd, "Copy the stages and the image metadata into the specified archive repo before deletion, the stages can be restored by werf stages restore command")
 	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and delete images from the specified repo")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupRegistryThrottling(&commonCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
 
 	common.SetupScanContextNamespaceOnly(&commonCmdData, cmd)
 	common.SetupDryRun(&commonCmdData, cmd)
@@ -77,8 +103,12 @@ It is safe to run this command periodically (daily is enough) by automated clean
 	common.SetupKubeConfigBase64(&commonCmdData, cmd)
 	common.SetupKubeContext(&commonCmdData, cmd)
 	common.SetupWithoutKube(&commonCmdData, cmd)
+	common.SetupAllowListFiles(&commonCmdData, cmd)
 	common.SetupKeepStagesBuiltWithinLastNHours(&commonCmdData, cmd)
 
+	cmd.Flags().StringVarP(&cmdData.PlanOut, "plan-out", "", os.Getenv("WERF_PLAN_OUT"), "Write the JSON plan of the stages, image metadata and import metadata records which would be deleted with the deletion reasons into the specified file without deleting anything (default $WERF_PLAN_OUT)")
+	cmd.Flags().StringVarP(&cmdData.ApplyPlan, "apply-plan", "", os.Getenv("WERF_APPLY_PLAN"), "Delete only the items of the reviewed plan created with --plan-out which still qualify for deletion (default $WERF_APPLY_PLAN)")
+
 	return cmd
 }
 
@@ -110,6 +140,20 @@ func runCleanup() error {
 		return err
 	}
 
+	allowListImages, err := allow_list.ReadFilesImages(*commonCmdData.AllowListFiles)
+	if err != nil {
+		return err
+	}
+
+	var approvedPlan *cleaning.CleanupPlan
+	if cmdData.ApplyPlan != "" {
+		plan, err := cleaning.ReadCleanupPlan(cmdData.ApplyPlan)
+		if err != nil {
+			return err
+		}
+		approvedPlan = plan
+	}
+
 	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
 		return err
 	}
@@ -191,6 +235,11 @@ func runCleanup() error {
 		return err
 	}
 
+	archiveStagesStorage, err := common.GetOptionalArchiveStagesStorage(containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
 	storageManager := manager.NewStorageManager(projectName, stagesStorage, secondaryStagesStorageList, storageLockManager, stagesStorageCache)
 
 	if stagesStorage.Address() != storage.LocalStorageAddress && *commonCmdData.Parallel {
@@ -203,6 +252,13 @@ func runCleanup() error {
 	}
 	logboek.Debug().LogF("Managed images names: %v\n", imagesNames)
 
+	imagesCleanupOptions := map[string]*config.ImageCleanup{}
+	for _, imageName := range imagesNames {
+		if imageCleanup := werfConfig.GetImageCleanup(imageName); imageCleanup != nil {
+			imagesCleanupOptions[imageName] = imageCleanup
+		}
+	}
+
 	kubernetesContextClients, err := common.GetKubernetesContextClients(&commonCmdData)
 	if err != nil {
 		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
@@ -214,9 +270,18 @@ func runCleanup() error {
 		KubernetesContextClients:                kubernetesContextClients,
 		KubernetesNamespaceRestrictionByContext: common.GetKubernetesNamespaceRestrictionByContext(&commonCmdData, kubernetesContextClients),
 		WithoutKube:                             *commonCmdData.WithoutKube,
+		AllowListImages:                         allowListImages,
 		GitHistoryBasedCleanupOptions:           werfConfig.Meta.Cleanup,
+		ImagesCleanupOptions:                    imagesCleanupOptions,
 		KeepStagesBuiltWithinLastNHours:         *commonCmdData.KeepStagesBuiltWithinLastNHours,
+		ArchiveStagesStorage:                    archiveStagesStorage,
+		ContainerRuntime:                        containerRuntime,
 		DryRun:                                  *commonCmdData.DryRun,
+		ApprovedPlan:                            approvedPlan,
+	}
+
+	if cmdData.PlanOut != "" {
+		cleanupOptions.Plan = cleaning.NewCleanupPlan(projectName, stagesStorage.String())
 	}
 
 	logboek.LogOptionalLn()
@@ -224,5 +289,14 @@ func runCleanup() error {
 		return err
 	}
 
+	if cleanupOptions.Plan != nil {
+		if err := cleanupOptions.Plan.Save(cmdData.PlanOut); err != nil {
+			return err
+		}
+
+		logboek.LogOptionalLn()
+		logboek.Default().LogF("Cleanup plan with %d items has been written into %s\n", len(cleanupOptions.Plan.Items), cmdData.PlanOut)
+	}
+
 	return nil
 }
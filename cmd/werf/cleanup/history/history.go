@@ -0,0 +1,250 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	WithinLastNHours uint64
+	Limit            int
+	Object           string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "history",
+		DisableFlagsInUseLine: true,
+		Short:                 "Show the objects deleted from the repo by cleanup, purge and managed-images rm commands",
+		Long: common.GetLongCommandDescription(`Show the objects deleted from the repo by cleanup, purge and managed-images rm commands.
+
+Each run of these commands, which has deleted anything, saves the audit record into the repo: who and when has run the command, which objects have been deleted and why. The records are shown starting from the latest one.`),
+		Example: `  # Show the records of the last day
+  $ werf cleanup history --repo registry.mydomain.com/myproject/werf --within-last-n-hours 24
+
+  # Find out when the metadata of the image backend has been deleted
+  $ werf cleanup history --repo registry.mydomain.com/myproject/werf --object "image backend" --limit 0`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismInspectorOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read images from the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupRegistryThrottling(&commonCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	cmd.Flags().Uint64VarP(&cmdData.WithinLastNHours, "within-last-n-hours", "", 0, "Show only the records saved within the last N hours (default all records)")
+	cmd.Flags().IntVarP(&cmdData.Limit, "limit", "", 10, "Show only the N latest records, 0 to show all records")
+	cmd.Flags().StringVarP(&cmdData.Object, "object", "", os.Getenv("WERF_OBJECT"), "Show only the deleted objects containing the specified substring, e.g. an image name, a stage ID or a commit (default $WERF_OBJECT)")
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.InitGiterminismInspector(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := git_repo.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	ids, err := getRecordIDs(ctx, stagesStorage, projectName)
+	if err != nil {
+		return err
+	}
+
+	// the records are fetched one by one starting from the latest one until the limit is reached
+	var shown int
+	for _, id := range ids {
+		if cmdData.Limit != 0 && shown == cmdData.Limit {
+			break
+		}
+
+		rec, err := stagesStorage.GetCleanupAuditRecord(ctx, projectName, id)
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping cleanup audit record %s: %s\n", id, err)
+			continue
+		} else if rec == nil {
+			continue
+		}
+
+		items := filterItems(rec.Items, cmdData.Object)
+		if len(items) == 0 {
+			continue
+		}
+
+		printRecord(ctx, rec, items)
+		shown++
+	}
+
+	if shown == 0 {
+		logboek.Context(ctx).Default().LogLn("No cleanup audit records found")
+	}
+
+	return nil
+}
+
+// getRecordIDs returns the IDs of the records matching the time filter starting from the latest one
+func getRecordIDs(ctx context.Context, stagesStorage storage.StagesStorage, projectName string) ([]string, error) {
+	ids, err := stagesStorage.GetCleanupAuditRecordIDs(ctx, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get cleanup audit records: %s", err)
+	}
+
+	createdAtByID := map[string]time.Time{}
+	var res []string
+	for _, id := range ids {
+		createdAt, err := storage.GetCleanupAuditRecordIDTime(id)
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping cleanup audit record %s: %s\n", id, err)
+			continue
+		}
+
+		if cmdData.WithinLastNHours != 0 && time.Since(createdAt) > time.Duration(cmdData.WithinLastNHours)*time.Hour {
+			continue
+		}
+
+		createdAtByID[id] = createdAt
+		res = append(res, id)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return createdAtByID[res[i]].After(createdAtByID[res[j]])
+	})
+
+	// without the object filter each record is shown, thus only the latest records are required
+	if cmdData.Object == "" && cmdData.Limit != 0 && len(res) > cmdData.Limit {
+		res = res[:cmdData.Limit]
+	}
+
+	return res, nil
+}
+
+func filterItems(items []*storage.CleanupAuditRecordItem, object string) []*storage.CleanupAuditRecordItem {
+	if object == "" {
+		return items
+	}
+
+	var res []*storage.CleanupAuditRecordItem
+	for _, item := range items {
+		if strings.Contains(item.Object, object) {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
+
+func printRecord(ctx context.Context, rec *storage.CleanupAuditRecord, items []*storage.CleanupAuditRecordItem) {
+	logboek.Context(ctx).Default().LogFHighlight("%s: %s by %s@%s (werf %s)\n", rec.CreatedAt.Format(time.RFC3339), rec.Command, rec.User, rec.Host, rec.WerfVersion)
+
+	tbl := table.New("Object", "Reason", "Details")
+	tbl.WithWriter(logboek.Context(ctx).ProxyOutStream())
+	tbl.WithHeaderFormatter(color.New(color.Underline).SprintfFunc())
+	for _, item := range items {
+		tbl.AddRow(item.Object, item.Reason, item.Details)
+	}
+	tbl.Print()
+
+	logboek.Context(ctx).LogOptionalLn()
+}
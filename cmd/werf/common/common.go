@@ -19,9 +19,11 @@ import (
 
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/buildkit"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/docker_registry/credentials"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_inspector"
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -65,6 +67,7 @@ type CmdData struct {
 	CommonRepoData         *RepoData
 	StagesStorage          *string
 	SecondaryStagesStorage *[]string
+	ArchiveStagesStorage   *string
 
 	SkipBuild *bool
 	StubTags  *bool
@@ -73,12 +76,21 @@ type CmdData struct {
 	Parallel           *bool
 	ParallelTasksLimit *int64
 
+	ContainerRuntime *string
+	BuildkitAddress  *string
+	RegistryOnly     *bool
+
 	DockerConfig                    *string
 	InsecureRegistry                *bool
 	SkipTlsVerifyRegistry           *bool
+	RegistryRateLimits              *[]string
+	RegistryMaxRetries              *int64
+	RegistryCredentialsFile         *string
 	DryRun                          *bool
 	KeepStagesBuiltWithinLastNHours *uint64
+	AllowedDiskUsagePercent         *uint64
 	WithoutKube                     *bool
+	AllowListFiles                  *[]string
 
 	LooseGiterminism *bool
 	Dev              *bool
@@ -104,6 +116,8 @@ type CmdData struct {
 	VirtualMergeFromCommit *string
 	VirtualMergeIntoCommit *string
 
+	BaseImageMirrors *[]string
+
 	ScanContextNamespaceOnly *bool
 
 	Tag *string
@@ -115,6 +129,9 @@ const (
 	StubTag                                = "TAG"
 	DefaultBuildParallelTasksLimit         = 5
 	DefaultCleanupParallelTasksLimit       = 10
+
+	DockerServerContainerRuntime = "docker-server"
+	BuildkitContainerRuntime     = "buildkit"
 )
 
 func GetLongCommandDescription(text string) string {
@@ -202,6 +219,20 @@ func SetupReportFormat(cmdData *CmdData, cmd *cobra.Command) {
 			"DockerTag": "<TAG>"
 			"DockerImageName": "<REPO>:<TAG>",
 			"DockerImageID": "<SHA256>",
+			"Stages": [
+				{
+					"Name": "<STAGE_NAME>",
+					"Digest": "<STAGE_DIGEST>",
+					"DockerImageName": "<REPO>:<STAGE_TAG>",
+					"Source": "primary|secondary|built",
+					"Size": <BYTES>,
+					"FetchSeconds": <SECONDS>,
+					"BuildSeconds": <SECONDS>,
+					"StoreSeconds": <SECONDS>,
+					"UploadedBytes": <BYTES>
+				},
+				...
+			]
 		},
 		...
 	  }
@@ -228,6 +259,14 @@ func SetupWithoutKube(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.WithoutKube, "without-kube", "", GetBoolEnvironmentDefaultFalse("WERF_WITHOUT_KUBE"), "Do not skip deployed Kubernetes images (default $WERF_WITHOUT_KUBE)")
 }
 
+func SetupAllowListFiles(cmdData *CmdData, cmd *cobra.Command) {
+	allowListFiles := predefinedValuesByEnvNamePrefix("WERF_ALLOW_LIST_FILE")
+
+	cmdData.AllowListFiles = &allowListFiles
+	cmd.Flags().StringArrayVarP(cmdData.AllowListFiles, "allow-list-file", "", allowListFiles, `Do not delete the images listed in the allow list file created by werf cleanup allow-list export command, e.g. for the clusters which are not reachable from the cleanup job (can specify multiple).
+Also, can be specified with $WERF_ALLOW_LIST_FILE* (e.g. $WERF_ALLOW_LIST_FILE_1=production.json, $WERF_ALLOW_LIST_FILE_2=staging.json)`)
+}
+
 func SetupKeepStagesBuiltWithinLastNHours(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.KeepStagesBuiltWithinLastNHours = new(uint64)
 
@@ -246,6 +285,22 @@ func SetupKeepStagesBuiltWithinLastNHours(cmdData *CmdData, cmd *cobra.Command)
 	cmd.Flags().Uint64VarP(cmdData.KeepStagesBuiltWithinLastNHours, "keep-stages-built-within-last-n-hours", "", defaultValue, "Keep stages that were built within last hours (default $WERF_KEEP_STAGES_BUILT_WITHIN_LAST_N_HOURS or 2)")
 }
 
+func SetupAllowedDiskUsagePercent(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.AllowedDiskUsagePercent = new(uint64)
+
+	envValue, err := getUint64EnvVar("WERF_ALLOWED_DISK_USAGE_PERCENT")
+	if err != nil {
+		TerminateWithError(err.Error(), 1)
+	}
+
+	var defaultValue uint64
+	if envValue != nil {
+		defaultValue = *envValue
+	}
+
+	cmd.Flags().Uint64VarP(cmdData.AllowedDiskUsagePercent, "allowed-disk-usage-percent", "", defaultValue, "Remove least recently used local stages and werf local cache until the usage of the docker storage volume and the werf local cache volume is under the specified percent, 0 to disable (default $WERF_ALLOWED_DISK_USAGE_PERCENT or 0)")
+}
+
 func predefinedValuesByEnvNamePrefix(envNamePrefix string, envNamePrefixesToExcept ...string) []string {
 	var result []string
 
@@ -328,12 +383,16 @@ func SetupCommonRepoData(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.CommonRepoData = &RepoData{IsCommon: true}
 
 	SetupImplementationForRepoData(cmdData.CommonRepoData, cmd, "repo-implementation", []string{"WERF_REPO_IMPLEMENTATION"})
+	SetupArtifactoryUsernameForRepoData(cmdData.CommonRepoData, cmd, "repo-artifactory-username", []string{"WERF_REPO_ARTIFACTORY_USERNAME"})
+	SetupArtifactoryPasswordForRepoData(cmdData.CommonRepoData, cmd, "repo-artifactory-password", []string{"WERF_REPO_ARTIFACTORY_PASSWORD"})
 	SetupDockerHubUsernameForRepoData(cmdData.CommonRepoData, cmd, "repo-docker-hub-username", []string{"WERF_REPO_DOCKER_HUB_USERNAME"})
 	SetupDockerHubPasswordForRepoData(cmdData.CommonRepoData, cmd, "repo-docker-hub-password", []string{"WERF_REPO_DOCKER_HUB_PASSWORD"})
 	SetupDockerHubTokenForRepoData(cmdData.CommonRepoData, cmd, "repo-docker-hub-token", []string{"WERF_REPO_DOCKER_HUB_TOKEN"})
 	SetupGithubTokenForRepoData(cmdData.CommonRepoData, cmd, "repo-github-token", []string{"WERF_REPO_GITHUB_TOKEN"})
 	SetupHarborUsernameForRepoData(cmdData.CommonRepoData, cmd, "repo-harbor-username", []string{"WERF_REPO_HARBOR_USERNAME"})
 	SetupHarborPasswordForRepoData(cmdData.CommonRepoData, cmd, "repo-harbor-password", []string{"WERF_REPO_HARBOR_PASSWORD"})
+	SetupNexusUsernameForRepoData(cmdData.CommonRepoData, cmd, "repo-nexus-username", []string{"WERF_REPO_NEXUS_USERNAME"})
+	SetupNexusPasswordForRepoData(cmdData.CommonRepoData, cmd, "repo-nexus-password", []string{"WERF_REPO_NEXUS_PASSWORD"})
 	SetupQuayTokenForRepoData(cmdData.CommonRepoData, cmd, "repo-quay-token", []string{"WERF_REPO_QUAY_TOKEN"})
 }
 
@@ -343,16 +402,23 @@ func SetupSecondaryStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().StringArrayVarP(cmdData.SecondaryStagesStorage, "secondary-repo", "", secondaryStagesStorage, "Specify one or multiple secondary read-only repo with images that will be used as a cache")
 }
 
+func SetupArchiveStagesStorage(cmdData *CmdData, cmd *cobra.Command, usage string) {
+	cmdData.ArchiveStagesStorage = new(string)
+	cmd.Flags().StringVarP(cmdData.ArchiveStagesStorage, "archive-repo", "", os.Getenv("WERF_ARCHIVE_REPO"), fmt.Sprintf("%s (default $WERF_ARCHIVE_REPO)", usage))
+}
+
 func SetupStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
 	SetupInsecureRegistry(cmdData, cmd)
 	SetupSkipTlsVerifyRegistry(cmdData, cmd)
+	SetupRegistryThrottling(cmdData, cmd)
+	SetupRegistryCredentialsFile(cmdData, cmd)
 	SetupCommonRepoData(cmdData, cmd)
 	setupStagesStorage(cmdData, cmd)
 }
 
 func setupStagesStorage(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.StagesStorage = new(string)
-	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo to store stages (default $WERF_REPO)"))
+	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo to store stages or %sPATH to store stages in the OCI image layout directory (default $WERF_REPO)", storage.OCILayoutStorageAddressPrefix))
 }
 
 func SetupStatusProgressPeriod(cmdData *CmdData, cmd *cobra.Command) {
@@ -455,6 +521,40 @@ func SetupSkipTlsVerifyRegistry(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.SkipTlsVerifyRegistry, "skip-tls-verify-registry", "", GetBoolEnvironmentDefaultFalse("WERF_SKIP_TLS_VERIFY_REGISTRY"), "Skip TLS certificate validation when accessing a registry (default $WERF_SKIP_TLS_VERIFY_REGISTRY)")
 }
 
+func SetupRegistryThrottling(cmdData *CmdData, cmd *cobra.Command) {
+	if cmdData.RegistryRateLimits != nil {
+		return
+	}
+
+	registryRateLimits := predefinedValuesByEnvNamePrefix("WERF_REGISTRY_RATE_LIMIT")
+	cmdData.RegistryRateLimits = &registryRateLimits
+	cmd.Flags().StringArrayVarP(cmdData.RegistryRateLimits, "registry-rate-limit", "", registryRateLimits, `Limit requests per second to each registry (REQUESTS_PER_SECOND) or to the specified registry (HOSTNAME=REQUESTS_PER_SECOND), can specify multiple.
+Also, can be specified with $WERF_REGISTRY_RATE_LIMIT* (e.g. $WERF_REGISTRY_RATE_LIMIT_DOCKERHUB="index.docker.io=2")`)
+
+	envValue, err := getInt64EnvVar("WERF_REGISTRY_MAX_RETRIES")
+	if err != nil {
+		TerminateWithError(err.Error(), 1)
+	}
+
+	defaultValue := int64(docker_registry.DefaultMaxRetries)
+	if envValue != nil {
+		defaultValue = *envValue
+	}
+
+	cmdData.RegistryMaxRetries = new(int64)
+	cmd.Flags().Int64VarP(cmdData.RegistryMaxRetries, "registry-max-retries", "", defaultValue, fmt.Sprintf("Retry registry requests throttled by the registry (429) or failed due to the registry temporary unavailability (502, 503, 504) with exponential backoff or the delay specified by Retry-After (default $WERF_REGISTRY_MAX_RETRIES or %d)", docker_registry.DefaultMaxRetries))
+}
+
+func SetupRegistryCredentialsFile(cmdData *CmdData, cmd *cobra.Command) {
+	if cmdData.RegistryCredentialsFile != nil {
+		return
+	}
+
+	cmdData.RegistryCredentialsFile = new(string)
+	cmd.Flags().StringVarP(cmdData.RegistryCredentialsFile, "registry-credentials-file", "", os.Getenv("WERF_REGISTRY_CREDENTIALS_FILE"), `Use the registry credentials file which maps registries to auth methods (basic, token, helper, ecr, gcr) for all registry implementations and docker login.
+The credentials specified with the implementation specific options take precedence, the docker config is used for the registries which are not defined in the file (default $WERF_REGISTRY_CREDENTIALS_FILE)`)
+}
+
 func SetupDryRun(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.DryRun = new(bool)
 	cmd.Flags().BoolVarP(cmdData.DryRun, "dry-run", "", GetBoolEnvironmentDefaultFalse("WERF_DRY_RUN"), "Indicate what the command would do without actually doing that (default $WERF_DRY_RUN)")
@@ -694,6 +794,36 @@ func SetupParallelTasksLimit(cmdData *CmdData, cmd *cobra.Command, defaultValue
 	cmd.Flags().Int64VarP(cmdData.ParallelTasksLimit, "parallel-tasks-limit", "", defaultValue, "Parallel tasks limit, set -1 to remove the limitation (default $WERF_PARALLEL_TASKS_LIMIT or 5)")
 }
 
+func SetupContainerRuntime(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.ContainerRuntime = new(string)
+	cmdData.BuildkitAddress = new(string)
+
+	defaultContainerRuntime := os.Getenv("WERF_CONTAINER_RUNTIME")
+	if defaultContainerRuntime == "" {
+		defaultContainerRuntime = DockerServerContainerRuntime
+	}
+
+	cmd.Flags().StringVarP(cmdData.ContainerRuntime, "container-runtime", "", defaultContainerRuntime, fmt.Sprintf(`Container runtime to build images: %[1]s or %[2]s (default $WERF_CONTAINER_RUNTIME or %[1]s).
+%[2]s runtime does not require docker server: images are built with buildctl (or $WERF_BUILDCTL_BIN_PATH, e.g. buildctl-daemonless.sh) and pushed directly into the repo, thus --repo is required.
+Stapel imports require docker server and are not supported by the %[2]s runtime`, DockerServerContainerRuntime, BuildkitContainerRuntime))
+	cmd.Flags().StringVarP(cmdData.BuildkitAddress, "buildkit-addr", "", os.Getenv("WERF_BUILDKIT_ADDR"), "Buildkit daemon address for the buildkit container runtime (default $WERF_BUILDKIT_ADDR or buildctl default)")
+}
+
+func GetContainerRuntime(ctx context.Context, cmdData *CmdData) (container_runtime.ContainerRuntime, error) {
+	switch *cmdData.ContainerRuntime {
+	case DockerServerContainerRuntime:
+		return &container_runtime.LocalDockerServerRuntime{}, nil
+	case BuildkitContainerRuntime:
+		if err := buildkit.Init(ctx, *cmdData.BuildkitAddress); err != nil {
+			return nil, err
+		}
+
+		return container_runtime.NewBuildkitRuntime(), nil
+	default:
+		return nil, fmt.Errorf("bad --container-runtime given %q, expected: \"%s\"", *cmdData.ContainerRuntime, strings.Join([]string{DockerServerContainerRuntime, BuildkitContainerRuntime}, "\", \""))
+	}
+}
+
 func SetupLogProjectDir(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.LogProjectDir = new(bool)
 	cmd.Flags().BoolVarP(cmdData.LogProjectDir, "log-project-dir", "", GetBoolEnvironmentDefaultFalse("WERF_LOG_PROJECT_DIR"), `Print current project directory path (default $WERF_LOG_PROJECT_DIR)`)
@@ -726,6 +856,11 @@ func SetupSkipBuild(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.SkipBuild, "skip-build", "Z", GetBoolEnvironmentDefaultFalse("WERF_SKIP_BUILD"), "Disable building of docker images, cached images in the repo should exist in the repo if werf.yaml contains at least one image description (default $WERF_SKIP_BUILD)")
 }
 
+func SetupRegistryOnly(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.RegistryOnly = new(bool)
+	cmd.Flags().BoolVarP(cmdData.RegistryOnly, "registry-only", "", GetBoolEnvironmentDefaultFalse("WERF_REGISTRY_ONLY"), "Use suitable stages from the secondary repos without copying them into the repo and without pulling them into the local docker server. Stages are fetched only when needed as the base of a stage to build, the last stage of the image is copied into the repo by the registry API. Stapel import sources are still pulled (default $WERF_REGISTRY_ONLY)")
+}
+
 func SetupStubTags(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.StubTags = new(bool)
 	cmd.Flags().BoolVarP(cmdData.StubTags, "stub-tags", "", GetBoolEnvironmentDefaultFalse("WERF_STUB_TAGS"), "Use stubs instead of real tags (default $WERF_STUB_TAGS)")
@@ -856,12 +991,16 @@ func GetStagesStorage(stagesStorageAddress string, containerRuntime container_ru
 				DockerRegistryOptions: docker_registry.DockerRegistryOptions{
 					InsecureRegistry:      *cmdData.InsecureRegistry,
 					SkipTlsVerifyRegistry: *cmdData.SkipTlsVerifyRegistry,
+					ArtifactoryUsername:   *cmdData.CommonRepoData.ArtifactoryUsername,
+					ArtifactoryPassword:   *cmdData.CommonRepoData.ArtifactoryPassword,
 					DockerHubUsername:     *cmdData.CommonRepoData.DockerHubUsername,
 					DockerHubPassword:     *cmdData.CommonRepoData.DockerHubPassword,
 					DockerHubToken:        *cmdData.CommonRepoData.DockerHubToken,
 					GitHubToken:           *cmdData.CommonRepoData.GitHubToken,
 					HarborUsername:        *cmdData.CommonRepoData.HarborUsername,
 					HarborPassword:        *cmdData.CommonRepoData.HarborPassword,
+					NexusUsername:         *cmdData.CommonRepoData.NexusUsername,
+					NexusPassword:         *cmdData.CommonRepoData.NexusPassword,
 					QuayToken:             *cmdData.CommonRepoData.QuayToken,
 				},
 			},
@@ -890,6 +1029,19 @@ func GetSecondaryStagesStorageList(stagesStorage storage.StagesStorage, containe
 	return res, nil
 }
 
+func GetOptionalArchiveStagesStorage(containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) (storage.StagesStorage, error) {
+	if *cmdData.ArchiveStagesStorage == "" {
+		return nil, nil
+	}
+
+	archiveStagesStorage, err := GetStagesStorage(*cmdData.ArchiveStagesStorage, containerRuntime, cmdData)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create archive stages storage at %s: %s", *cmdData.ArchiveStagesStorage, err)
+	}
+
+	return archiveStagesStorage, nil
+}
+
 func GetOptionalWerfConfig(ctx context.Context, cmdData *CmdData, giterminismManager giterminism_manager.Interface, opts config.WerfConfigOptions) (*config.WerfConfig, error) {
 	customWerfConfigRelPath, err := GetCustomWerfConfigRelPath(giterminismManager, cmdData)
 	if err != nil {
@@ -968,6 +1120,12 @@ func GetWerfConfigOptions(cmdData *CmdData, LogRenderedFilePath bool) config.Wer
 }
 
 func GetGiterminismManager(cmdData *CmdData) (giterminism_manager.Interface, error) {
+	return GetGiterminismManagerForCommit(cmdData, "")
+}
+
+// GetGiterminismManagerForCommit returns giterminism manager which uses the specified git revision
+// instead of the current HEAD commit (if revision is not empty).
+func GetGiterminismManagerForCommit(cmdData *CmdData, revision string) (giterminism_manager.Interface, error) {
 	gitWorkTree, err := GetGitWorkTree(cmdData)
 	if err != nil {
 		return nil, err
@@ -978,6 +1136,15 @@ func GetGiterminismManager(cmdData *CmdData) (giterminism_manager.Interface, err
 		return nil, err
 	}
 
+	if revision != "" {
+		commit, err := localGitRepo.ResolveCommit(BackgroundContext(), revision)
+		if err != nil {
+			return nil, err
+		}
+
+		localGitRepo = localGitRepo.WithHeadCommit(commit)
+	}
+
 	headCommit, err := localGitRepo.HeadCommit(BackgroundContext())
 	if err != nil {
 		return nil, err
@@ -1240,7 +1407,65 @@ func ProcessLogTerminalWidth(cmdData *CmdData) error {
 }
 
 func DockerRegistryInit(cmdData *CmdData) error {
-	return docker_registry.Init(BackgroundContext(), *cmdData.InsecureRegistry, *cmdData.SkipTlsVerifyRegistry)
+	if err := RegistryCredentialsInit(cmdData); err != nil {
+		return err
+	}
+
+	throttlingOptions, err := getRegistryThrottlingOptions(cmdData)
+	if err != nil {
+		return err
+	}
+
+	return docker_registry.Init(BackgroundContext(), *cmdData.InsecureRegistry, *cmdData.SkipTlsVerifyRegistry, throttlingOptions)
+}
+
+func RegistryCredentialsInit(cmdData *CmdData) error {
+	if cmdData.RegistryCredentialsFile == nil {
+		return nil
+	}
+
+	return credentials.Init(*cmdData.RegistryCredentialsFile)
+}
+
+func getRegistryThrottlingOptions(cmdData *CmdData) (docker_registry.ThrottlingOptions, error) {
+	options := docker_registry.ThrottlingOptions{
+		MaxRetries:                  docker_registry.DefaultMaxRetries,
+		RequestsPerSecondByRegistry: map[string]float64{},
+	}
+
+	if cmdData.RegistryMaxRetries != nil {
+		if *cmdData.RegistryMaxRetries < 0 {
+			return options, fmt.Errorf("bad --registry-max-retries value %d: expected non-negative number", *cmdData.RegistryMaxRetries)
+		}
+
+		options.MaxRetries = int(*cmdData.RegistryMaxRetries)
+	}
+
+	if cmdData.RegistryRateLimits == nil {
+		return options, nil
+	}
+
+	for _, value := range *cmdData.RegistryRateLimits {
+		var hostname, requestsPerSecondStr string
+		if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+			hostname, requestsPerSecondStr = parts[0], parts[1]
+		} else {
+			requestsPerSecondStr = parts[0]
+		}
+
+		requestsPerSecond, err := strconv.ParseFloat(requestsPerSecondStr, 64)
+		if err != nil || requestsPerSecond < 0 {
+			return options, fmt.Errorf("bad --registry-rate-limit value %q: expected REQUESTS_PER_SECOND or HOSTNAME=REQUESTS_PER_SECOND", value)
+		}
+
+		if hostname == "" {
+			options.RequestsPerSecond = requestsPerSecond
+		} else {
+			options.RequestsPerSecondByRegistry[hostname] = requestsPerSecond
+		}
+	}
+
+	return options, nil
 }
 
 func ValidateRepoImplementation(implementation string) error {
@@ -1316,6 +1541,27 @@ func SetupVirtualMergeIntoCommit(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().StringVarP(cmdData.VirtualMergeIntoCommit, "virtual-merge-into-commit", "", os.Getenv("WERF_VIRTUAL_MERGE_INTO_COMMIT"), "Commit hash for virtual/ephemeral merge commit which is base for changes introduced in the pull request ($WERF_VIRTUAL_MERGE_INTO_COMMIT by default)")
 }
 
+func SetupBaseImageMirrors(cmdData *CmdData, cmd *cobra.Command) {
+	baseImageMirrors := predefinedValuesByEnvNamePrefix("WERF_BASE_IMAGE_MIRROR")
+	cmdData.BaseImageMirrors = &baseImageMirrors
+	cmd.Flags().StringArrayVarP(cmdData.BaseImageMirrors, "base-image-mirror", "", baseImageMirrors, `Fetch base images of the registry or the repository from the mirror (SOURCE=MIRROR, e.g. docker.io=mirror.example.com/dockerhub), can specify multiple.
+Stage digests are calculated using the original base image names, thus the cache remains valid regardless of the mirror.
+Also, can be specified with $WERF_BASE_IMAGE_MIRROR* (e.g. $WERF_BASE_IMAGE_MIRROR_DOCKERHUB="docker.io=mirror.example.com/dockerhub")`)
+}
+
+func GetBaseImageMirrors(cmdData *CmdData) (*docker_registry.BaseImageMirrors, error) {
+	if cmdData.BaseImageMirrors == nil {
+		return nil, nil
+	}
+
+	baseImageMirrors, err := docker_registry.ParseBaseImageMirrors(*cmdData.BaseImageMirrors)
+	if err != nil {
+		return nil, fmt.Errorf("bad --base-image-mirror: %s", err)
+	}
+
+	return baseImageMirrors, nil
+}
+
 func OpenLocalGitRepo(workTreeDir string) (git_repo.Local, error) {
 	return git_repo.OpenLocalRepo("own", workTreeDir, git_repo.OpenLocalRepoOptions{Dev: giterminism_inspector.DevMode})
 }
@@ -9,18 +9,28 @@ import (
 	"github.com/werf/werf/pkg/container_runtime"
 )
 
-func GetConveyorOptions(commonCmdData *CmdData) build.ConveyorOptions {
+func GetConveyorOptions(commonCmdData *CmdData) (build.ConveyorOptions, error) {
+	baseImageMirrors, err := GetBaseImageMirrors(commonCmdData)
+	if err != nil {
+		return build.ConveyorOptions{}, err
+	}
+
 	return build.ConveyorOptions{
 		LocalGitRepoVirtualMergeOptions: stage.VirtualMergeOptions{
 			VirtualMerge:           *commonCmdData.VirtualMerge,
 			VirtualMergeFromCommit: *commonCmdData.VirtualMergeFromCommit,
 			VirtualMergeIntoCommit: *commonCmdData.VirtualMergeIntoCommit,
 		},
-	}
+		BaseImageMirrors: baseImageMirrors,
+	}, nil
 }
 
 func GetConveyorOptionsWithParallel(commonCmdData *CmdData, buildStagesOptions build.BuildOptions) (build.ConveyorOptions, error) {
-	conveyorOptions := GetConveyorOptions(commonCmdData)
+	conveyorOptions, err := GetConveyorOptions(commonCmdData)
+	if err != nil {
+		return conveyorOptions, err
+	}
+
 	conveyorOptions.Parallel = !(buildStagesOptions.ImageBuildOptions.IntrospectAfterError || buildStagesOptions.ImageBuildOptions.IntrospectBeforeError || len(buildStagesOptions.Targets) != 0) && *commonCmdData.Parallel
 
 	parallelTasksLimit, err := GetParallelTasksLimit(commonCmdData)
@@ -52,6 +62,7 @@ func GetBuildOptions(commonCmdData *CmdData, werfConfig *config.WerfConfig) (bui
 		IntrospectOptions: introspectOptions,
 		ReportPath:        *commonCmdData.ReportPath,
 		ReportFormat:      reportFormat,
+		RegistryOnly:      *commonCmdData.RegistryOnly,
 	}
 
 	return buildOptions, nil
@@ -206,15 +206,7 @@ cli: &cli
 
 func genCliSidebar(cmd *cobra.Command, indent int, buf *bytes.Buffer) error {
 	if len(cmd.Commands()) == 0 {
-		fullCommandName := fullCommandFilesystemPath(cmd.CommandPath())
-
-		commandRecord := fmt.Sprintf(`
-%[1]s- title: %[2]s
-%[1]s  url: /documentation/reference/cli/%[3]s.html
-`, strings.Repeat("  ", indent), cmd.CommandPath(), fullCommandName)
-
-		_, err := buf.WriteString(commandRecord)
-		if err != nil {
+		if err := genCliSidebarCommandRecord(cmd, indent, buf); err != nil {
 			return err
 		}
 	} else {
@@ -229,6 +221,14 @@ func genCliSidebar(cmd *cobra.Command, indent int, buf *bytes.Buffer) error {
 		}
 
 		indent += 1
+
+		// the runnable command with subcommands is listed along with its subcommands
+		if cmd.Runnable() {
+			if err := genCliSidebarCommandRecord(cmd, indent, buf); err != nil {
+				return err
+			}
+		}
+
 		for _, command := range cmd.Commands() {
 			if cmd.Hidden {
 				continue
@@ -243,6 +243,18 @@ func genCliSidebar(cmd *cobra.Command, indent int, buf *bytes.Buffer) error {
 	return nil
 }
 
+func genCliSidebarCommandRecord(cmd *cobra.Command, indent int, buf *bytes.Buffer) error {
+	fullCommandName := fullCommandFilesystemPath(cmd.CommandPath())
+
+	commandRecord := fmt.Sprintf(`
+%[1]s- title: %[2]s
+%[1]s  url: /documentation/reference/cli/%[3]s.html
+`, strings.Repeat("  ", indent), cmd.CommandPath(), fullCommandName)
+
+	_, err := buf.WriteString(commandRecord)
+	return err
+}
+
 func GenCliOverview(cmdGroups templates.CommandGroups, pagesDir string) error {
 	indexPage := `---
 title: Overview of command groups
@@ -267,7 +279,7 @@ toc: false
 			}
 
 			var fullCommandName string
-			if len(cmd.Commands()) == 0 {
+			if len(cmd.Commands()) == 0 || cmd.Runnable() {
 				fullCommandName = fullCommandFilesystemPath(cmd.CommandPath())
 			} else {
 				fullCommandName = fullCommandFilesystemPath(cmd.Commands()[0].CommandPath())
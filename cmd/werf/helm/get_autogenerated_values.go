@@ -10,7 +10,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
@@ -61,6 +60,7 @@ These values includes project name, docker images ids and other`),
 	common.SetupSecondaryStagesStorageOptions(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupStagesStorageOptions(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupSynchronization(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupContainerRuntime(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupKubeConfig(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupKubeConfigBase64(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupKubeContext(&getAutogeneratedValuedCmdData, cmd)
@@ -68,12 +68,15 @@ These values includes project name, docker images ids and other`),
 	common.SetupVirtualMerge(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupVirtualMergeFromCommit(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupVirtualMergeIntoCommit(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupBaseImageMirrors(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupNamespace(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupDockerConfig(&getAutogeneratedValuedCmdData, cmd, "Command needs granted permissions to read and pull images from the specified repo")
 	common.SetupInsecureRegistry(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupRegistryThrottling(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupStubTags(&getAutogeneratedValuedCmdData, cmd)
 
@@ -165,7 +168,10 @@ func runGetServiceValues() error {
 		if err != nil {
 			return fmt.Errorf("%s (use --stub-tags option to get service values without real tags)", err)
 		}
-		containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+		containerRuntime, err := common.GetContainerRuntime(ctx, &getAutogeneratedValuedCmdData)
+		if err != nil {
+			return err
+		}
 		stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &getAutogeneratedValuedCmdData)
 		if err != nil {
 			return err
@@ -189,7 +195,12 @@ func runGetServiceValues() error {
 
 		storageManager := manager.NewStorageManager(projectName, stagesStorage, secondaryStagesStorageList, storageLockManager, stagesStorageCache)
 
-		conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, *giterminismManager.LocalGitRepo(), []string{}, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, common.GetConveyorOptions(&getAutogeneratedValuedCmdData))
+		conveyorOptions, err := common.GetConveyorOptions(&getAutogeneratedValuedCmdData)
+		if err != nil {
+			return err
+		}
+
+		conveyorWithRetry := build.NewConveyorWithRetryWrapper(werfConfig, giterminismManager, *giterminismManager.LocalGitRepo(), []string{}, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, conveyorOptions)
 		defer conveyorWithRetry.Terminate()
 
 		if err := conveyorWithRetry.WithRetryBlock(ctx, func(c *build.Conveyor) error {
@@ -32,6 +32,13 @@ The data include:
   * Remote git clones cache.
   * Git worktree cache.
 
+With --allowed-disk-usage-percent option werf also measures the usage of the docker storage volume and the werf local cache volume and, until the usage is under the threshold, removes least recently used:
+* Local stages.
+* Git archives and patches cache.
+* Images manifests cache.
+
+Stages, caches and containers locked by running werf processes are skipped.
+
 It is safe to run this command periodically by automated cleanup job in parallel with other werf commands such as build, converge and cleanup.`),
 		DisableFlagsInUseLine: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -56,6 +63,7 @@ It is safe to run this command periodically by automated cleanup job in parallel
 	common.SetupLogOptions(&commonCmdData, cmd)
 
 	common.SetupDryRun(&commonCmdData, cmd)
+	common.SetupAllowedDiskUsagePercent(&commonCmdData, cmd)
 
 	return cmd
 }
@@ -93,8 +101,15 @@ func runGC() error {
 	}
 	ctx = ctxWithDockerCli
 
+	if *commonCmdData.AllowedDiskUsagePercent > 100 {
+		return fmt.Errorf("bad --allowed-disk-usage-percent value %d: expected value from 0 to 100", *commonCmdData.AllowedDiskUsagePercent)
+	}
+
 	logboek.LogOptionalLn()
-	hostCleanupOptions := host_cleaning.HostCleanupOptions{DryRun: *commonCmdData.DryRun}
+	hostCleanupOptions := host_cleaning.HostCleanupOptions{
+		DryRun:                  *commonCmdData.DryRun,
+		AllowedDiskUsagePercent: float64(*commonCmdData.AllowedDiskUsagePercent),
+	}
 	if err := host_cleaning.HostCleanup(ctx, hostCleanupOptions); err != nil {
 		return err
 	}
@@ -23,6 +23,9 @@ import (
 	"github.com/werf/werf/cmd/werf/slugify"
 	"github.com/werf/werf/cmd/werf/synchronization"
 
+	cleanup_allow_list_export "github.com/werf/werf/cmd/werf/cleanup/allow_list/export"
+	cleanup_history "github.com/werf/werf/cmd/werf/cleanup/history"
+
 	managed_images_add "github.com/werf/werf/cmd/werf/managed_images/add"
 	managed_images_ls "github.com/werf/werf/cmd/werf/managed_images/ls"
 	managed_images_rm "github.com/werf/werf/cmd/werf/managed_images/rm"
@@ -45,7 +48,12 @@ import (
 	"github.com/werf/werf/cmd/werf/docs"
 	"github.com/werf/werf/cmd/werf/version"
 
+	stage_explain "github.com/werf/werf/cmd/werf/stage/explain"
 	stage_image "github.com/werf/werf/cmd/werf/stage/image"
+	stages_export "github.com/werf/werf/cmd/werf/stages/export"
+	stages_import "github.com/werf/werf/cmd/werf/stages/import"
+	stages_migrate_metadata "github.com/werf/werf/cmd/werf/stages/migrate_metadata"
+	stages_restore "github.com/werf/werf/cmd/werf/stages/restore"
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/cmd/werf/common/templates"
@@ -96,7 +104,7 @@ Find more information at https://werf.io`),
 		{
 			Message: "Cleaning commands",
 			Commands: []*cobra.Command{
-				cleanup.NewCmd(),
+				cleanupCmd(),
 				purge.NewCmd(),
 			},
 		},
@@ -116,6 +124,7 @@ Find more information at https://werf.io`),
 			Commands: []*cobra.Command{
 				configCmd(),
 				managedImagesCmd(),
+				stagesCmd(),
 				hostCmd(),
 				helm.NewCmd(),
 			},
@@ -138,6 +147,26 @@ Find more information at https://werf.io`),
 	return rootCmd
 }
 
+func cleanupCmd() *cobra.Command {
+	cmd := cleanup.NewCmd()
+
+	allowListCmd := &cobra.Command{
+		Use:   "allow-list",
+		Short: "Work with the allow list of the images used in Kubernetes",
+	}
+
+	allowListCmd.AddCommand(
+		cleanup_allow_list_export.NewCmd(),
+	)
+
+	cmd.AddCommand(
+		allowListCmd,
+		cleanup_history.NewCmd(),
+	)
+
+	return cmd
+}
+
 func dockerComposeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "compose",
@@ -195,6 +224,21 @@ func managedImagesCmd() *cobra.Command {
 	return cmd
 }
 
+func stagesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stages",
+		Short: "Work with project stages stored in the repo",
+	}
+	cmd.AddCommand(
+		stages_export.NewCmd(),
+		stages_import.NewCmd(),
+		stages_restore.NewCmd(),
+		stages_migrate_metadata.NewCmd(),
+	)
+
+	return cmd
+}
+
 func stageCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:    "stage",
@@ -202,6 +246,7 @@ func stageCmd() *cobra.Command {
 	}
 	cmd.AddCommand(
 		stage_image.NewCmd(),
+		stage_explain.NewCmd(),
 	)
 
 	return cmd
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/cleaning"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
@@ -55,6 +56,8 @@ func NewCmd() *cobra.Command {
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and write images to the specified repo")
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupRegistryThrottling(&commonCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
 
 	common.SetupLogOptions(&commonCmdData, cmd)
 	common.SetupLogProjectDir(&commonCmdData, cmd)
@@ -157,11 +160,21 @@ func run(imageNames []string) error {
 	_ = storageLockManager
 	_ = secondaryStagesStorageList
 
+	auditLog := cleaning.NewAuditLog("werf managed-images rm", cleaning.CleanupPlanReasonManualRemoval)
+
 	errs := []error{}
 	for _, imageName := range imageNames {
-		if err := stagesStorage.RmManagedImage(ctx, projectName, common.GetManagedImageName(imageName)); err != nil {
+		managedImageName := common.GetManagedImageName(imageName)
+		if err := stagesStorage.RmManagedImage(ctx, projectName, managedImageName); err != nil {
 			errs = append(errs, fmt.Errorf("unable to remove known config image name %q of project %q: %s", imageName, projectName, err))
+			continue
 		}
+
+		auditLog.Deleted(&cleaning.CleanupPlanItem{Kind: cleaning.CleanupPlanItemManagedImage, ImageName: managedImageName})
+	}
+
+	if err := auditLog.Save(ctx, projectName, stagesStorage); err != nil {
+		errs = append(errs, err)
 	}
 
 	if len(errs) > 0 {
@@ -0,0 +1,297 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/level"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	FromCommit string
+	ToCommit   string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "explain [options] IMAGE_NAME STAGE_NAME",
+		Short:                 "Explain why the stage digest differs between two commits",
+		DisableFlagsInUseLine: true,
+		Long: common.GetLongCommandDescription(`Explain why the stage digest differs between two commits.
+
+The command calculates digests of the image stages at both commits without building missing stages and prints which inputs of the stages digests have been changed: builder checksums, git mappings stageDependencies checksums, Dockerfile instructions dependencies, imports checksums, base images ids, etc.`),
+		Annotations: map[string]string{
+			common.DisableOptionsInUseLineAnno: "1",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logboek.SetAcceptedLevel(level.Error)
+
+			if err := common.ValidateArgumentCount(2, args, cmd); err != nil {
+				return err
+			}
+
+			if cmdData.FromCommit == "" || cmdData.ToCommit == "" {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--from and --to params required")
+			}
+
+			return run(args[0], args[1])
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismInspectorOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupSecondaryStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+	common.SetupRegistryThrottling(&commonCmdData, cmd)
+	common.SetupRegistryCredentialsFile(&commonCmdData, cmd)
+
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.FromCommit, "from", "", os.Getenv("WERF_FROM"), "Git commit (or branch, tag) to compare stages digests from (default $WERF_FROM)")
+	cmd.Flags().StringVarP(&cmdData.ToCommit, "to", "", os.Getenv("WERF_TO"), "Git commit (or branch, tag) to compare stages digests to (default $WERF_TO)")
+
+	return cmd
+}
+
+func run(imageName, stageName string) error {
+	ctx := common.BackgroundContext()
+
+	if !isStageNameValid(stageName) {
+		return fmt.Errorf("unknown stage %q", stageName)
+	}
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.InitGiterminismInspector(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := git_repo.Init(); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	if err := ssh_agent.Init(ctx, *commonCmdData.SSHKeys); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+	defer func() {
+		err := ssh_agent.Terminate()
+		if err != nil {
+			logboek.Warn().LogF("WARNING: ssh agent termination failed: %s\n", err)
+		}
+	}()
+
+	fromGiterminismManager, err := common.GetGiterminismManagerForCommit(&commonCmdData, cmdData.FromCommit)
+	if err != nil {
+		return err
+	}
+
+	toGiterminismManager, err := common.GetGiterminismManagerForCommit(&commonCmdData, cmdData.ToCommit)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, toGiterminismManager.ProjectDir())
+
+	fromWerfConfig, err := getWerfConfig(ctx, fromGiterminismManager, imageName, cmdData.FromCommit)
+	if err != nil {
+		return err
+	}
+
+	toWerfConfig, err := getWerfConfig(ctx, toGiterminismManager, imageName, cmdData.ToCommit)
+	if err != nil {
+		return err
+	}
+
+	projectName := toWerfConfig.Meta.Project
+
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+
+	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+	if err != nil {
+		return err
+	}
+	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+	secondaryStagesStorageList, err := common.GetSecondaryStagesStorageList(stagesStorage, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	storageManager := manager.NewStorageManager(projectName, stagesStorage, secondaryStagesStorageList, storageLockManager, stagesStorageCache)
+
+	explainStages := func(werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface) ([]*build.StageExplanation, error) {
+		c := build.NewConveyor(werfConfig, giterminismManager, *giterminismManager.LocalGitRepo(), []string{imageName}, giterminismManager.ProjectDir(), projectTmpDir, ssh_agent.SSHAuthSock, containerRuntime, storageManager, storageLockManager, build.ConveyorOptions{})
+		defer c.Terminate(ctx)
+
+		return c.ExplainStages(ctx, imageName)
+	}
+
+	fromStagesExplanations, err := explainStages(fromWerfConfig, fromGiterminismManager)
+	if err != nil {
+		return fmt.Errorf("unable to explain stages at %s: %s", cmdData.FromCommit, err)
+	}
+
+	toStagesExplanations, err := explainStages(toWerfConfig, toGiterminismManager)
+	if err != nil {
+		return fmt.Errorf("unable to explain stages at %s: %s", cmdData.ToCommit, err)
+	}
+
+	return printStagesExplanationsDiff(build.DiffStagesExplanations(fromStagesExplanations, toStagesExplanations), stageName)
+}
+
+func getWerfConfig(ctx context.Context, giterminismManager giterminism_manager.Interface, imageName, commit string) (*config.WerfConfig, error) {
+	werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load werf config at %s: %s", commit, err)
+	}
+
+	if !werfConfig.HasImage(imageName) {
+		return nil, fmt.Errorf("image %q is not defined in werf.yaml at %s", logging.ImageLogName(imageName, false), commit)
+	}
+
+	return werfConfig, nil
+}
+
+func isStageNameValid(stageName string) bool {
+	for _, name := range stage.AllStages {
+		if string(name) == stageName {
+			return true
+		}
+	}
+
+	return stage.IsDockerfileStageName(stageName)
+}
+
+func printStagesExplanationsDiff(stagesDiffs []*build.StageExplanationDiff, stageName string) error {
+	for _, diff := range stagesDiffs {
+		switch {
+		case diff.FromUnavailableReason != "" || diff.ToUnavailableReason != "":
+			fmt.Printf("Stage %s: cannot be explained\n", diff.Name)
+			printUnavailableReason("from", diff.FromUnavailableReason)
+			printUnavailableReason("to", diff.ToUnavailableReason)
+		case diff.FromDigest == "":
+			fmt.Printf("Stage %s: added\n", diff.Name)
+		case diff.ToDigest == "":
+			fmt.Printf("Stage %s: removed\n", diff.Name)
+		case diff.IsDigestChanged():
+			fmt.Printf("Stage %s: digest changed\n", diff.Name)
+		default:
+			fmt.Printf("Stage %s: digest not changed\n", diff.Name)
+		}
+
+		if diff.FromDigest != "" {
+			fmt.Printf("  from: %s\n", diff.FromDigest)
+		}
+		if diff.ToDigest != "" {
+			fmt.Printf("  to:   %s\n", diff.ToDigest)
+		}
+
+		for _, inputDiff := range diff.Inputs {
+			switch inputDiff.Type {
+			case build.DependenciesInputChanged:
+				fmt.Printf("  ~ %s:\n      - %q\n      + %q\n", inputDiff.Name, inputDiff.FromValue, inputDiff.ToValue)
+			case build.DependenciesInputAdded:
+				fmt.Printf("  + %s: %q\n", inputDiff.Name, inputDiff.ToValue)
+			case build.DependenciesInputRemoved:
+				fmt.Printf("  - %s: %q\n", inputDiff.Name, inputDiff.FromValue)
+			}
+		}
+
+		if diff.Name == stageName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("stage %s not found at both commits", stageName)
+}
+
+func printUnavailableReason(commitType, reason string) {
+	if reason != "" {
+		fmt.Printf("  %s: %s\n", commitType, reason)
+	}
+}
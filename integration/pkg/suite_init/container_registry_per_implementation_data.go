@@ -21,11 +21,17 @@ func (data *ContainerRegistryPerImplementationData) ActivateImplementationWerfEn
 	stubsData.Stubs.SetEnv("WERF_REPO_IMPLEMENTATION", implData.ImplementationName)
 
 	switch implementationName {
+	case docker_registry.ArtifactoryImplementationName:
+		stubsData.Stubs.SetEnv("WERF_REPO_ARTIFACTORY_USERNAME", implData.RegistryOptions.ArtifactoryUsername)
+		stubsData.Stubs.SetEnv("WERF_REPO_ARTIFACTORY_PASSWORD", implData.RegistryOptions.ArtifactoryPassword)
 	case docker_registry.DockerHubImplementationName:
 		stubsData.Stubs.SetEnv("WERF_REPO_DOCKER_HUB_USERNAME", implData.RegistryOptions.DockerHubUsername)
 		stubsData.Stubs.SetEnv("WERF_REPO_DOCKER_HUB_PASSWORD", implData.RegistryOptions.DockerHubPassword)
 	case docker_registry.GitHubPackagesImplementationName:
 		stubsData.Stubs.SetEnv("WERF_REPO_GITHUB_TOKEN", implData.RegistryOptions.GitHubToken)
+	case docker_registry.NexusImplementationName:
+		stubsData.Stubs.SetEnv("WERF_REPO_NEXUS_USERNAME", implData.RegistryOptions.NexusUsername)
+		stubsData.Stubs.SetEnv("WERF_REPO_NEXUS_PASSWORD", implData.RegistryOptions.NexusPassword)
 	}
 
 	return true
@@ -94,6 +100,16 @@ func ContainerRegistryImplementationDockerRegistryOptionsAndSetEnvs(implementati
 	)
 
 	switch implementationName {
+	case docker_registry.ArtifactoryImplementationName:
+		username := utils.GetRequiredEnv(usernameEnvName)
+		password := utils.GetRequiredEnv(passwordEnvName)
+
+		return docker_registry.DockerRegistryOptions{
+			InsecureRegistry:      false,
+			SkipTlsVerifyRegistry: false,
+			ArtifactoryUsername:   username,
+			ArtifactoryPassword:   password,
+		}
 	case docker_registry.DockerHubImplementationName:
 		username := utils.GetRequiredEnv(usernameEnvName)
 		password := utils.GetRequiredEnv(passwordEnvName)
@@ -122,6 +138,16 @@ func ContainerRegistryImplementationDockerRegistryOptionsAndSetEnvs(implementati
 			HarborUsername:        username,
 			HarborPassword:        password,
 		}
+	case docker_registry.NexusImplementationName:
+		username := utils.GetRequiredEnv(usernameEnvName)
+		password := utils.GetRequiredEnv(passwordEnvName)
+
+		return docker_registry.DockerRegistryOptions{
+			InsecureRegistry:      false,
+			SkipTlsVerifyRegistry: false,
+			NexusUsername:         username,
+			NexusPassword:         password,
+		}
 	case docker_registry.QuayImplementationName:
 		tokenEnvName := fmt.Sprintf(
 			"WERF_TEST_%s_TOKEN",
@@ -24,6 +24,11 @@ import (
 // WERF_TEST_DOCKER_REGISTRY_IMPLEMENTATION_<implementation name>
 // WERF_TEST_<implementation name>_REGISTRY
 //
+// export WERF_TEST_DOCKER_REGISTRY_IMPLEMENTATION_ARTIFACTORY
+// export WERF_TEST_ARTIFACTORY_REGISTRY
+// export WERF_TEST_ARTIFACTORY_USERNAME
+// export WERF_TEST_ARTIFACTORY_PASSWORD
+//
 // export WERF_TEST_DOCKER_REGISTRY_IMPLEMENTATION_ECR
 // export WERF_TEST_ECR_REGISTRY
 //
@@ -41,6 +46,11 @@ import (
 // export WERF_TEST_HARBOR_USERNAME
 // export WERF_TEST_HARBOR_PASSWORD
 //
+// export WERF_TEST_DOCKER_REGISTRY_IMPLEMENTATION_NEXUS
+// export WERF_TEST_NEXUS_REGISTRY
+// export WERF_TEST_NEXUS_USERNAME
+// export WERF_TEST_NEXUS_PASSWORD
+//
 // export WERF_TEST_DOCKER_REGISTRY_IMPLEMENTATION_QUAY
 // export WERF_TEST_QUAY_REGISTRY
 // export WERF_TEST_QUAY_TOKEN
@@ -253,6 +263,19 @@ func implementationDockerRegistryOptionsAndSetEnvs(implementationName string) do
 	)
 
 	switch implementationName {
+	case docker_registry.ArtifactoryImplementationName:
+		username := utils.GetRequiredEnv(usernameEnvName)
+		password := utils.GetRequiredEnv(passwordEnvName)
+
+		SuiteData.Stubs.SetEnv("WERF_REPO_ARTIFACTORY_USERNAME", username)
+		SuiteData.Stubs.SetEnv("WERF_REPO_ARTIFACTORY_PASSWORD", password)
+
+		return docker_registry.DockerRegistryOptions{
+			InsecureRegistry:      false,
+			SkipTlsVerifyRegistry: false,
+			ArtifactoryUsername:   username,
+			ArtifactoryPassword:   password,
+		}
 	case docker_registry.DockerHubImplementationName:
 		username := utils.GetRequiredEnv(usernameEnvName)
 		password := utils.GetRequiredEnv(passwordEnvName)
@@ -286,6 +309,19 @@ func implementationDockerRegistryOptionsAndSetEnvs(implementationName string) do
 			HarborUsername:        username,
 			HarborPassword:        password,
 		}
+	case docker_registry.NexusImplementationName:
+		username := utils.GetRequiredEnv(usernameEnvName)
+		password := utils.GetRequiredEnv(passwordEnvName)
+
+		SuiteData.Stubs.SetEnv("WERF_REPO_NEXUS_USERNAME", username)
+		SuiteData.Stubs.SetEnv("WERF_REPO_NEXUS_PASSWORD", password)
+
+		return docker_registry.DockerRegistryOptions{
+			InsecureRegistry:      false,
+			SkipTlsVerifyRegistry: false,
+			NexusUsername:         username,
+			NexusPassword:         password,
+		}
 	case docker_registry.QuayImplementationName:
 		tokenEnvName := fmt.Sprintf(
 			"WERF_TEST_%s_TOKEN",
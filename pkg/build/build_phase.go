@@ -24,7 +24,6 @@ import (
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
 	imagePkg "github.com/werf/werf/pkg/image"
-	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
@@ -42,6 +41,11 @@ type BuildOptions struct {
 	ReportPath   string
 	ReportFormat ReportFormat
 
+	// RegistryOnly uses suitable stages of the secondary repos as is without copying them into the primary repo,
+	// the stages are fetched only when needed to build a new stage and the last stage of the image
+	// is copied into the primary repo by the registry API without the local docker server
+	RegistryOnly bool
+
 	DryRun bool
 }
 
@@ -68,7 +72,7 @@ func NewBuildPhase(c *Conveyor, opts BuildPhaseOptions) *BuildPhase {
 	return &BuildPhase{
 		BasePhase:         BasePhase{c},
 		BuildPhaseOptions: opts,
-		ImagesReport:      &ImagesReport{Images: make(map[string]ReportImageRecord)},
+		ImagesReport:      &ImagesReport{Images: make(map[string]ReportImageRecord), stagesRecords: make(map[string][]ReportStageRecord)},
 	}
 }
 
@@ -79,6 +83,9 @@ type BuildPhase struct {
 	StagesIterator              *StagesIterator
 	ShouldAddManagedImageRecord bool
 
+	// StageReportRecord collects report data of the currently processed stage
+	StageReportRecord *ReportStageRecord
+
 	ImagesReport *ImagesReport
 }
 
@@ -92,6 +99,8 @@ type ReportFormat string
 type ImagesReport struct {
 	mux    sync.Mutex
 	Images map[string]ReportImageRecord
+
+	stagesRecords map[string][]ReportStageRecord
 }
 
 func (report *ImagesReport) SetImageRecord(name string, imageRecord ReportImageRecord) {
@@ -100,6 +109,28 @@ func (report *ImagesReport) SetImageRecord(name string, imageRecord ReportImageR
 	report.Images[name] = imageRecord
 }
 
+func (report *ImagesReport) AddStageRecord(imageName string, stageRecord ReportStageRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+	report.stagesRecords[imageName] = append(report.stagesRecords[imageName], stageRecord)
+}
+
+// UpdateLastStageRecord changes the record of the last processed stage of the image
+func (report *ImagesReport) UpdateLastStageRecord(imageName string, update func(stageRecord *ReportStageRecord)) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	if records := report.stagesRecords[imageName]; len(records) > 0 {
+		update(&records[len(records)-1])
+	}
+}
+
+func (report *ImagesReport) GetStagesRecords(imageName string) []ReportStageRecord {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+	return report.stagesRecords[imageName]
+}
+
 func (report *ImagesReport) ToJsonData() ([]byte, error) {
 	report.mux.Lock()
 	defer report.mux.Unlock()
@@ -148,6 +179,35 @@ type ReportImageRecord struct {
 	DockerTag       string
 	DockerImageID   string
 	DockerImageName string
+	Stages          []ReportStageRecord `json:",omitempty"`
+}
+
+const (
+	ReportStageSourcePrimary   ReportStageSource = "primary"
+	ReportStageSourceSecondary ReportStageSource = "secondary"
+	ReportStageSourceBuilt     ReportStageSource = "built"
+)
+
+// ReportStageSource is the origin of the stage used by the image:
+// the primary stages storage, one of the secondary stages storages or a newly built stage
+type ReportStageSource string
+
+type ReportStageRecord struct {
+	Name            string
+	Digest          string
+	DockerImageName string
+	Source          ReportStageSource
+	Size            int64
+
+	// FetchSeconds is the time spent fetching the base image of the built stage
+	// or copying the stage from the secondary stages storage
+	FetchSeconds float64
+	BuildSeconds float64
+	StoreSeconds float64
+
+	// UploadedBytes is the size of the layers actually uploaded while storing the built or copied stage,
+	// layers already existing in the repo or mounted from the secondary stages storages are not counted
+	UploadedBytes int64
 }
 
 func (phase *BuildPhase) Name() string {
@@ -155,6 +215,12 @@ func (phase *BuildPhase) Name() string {
 }
 
 func (phase *BuildPhase) BeforeImages(_ context.Context) error {
+	if phase.RegistryOnly {
+		if _, ok := phase.Conveyor.StorageManager.StagesStorage.(*storage.RepoStagesStorage); !ok {
+			return fmt.Errorf("registry-only mode cannot be used with %s stages storage: repo should be specified", phase.Conveyor.StorageManager.StagesStorage.String())
+		}
+	}
+
 	return nil
 }
 
@@ -175,6 +241,17 @@ func (phase *BuildPhase) createReport(ctx context.Context) error {
 			DockerTag:       desc.Info.Tag,
 			DockerImageID:   desc.Info.ID,
 			DockerImageName: desc.Info.Name,
+			Stages:          phase.ImagesReport.GetStagesRecords(img.GetName()),
+		})
+	}
+
+	for imageName, indexInfo := range phase.Conveyor.imagesIndexes {
+		phase.ImagesReport.SetImageRecord(imageName, ReportImageRecord{
+			WerfImageName:   imageName,
+			DockerRepo:      indexInfo.Repository,
+			DockerTag:       indexInfo.Tag,
+			DockerImageID:   indexInfo.ID,
+			DockerImageName: indexInfo.Name,
 		})
 	}
 
@@ -225,6 +302,12 @@ func (phase *BuildPhase) AfterImageStages(ctx context.Context, img *Image) error
 		return nil
 	}
 
+	if phase.RegistryOnly {
+		if err := phase.copyLastStageFromSecondaryStagesStorage(ctx, img); err != nil {
+			return err
+		}
+	}
+
 	if err := phase.addManagedImage(ctx, img); err != nil {
 		return err
 	}
@@ -288,11 +371,42 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		return nil
 	}
 
+	phase.StageReportRecord = &ReportStageRecord{Name: string(stg.Name())}
+	if err := phase.doImageStage(ctx, img, stg); err != nil {
+		return err
+	}
+
+	if stageDesc := stg.GetImage().GetStageDescription(); stageDesc != nil {
+		if phase.StageReportRecord.Source != ReportStageSourceBuilt {
+			phase.touchStageAccessRecord(ctx, stageDesc)
+		}
+
+		phase.StageReportRecord.Digest = stg.GetDigest()
+		phase.StageReportRecord.DockerImageName = stageDesc.Info.Name
+		phase.StageReportRecord.Size = stageDesc.Info.Size
+		phase.ImagesReport.AddStageRecord(img.GetName(), *phase.StageReportRecord)
+	}
+
+	return nil
+}
+
+// touchStageAccessRecord records the usage of the local stage as the cache for the host cleanup
+func (phase *BuildPhase) touchStageAccessRecord(ctx context.Context, stageDesc *image.StageDescription) {
+	if _, ok := phase.Conveyor.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime); !ok {
+		return
+	}
+
+	if err := image.CommonStagesAccessRecords.TouchStage(stageDesc.Info.ID, stageDesc.Info.Name); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Unable to record stage %s access: %s\n", stageDesc.Info.Name, err)
+	}
+}
+
+func (phase *BuildPhase) doImageStage(ctx context.Context, img *Image, stg stage.Interface) error {
 	if err := stg.FetchDependencies(ctx, phase.Conveyor, phase.Conveyor.ContainerRuntime); err != nil {
 		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
 	}
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if isStageBasedOnPrevStage(stg) {
 		if phase.StagesIterator.PrevNonEmptyStage == nil {
 			panic(fmt.Sprintf("expected PrevNonEmptyStage to be set for image %q stage %s", img.GetName(), stg.Name()))
 		}
@@ -314,6 +428,8 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 
 	// Stage is cached in the stages storage
 	if foundSuitableStage {
+		phase.StageReportRecord.Source = ReportStageSourcePrimary
+
 		logboek.Context(ctx).Default().LogFHighlight("Use cache image for %s\n", stg.LogDetailedName())
 		logImageInfo(ctx, stg.GetImage(), phase.getPrevNonEmptyStageImageSize(), true)
 
@@ -343,9 +459,13 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), uuid.New().String())
 		stg.SetImage(i)
 
+		phase.StageReportRecord.Source = ReportStageSourceBuilt
+
+		fetchStartTime := time.Now()
 		if err := phase.fetchBaseImageForStage(ctx, img, stg); err != nil {
 			return err
 		}
+		phase.StageReportRecord.FetchSeconds = time.Since(fetchStartTime).Seconds()
 		if err := phase.prepareStageInstructions(ctx, img, stg); err != nil {
 			return err
 		}
@@ -387,16 +507,40 @@ func (phase *BuildPhase) findAndFetchStageFromSecondaryStagesStorage(ctx context
 				i.SetStageDescription(stageDesc)
 				stg.SetImage(i)
 
+				phase.StageReportRecord.Source = ReportStageSourcePrimary
+
 				logboek.Context(ctx).Default().LogFHighlight("Use cache image for %s\n", stg.LogDetailedName())
 				logImageInfo(ctx, stg.GetImage(), phase.getPrevNonEmptyStageImageSize(), true)
 
 				return nil
 			}
 
+			// The stage will be fetched from the secondary stages storage only if needed to build the next stage
+			// and the last stage of the image will be copied into the primary stages storage by the registry API
+			if phase.RegistryOnly {
+				i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), secondaryStageDesc.Info.Name)
+				i.SetStageDescription(secondaryStageDesc)
+				stg.SetImage(i)
+
+				phase.StageReportRecord.Source = ReportStageSourceSecondary
+
+				logboek.Context(ctx).Default().LogFHighlight("Use cache image for %s from %s\n", stg.LogDetailedName(), secondaryStagesStorage.String())
+				logImageInfo(ctx, stg.GetImage(), phase.getPrevNonEmptyStageImageSize(), true)
+
+				return nil
+			}
+
 			return logboek.Context(ctx).Default().LogProcess("Copy suitable stage from %s", secondaryStagesStorage.String()).DoError(func() error {
 				// Copy suitable stage from a secondary stages storage to the primary stages storage
 				// while primary stages storage lock for this digest is held
-				if copiedStageDesc, err := phase.Conveyor.StorageManager.CopySuitableByDigestStage(ctx, secondaryStageDesc, secondaryStagesStorage, phase.Conveyor.StorageManager.StagesStorage, phase.Conveyor.ContainerRuntime); err != nil {
+				phase.StageReportRecord.Source = ReportStageSourceSecondary
+
+				copyStartTime := time.Now()
+				defer func() {
+					phase.StageReportRecord.FetchSeconds = time.Since(copyStartTime).Seconds()
+				}()
+
+				if copiedStageDesc, err := phase.copySuitableByDigestStage(ctx, secondaryStageDesc, secondaryStagesStorage); err != nil {
 					return fmt.Errorf("unable to copy suitable stage %s from %s to %s: %s", secondaryStageDesc.StageID.String(), secondaryStagesStorage.String(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
 				} else {
 					i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), copiedStageDesc.Info.Name)
@@ -442,12 +586,83 @@ ScanSecondaryStagesStorageList:
 	return foundSuitableStage, nil
 }
 
+func (phase *BuildPhase) copySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage storage.StagesStorage) (*image.StageDescription, error) {
+	return phase.Conveyor.StorageManager.CopySuitableByDigestStage(ctx, stageDesc, sourceStagesStorage, phase.Conveyor.StorageManager.StagesStorage, phase.Conveyor.ContainerRuntime)
+}
+
+func (phase *BuildPhase) copyLastStageFromSecondaryStagesStorage(ctx context.Context, img *Image) error {
+	stg := img.GetLastNonEmptyStage()
+	secondaryStageDesc := stg.GetImage().GetStageDescription()
+
+	secondaryStagesStorage := phase.Conveyor.StorageManager.GetSecondaryStagesStorageOfStage(secondaryStageDesc)
+	if secondaryStagesStorage == nil {
+		return nil
+	}
+
+	if lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest()); err != nil {
+		return fmt.Errorf("unable to lock project %s digest %s: %s", phase.Conveyor.projectName(), stg.GetDigest(), err)
+	} else {
+		defer phase.Conveyor.StorageLockManager.Unlock(ctx, lock)
+	}
+
+	stages, err := phase.Conveyor.StorageManager.GetStagesByDigest(ctx, stg.LogDetailedName(), stg.GetDigest())
+	if err != nil {
+		return err
+	}
+
+	stageDesc, err := phase.Conveyor.StorageManager.SelectSuitableStage(ctx, phase.Conveyor, stg, stages)
+	if err != nil {
+		return err
+	}
+
+	if stageDesc == nil {
+		if err := logboek.Context(ctx).Default().LogProcess("Copy stage %s from %s", stg.LogDetailedName(), secondaryStagesStorage.String()).DoError(func() error {
+			copyStartTime := time.Now()
+
+			copiedStageDesc, report, err := phase.Conveyor.StorageManager.CopySuitableByDigestStageInRegistry(ctx, secondaryStageDesc, secondaryStagesStorage, phase.Conveyor.StorageManager.StagesStorage)
+			if err != nil {
+				return fmt.Errorf("unable to copy stage %s from %s to %s: %s", secondaryStageDesc.StageID.String(), secondaryStagesStorage.String(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
+			}
+
+			phase.ImagesReport.UpdateLastStageRecord(img.GetName(), func(stageRecord *ReportStageRecord) {
+				stageRecord.FetchSeconds = time.Since(copyStartTime).Seconds()
+				stageRecord.UploadedBytes = report.UploadedBytes
+			})
+
+			var stageIDs []image.StageID
+			for _, desc := range stages {
+				stageIDs = append(stageIDs, *desc.StageID)
+			}
+			stageIDs = append(stageIDs, *copiedStageDesc.StageID)
+
+			if err := phase.Conveyor.StorageManager.AtomicStoreStagesByDigestToCache(ctx, string(stg.Name()), stg.GetDigest(), stageIDs); err != nil {
+				return err
+			}
+
+			stageDesc = copiedStageDesc
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(stg.GetImage()).GetFromImage(), stageDesc.Info.Name)
+	i.SetStageDescription(stageDesc)
+	stg.SetImage(i)
+
+	phase.ImagesReport.UpdateLastStageRecord(img.GetName(), func(stageRecord *ReportStageRecord) {
+		stageRecord.DockerImageName = stageDesc.Info.Name
+	})
+
+	return nil
+}
+
 func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image, stg stage.Interface) error {
 	if stg.Name() == "from" {
 		if err := img.FetchBaseImage(ctx, phase.Conveyor); err != nil {
 			return fmt.Errorf("unable to fetch base image %s for stage %s: %s", img.GetBaseImage().Name(), stg.LogDetailedName(), err)
 		}
-	} else if stg.Name() == "dockerfile" {
+	} else if !isStageBasedOnPrevStage(stg) {
 		return nil
 	} else {
 		return phase.Conveyor.StorageManager.FetchStage(ctx, phase.StagesIterator.PrevBuiltStage)
@@ -563,13 +778,6 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 }
 
 func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.Interface) error {
-	if !img.isDockerfileImage {
-		_, err := stapel.GetOrCreateContainer(ctx)
-		if err != nil {
-			return fmt.Errorf("get or create stapel container failed: %s", err)
-		}
-	}
-
 	infoSectionFunc := func(err error) {
 		if err != nil {
 			logboek.Context(ctx).Streams().DoWithIndent(func() {
@@ -614,11 +822,13 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 		time.Sleep(time.Duration(seconds) * time.Second)
 	}
 
+	buildStartTime := time.Now()
 	if err := logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
 		return stageImage.Build(ctx, phase.ImageBuildOptions)
 	}); err != nil {
 		return fmt.Errorf("failed to build image for stage %s with digest %s: %s", stg.Name(), stg.GetDigest(), err)
 	}
+	phase.StageReportRecord.BuildSeconds = time.Since(buildStartTime).Seconds()
 
 	if v := os.Getenv("WERF_TEST_ATOMIC_STAGE_BUILD__SLEEP_SECONDS_BEFORE_STAGE_SAVE"); v != "" {
 		seconds := 0
@@ -647,6 +857,9 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), stageDesc.Info.Name)
 			i.SetStageDescription(stageDesc)
 			stg.SetImage(i)
+
+			phase.StageReportRecord.Source = ReportStageSourcePrimary
+
 			return nil
 		} else { // use newly built image
 			newStageImageName, uniqueID := phase.Conveyor.StorageManager.GenerateStageUniqueID(stg.GetDigest(), stages)
@@ -655,9 +868,12 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			stageImageObj.SetName(newStageImageName)
 			phase.Conveyor.SetStageImage(stageImageObj)
 
+			storeStartTime := time.Now()
 			if err := logboek.Context(ctx).Info().LogProcess("Store stage").DoError(func() error {
-				if err := phase.Conveyor.StorageManager.StagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage}); err != nil {
+				if report, err := phase.Conveyor.StorageManager.StagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage}, phase.Conveyor.StorageManager.GetStoreImageOptions()); err != nil {
 					return fmt.Errorf("unable to store stage %s digest %s image %s into repo %s: %s", stg.LogDetailedName(), stg.GetDigest(), stageImage.Name(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
+				} else {
+					phase.StageReportRecord.UploadedBytes = report.UploadedBytes
 				}
 				if desc, err := phase.Conveyor.StorageManager.StagesStorage.GetStageDescription(ctx, phase.Conveyor.projectName(), stg.GetDigest(), uniqueID); err != nil {
 					return fmt.Errorf("unable to get stage %s digest %s image %s description from repo %s after stages has been stored into repo: %s", stg.LogDetailedName(), stg.GetDigest(), stageImage.Name(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
@@ -668,6 +884,7 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			}); err != nil {
 				return err
 			}
+			phase.StageReportRecord.StoreSeconds = time.Since(storeStartTime).Seconds()
 
 			var stageIDs []image.StageID
 			for _, stageDesc := range stages {
@@ -748,27 +965,15 @@ func byteCountBinary(b int64) string {
 }
 
 func calculateDigest(ctx context.Context, stageName, stageDependencies string, prevNonEmptyStage stage.Interface, conveyor *Conveyor) (string, error) {
-	checksumArgs := []string{image.BuildCacheVersion, stageName, stageDependencies}
-	if prevNonEmptyStage != nil {
-		prevStageDependencies, err := prevNonEmptyStage.GetNextStageDependencies(ctx, conveyor)
-		if err != nil {
-			return "", fmt.Errorf("unable to get prev stage %s dependencies for the stage %s: %s", prevNonEmptyStage.Name(), stageName, err)
-		}
-
-		checksumArgs = append(checksumArgs, prevNonEmptyStage.GetDigest(), prevStageDependencies)
+	checksumArgsNames, checksumArgs, err := getDigestChecksumArgs(ctx, stageName, stageDependencies, prevNonEmptyStage, conveyor)
+	if err != nil {
+		return "", err
 	}
 
 	digest := util.Sha3_224Hash(checksumArgs...)
 
 	blockMsg := fmt.Sprintf("Stage %s digest %s", stageName, digest)
 	logboek.Context(ctx).Debug().LogBlock(blockMsg).Do(func() {
-		checksumArgsNames := []string{
-			"BuildCacheVersion",
-			"stageName",
-			"stageDependencies",
-			"prevNonEmptyStage digest",
-			"prevNonEmptyStage dependencies for next stage",
-		}
 		for ind, checksumArg := range checksumArgs {
 			logboek.Context(ctx).Debug().LogF("%s => %q\n", checksumArgsNames[ind], checksumArg)
 		}
@@ -777,6 +982,32 @@ func calculateDigest(ctx context.Context, stageName, stageDependencies string, p
 	return digest, nil
 }
 
+func getDigestChecksumArgs(ctx context.Context, stageName, stageDependencies string, prevNonEmptyStage stage.Interface, conveyor *Conveyor) ([]string, []string, error) {
+	checksumArgs := []string{image.BuildCacheVersion, stageName, stageDependencies}
+	checksumArgsNames := []string{
+		"BuildCacheVersion",
+		"stageName",
+		"stageDependencies",
+	}
+
+	if prevNonEmptyStage != nil {
+		prevStageDependencies, err := prevNonEmptyStage.GetNextStageDependencies(ctx, conveyor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get prev stage %s dependencies for the stage %s: %s", prevNonEmptyStage.Name(), stageName, err)
+		}
+
+		checksumArgs = append(checksumArgs, prevNonEmptyStage.GetDigest(), prevStageDependencies)
+		checksumArgsNames = append(checksumArgsNames, "prevNonEmptyStage digest", "prevNonEmptyStage dependencies for next stage")
+	} else if conveyor.platform != "" {
+		// NOTE: the platform is only added to the first stage digest,
+		// NOTE: digests of the following stages depend on it through the prev stage digest
+		checksumArgs = append(checksumArgs, conveyor.platform)
+		checksumArgsNames = append(checksumArgsNames, "platform")
+	}
+
+	return checksumArgsNames, checksumArgs, nil
+}
+
 // TODO: move these prints to the after-images hook, print summary over all images
 func (phase *BuildPhase) printShouldBeBuiltError(ctx context.Context, img *Image, stg stage.Interface) {
 	logboek.Context(ctx).Default().LogProcess("Built stages cache check").
@@ -111,11 +111,7 @@ func (b *Ansible) stage(ctx context.Context, userStageName string, container Con
 		fmt.Sprintf("%s:%s:rw", stageHostTmpDir, b.containerTmpDir()),
 	)
 
-	containerName, err := stapel.GetOrCreateContainer(ctx)
-	if err != nil {
-		return err
-	}
-	container.AddVolumeFrom(fmt.Sprintf("%s:ro", containerName))
+	container.AddVolumeFrom(fmt.Sprintf("%s:ro", stapel.ContainerName()))
 
 	commandParts := []string{
 		path.Join(b.containerWorkDir(), "ansible-playbook"),
@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +27,7 @@ import (
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/image"
@@ -55,6 +57,10 @@ type Conveyor struct {
 	images    []*Image
 	imageSets [][]*Image
 
+	platform          string
+	platformConveyors []*Conveyor
+	imagesIndexes     map[string]*image.Info
+
 	stageImages        map[string]*container_runtime.StageImage
 	localGitRepo       git_repo.Local
 	giterminismManager giterminism_manager.Interface
@@ -81,6 +87,7 @@ type ConveyorOptions struct {
 	Parallel                        bool
 	ParallelTasksLimit              int64
 	LocalGitRepoVirtualMergeOptions stage.VirtualMergeOptions
+	BaseImageMirrors                *docker_registry.BaseImageMirrors
 }
 
 func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface, localGitRepo git_repo.Local, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock string, containerRuntime container_runtime.ContainerRuntime, storageManager *manager.StorageManager, storageLockManager storage.LockManager, opts ConveyorOptions) *Conveyor {
@@ -192,6 +199,14 @@ func (c *Conveyor) GetLocalGitRepoVirtualMergeOptions() stage.VirtualMergeOption
 	return c.ConveyorOptions.LocalGitRepoVirtualMergeOptions
 }
 
+func (c *Conveyor) GetContainerRuntime() container_runtime.ContainerRuntime {
+	return c.ContainerRuntime
+}
+
+func (c *Conveyor) GetBaseImageReference(baseImageName string) string {
+	return c.ConveyorOptions.BaseImageMirrors.Resolve(baseImageName)
+}
+
 func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error) {
 	c.getServiceRWMutex("ImportServer").Lock()
 	defer c.getServiceRWMutex("ImportServer").Unlock()
@@ -262,6 +277,12 @@ func (c *Conveyor) Terminate(ctx context.Context) error {
 		}
 	}
 
+	for _, platformConveyor := range c.platformConveyors {
+		if err := platformConveyor.Terminate(ctx); err != nil {
+			terminateErrors = append(terminateErrors, err)
+		}
+	}
+
 	if len(terminateErrors) > 0 {
 		errMsg := "Errors occurred during conveyor termination:\n"
 		for _, err := range terminateErrors {
@@ -323,6 +344,22 @@ func (c *Conveyor) ShouldBeBuilt(ctx context.Context) error {
 		return err
 	}
 
+	if len(c.platformConveyors) != 0 {
+		for _, platformConveyor := range c.platformConveyors {
+			phases := []Phase{
+				NewBuildPhase(platformConveyor, BuildPhaseOptions{ShouldBeBuiltMode: true}),
+			}
+
+			if err := platformConveyor.runPhases(ctx, phases, false); err != nil {
+				return err
+			}
+		}
+
+		if err := c.processImagesIndexes(ctx, true); err != nil {
+			return err
+		}
+	}
+
 	phases := []Phase{
 		NewBuildPhase(c, BuildPhaseOptions{ShouldBeBuiltMode: true}),
 	}
@@ -334,6 +371,35 @@ func (c *Conveyor) ShouldBeBuilt(ctx context.Context) error {
 	return nil
 }
 
+// ExplainStages calculates digests of the image stages without building missing stages
+// and returns inputs which each stage digest is calculated from.
+func (c *Conveyor) ExplainStages(ctx context.Context, imageName string) ([]*StageExplanation, error) {
+	if err := c.determineStages(ctx); err != nil {
+		return nil, err
+	}
+
+	if !c.hasImage(imageName) {
+		return nil, fmt.Errorf("stages of the multi-platform image %q cannot be explained", imageName)
+	}
+
+	explainPhase := NewExplainPhase(c)
+	if err := c.runPhases(ctx, []Phase{explainPhase}, false); err != nil {
+		return nil, err
+	}
+
+	return explainPhase.StagesExplanations.GetStagesExplanations(imageName), nil
+}
+
+func (c *Conveyor) hasImage(imageName string) bool {
+	for _, img := range c.images {
+		if img.GetName() == imageName {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Conveyor) FetchLastImageStage(ctx context.Context, imageName string) error {
 	lastImageStage := c.GetImage(imageName).GetLastNonEmptyStage()
 	return c.StorageManager.FetchStage(ctx, lastImageStage)
@@ -347,6 +413,11 @@ func (c *Conveyor) GetImageInfoGetters() (images []*image.InfoGetter) {
 		images = append(images, img.GetImageInfoGetter())
 	}
 
+	for _, imageName := range c.getImagesIndexesNames() {
+		indexInfo := c.imagesIndexes[imageName]
+		images = append(images, image.NewInfoGetter(imageName, indexInfo.Name, indexInfo.Tag))
+	}
+
 	return images
 }
 
@@ -360,6 +431,10 @@ func (c *Conveyor) GetImagesEnvArray() []string {
 		envArray = append(envArray, generateImageEnv(img.name, c.GetImageNameForLastImageStage(img.name)))
 	}
 
+	for _, imageName := range c.getImagesIndexesNames() {
+		envArray = append(envArray, generateImageEnv(imageName, c.imagesIndexes[imageName].Name))
+	}
+
 	return envArray
 }
 
@@ -379,9 +454,129 @@ func (c *Conveyor) Build(ctx context.Context, opts BuildOptions) error {
 		return nil
 	}
 
+	if len(c.platformConveyors) != 0 {
+		if err := c.buildPlatformImages(ctx, opts); err != nil {
+			return err
+		}
+	}
+
 	return c.runPhases(ctx, phases, true)
 }
 
+func (c *Conveyor) buildPlatformImages(ctx context.Context, opts BuildOptions) error {
+	if _, ok := c.StorageManager.StagesStorage.(*storage.RepoStagesStorage); !ok {
+		return fmt.Errorf("multi-platform images cannot be built with %s stages storage: repo should be specified", c.StorageManager.StagesStorage.String())
+	}
+
+	// NOTE: the report is created by the main conveyor and includes images indexes
+	platformOpts := opts
+	platformOpts.ReportPath = ""
+
+	for _, platformConveyor := range c.platformConveyors {
+		platformOpts.ImageBuildOptions.Platform = platformConveyor.platform
+
+		phases := []Phase{
+			NewBuildPhase(platformConveyor, BuildPhaseOptions{
+				BuildOptions: platformOpts,
+			}),
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Building images for platform %s", platformConveyor.platform).
+			Options(func(options types.LogProcessOptionsInterface) {
+				options.Style(style.Highlight())
+			}).
+			DoError(func() error {
+				return platformConveyor.runPhases(ctx, phases, true)
+			}); err != nil {
+			return err
+		}
+	}
+
+	return c.processImagesIndexes(ctx, false)
+}
+
+// processImagesIndexes publishes (or only checks in the should-be-built mode) an image index
+// for each multi-platform image using the last stages built by the platform conveyors.
+func (c *Conveyor) processImagesIndexes(ctx context.Context, shouldBeBuiltMode bool) error {
+	c.imagesIndexes = make(map[string]*image.Info)
+
+	stageDescByPlatformByImageName := c.getMultiPlatformImagesStagesDescriptions()
+
+	var imagesNames []string
+	for imageName := range stageDescByPlatformByImageName {
+		imagesNames = append(imagesNames, imageName)
+	}
+	sort.Strings(imagesNames)
+
+	for _, imageName := range imagesNames {
+		stageDescByPlatform := stageDescByPlatformByImageName[imageName]
+
+		var indexInfo *image.Info
+		if err := logboek.Context(ctx).Default().LogProcess("Processing image %s index", logging.ImageLogName(imageName, false)).
+			DoError(func() error {
+				var err error
+				if shouldBeBuiltMode {
+					indexInfo, err = c.StorageManager.GetImageIndex(ctx, stageDescByPlatform)
+					if err == nil && indexInfo == nil {
+						return fmt.Errorf("image index is not exist in repo")
+					}
+				} else {
+					indexInfo, err = c.StorageManager.PublishImageIndex(ctx, stageDescByPlatform)
+				}
+
+				return err
+			}); err != nil {
+			return fmt.Errorf("unable to process image %s index: %s", logging.ImageLogName(imageName, false), err)
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  name: %s\n", indexInfo.Name)
+		logboek.Context(ctx).Default().LogFDetails("digest: %s\n", indexInfo.RepoDigest)
+
+		c.imagesIndexes[imageName] = indexInfo
+	}
+
+	return nil
+}
+
+func (c *Conveyor) getMultiPlatformImagesStagesDescriptions() map[string]map[string]*image.StageDescription {
+	res := make(map[string]map[string]*image.StageDescription)
+	for _, platformConveyor := range c.platformConveyors {
+		for _, img := range platformConveyor.images {
+			if img.isArtifact {
+				continue
+			}
+
+			// skip dependencies which are built for the platform, but are not multi-platform images themselves
+			if !util.IsStringsContainValue(c.werfConfig.GetImage(img.GetName()).GetPlatform(), platformConveyor.platform) {
+				continue
+			}
+
+			if _, hasKey := res[img.GetName()]; !hasKey {
+				res[img.GetName()] = make(map[string]*image.StageDescription)
+			}
+			res[img.GetName()][platformConveyor.platform] = img.GetLastNonEmptyStage().GetImage().GetStageDescription()
+		}
+	}
+
+	return res
+}
+
+func (c *Conveyor) getImagesIndexesNames() []string {
+	var res []string
+	for imageName := range c.imagesIndexes {
+		res = append(res, imageName)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func (c *Conveyor) newPlatformConveyor(platform string) *Conveyor {
+	platformConveyor := NewConveyor(c.werfConfig, c.giterminismManager, c.localGitRepo, c.imageNamesToProcess, c.projectDir, c.baseTmpDir, c.sshAuthSock, c.ContainerRuntime, c.StorageManager, c.StorageLockManager, c.ConveyorOptions)
+	platformConveyor.platform = platform
+	return platformConveyor
+}
+
 func (c *Conveyor) determineStages(ctx context.Context) error {
 	return logboek.Context(ctx).Info().LogProcess("Determining of stages").
 		Options(func(options types.LogProcessOptionsInterface) {
@@ -394,7 +589,17 @@ func (c *Conveyor) determineStages(ctx context.Context) error {
 
 func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 	imageConfigsToProcess := getImageConfigsToProcess(ctx, c)
-	configSets := c.werfConfig.ImagesWithDependenciesBySets(imageConfigsToProcess)
+
+	if c.platform == "" {
+		for _, platform := range getImageConfigsPlatforms(imageConfigsToProcess) {
+			c.platformConveyors = append(c.platformConveyors, c.newPlatformConveyor(platform))
+		}
+	}
+
+	configSets := c.werfConfig.ImagesWithDependenciesBySets(filterImageConfigsByPlatform(imageConfigsToProcess, c.platform))
+	if err := validateImageConfigsForContainerRuntime(configSets, c.ContainerRuntime); err != nil {
+		return err
+	}
 
 	for _, iteration := range configSets {
 		var imageSet []*Image
@@ -444,6 +649,15 @@ func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 		c.imageSets = append(c.imageSets, imageSet)
 	}
 
+	for _, platformConveyor := range c.platformConveyors {
+		if err := logboek.Context(ctx).Info().LogProcess("Platform %s", platformConveyor.platform).
+			DoError(func() error {
+				return platformConveyor.doDetermineStages(ctx)
+			}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -624,7 +838,7 @@ func (c *Conveyor) GetOrCreateStageImage(fromImage *container_runtime.StageImage
 		return img
 	}
 
-	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime))
+	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime)
 	c.SetStageImage(img)
 	return img
 }
@@ -728,6 +942,7 @@ func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceCon
 
 func handleImageFromName(ctx context.Context, from string, fromLatest bool, image *Image, c *Conveyor) error {
 	image.baseImageName = from
+	image.baseImageReference = c.GetBaseImageReference(from)
 
 	if fromLatest {
 		if _, err := image.getFromBaseImageIdFromRegistry(ctx, c, image.baseImageName); err != nil {
@@ -777,6 +992,50 @@ func getImageConfigsToProcess(ctx context.Context, c *Conveyor) []config.ImageIn
 	return imageConfigsToProcess
 }
 
+func getImageConfigsPlatforms(imageConfigs []config.ImageInterface) []string {
+	var platforms []string
+	for _, imageConfig := range imageConfigs {
+		for _, platform := range imageConfig.GetPlatform() {
+			if !util.IsStringsContainValue(platforms, platform) {
+				platforms = append(platforms, platform)
+			}
+		}
+	}
+
+	return platforms
+}
+
+// validateImageConfigsForContainerRuntime checks that the images with dependencies can be built by the container runtime
+func validateImageConfigsForContainerRuntime(configSets [][]config.ImageInterface, containerRuntime container_runtime.ContainerRuntime) error {
+	// imports are performed by the rsync server and checksum containers of the docker server
+	if _, isBuildkit := containerRuntime.(*container_runtime.BuildkitRuntime); !isBuildkit {
+		return nil
+	}
+
+	for _, configSet := range configSets {
+		for _, imageConfig := range configSet {
+			if stapelImageConfig, ok := imageConfig.(config.StapelImageInterface); ok && len(stapelImageConfig.ImageBaseConfig().Import) != 0 {
+				return fmt.Errorf("%s imports are not supported by the %s container runtime: docker server is required, use --container-runtime=docker-server", logging.ImageLogProcessName(stapelImageConfig.ImageBaseConfig().Name, stapelImageConfig.IsArtifact()), containerRuntime.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// filterImageConfigsByPlatform selects images without platforms for the main conveyor (empty platform)
+// and images with the specified platform for the platform conveyor.
+func filterImageConfigsByPlatform(imageConfigs []config.ImageInterface, platform string) []config.ImageInterface {
+	var res []config.ImageInterface
+	for _, imageConfig := range imageConfigs {
+		if platform == "" && len(imageConfig.GetPlatform()) == 0 || platform != "" && util.IsStringsContainValue(imageConfig.GetPlatform(), platform) {
+			res = append(res, imageConfig)
+		}
+	}
+
+	return res
+}
+
 func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.StapelImageInterface, c *Conveyor) error {
 	var stages []stage.Interface
 
@@ -1187,29 +1446,91 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		ProjectName: c.werfConfig.Meta.Project,
 	}
 
-	dockerfileStage := stage.GenerateDockerfileStage(
-		stage.NewDockerRunArgs(
+	newDockerRunArgs := func(target string) *stage.DockerRunArgs {
+		return stage.NewDockerRunArgs(
 			imageFromDockerfileConfig.Dockerfile,
-			imageFromDockerfileConfig.Target,
+			target,
 			imageFromDockerfileConfig.Context,
 			imageFromDockerfileConfig.ContextAddFile,
 			imageFromDockerfileConfig.Args,
 			imageFromDockerfileConfig.AddHost,
 			imageFromDockerfileConfig.Network,
 			imageFromDockerfileConfig.SSH,
-		),
-		ds,
-		stage.NewContextChecksum(c.projectDir, dockerignorePathMatcher, c.GetLocalGitRepo()),
-		baseStageOptions,
-	)
+			imageFromDockerfileConfig.Secrets,
+			c.platform,
+		)
+	}
 
-	img.stages = append(img.stages, dockerfileStage)
+	var dockerfileStages []*stage.DockerfileStage
+	if imageFromDockerfileConfig.Staged {
+		for ind, dockerStageIndex := range getDockerStagesChain(dockerStages, dockerTargetIndex) {
+			stageName := stage.Dockerfile
+			target := imageFromDockerfileConfig.Target
+			if dockerStageIndex != dockerTargetIndex {
+				stageName = stage.DockerfileStageName(dockerStages[dockerStageIndex].Name)
+				target = dockerStages[dockerStageIndex].Name
+			}
 
-	logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
+			stageDockerStages, err := stage.NewDockerStages(
+				dockerStages,
+				util.MapStringInterfaceToMapStringString(imageFromDockerfileConfig.Args),
+				dockerMetaArgs,
+				dockerStageIndex,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			dockerfileStages = append(dockerfileStages, stage.GenerateDockerfileStageForDockerStage(
+				stageName,
+				dockerfileData,
+				ind != 0,
+				newDockerRunArgs(target),
+				stageDockerStages,
+				stage.NewContextChecksum(c.projectDir, dockerignorePathMatcher, c.GetLocalGitRepo()),
+				baseStageOptions,
+			))
+		}
+	} else {
+		dockerfileStages = append(dockerfileStages, stage.GenerateDockerfileStage(
+			newDockerRunArgs(imageFromDockerfileConfig.Target),
+			ds,
+			stage.NewContextChecksum(c.projectDir, dockerignorePathMatcher, c.GetLocalGitRepo()),
+			baseStageOptions,
+		))
+	}
+
+	for _, dockerfileStage := range dockerfileStages {
+		if err := dockerfileStage.ValidateRunMounts(); err != nil {
+			return nil, fmt.Errorf("image %s: %s", imageFromDockerfileConfig.Name, err)
+		}
+
+		img.stages = append(img.stages, dockerfileStage)
+
+		logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
+	}
 
 	return img, nil
 }
 
+// getDockerStagesChain returns indexes of the target Dockerfile stage and the stages it is based on,
+// starting from the stage based on the external image
+func getDockerStagesChain(dockerStages []instructions.Stage, dockerTargetIndex int) []int {
+	chain := []int{dockerTargetIndex}
+
+chainLoop:
+	for {
+		for ind, dockerStage := range dockerStages[:chain[0]] {
+			if dockerStage.Name != "" && dockerStages[chain[0]].BaseName == dockerStage.Name {
+				chain = append([]int{ind}, chain...)
+				continue chainLoop
+			}
+		}
+
+		return chain
+	}
+}
+
 func resolveDockerStagesFromValue(stages []instructions.Stage) {
 	nameToIndex := make(map[string]string)
 	for i, s := range stages {
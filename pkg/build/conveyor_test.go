@@ -0,0 +1,233 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+func TestFilterImageConfigsByPlatform(t *testing.T) {
+	imageConfigs := []config.ImageInterface{
+		&config.ImageFromDockerfile{Name: "no-platform"},
+		&config.ImageFromDockerfile{Name: "amd64", Platform: []string{"linux/amd64"}},
+		&config.ImageFromDockerfile{Name: "multi-platform", Platform: []string{"linux/amd64", "linux/arm64"}},
+	}
+
+	tests := []struct {
+		name               string
+		platform           string
+		expectedImageNames []string
+	}{
+		{
+			name:               "main conveyor selects images without platforms",
+			platform:           "",
+			expectedImageNames: []string{"no-platform"},
+		},
+		{
+			name:               "platform conveyor selects images with the platform",
+			platform:           "linux/amd64",
+			expectedImageNames: []string{"amd64", "multi-platform"},
+		},
+		{
+			name:               "platform conveyor selects only multi-platform images with the platform",
+			platform:           "linux/arm64",
+			expectedImageNames: []string{"multi-platform"},
+		},
+		{
+			name:               "unknown platform",
+			platform:           "linux/s390x",
+			expectedImageNames: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var imageNames []string
+			for _, imageConfig := range filterImageConfigsByPlatform(imageConfigs, tt.platform) {
+				imageNames = append(imageNames, imageConfig.GetName())
+			}
+
+			if !reflect.DeepEqual(imageNames, tt.expectedImageNames) {
+				t.Errorf("expected images %v, got %v", tt.expectedImageNames, imageNames)
+			}
+		})
+	}
+}
+
+func TestValidateImageConfigsForContainerRuntime(t *testing.T) {
+	imageWithImports := &config.StapelImage{StapelImageBase: &config.StapelImageBase{Name: "app", Import: []*config.Import{{}}}}
+	artifactWithImports := &config.StapelImageArtifact{StapelImageBase: &config.StapelImageBase{Name: "artifact", Import: []*config.Import{{}}}}
+	imageWithoutImports := &config.StapelImage{StapelImageBase: &config.StapelImageBase{Name: "app"}}
+	dockerfileImage := &config.ImageFromDockerfile{Name: "dockerfile"}
+
+	tests := []struct {
+		name             string
+		configSets       [][]config.ImageInterface
+		containerRuntime container_runtime.ContainerRuntime
+		expectedErr      string
+	}{
+		{
+			name:             "docker server runtime supports imports",
+			configSets:       [][]config.ImageInterface{{artifactWithImports}, {imageWithImports}},
+			containerRuntime: &container_runtime.LocalDockerServerRuntime{},
+		},
+		{
+			name:             "buildkit runtime without imports",
+			configSets:       [][]config.ImageInterface{{imageWithoutImports, dockerfileImage}},
+			containerRuntime: container_runtime.NewBuildkitRuntime(),
+		},
+		{
+			name:             "buildkit runtime with image imports",
+			configSets:       [][]config.ImageInterface{{dockerfileImage}, {imageWithImports}},
+			containerRuntime: container_runtime.NewBuildkitRuntime(),
+			expectedErr:      "image app imports are not supported by the buildkit container runtime",
+		},
+		{
+			name:             "buildkit runtime with dependency artifact imports",
+			configSets:       [][]config.ImageInterface{{artifactWithImports}, {imageWithoutImports}},
+			containerRuntime: container_runtime.NewBuildkitRuntime(),
+			expectedErr:      "artifact artifact imports are not supported by the buildkit container runtime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImageConfigsForContainerRuntime(tt.configSets, tt.containerRuntime)
+			switch {
+			case tt.expectedErr == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tt.expectedErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectedErr)):
+				t.Errorf("expected error %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+type processImagesIndexesTestStage struct {
+	stage.Interface
+	image container_runtime.ImageInterface
+}
+
+func (s *processImagesIndexesTestStage) GetImage() container_runtime.ImageInterface {
+	return s.image
+}
+
+func TestConveyorProcessImagesIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	if err := docker_registry.Init(ctx, true, false, docker_registry.ThrottlingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repoAddress := strings.TrimPrefix(server.URL, "http://") + "/project"
+	stagesStorage, err := storage.NewRepoStagesStorage(repoAddress, nil, storage.RepoStagesStorageOptions{
+		DockerRegistryOptions: docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+		Implementation:        docker_registry.DefaultImplementationName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPlatformConveyor := func(platform string, uniqueID int64) *Conveyor {
+		platformConveyor := &Conveyor{platform: platform}
+		for _, imageName := range []string{"app", "base"} {
+			stageID := image.StageID{Digest: fmt.Sprintf("%s-digest", imageName), UniqueID: uniqueID}
+			stageImageName := stagesStorage.ConstructStageImageName("project", stageID.Digest, stageID.UniqueID)
+
+			img, err := random.Image(1024, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := docker_registry.API().WriteRepoImage(ctx, stageImageName, img); err != nil {
+				t.Fatal(err)
+			}
+
+			stageImage := container_runtime.NewStageImage(nil, stageImageName, nil)
+			stageImage.SetStageDescription(&image.StageDescription{StageID: &stageID, Info: &image.Info{Name: stageImageName}})
+
+			platformConveyor.images = append(platformConveyor.images, &Image{
+				name:              imageName,
+				lastNonEmptyStage: &processImagesIndexesTestStage{image: stageImage},
+			})
+		}
+
+		return platformConveyor
+	}
+
+	c := &Conveyor{
+		werfConfig: &config.WerfConfig{
+			ImagesFromDockerfile: []*config.ImageFromDockerfile{
+				{Name: "app", Platform: []string{"linux/amd64", "linux/arm64"}},
+				// the dependency is built by the platform conveyors, but it is not a multi-platform image
+				{Name: "base"},
+			},
+		},
+		platformConveyors: []*Conveyor{
+			newPlatformConveyor("linux/amd64", 1),
+			newPlatformConveyor("linux/arm64", 2),
+		},
+		StorageManager: manager.NewStorageManager("project", stagesStorage, nil, nil, nil),
+	}
+
+	if err := c.processImagesIndexes(ctx, true); err == nil || !strings.Contains(err.Error(), "image index is not exist in repo") {
+		t.Fatalf("expected the missing index error in the should-be-built mode, got %v", err)
+	}
+
+	if err := c.processImagesIndexes(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if imagesIndexesNames := c.getImagesIndexesNames(); !reflect.DeepEqual(imagesIndexesNames, []string{"app"}) {
+		t.Fatalf("expected the index only for the multi-platform image, got %v", imagesIndexesNames)
+	}
+
+	indexInfo := c.imagesIndexes["app"]
+	if !strings.HasPrefix(indexInfo.Name, repoAddress+":"+storage.RepoImageIndex_ImageTagPrefix) {
+		t.Fatalf("unexpected index name %s", indexInfo.Name)
+	}
+
+	_, indexManifest, err := docker_registry.API().GetRepoImageIndexManifest(ctx, indexInfo.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var platforms, stageIDs []string
+	for _, desc := range indexManifest.Manifests {
+		platforms = append(platforms, fmt.Sprintf("%s/%s", desc.Platform.OS, desc.Platform.Architecture))
+		stageIDs = append(stageIDs, desc.Annotations[image.WerfImageIndexStageIDAnnotation])
+	}
+
+	if expected := []string{"linux/amd64", "linux/arm64"}; !reflect.DeepEqual(platforms, expected) {
+		t.Errorf("expected index platforms %v, got %v", expected, platforms)
+	}
+	if expected := []string{"app-digest-1", "app-digest-2"}; !reflect.DeepEqual(stageIDs, expected) {
+		t.Errorf("expected index stages %v, got %v", expected, stageIDs)
+	}
+
+	// the index of the same stages is reused
+	if err := c.processImagesIndexes(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if c.imagesIndexes["app"].RepoDigest != indexInfo.RepoDigest {
+		t.Errorf("expected the index %s to be reused, got %s", indexInfo.RepoDigest, c.imagesIndexes["app"].RepoDigest)
+	}
+}
@@ -0,0 +1,333 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/util"
+)
+
+type StageExplanation struct {
+	Name   string
+	Digest string
+	// Exists is true when the stage with the digest exists in the repo
+	Exists bool
+	// UnavailableReason is set when the stage digest cannot be calculated without building previous stages
+	UnavailableReason string
+	Inputs            []stage.DependenciesInput
+}
+
+type StagesExplanations struct {
+	mux    sync.Mutex
+	Images map[string][]*StageExplanation
+}
+
+func (explanations *StagesExplanations) AddStageExplanation(imageName string, stageExplanation *StageExplanation) {
+	explanations.mux.Lock()
+	defer explanations.mux.Unlock()
+	explanations.Images[imageName] = append(explanations.Images[imageName], stageExplanation)
+}
+
+func (explanations *StagesExplanations) GetStagesExplanations(imageName string) []*StageExplanation {
+	explanations.mux.Lock()
+	defer explanations.mux.Unlock()
+	return explanations.Images[imageName]
+}
+
+// ExplainPhase calculates stages digests the same way as the build phase does,
+// but does not build missing stages and collects inputs of each stage digest instead.
+type ExplainPhase struct {
+	*BuildPhase
+
+	StagesExplanations *StagesExplanations
+
+	unavailableReason string
+}
+
+func NewExplainPhase(c *Conveyor) *ExplainPhase {
+	return &ExplainPhase{
+		BuildPhase:         NewBuildPhase(c, BuildPhaseOptions{}),
+		StagesExplanations: &StagesExplanations{Images: make(map[string][]*StageExplanation)},
+	}
+}
+
+func (phase *ExplainPhase) Name() string {
+	return "explain"
+}
+
+func (phase *ExplainPhase) AfterImages(_ context.Context) error {
+	return nil
+}
+
+func (phase *ExplainPhase) BeforeImageStages(ctx context.Context, img *Image) error {
+	phase.unavailableReason = ""
+	return phase.BuildPhase.BeforeImageStages(ctx, img)
+}
+
+func (phase *ExplainPhase) AfterImageStages(_ context.Context, img *Image) error {
+	if phase.StagesIterator.PrevNonEmptyStage != nil {
+		img.SetLastNonEmptyStage(phase.StagesIterator.PrevNonEmptyStage)
+		img.SetContentDigest(phase.StagesIterator.PrevNonEmptyStage.GetContentDigest())
+	}
+
+	return nil
+}
+
+func (phase *ExplainPhase) OnImageStage(ctx context.Context, img *Image, stg stage.Interface) error {
+	if phase.unavailableReason == "" {
+		phase.unavailableReason = phase.getStageUnavailableReason(img, stg)
+	}
+
+	if phase.unavailableReason != "" {
+		phase.StagesExplanations.AddStageExplanation(img.GetName(), &StageExplanation{
+			Name:              string(stg.Name()),
+			UnavailableReason: phase.unavailableReason,
+		})
+		return nil
+	}
+
+	return phase.StagesIterator.OnImageStage(ctx, img, stg, func(img *Image, stg stage.Interface, isEmpty bool) error {
+		return phase.onImageStage(ctx, img, stg, isEmpty)
+	})
+}
+
+// getStageUnavailableReason checks whether the stage digest depends on the previous stages
+// which should be built (but do not exist in the repo) or on the import source images
+// which should be built.
+func (phase *ExplainPhase) getStageUnavailableReason(img *Image, stg stage.Interface) string {
+	switch stg.Name() {
+	case stage.GitCache, stage.GitLatestPatch:
+		prevNonEmptyStage := phase.StagesIterator.PrevNonEmptyStage
+		if prevNonEmptyStage != nil && prevNonEmptyStage.GetImage().GetStageDescription() == nil {
+			return fmt.Sprintf("previous stage %s does not exist in the repo", prevNonEmptyStage.LogDetailedName())
+		}
+	}
+
+	if importsStage, ok := stg.(*stage.ImportsStage); ok {
+		for _, elm := range importsStage.GetImports() {
+			sourceImageName := elm.ImageName
+			if sourceImageName == "" {
+				sourceImageName = elm.ArtifactName
+			}
+
+			var sourceStage stage.Interface
+			if elm.Stage == "" {
+				sourceStage = phase.Conveyor.GetImage(sourceImageName).GetLastNonEmptyStage()
+			} else {
+				sourceStage = phase.Conveyor.getImageStage(sourceImageName, elm.Stage)
+			}
+
+			if sourceStage == nil || sourceStage.GetImage() == nil || sourceStage.GetImage().GetStageDescription() == nil {
+				return fmt.Sprintf("import source image %q does not exist in the repo", sourceImageName)
+			}
+		}
+	}
+
+	return ""
+}
+
+func (phase *ExplainPhase) onImageStage(ctx context.Context, img *Image, stg stage.Interface, isEmpty bool) error {
+	if isEmpty {
+		return nil
+	}
+
+	if err := stg.FetchDependencies(ctx, phase.Conveyor, phase.Conveyor.ContainerRuntime); err != nil {
+		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
+	}
+
+	foundSuitableStage, cleanupFunc, err := phase.calculateStage(ctx, img, stg)
+	if cleanupFunc != nil {
+		defer cleanupFunc()
+	}
+	if err != nil {
+		return err
+	}
+
+	if !foundSuitableStage {
+		i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), uuid.New().String())
+		stg.SetImage(i)
+	}
+
+	// stageDependencies are explained with the stage inputs
+	checksumArgsNames, checksumArgs, err := getDigestChecksumArgs(ctx, string(stg.Name()), "", phase.StagesIterator.PrevNonEmptyStage, phase.Conveyor)
+	if err != nil {
+		return err
+	}
+
+	var inputs []stage.DependenciesInput
+	for ind, name := range checksumArgsNames {
+		switch name {
+		case "stageName", "stageDependencies":
+			continue
+		}
+
+		inputs = append(inputs, stage.DependenciesInput{Name: name, Value: checksumArgs[ind]})
+	}
+	inputs = append(inputs, stg.GetDependenciesInputs()...)
+
+	phase.StagesExplanations.AddStageExplanation(img.GetName(), &StageExplanation{
+		Name:   string(stg.Name()),
+		Digest: stg.GetDigest(),
+		Exists: foundSuitableStage,
+		Inputs: inputs,
+	})
+
+	return nil
+}
+
+func (phase *ExplainPhase) Clone() Phase {
+	u := *phase
+	u.BuildPhase = phase.BuildPhase.Clone().(*BuildPhase)
+	return &u
+}
+
+type StageExplanationDiff struct {
+	Name       string
+	FromDigest string
+	ToDigest   string
+
+	FromUnavailableReason string
+	ToUnavailableReason   string
+
+	Inputs []*DependenciesInputDiff
+}
+
+func (diff *StageExplanationDiff) IsDigestChanged() bool {
+	return diff.FromDigest != diff.ToDigest
+}
+
+const (
+	DependenciesInputChanged DependenciesInputDiffType = "changed"
+	DependenciesInputAdded   DependenciesInputDiffType = "added"
+	DependenciesInputRemoved DependenciesInputDiffType = "removed"
+)
+
+type DependenciesInputDiffType string
+
+type DependenciesInputDiff struct {
+	Type      DependenciesInputDiffType
+	Name      string
+	FromValue string
+	ToValue   string
+}
+
+// DiffStagesExplanations compares stages explanations of the same image calculated for the different commits.
+// Stages are matched by name, inputs with unique names are compared by value,
+// other inputs are compared as sets of values.
+func DiffStagesExplanations(from, to []*StageExplanation) []*StageExplanationDiff {
+	fromByName := map[string]*StageExplanation{}
+	for _, explanation := range from {
+		fromByName[explanation.Name] = explanation
+	}
+
+	toByName := map[string]*StageExplanation{}
+	for _, explanation := range to {
+		toByName[explanation.Name] = explanation
+	}
+
+	var stagesNames []string
+	for _, stageName := range stage.AllStages {
+		if fromByName[string(stageName)] != nil || toByName[string(stageName)] != nil {
+			stagesNames = append(stagesNames, string(stageName))
+		}
+	}
+
+	// stages of the staged Dockerfile image have dynamic names
+	for _, explanations := range [][]*StageExplanation{from, to} {
+		for _, explanation := range explanations {
+			if !util.IsStringsContainValue(stagesNames, explanation.Name) {
+				stagesNames = append(stagesNames, explanation.Name)
+			}
+		}
+	}
+
+	var res []*StageExplanationDiff
+	for _, stageName := range stagesNames {
+		diff := &StageExplanationDiff{Name: stageName}
+
+		var fromInputs, toInputs []stage.DependenciesInput
+		if explanation := fromByName[stageName]; explanation != nil {
+			diff.FromDigest = explanation.Digest
+			diff.FromUnavailableReason = explanation.UnavailableReason
+			fromInputs = explanation.Inputs
+		}
+		if explanation := toByName[stageName]; explanation != nil {
+			diff.ToDigest = explanation.Digest
+			diff.ToUnavailableReason = explanation.UnavailableReason
+			toInputs = explanation.Inputs
+		}
+
+		diff.Inputs = diffDependenciesInputs(fromInputs, toInputs)
+		res = append(res, diff)
+	}
+
+	return res
+}
+
+func diffDependenciesInputs(from, to []stage.DependenciesInput) []*DependenciesInputDiff {
+	var names []string
+	fromValuesByName := map[string][]string{}
+	toValuesByName := map[string][]string{}
+
+	for _, input := range from {
+		if _, hasKey := fromValuesByName[input.Name]; !hasKey {
+			names = append(names, input.Name)
+		}
+		fromValuesByName[input.Name] = append(fromValuesByName[input.Name], input.Value)
+	}
+
+	for _, input := range to {
+		_, inFrom := fromValuesByName[input.Name]
+		_, inTo := toValuesByName[input.Name]
+		if !inFrom && !inTo {
+			names = append(names, input.Name)
+		}
+		toValuesByName[input.Name] = append(toValuesByName[input.Name], input.Value)
+	}
+
+	var res []*DependenciesInputDiff
+	for _, name := range names {
+		fromValues := fromValuesByName[name]
+		toValues := toValuesByName[name]
+
+		if len(fromValues) == 1 && len(toValues) == 1 {
+			if fromValues[0] != toValues[0] {
+				res = append(res, &DependenciesInputDiff{Type: DependenciesInputChanged, Name: name, FromValue: fromValues[0], ToValue: toValues[0]})
+			}
+			continue
+		}
+
+		for _, value := range subtractValues(fromValues, toValues) {
+			res = append(res, &DependenciesInputDiff{Type: DependenciesInputRemoved, Name: name, FromValue: value})
+		}
+
+		for _, value := range subtractValues(toValues, fromValues) {
+			res = append(res, &DependenciesInputDiff{Type: DependenciesInputAdded, Name: name, ToValue: value})
+		}
+	}
+
+	return res
+}
+
+// subtractValues returns values from a which are not in b (taking duplicates into account)
+func subtractValues(a, b []string) []string {
+	counts := map[string]int{}
+	for _, value := range b {
+		counts[value]++
+	}
+
+	var res []string
+	for _, value := range a {
+		if counts[value] > 0 {
+			counts[value]--
+			continue
+		}
+		res = append(res, value)
+	}
+
+	return res
+}
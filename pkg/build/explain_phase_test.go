@@ -0,0 +1,144 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/build/stage"
+)
+
+func TestDiffDependenciesInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     []stage.DependenciesInput
+		to       []stage.DependenciesInput
+		expected []*DependenciesInputDiff
+	}{
+		{
+			name:     "same inputs",
+			from:     []stage.DependenciesInput{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "echo"}},
+			to:       []stage.DependenciesInput{{Name: "from", Value: "alpine"}, {Name: "shell", Value: "echo"}},
+			expected: nil,
+		},
+		{
+			name: "changed unique input",
+			from: []stage.DependenciesInput{{Name: "from", Value: "alpine:3.12"}},
+			to:   []stage.DependenciesInput{{Name: "from", Value: "alpine:3.13"}},
+			expected: []*DependenciesInputDiff{
+				{Type: DependenciesInputChanged, Name: "from", FromValue: "alpine:3.12", ToValue: "alpine:3.13"},
+			},
+		},
+		{
+			name: "added and removed inputs",
+			from: []stage.DependenciesInput{{Name: "from", Value: "alpine"}, {Name: "user", Value: "root"}},
+			to:   []stage.DependenciesInput{{Name: "from", Value: "alpine"}, {Name: "workdir", Value: "/app"}},
+			expected: []*DependenciesInputDiff{
+				{Type: DependenciesInputRemoved, Name: "user", FromValue: "root"},
+				{Type: DependenciesInputAdded, Name: "workdir", ToValue: "/app"},
+			},
+		},
+		{
+			name: "multiple values are compared as sets",
+			from: []stage.DependenciesInput{{Name: "shell", Value: "a"}, {Name: "shell", Value: "b"}, {Name: "shell", Value: "b"}},
+			to:   []stage.DependenciesInput{{Name: "shell", Value: "b"}, {Name: "shell", Value: "c"}, {Name: "shell", Value: "a"}},
+			expected: []*DependenciesInputDiff{
+				{Type: DependenciesInputRemoved, Name: "shell", FromValue: "b"},
+				{Type: DependenciesInputAdded, Name: "shell", ToValue: "c"},
+			},
+		},
+		{
+			name:     "reordered multiple values",
+			from:     []stage.DependenciesInput{{Name: "shell", Value: "a"}, {Name: "shell", Value: "b"}},
+			to:       []stage.DependenciesInput{{Name: "shell", Value: "b"}, {Name: "shell", Value: "a"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := diffDependenciesInputs(tt.from, tt.to); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("expected %s, got %s", formatDependenciesInputDiffs(tt.expected), formatDependenciesInputDiffs(res))
+			}
+		})
+	}
+}
+
+func TestDiffStagesExplanations(t *testing.T) {
+	from := []*StageExplanation{
+		{Name: string(stage.From), Digest: "from-1", Inputs: []stage.DependenciesInput{{Name: "from", Value: "alpine"}}},
+		{Name: string(stage.Install), Digest: "install-1", Inputs: []stage.DependenciesInput{{Name: "shell", Value: "apk add git"}}},
+		{Name: string(stage.Setup), UnavailableReason: "previous stage does not exist in the repo"},
+	}
+	to := []*StageExplanation{
+		{Name: string(stage.Setup), Digest: "setup-2"},
+		{Name: string(stage.Install), Digest: "install-2", Inputs: []stage.DependenciesInput{{Name: "shell", Value: "apk add curl"}}},
+		{Name: string(stage.From), Digest: "from-1", Inputs: []stage.DependenciesInput{{Name: "from", Value: "alpine"}}},
+		{Name: "dockerfile-stage-builder", Digest: "builder-2"},
+	}
+
+	diffs := DiffStagesExplanations(from, to)
+
+	var names []string
+	for _, diff := range diffs {
+		names = append(names, diff.Name)
+	}
+	if expected := []string{string(stage.From), string(stage.Install), string(stage.Setup), "dockerfile-stage-builder"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected stages %v in the stages order, got %v", expected, names)
+	}
+
+	tests := []struct {
+		name                  string
+		diff                  *StageExplanationDiff
+		expectedDigestChanged bool
+		expectedInputs        []*DependenciesInputDiff
+	}{
+		{
+			name:                  "same stage",
+			diff:                  diffs[0],
+			expectedDigestChanged: false,
+		},
+		{
+			name:                  "stage with the changed input",
+			diff:                  diffs[1],
+			expectedDigestChanged: true,
+			expectedInputs: []*DependenciesInputDiff{
+				{Type: DependenciesInputChanged, Name: "shell", FromValue: "apk add git", ToValue: "apk add curl"},
+			},
+		},
+		{
+			name:                  "stage unavailable in one of the commits",
+			diff:                  diffs[2],
+			expectedDigestChanged: true,
+		},
+		{
+			name:                  "stage existing in one of the commits",
+			diff:                  diffs[3],
+			expectedDigestChanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.diff.IsDigestChanged() != tt.expectedDigestChanged {
+				t.Errorf("expected digest changed %v, got %v (%s -> %s)", tt.expectedDigestChanged, tt.diff.IsDigestChanged(), tt.diff.FromDigest, tt.diff.ToDigest)
+			}
+
+			if !reflect.DeepEqual(tt.diff.Inputs, tt.expectedInputs) {
+				t.Errorf("expected inputs %s, got %s", formatDependenciesInputDiffs(tt.expectedInputs), formatDependenciesInputDiffs(tt.diff.Inputs))
+			}
+		})
+	}
+
+	if diffs[2].FromUnavailableReason == "" || diffs[2].ToUnavailableReason != "" {
+		t.Errorf("unexpected unavailable reasons %q -> %q", diffs[2].FromUnavailableReason, diffs[2].ToUnavailableReason)
+	}
+}
+
+func formatDependenciesInputDiffs(diffs []*DependenciesInputDiff) []DependenciesInputDiff {
+	var res []DependenciesInputDiff
+	for _, diff := range diffs {
+		res = append(res, *diff)
+	}
+
+	return res
+}
@@ -28,6 +28,7 @@ type Image struct {
 	name string
 
 	baseImageName      string
+	baseImageReference string
 	baseImageImageName string
 	baseImageRepoId    string
 
@@ -140,6 +141,7 @@ func (i *Image) SetupBaseImage(c *Conveyor) {
 	} else {
 		i.baseImageType = ImageFromRegistryAsBaseImage
 		i.baseImage = c.GetOrCreateStageImage(nil, i.baseImageName)
+		i.baseImage.SetPlatform(c.platform)
 	}
 }
 
@@ -150,11 +152,11 @@ func (i *Image) GetBaseImage() *container_runtime.StageImage {
 func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	switch i.baseImageType {
 	case ImageFromRegistryAsBaseImage:
-		containerRuntime := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime)
-
-		if inspect, err := containerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
+		if inspect, err := c.ContainerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
 			return fmt.Errorf("unable to inspect local image %s: %s", i.baseImage.Name(), err)
-		} else if inspect != nil {
+		} else if inspect != nil && c.platform == "" {
+			// NOTE: base image id from the registry corresponds to the default platform,
+			// NOTE: so the image for the specific platform is always pulled
 			// TODO: do not use container_runtime.StageImage for base image
 			i.baseImage.SetStageDescription(&image.StageDescription{
 				StageID: nil, // this is not a stage actually, TODO
@@ -178,12 +180,16 @@ func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 				options.Style(style.Highlight())
 			}).
 			DoError(func() error {
+				if i.baseImageReference != "" && i.baseImageReference != i.baseImage.Name() {
+					return stage.PullMirroredBaseImage(ctx, c.ContainerRuntime, i.baseImage.Name(), i.baseImageReference)
+				}
+
 				return c.ContainerRuntime.PullImageFromRegistry(ctx, &container_runtime.DockerImage{Image: i.baseImage})
 			}); err != nil {
 			return err
 		}
 
-		if inspect, err := containerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
+		if inspect, err := c.ContainerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
 			return fmt.Errorf("unable to inspect local image %s: %s", i.baseImage.Name(), err)
 		} else if inspect == nil {
 			return fmt.Errorf("unable to inspect local image %s after successful pull: image is not exists", i.baseImage.Name())
@@ -220,14 +226,16 @@ func (i *Image) getFromBaseImageIdFromRegistry(ctx context.Context, c *Conveyor,
 		return "", c.GetBaseImagesRepoErrCache(baseImageName)
 	}
 
+	reference := c.GetBaseImageReference(baseImageName)
+
 	var fetchedBaseRepoImage *image.Info
-	processMsg := fmt.Sprintf("Trying to get from base image id from registry (%s)", baseImageName)
+	processMsg := fmt.Sprintf("Trying to get from base image id from registry (%s)", reference)
 	if err := logboek.Context(ctx).Info().LogProcessInline(processMsg).DoError(func() error {
 		var fetchImageIdErr error
-		fetchedBaseRepoImage, fetchImageIdErr = docker_registry.API().GetRepoImage(ctx, baseImageName)
+		fetchedBaseRepoImage, fetchImageIdErr = docker_registry.API().GetRepoImage(ctx, reference)
 		if fetchImageIdErr != nil {
 			c.SetBaseImagesRepoErrCache(baseImageName, fetchImageIdErr)
-			return fmt.Errorf("can not get base image id from registry (%s): %s", baseImageName, fetchImageIdErr)
+			return fmt.Errorf("can not get base image id from registry (%s): %s", reference, fetchImageIdErr)
 		}
 
 		return nil
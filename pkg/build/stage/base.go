@@ -80,16 +80,23 @@ func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
 }
 
 type BaseStage struct {
-	name             StageName
-	imageName        string
-	digest           string
-	contentDigest    string
-	image            container_runtime.ImageInterface
-	gitMappings      []*GitMapping
-	imageTmpDir      string
-	containerWerfDir string
-	configMounts     []*config.Mount
-	projectName      string
+	name               StageName
+	imageName          string
+	digest             string
+	contentDigest      string
+	dependenciesInputs []DependenciesInput
+	image              container_runtime.ImageInterface
+	gitMappings        []*GitMapping
+	imageTmpDir        string
+	containerWerfDir   string
+	configMounts       []*config.Mount
+	projectName        string
+}
+
+// DependenciesInput is a named value the stage dependencies are calculated from
+type DependenciesInput struct {
+	Name  string
+	Value string
 }
 
 func (s *BaseStage) LogDetailedName() string {
@@ -121,6 +128,15 @@ func (s *BaseStage) GetNextStageDependencies(_ context.Context, _ Conveyor) (str
 	return "", nil
 }
 
+// GetDependenciesInputs returns inputs of the last GetDependencies call
+func (s *BaseStage) GetDependenciesInputs() []DependenciesInput {
+	return s.dependenciesInputs
+}
+
+func (s *BaseStage) setDependenciesInputs(inputs []DependenciesInput) {
+	s.dependenciesInputs = inputs
+}
+
 func (s *BaseStage) getNextStageGitDependencies(ctx context.Context, c Conveyor) (string, error) {
 	var args []string
 	for _, gitMapping := range s.gitMappings {
@@ -28,7 +28,10 @@ type BeforeInstallStage struct {
 }
 
 func (s *BeforeInstallStage) GetDependencies(ctx context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
-	return s.builder.BeforeInstallChecksum(ctx), nil
+	builderChecksum := s.builder.BeforeInstallChecksum(ctx)
+	s.setDependenciesInputs([]DependenciesInput{{Name: "builder checksum", Value: builderChecksum}})
+
+	return builderChecksum, nil
 }
 
 func (s *BeforeInstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/werf/werf/pkg/build/import_server"
+	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/storage"
 )
 
@@ -22,6 +23,8 @@ type Conveyor interface {
 	GetImageIDForImageStage(imageName, stageName string) string
 
 	GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error)
+	GetBaseImageReference(baseImageName string) string
+	GetContainerRuntime() container_runtime.ContainerRuntime
 	GetLocalGitRepoVirtualMergeOptions() VirtualMergeOptions
 
 	GetProjectRepoCommit(ctx context.Context) (string, error)
@@ -2,6 +2,7 @@ package stage
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
 	"github.com/werf/werf/pkg/config"
@@ -43,6 +44,24 @@ func (s *DockerInstructionsStage) GetDependencies(_ context.Context, _ Conveyor,
 	args = append(args, s.instructions.User)
 	args = append(args, s.instructions.HealthCheck)
 
+	var inputs []DependenciesInput
+	for _, volume := range s.instructions.Volume {
+		inputs = append(inputs, DependenciesInput{Name: "volume", Value: volume})
+	}
+	for _, expose := range s.instructions.Expose {
+		inputs = append(inputs, DependenciesInput{Name: "expose", Value: expose})
+	}
+	inputs = append(inputs, mapToDependenciesInputs("env", s.instructions.Env)...)
+	inputs = append(inputs, mapToDependenciesInputs("label", s.instructions.Label)...)
+	inputs = append(inputs,
+		DependenciesInput{Name: "cmd", Value: s.instructions.Cmd},
+		DependenciesInput{Name: "entrypoint", Value: s.instructions.Entrypoint},
+		DependenciesInput{Name: "workdir", Value: s.instructions.Workdir},
+		DependenciesInput{Name: "user", Value: s.instructions.User},
+		DependenciesInput{Name: "healthCheck", Value: s.instructions.HealthCheck},
+	)
+	s.setDependenciesInputs(inputs)
+
 	return util.Sha256Hash(args...), nil
 }
 
@@ -60,6 +79,15 @@ func mapToSortedArgs(h map[string]string) (result []string) {
 	return
 }
 
+func mapToDependenciesInputs(namePrefix string, h map[string]string) (result []DependenciesInput) {
+	args := mapToSortedArgs(h)
+	for i := 0; i < len(args); i += 2 {
+		result = append(result, DependenciesInput{Name: fmt.Sprintf("%s %s", namePrefix, args[i]), Value: args[i+1]})
+	}
+
+	return
+}
+
 func (s *DockerInstructionsStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
 	if err := s.BaseStage.PrepareImage(ctx, c, prevBuiltImage, image); err != nil {
 		return err
@@ -20,6 +20,7 @@ import (
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/style"
 
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/git_repo"
@@ -32,15 +33,25 @@ import (
 )
 
 func GenerateDockerfileStage(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
-	return newDockerfileStage(dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
+	return newDockerfileStage(Dockerfile, dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
 }
 
-func newDockerfileStage(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
+// GenerateDockerfileStageForDockerStage creates the stage which builds the single target stage of the Dockerfile.
+// The stage based on the previous werf stage is built from the previous stage image instead of the Dockerfile base stage.
+func GenerateDockerfileStageForDockerStage(name StageName, dockerfileData []byte, basedOnPrevStage bool, dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
+	s := newDockerfileStage(name, dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
+	s.dockerfileData = dockerfileData
+	s.basedOnPrevStage = basedOnPrevStage
+
+	return s
+}
+
+func newDockerfileStage(name StageName, dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
 	s := &DockerfileStage{}
 	s.DockerRunArgs = dockerRunArgs
 	s.DockerStages = dockerStages
 	s.ContextChecksum = contextChecksum
-	s.BaseStage = newBaseStage(Dockerfile, baseStageOptions)
+	s.BaseStage = newBaseStage(name, baseStageOptions)
 
 	return s
 }
@@ -50,9 +61,25 @@ type DockerfileStage struct {
 	*DockerStages
 	*ContextChecksum
 	*BaseStage
+
+	dockerfileData   []byte
+	basedOnPrevStage bool
+}
+
+// DockerfileStageName returns the name of the stage which builds the intermediate Dockerfile stage
+func DockerfileStageName(dockerStageName string) StageName {
+	return StageName(fmt.Sprintf("%s-%s", Dockerfile, dockerStageName))
+}
+
+func IsDockerfileStageName(name string) bool {
+	return name == string(Dockerfile) || strings.HasPrefix(name, string(Dockerfile)+"-")
 }
 
-func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFile []string, buildArgs map[string]interface{}, addHost []string, network, ssh string) *DockerRunArgs {
+func (s *DockerfileStage) IsBasedOnPrevStage() bool {
+	return s.basedOnPrevStage
+}
+
+func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFile []string, buildArgs map[string]interface{}, addHost []string, network, ssh string, secrets []*config.DockerfileSecret, platform string) *DockerRunArgs {
 	return &DockerRunArgs{
 		dockerfilePath: dockerfilePath,
 		target:         target,
@@ -62,6 +89,8 @@ func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFile []s
 		addHost:        addHost,
 		network:        network,
 		ssh:            ssh,
+		secrets:        secrets,
+		platform:       platform,
 	}
 }
 
@@ -74,6 +103,8 @@ type DockerRunArgs struct {
 	addHost        []string
 	network        string
 	ssh            string
+	secrets        []*config.DockerfileSecret
+	platform       string
 }
 
 func (d *DockerRunArgs) contextAddFileRelativeToProject() []string {
@@ -256,14 +287,107 @@ type ContextChecksum struct {
 	mainStatusResult *status.Result
 }
 
+type dockerfileRunMount struct {
+	Type     string
+	ID       string
+	Required bool
+	Target   string
+	From     string
+	Sharing  string
+}
+
+// ValidateRunMounts checks that required secrets of RUN --mount=type=secret instructions are passed to the build
+// and RUN --mount=type=cache instructions have target, do not use the same cache with different sharing modes and do not refer to the later stages.
+// Stages after the target stage are not checked since they are not built.
+func (s *DockerfileStage) ValidateRunMounts() error {
+	if !isDockerfileRunMountSupported {
+		if len(s.secrets) != 0 {
+			return fmt.Errorf("secrets cannot be used: werf is built without RUN --mount instructions support (dfrunmount build tag)")
+		}
+
+		return nil
+	}
+
+	cacheSharingByID := map[string]string{}
+	for stageIndex, stage := range s.dockerStages[:s.dockerTargetStageIndex+1] {
+		for _, cmd := range stage.Commands {
+			runCommand, ok := cmd.(*instructions.RunCommand)
+			if !ok {
+				continue
+			}
+
+			for _, mount := range getDockerfileRunMounts(runCommand) {
+				switch mount.Type {
+				case "secret":
+					if mount.Required && !s.hasSecret(mount.ID) {
+						return fmt.Errorf("%s: required secret %q is not specified in the image secrets", runCommand.String(), mount.ID)
+					}
+				case "cache":
+					if err := s.validateCacheRunMount(stageIndex, mount, cacheSharingByID); err != nil {
+						return fmt.Errorf("%s: %s", runCommand.String(), err)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *DockerfileStage) validateCacheRunMount(stageIndex int, mount *dockerfileRunMount, cacheSharingByID map[string]string) error {
+	if mount.Target == "" {
+		return fmt.Errorf("cache mount target is required")
+	}
+
+	if sharing, ok := cacheSharingByID[mount.ID]; ok && sharing != mount.Sharing {
+		return fmt.Errorf("cache %q is already used with sharing=%s, got sharing=%s", mount.ID, sharing, mount.Sharing)
+	}
+	cacheSharingByID[mount.ID] = mount.Sharing
+
+	if mount.From != "" {
+		for laterStageIndex := stageIndex; laterStageIndex < len(s.dockerStages); laterStageIndex++ {
+			if strings.EqualFold(s.dockerStages[laterStageIndex].Name, mount.From) {
+				return fmt.Errorf("cache mount from stage %q which is not defined before the current stage", mount.From)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsBuildkitRequired returns true when the Dockerfile cannot be built by the legacy docker builder
+func (s *DockerfileStage) IsBuildkitRequired() bool {
+	if len(s.secrets) != 0 {
+		return true
+	}
+
+	for _, stage := range s.dockerStages[:s.dockerTargetStageIndex+1] {
+		for _, cmd := range stage.Commands {
+			if runCommand, ok := cmd.(*instructions.RunCommand); ok && len(getDockerfileRunMounts(runCommand)) != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *DockerfileStage) hasSecret(id string) bool {
+	for _, secret := range s.secrets {
+		if secret.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
 type dockerfileInstructionInterface interface {
 	String() string
 	Name() string
 }
 
-func (s *DockerfileStage) FetchDependencies(ctx context.Context, _ Conveyor, cr container_runtime.ContainerRuntime) error {
-	containerRuntime := cr.(*container_runtime.LocalDockerServerRuntime)
-
+func (s *DockerfileStage) FetchDependencies(ctx context.Context, c Conveyor, containerRuntime container_runtime.ContainerRuntime) error {
 outerLoop:
 	for ind, stage := range s.dockerStages {
 		for relatedStageIndex, relatedStage := range s.dockerStages {
@@ -299,10 +423,12 @@ outerLoop:
 			return inspect.Config.OnBuild, nil
 		}
 
+		reference := c.GetBaseImageReference(resolvedBaseName)
+
 		getBaseImageOnBuildRemotely := func() ([]string, error) {
-			configFile, err := docker_registry.API().GetRepoImageConfigFile(ctx, resolvedBaseName)
+			configFile, err := docker_registry.API().GetRepoImageConfigFile(ctx, reference)
 			if err != nil {
-				return nil, fmt.Errorf("get repo image %s config file failed: %s", resolvedBaseName, err)
+				return nil, fmt.Errorf("get repo image %s config file failed: %s", reference, err)
 			}
 
 			return configFile.Config.OnBuild, nil
@@ -317,8 +443,8 @@ outerLoop:
 				if isUnsupportedMediaTypeError(getRemotelyErr) {
 					logboek.Context(ctx).Warn().LogF("WARNING: Could not get base image manifest from local docker and from docker registry: %s\n", getRemotelyErr)
 					logboek.Context(ctx).Warn().LogLn("WARNING: The base image pulling is necessary for calculating digest of image correctly\n")
-					if err := logboek.Context(ctx).Default().LogProcess("Pulling base image %s", resolvedBaseName).DoError(func() error {
-						return containerRuntime.PullImage(ctx, resolvedBaseName)
+					if err := logboek.Context(ctx).Default().LogProcess("Pulling base image %s", reference).DoError(func() error {
+						return PullMirroredBaseImage(ctx, containerRuntime, resolvedBaseName, reference)
 					}); err != nil {
 						return err
 					}
@@ -338,6 +464,61 @@ outerLoop:
 	return nil
 }
 
+// fetchMirroredBaseImages pulls the base images which have mirrors and are missing locally,
+// so that the builder uses the local images tagged by the canonical names instead of pulling them from the original registries
+func (s *DockerfileStage) fetchMirroredBaseImages(ctx context.Context, c Conveyor, containerRuntime container_runtime.ContainerRuntime) error {
+outerLoop:
+	for ind, stage := range s.dockerStages {
+		for relatedStageIndex, relatedStage := range s.dockerStages {
+			if ind == relatedStageIndex {
+				continue
+			}
+
+			if stage.BaseName == relatedStage.Name {
+				continue outerLoop
+			}
+		}
+
+		resolvedBaseName, err := s.ShlexProcessWordWithMetaArgs(stage.BaseName)
+		if err != nil {
+			return err
+		}
+
+		reference := c.GetBaseImageReference(resolvedBaseName)
+		if resolvedBaseName == "scratch" || reference == resolvedBaseName {
+			continue
+		}
+
+		if inspect, err := containerRuntime.GetImageInspect(ctx, resolvedBaseName); err != nil {
+			return fmt.Errorf("unable to inspect local image %s: %s", resolvedBaseName, err)
+		} else if inspect != nil {
+			continue
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Pulling base image %s", reference).DoError(func() error {
+			return PullMirroredBaseImage(ctx, containerRuntime, resolvedBaseName, reference)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PullMirroredBaseImage pulls the base image by the mirror reference and tags it by the canonical base image name
+func PullMirroredBaseImage(ctx context.Context, containerRuntime container_runtime.ContainerRuntime, baseImageName, reference string) error {
+	if err := containerRuntime.PullImage(ctx, reference); err != nil {
+		return err
+	}
+
+	if reference == baseImageName {
+		return nil
+	}
+
+	mirroredImage := &container_runtime.DockerImage{Image: container_runtime.NewStageImage(nil, reference, containerRuntime)}
+	return containerRuntime.RenameImage(ctx, mirroredImage, baseImageName, false)
+}
+
 func isUnsupportedMediaTypeError(err error) bool {
 	return strings.Contains(err.Error(), "unsupported MediaType")
 }
@@ -394,6 +575,11 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, _ Conveyor, _, _
 			}
 
 			if stage.BaseName == relatedStage.Name {
+				// the base stage is the previous werf stage, which digest is already taken into account
+				if ind == s.dockerTargetStageIndex && s.basedOnPrevStage {
+					continue
+				}
+
 				stagesDependencies[ind] = append(stagesDependencies[ind], stagesDependencies[relatedStageIndex]...)
 				stagesDependencies[ind] = append(stagesDependencies[ind], stagesOnBuildDependencies[relatedStageIndex]...)
 			}
@@ -418,6 +604,12 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, _ Conveyor, _, _
 		logboek.Context(ctx).LogLn(dockerfileStageDependencies)
 	}
 
+	var inputs []DependenciesInput
+	for _, dependency := range dockerfileStageDependencies {
+		inputs = append(inputs, DependenciesInput{Name: "dockerfile instruction dependency", Value: dependency})
+	}
+	s.setDependenciesInputs(inputs)
+
 	return util.Sha256Hash(dockerfileStageDependencies...), nil
 }
 
@@ -599,12 +791,36 @@ func (s *DockerfileStage) dockerfileOnBuildInstructionDependencies(ctx context.C
 	return []string{expression}, onBuildDependencies, nil
 }
 
-func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img container_runtime.ImageInterface) error {
+func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, img container_runtime.ImageInterface) error {
+	if err := s.fetchMirroredBaseImages(ctx, c, c.GetContainerRuntime()); err != nil {
+		return err
+	}
+
 	archivePath, err := s.prepareContextArchive(ctx)
 	if err != nil {
 		return err
 	}
 
+	if s.basedOnPrevStage {
+		dockerfilePath := s.dockerfilePath
+		if dockerfilePath == "" {
+			dockerfilePath = "Dockerfile"
+		}
+
+		dockerfileData := s.dockerfileWithBaseImage(prevBuiltImage.Name())
+		if err := logboek.Context(ctx).Debug().LogProcess("Replace %s in build context archive %s", dockerfilePath, archivePath).DoError(func() error {
+			destinationArchivePath, err := context_manager.ReplaceFileInContextArchive(ctx, archivePath, dockerfilePath, dockerfileData)
+			if err != nil {
+				return err
+			}
+
+			archivePath = destinationArchivePath
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
 	commit, err := s.localGitRepo.HeadCommit(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get head commit %s", err)
@@ -614,6 +830,10 @@ func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img c
 	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=%s", image.WerfProjectRepoCommitLabel, commit))
 	img.DockerfileImageBuilder().SetFilePathToStdin(archivePath)
 
+	if s.IsBuildkitRequired() {
+		img.DockerfileImageBuilder().EnableBuildkit()
+	}
+
 	if giterminism_inspector.DevMode {
 		img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=true", image.WerfDevLabel))
 	}
@@ -621,6 +841,27 @@ func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img c
 	return nil
 }
 
+// dockerfileWithBaseImage replaces FROM instruction of the target Dockerfile stage with the specified image
+func (s *DockerfileStage) dockerfileWithBaseImage(baseImageName string) []byte {
+	targetStage := s.dockerStages[s.dockerTargetStageIndex]
+
+	from := fmt.Sprintf("FROM %s", baseImageName)
+	if targetStage.Name != "" {
+		from += fmt.Sprintf(" AS %s", targetStage.Name)
+	}
+
+	lines := strings.Split(string(s.dockerfileData), "\n")
+	startLine := targetStage.Location[0].Start.Line
+	endLine := targetStage.Location[len(targetStage.Location)-1].End.Line
+
+	var result []string
+	result = append(result, lines[:startLine-1]...)
+	result = append(result, from)
+	result = append(result, lines[endLine:]...)
+
+	return []byte(strings.Join(result, "\n"))
+}
+
 func (s *DockerfileStage) prepareContextArchive(ctx context.Context) (string, error) {
 	commit, err := s.localGitRepo.HeadCommit(ctx)
 	if err != nil {
@@ -685,9 +926,30 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 		result = append(result, fmt.Sprintf("--ssh=%s", s.ssh))
 	}
 
+	for _, secret := range s.secrets {
+		if secret.Env != "" {
+			result = append(result, fmt.Sprintf("--secret=id=%s,env=%s", secret.ID, secret.Env))
+		} else {
+			result = append(result, fmt.Sprintf("--secret=id=%s,src=%s", secret.ID, secretSrcAbsolutePath(s.projectPath, secret.Src)))
+		}
+	}
+
+	if s.platform != "" {
+		result = append(result, fmt.Sprintf("--platform=%s", s.platform))
+	}
+
 	return result
 }
 
+// secretSrcAbsolutePath resolves the secret src relative to the project directory unless it is absolute or starts with ~
+func secretSrcAbsolutePath(projectPath, src string) string {
+	if filepath.IsAbs(src) || strings.HasPrefix(src, "~") {
+		return util.ExpandPath(src)
+	}
+
+	return filepath.Join(projectPath, src)
+}
+
 func (s *DockerfileStage) calculateFilesChecksum(ctx context.Context, wildcards []string, dockerfileLine string) (string, error) {
 	var checksum string
 	var err error
@@ -0,0 +1,13 @@
+// +build !dfrunmount
+
+package stage
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+const isDockerfileRunMountSupported = false
+
+func getDockerfileRunMounts(_ *instructions.RunCommand) []*dockerfileRunMount {
+	return nil
+}
@@ -0,0 +1,45 @@
+// +build dfrunmount
+
+package stage
+
+import (
+	"path"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+const isDockerfileRunMountSupported = true
+
+func getDockerfileRunMounts(c *instructions.RunCommand) []*dockerfileRunMount {
+	var mounts []*dockerfileRunMount
+	for _, m := range instructions.GetMounts(c) {
+		mount := &dockerfileRunMount{Type: m.Type, Required: m.Required, Target: m.Target, From: m.From}
+
+		switch m.Type {
+		case instructions.MountTypeSecret:
+			switch {
+			case m.Source != "":
+				mount.ID = m.Source
+			case m.CacheID != "":
+				mount.ID = m.CacheID
+			default:
+				mount.ID = path.Base(m.Target)
+			}
+		case instructions.MountTypeCache:
+			// the same defaults as BuildKit uses
+			mount.ID = m.CacheID
+			if mount.ID == "" {
+				mount.ID = path.Clean(m.Target)
+			}
+
+			mount.Sharing = m.CacheSharing
+			if mount.Sharing == "" {
+				mount.Sharing = instructions.MountSharingShared
+			}
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts
+}
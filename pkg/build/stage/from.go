@@ -48,25 +48,33 @@ type FromStage struct {
 
 func (s *FromStage) GetDependencies(_ context.Context, c Conveyor, prevImage, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
+	var inputs []DependenciesInput
 
 	if s.cacheVersion != "" {
 		args = append(args, s.cacheVersion)
+		inputs = append(inputs, DependenciesInput{Name: "fromCacheVersion", Value: s.cacheVersion})
 	}
 
 	if s.baseImageRepoIdOrNone != "" {
 		args = append(args, s.baseImageRepoIdOrNone)
+		inputs = append(inputs, DependenciesInput{Name: "base image id", Value: s.baseImageRepoIdOrNone})
 	}
 
 	for _, mount := range s.configMounts {
 		args = append(args, filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
+		inputs = append(inputs, DependenciesInput{Name: fmt.Sprintf("mount %s", path.Clean(mount.To)), Value: fmt.Sprintf("%s %s", mount.Type, filepath.ToSlash(filepath.Clean(mount.From)))})
 	}
 
 	if s.fromImageOrArtifactImageName != "" {
 		args = append(args, c.GetImageContentDigest(s.fromImageOrArtifactImageName))
+		inputs = append(inputs, DependenciesInput{Name: fmt.Sprintf("image %s content digest", s.fromImageOrArtifactImageName), Value: c.GetImageContentDigest(s.fromImageOrArtifactImageName)})
 	} else {
 		args = append(args, prevImage.Name())
+		inputs = append(inputs, DependenciesInput{Name: "base image", Value: prevImage.Name()})
 	}
 
+	s.setDependenciesInputs(inputs)
+
 	return util.Sha256Hash(args...), nil
 }
 
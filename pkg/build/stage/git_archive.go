@@ -49,11 +49,14 @@ func (s *GitArchiveStage) SelectSuitableStage(ctx context.Context, c Conveyor, s
 
 func (s *GitArchiveStage) GetDependencies(_ context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
+	var inputs []DependenciesInput
 	for _, gitMapping := range s.gitMappings {
 		args = append(args, gitMapping.GetParamshash())
+		inputs = append(inputs, DependenciesInput{Name: fmt.Sprintf("git mapping %s params checksum", gitMapping.Name), Value: gitMapping.GetParamshash()})
 	}
 
 	sort.Strings(args)
+	s.setDependenciesInputs(inputs)
 
 	return util.Sha256Hash(args...), nil
 }
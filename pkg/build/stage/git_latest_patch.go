@@ -52,6 +52,7 @@ func (s *GitLatestPatchStage) IsEmpty(ctx context.Context, c Conveyor, prevBuilt
 
 func (s *GitLatestPatchStage) GetDependencies(ctx context.Context, c Conveyor, _, prevBuiltImage container_runtime.ImageInterface) (string, error) {
 	var args []string
+	var inputs []DependenciesInput
 
 	for _, gitMapping := range s.gitMappings {
 		patchContent, err := gitMapping.GetPatchContent(ctx, c, prevBuiltImage)
@@ -60,8 +61,11 @@ func (s *GitLatestPatchStage) GetDependencies(ctx context.Context, c Conveyor, _
 		}
 
 		args = append(args, patchContent)
+		inputs = append(inputs, DependenciesInput{Name: fmt.Sprintf("git mapping %s patch checksum", gitMapping.Name), Value: util.Sha256Hash(patchContent)})
 	}
 
+	s.setDependenciesInputs(inputs)
+
 	return util.Sha256Hash(args...), nil
 }
 
@@ -51,8 +51,13 @@ type ImportsStage struct {
 	imports []*config.Import
 }
 
+func (s *ImportsStage) GetImports() []*config.Import {
+	return s.imports
+}
+
 func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
 	var args []string
+	var inputs []DependenciesInput
 
 	for ind, elm := range s.imports {
 		var sourceChecksum string
@@ -67,8 +72,17 @@ func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 		args = append(args, sourceChecksum)
 		args = append(args, elm.To)
 		args = append(args, elm.Group, elm.Owner)
+
+		inputs = append(inputs,
+			DependenciesInput{Name: fmt.Sprintf("import %d source checksum", ind), Value: sourceChecksum},
+			DependenciesInput{Name: fmt.Sprintf("import %d to", ind), Value: elm.To},
+			DependenciesInput{Name: fmt.Sprintf("import %d group", ind), Value: elm.Group},
+			DependenciesInput{Name: fmt.Sprintf("import %d owner", ind), Value: elm.Owner},
+		)
 	}
 
+	s.setDependenciesInputs(inputs)
+
 	return util.Sha256Hash(args...), nil
 }
 
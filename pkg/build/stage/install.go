@@ -29,12 +29,15 @@ type InstallStage struct {
 }
 
 func (s *InstallStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
-	stageDependenciesChecksum, err := s.getStageDependenciesChecksum(ctx, c, Install)
+	stageDependenciesInputs, stageDependenciesChecksum, err := s.getStageDependenciesInputsAndChecksum(ctx, c, Install)
 	if err != nil {
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.InstallChecksum(ctx), stageDependenciesChecksum), nil
+	builderChecksum := s.builder.InstallChecksum(ctx)
+	s.setDependenciesInputs(append([]DependenciesInput{{Name: "builder checksum", Value: builderChecksum}}, stageDependenciesInputs...))
+
+	return util.Sha256Hash(builderChecksum, stageDependenciesChecksum), nil
 }
 
 func (s *InstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -16,6 +16,7 @@ type Interface interface {
 	FetchDependencies(ctx context.Context, c Conveyor, cr container_runtime.ContainerRuntime) error
 	GetDependencies(ctx context.Context, c Conveyor, prevImage container_runtime.ImageInterface, prevBuiltImage container_runtime.ImageInterface) (string, error)
 	GetNextStageDependencies(ctx context.Context, c Conveyor) (string, error)
+	GetDependenciesInputs() []DependenciesInput
 
 	PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error
 
@@ -29,12 +29,15 @@ type SetupStage struct {
 }
 
 func (s *SetupStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
-	stageDependenciesChecksum, err := s.getStageDependenciesChecksum(ctx, c, Setup)
+	stageDependenciesInputs, stageDependenciesChecksum, err := s.getStageDependenciesInputsAndChecksum(ctx, c, Setup)
 	if err != nil {
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.SetupChecksum(ctx), stageDependenciesChecksum), nil
+	builderChecksum := s.builder.SetupChecksum(ctx)
+	s.setDependenciesInputs(append([]DependenciesInput{{Name: "builder checksum", Value: builderChecksum}}, stageDependenciesInputs...))
+
+	return util.Sha256Hash(builderChecksum, stageDependenciesChecksum), nil
 }
 
 func (s *SetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
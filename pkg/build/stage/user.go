@@ -2,6 +2,7 @@ package stage
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/werf/logboek"
@@ -37,11 +38,17 @@ type UserStage struct {
 }
 
 func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor, name StageName) (string, error) {
+	_, checksum, err := s.getStageDependenciesInputsAndChecksum(ctx, c, name)
+	return checksum, err
+}
+
+func (s *UserStage) getStageDependenciesInputsAndChecksum(ctx context.Context, c Conveyor, name StageName) ([]DependenciesInput, string, error) {
+	var inputs []DependenciesInput
 	var args []string
 	for _, gitMapping := range s.gitMappings {
 		checksum, err := gitMapping.StageDependenciesChecksum(ctx, c, name)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
 
 		if debugUserStageChecksum() {
@@ -52,9 +59,10 @@ func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor
 		}
 
 		args = append(args, checksum)
+		inputs = append(inputs, DependenciesInput{Name: fmt.Sprintf("git mapping %s stageDependencies checksum", gitMapping.Name), Value: checksum})
 	}
 
-	return util.Sha256Hash(args...), nil
+	return inputs, util.Sha256Hash(args...), nil
 }
 
 func debugUserStageChecksum() bool {
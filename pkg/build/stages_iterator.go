@@ -48,7 +48,7 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 	}
 	logboek.Context(ctx).Debug().LogF("%s stage is empty: %v\n", stg.LogDetailedName(), isEmpty)
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if isStageBasedOnPrevStage(stg) {
 		if iterator.PrevStage == nil {
 			panic(fmt.Sprintf("expected PrevStage to be set for image %q stage %s!", img.GetName(), stg.Name()))
 		}
@@ -78,3 +78,12 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 
 	return nil
 }
+
+// isStageBasedOnPrevStage returns false for the first image stage, which is based on the base image
+func isStageBasedOnPrevStage(stg stage.Interface) bool {
+	if dockerfileStage, ok := stg.(*stage.DockerfileStage); ok {
+		return dockerfileStage.IsBasedOnPrevStage()
+	}
+
+	return stg.Name() != stage.From
+}
@@ -0,0 +1,66 @@
+package buildkit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/werf/logboek"
+)
+
+var (
+	buildctlBinPath string
+	buildkitAddress string
+)
+
+func Init(ctx context.Context, address string) error {
+	binPath := "buildctl"
+	if v := os.Getenv("WERF_BUILDCTL_BIN_PATH"); v != "" {
+		binPath = v
+	}
+
+	path, err := exec.LookPath(binPath)
+	if err != nil {
+		return fmt.Errorf("unable to find buildctl binary %q: %s", binPath, err)
+	}
+
+	buildctlBinPath = path
+	buildkitAddress = address
+
+	logboek.Context(ctx).Debug().LogF("Using buildctl %s (address %q)\n", buildctlBinPath, buildkitAddress)
+
+	return nil
+}
+
+func CliBuild_LiveOutput(ctx context.Context, args ...string) error {
+	if buildctlBinPath == "" {
+		return fmt.Errorf("buildkit is not initialized")
+	}
+
+	var cmdArgs []string
+	if buildkitAddress != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--addr=%s", buildkitAddress))
+	}
+	cmdArgs = append(cmdArgs, "build", "--progress=plain")
+	cmdArgs = append(cmdArgs, args...)
+
+	if debugBuildctlCommand() {
+		fmt.Printf("Buildctl command:\n%s %s\n", buildctlBinPath, strings.Join(cmdArgs, " "))
+	}
+
+	cmd := exec.CommandContext(ctx, buildctlBinPath, cmdArgs...)
+	cmd.Stdout = logboek.Context(ctx).ProxyOutStream()
+	cmd.Stderr = logboek.Context(ctx).ProxyErrStream()
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buildctl build failed: %s", err)
+	}
+
+	return nil
+}
+
+func debugBuildctlCommand() bool {
+	return os.Getenv("WERF_DEBUG_BUILDCTL_COMMAND") == "1"
+}
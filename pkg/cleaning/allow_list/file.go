@@ -0,0 +1,78 @@
+package allow_list
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// File is the set of the images used in Kubernetes, the file is exported from the clusters
+// which are not reachable from the cleanup job and merged into the images used in Kubernetes during cleanup.
+type File struct {
+	CreatedAt time.Time
+	Contexts  []string
+	Images    []string
+}
+
+func NewFile(contexts, images []string) *File {
+	imagesSet := map[string]bool{}
+	for _, image := range images {
+		imagesSet[image] = true
+	}
+
+	file := &File{
+		CreatedAt: time.Now().UTC(),
+		Contexts:  contexts,
+	}
+
+	for image := range imagesSet {
+		file.Images = append(file.Images, image)
+	}
+	sort.Strings(file.Images)
+
+	return file
+}
+
+func ReadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read allow list file %s: %s", path, err)
+	}
+
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("unable to parse allow list file %s: %s", path, err)
+	}
+
+	return file, nil
+}
+
+func ReadFilesImages(paths []string) ([]string, error) {
+	var images []string
+	for _, path := range paths {
+		file, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, file.Images...)
+	}
+
+	return images, nil
+}
+
+func (file *File) Save(path string) error {
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal allow list: %s", err)
+	}
+	data = append(data, []byte("\n")...)
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("unable to write allow list file %s: %s", path, err)
+	}
+
+	return nil
+}
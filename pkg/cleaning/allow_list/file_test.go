@@ -0,0 +1,114 @@
+package allow_list
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-allow-list-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tests := []struct {
+		name             string
+		content          string
+		expectedContexts []string
+		expectedImages   []string
+		expectedErr      string
+	}{
+		{
+			name:             "exported file",
+			content:          `{"CreatedAt": "2021-01-25T12:00:00Z", "Contexts": ["production"], "Images": ["repo:a", "repo:b"]}`,
+			expectedContexts: []string{"production"},
+			expectedImages:   []string{"repo:a", "repo:b"},
+		},
+		{
+			name:           "file without contexts",
+			content:        `{"Images": ["repo:a"]}`,
+			expectedImages: []string{"repo:a"},
+		},
+		{
+			name:        "invalid file",
+			content:     `repo:a`,
+			expectedErr: "unable to parse allow list file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, "allow-list.json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := ReadFile(path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(file.Contexts, tt.expectedContexts) {
+				t.Errorf("expected contexts %v, got %v", tt.expectedContexts, file.Contexts)
+			}
+			if !reflect.DeepEqual(file.Images, tt.expectedImages) {
+				t.Errorf("expected images %v, got %v", tt.expectedImages, file.Images)
+			}
+		})
+	}
+
+	if _, err := ReadFile(filepath.Join(tmpDir, "absent.json")); err == nil || !strings.Contains(err.Error(), "unable to read allow list file") {
+		t.Errorf("expected read error of the absent file, got %v", err)
+	}
+}
+
+func TestFileSaveAndReadFilesImages(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-allow-list-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	productionPath := filepath.Join(tmpDir, "production.json")
+	if err := NewFile([]string{"production"}, []string{"repo:b", "repo:a", "repo:b"}).Save(productionPath); err != nil {
+		t.Fatal(err)
+	}
+
+	stagingPath := filepath.Join(tmpDir, "staging.json")
+	if err := NewFile([]string{"staging"}, []string{"repo:c"}).Save(stagingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ReadFile(productionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"repo:a", "repo:b"}; !reflect.DeepEqual(file.Images, expected) {
+		t.Errorf("expected the unique sorted images %v, got %v", expected, file.Images)
+	}
+	if file.CreatedAt.IsZero() {
+		t.Error("expected the creation time to be saved")
+	}
+
+	images, err := ReadFilesImages([]string{productionPath, stagingPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"repo:a", "repo:b", "repo:c"}; !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images of all files %v, got %v", expected, images)
+	}
+
+	if _, err := ReadFilesImages([]string{productionPath, filepath.Join(tmpDir, "absent.json")}); err == nil {
+		t.Error("expected error of the absent file")
+	}
+}
@@ -0,0 +1,98 @@
+package allow_list
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// getHelmReleasesRevisionsImages gets images from the manifests of the last revisions of each helm release,
+// so that the images needed to rollback the release are kept
+func getHelmReleasesRevisionsImages(kubernetesClient kubernetes.Interface, kubernetesNamespace string, revisions int) ([]string, error) {
+	releases, err := queryHelmReleases(kubernetesClient, kubernetesNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	releasesByName := map[string][]*release.Release{}
+	for _, rel := range releases {
+		key := fmt.Sprintf("%s/%s", rel.Namespace, rel.Name)
+		releasesByName[key] = append(releasesByName[key], rel)
+	}
+
+	var images []string
+	for _, releaseRevisions := range releasesByName {
+		sort.Slice(releaseRevisions, func(i, j int) bool {
+			return releaseRevisions[i].Version > releaseRevisions[j].Version
+		})
+
+		if len(releaseRevisions) > revisions {
+			releaseRevisions = releaseRevisions[:revisions]
+		}
+
+		for _, rel := range releaseRevisions {
+			manifests := []string{rel.Manifest}
+			for _, hook := range rel.Hooks {
+				manifests = append(manifests, hook.Manifest)
+			}
+
+			for _, manifest := range manifests {
+				for _, doc := range releaseutil.SplitManifests(manifest) {
+					var obj interface{}
+					if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+						return nil, fmt.Errorf("unable to parse release %s/%s revision %d manifest: %s", rel.Namespace, rel.Name, rel.Version, err)
+					}
+
+					images = append(images, findImageFields(obj)...)
+				}
+			}
+		}
+	}
+
+	return images, nil
+}
+
+func queryHelmReleases(kubernetesClient kubernetes.Interface, kubernetesNamespace string) ([]*release.Release, error) {
+	var storageDriver driver.Driver
+	switch os.Getenv("HELM_DRIVER") {
+	case "configmap", "configmaps":
+		storageDriver = driver.NewConfigMaps(kubernetesClient.CoreV1().ConfigMaps(kubernetesNamespace))
+	default:
+		storageDriver = driver.NewSecrets(kubernetesClient.CoreV1().Secrets(kubernetesNamespace))
+	}
+
+	releases, err := storageDriver.Query(map[string]string{"owner": "helm"})
+	if err == driver.ErrReleaseNotFound {
+		return nil, nil
+	}
+
+	return releases, err
+}
+
+// findImageFields collects the string values of all image fields in the object
+func findImageFields(obj interface{}) []string {
+	var images []string
+
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if image, ok := v.(string); ok && k == "image" && image != "" {
+				images = append(images, image)
+			} else {
+				images = append(images, findImageFields(v)...)
+			}
+		}
+	case []interface{}:
+		for _, v := range value {
+			images = append(images, findImageFields(v)...)
+		}
+	}
+
+	return images
+}
@@ -6,9 +6,13 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
 )
 
-func DeployedDockerImages(kubernetesClient kubernetes.Interface, kubernetesNamespace string) ([]string, error) {
+func DeployedDockerImages(ctx context.Context, kubernetesClient kubernetes.Interface, kubernetesNamespace string, allowList config.MetaCleanupAllowList) ([]string, error) {
 	var deployedDockerImages []string
 
 	images, err := getPodsImages(kubernetesClient, kubernetesNamespace)
@@ -67,6 +71,28 @@ func DeployedDockerImages(kubernetesClient kubernetes.Interface, kubernetesNames
 
 	deployedDockerImages = append(deployedDockerImages, images...)
 
+	for _, resource := range allowList.KubernetesResources {
+		images, err = getKubernetesResourcesImages(kubernetesClient, kubernetesNamespace, resource)
+		if isKubernetesResourceNotFoundError(err) {
+			// the custom resource definition may be installed only in some of the clusters
+			logboek.Context(ctx).Warn().LogF("WARNING: Skip %s: the resource is not found in the cluster: %s\n", resource.String(), err)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot get %s images: %s", resource.String(), err)
+		}
+
+		deployedDockerImages = append(deployedDockerImages, images...)
+	}
+
+	if allowList.HelmReleaseRevisions != 0 {
+		images, err = getHelmReleasesRevisionsImages(kubernetesClient, kubernetesNamespace, allowList.HelmReleaseRevisions)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get helm releases revisions images: %s", err)
+		}
+
+		deployedDockerImages = append(deployedDockerImages, images...)
+	}
+
 	return deployedDockerImages, nil
 }
 
@@ -0,0 +1,81 @@
+package allow_list
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+// getKubernetesResourcesImages gets images of the arbitrary resources by the JSONPath image fields,
+// the resources are requested as unstructured objects, so that custom resources are supported
+func getKubernetesResourcesImages(kubernetesClient kubernetes.Interface, kubernetesNamespace string, resource *config.MetaCleanupAllowListKubernetesResource) ([]string, error) {
+	var imagePaths []*jsonpath.JSONPath
+	for _, imagePath := range resource.ImagePaths {
+		jp := jsonpath.New(resource.String()).AllowMissingKeys(true)
+		if err := jp.Parse(imagePath); err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %s", imagePath, err)
+		}
+
+		imagePaths = append(imagePaths, jp)
+	}
+
+	raw, err := kubernetesClient.Discovery().RESTClient().Get().AbsPath(kubernetesResourcePath(resource, kubernetesNamespace)).Do(context.Background()).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Items []interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %s list: %s", resource.String(), err)
+	}
+
+	var images []string
+	for _, item := range list.Items {
+		for _, jp := range imagePaths {
+			results, err := jp.FindResults(item)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find images by JSONPath: %s", err)
+			}
+
+			for _, result := range results {
+				for _, value := range result {
+					if image, ok := value.Interface().(string); ok && image != "" {
+						images = append(images, image)
+					}
+				}
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// isKubernetesResourceNotFoundError reports whether the resource is not served by the cluster, e.g. the custom resource definition is not installed
+func isKubernetesResourceNotFoundError(err error) bool {
+	return err != nil && (apierrors.IsNotFound(err) || meta.IsNoMatchError(err))
+}
+
+func kubernetesResourcePath(resource *config.MetaCleanupAllowListKubernetesResource, kubernetesNamespace string) string {
+	var parts []string
+	if resource.Group == "" {
+		parts = append(parts, "/api", resource.Version)
+	} else {
+		parts = append(parts, "/apis", resource.Group, resource.Version)
+	}
+
+	if kubernetesNamespace != "" {
+		parts = append(parts, "namespaces", kubernetesNamespace)
+	}
+
+	return path.Join(append(parts, resource.Resource)...)
+}
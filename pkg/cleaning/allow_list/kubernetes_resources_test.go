@@ -0,0 +1,138 @@
+package allow_list
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func TestGetKubernetesResourcesImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apis/example.com/v1/namespaces/app/workers":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"items": [
+				{"spec": {"image": "repo:worker-1", "sidecars": [{"image": "repo:sidecar"}, {"image": ""}]}},
+				{"spec": {"image": "repo:worker-2"}}
+			]}`))
+		case "/apis/missing.example.com/v1/namespaces/app/workers":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"items": []}`))
+		}
+	}))
+	defer server.Close()
+
+	kubernetesClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workersResource := &config.MetaCleanupAllowListKubernetesResource{
+		Group:      "example.com",
+		Version:    "v1",
+		Resource:   "workers",
+		ImagePaths: []string{"{.spec.image}", "{.spec.sidecars[*].image}"},
+	}
+
+	tests := []struct {
+		name                string
+		resource            *config.MetaCleanupAllowListKubernetesResource
+		kubernetesNamespace string
+		expectedImages      []string
+		expectedNotFound    bool
+	}{
+		{
+			name:                "images by the image paths",
+			resource:            workersResource,
+			kubernetesNamespace: "app",
+			expectedImages:      []string{"repo:worker-1", "repo:sidecar", "repo:worker-2"},
+		},
+		{
+			name: "resource of the not installed custom resource definition",
+			resource: &config.MetaCleanupAllowListKubernetesResource{
+				Group:      "missing.example.com",
+				Version:    "v1",
+				Resource:   "workers",
+				ImagePaths: []string{"{.spec.image}"},
+			},
+			kubernetesNamespace: "app",
+			expectedNotFound:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := getKubernetesResourcesImages(kubernetesClient, tt.kubernetesNamespace, tt.resource)
+			if tt.expectedNotFound {
+				if !isKubernetesResourceNotFoundError(err) {
+					t.Fatalf("expected not found error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(images, tt.expectedImages) {
+				t.Errorf("expected images %v, got %v", tt.expectedImages, images)
+			}
+		})
+	}
+
+	t.Run("deployed images skip the resource of the not installed custom resource definition", func(t *testing.T) {
+		allowList := config.MetaCleanupAllowList{
+			KubernetesResources: []*config.MetaCleanupAllowListKubernetesResource{
+				{Group: "missing.example.com", Version: "v1", Resource: "workers", ImagePaths: []string{"{.spec.image}"}},
+				workersResource,
+			},
+		}
+
+		images, err := DeployedDockerImages(context.Background(), kubernetesClient, "app", allowList)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := []string{"repo:worker-1", "repo:sidecar", "repo:worker-2"}; !reflect.DeepEqual(images, expected) {
+			t.Errorf("expected images %v, got %v", expected, images)
+		}
+	})
+}
+
+func TestKubernetesResourcePath(t *testing.T) {
+	tests := []struct {
+		name                string
+		resource            *config.MetaCleanupAllowListKubernetesResource
+		kubernetesNamespace string
+		expected            string
+	}{
+		{
+			name:                "core group",
+			resource:            &config.MetaCleanupAllowListKubernetesResource{Version: "v1", Resource: "pods"},
+			kubernetesNamespace: "app",
+			expected:            "/api/v1/namespaces/app/pods",
+		},
+		{
+			name:     "named group in all namespaces",
+			resource: &config.MetaCleanupAllowListKubernetesResource{Group: "example.com", Version: "v1", Resource: "workers"},
+			expected: "/apis/example.com/v1/workers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resourcePath := kubernetesResourcePath(tt.resource, tt.kubernetesNamespace); resourcePath != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, resourcePath)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/werf/werf/pkg/cleaning/allow_list"
 	"github.com/werf/werf/pkg/cleaning/git_history_based_cleanup"
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
@@ -34,29 +35,72 @@ type CleanupOptions struct {
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
 	DryRun                                  bool
+
+	// ImagesCleanupOptions override or extend the keep policies of GitHistoryBasedCleanupOptions for the particular images
+	ImagesCleanupOptions map[string]*config.ImageCleanup
+
+	// AllowListImages are the images used in Kubernetes clusters which are not reachable from the cleanup,
+	// the images are exported from such clusters into the allow list files
+	AllowListImages []string
+
+	// ArchiveStagesStorage receives the stages and the image metadata before deletion,
+	// the archived stages can be restored into the stages storage by werf stages restore
+	ArchiveStagesStorage storage.StagesStorage
+	ContainerRuntime     container_runtime.ContainerRuntime
+
+	// Plan collects the items which would be deleted, nothing is deleted while the plan is being created
+	Plan *CleanupPlan
+	// ApprovedPlan restricts deletion to the items of the reviewed plan which still qualify for deletion
+	ApprovedPlan *CleanupPlan
 }
 
 func Cleanup(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options CleanupOptions) error {
-	return newCleanupManager(projectName, storageManager, options).run(ctx)
+	if options.ApprovedPlan != nil {
+		if err := options.ApprovedPlan.Validate(projectName, storageManager.StagesStorage.String()); err != nil {
+			return err
+		}
+	}
+
+	m := newCleanupManager(projectName, storageManager, options)
+	err := m.run(ctx)
+
+	if m.DryRun {
+		return err
+	}
+
+	return m.audit.saveAfterRun(ctx, projectName, storageManager.StagesStorage, err)
 }
 
 func newCleanupManager(projectName string, storageManager *manager.StorageManager, options CleanupOptions) *cleanupManager {
+	plan := options.Plan
+	if plan == nil {
+		plan = NewCleanupPlan(projectName, storageManager.StagesStorage.String())
+	}
+
 	return &cleanupManager{
+		plan:                                    plan,
+		audit:                                   NewAuditLog("werf cleanup", ""),
 		ProjectName:                             projectName,
 		StorageManager:                          storageManager,
 		ImageNameList:                           options.ImageNameList,
-		DryRun:                                  options.DryRun,
+		DryRun:                                  options.DryRun || options.Plan != nil,
+		ApprovedPlan:                            options.ApprovedPlan,
 		LocalGit:                                options.LocalGit,
 		KubernetesContextClients:                options.KubernetesContextClients,
 		KubernetesNamespaceRestrictionByContext: options.KubernetesNamespaceRestrictionByContext,
 		WithoutKube:                             options.WithoutKube,
+		AllowListImages:                         options.AllowListImages,
 		GitHistoryBasedCleanupOptions:           options.GitHistoryBasedCleanupOptions,
+		ImagesCleanupOptions:                    options.ImagesCleanupOptions,
 		KeepStagesBuiltWithinLastNHours:         options.KeepStagesBuiltWithinLastNHours,
+		ArchiveStagesStorage:                    options.ArchiveStagesStorage,
+		ContainerRuntime:                        options.ContainerRuntime,
 	}
 }
 
 type cleanupManager struct {
-	stages []*image.StageDescription
+	stages        []*image.StageDescription
+	imagesIndexes []*image.ImageIndexDescription
 
 	imageNameStageIDCommitList            map[string]map[string][]string
 	imageNameStageIDCommitListToCleanup   map[string]map[string][]string
@@ -67,6 +111,14 @@ type cleanupManager struct {
 	checksumSourceImageIDs       map[string][]string
 	nonexistentImportMetadataIDs []string
 
+	plan      *CleanupPlan
+	planMutex sync.Mutex
+	audit     *AuditLog
+
+	deployedDockerImages  []string
+	deployedStageIDs      map[string]bool
+	quotaExceededStageIDs map[string]bool
+
 	ProjectName                             string
 	StorageManager                          *manager.StorageManager
 	ImageNameList                           []string
@@ -74,9 +126,14 @@ type cleanupManager struct {
 	KubernetesContextClients                []*kube.ContextClient
 	KubernetesNamespaceRestrictionByContext map[string]string
 	WithoutKube                             bool
+	AllowListImages                         []string
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
+	ImagesCleanupOptions                    map[string]*config.ImageCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
+	ArchiveStagesStorage                    storage.StagesStorage
+	ContainerRuntime                        container_runtime.ContainerRuntime
 	DryRun                                  bool
+	ApprovedPlan                            *CleanupPlan
 }
 
 type GitRepo interface {
@@ -91,6 +148,12 @@ func (m *cleanupManager) init(ctx context.Context) error {
 		return err
 	}
 
+	if err := logboek.Context(ctx).Info().LogProcess("Fetching images indexes").DoError(func() error {
+		return m.initImagesIndexes(ctx)
+	}); err != nil {
+		return err
+	}
+
 	if err := logboek.Context(ctx).Info().LogProcess("Fetching metadata").DoError(func() error {
 		return m.initImagesMetadata(ctx)
 	}); err != nil {
@@ -226,7 +289,7 @@ func (m *cleanupManager) run(ctx context.Context) error {
 	}
 
 	if m.LocalGit != nil {
-		if !m.WithoutKube {
+		if !m.WithoutKube || len(m.AllowListImages) != 0 {
 			if err := logboek.Context(ctx).LogProcess("Skipping tags that are being used in Kubernetes").DoError(func() error {
 				return m.skipStageIDsThatAreUsedInKubernetes(ctx)
 			}); err != nil {
@@ -250,15 +313,53 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if m.GitHistoryBasedCleanupOptions.ForeignTags != nil {
+		if err := logboek.Context(ctx).LogProcess("Cleanup foreign tags").DoError(func() error {
+			return m.cleanupForeignTags(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.ApprovedPlan != nil {
+		m.handleNotQualifiedApprovedPlanItems(ctx)
+	}
+
 	return nil
 }
 
+// planItem adds the item into the cleanup plan and checks whether the item deletion is approved
+func (m *cleanupManager) planItem(ctx context.Context, item *CleanupPlanItem) bool {
+	m.planMutex.Lock()
+	defer m.planMutex.Unlock()
+
+	m.plan.addItem(item)
+
+	if m.ApprovedPlan != nil && !m.ApprovedPlan.HasItem(item) {
+		logboek.Context(ctx).Warn().LogF("WARNING: Skipping %s deletion: not found in the cleanup plan\n", item.String())
+		return false
+	}
+
+	m.audit.expect(item)
+
+	return true
+}
+
+func (m *cleanupManager) handleNotQualifiedApprovedPlanItems(ctx context.Context) {
+	for _, item := range m.ApprovedPlan.Items {
+		if !m.plan.HasItem(item) {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping %s deletion: no longer qualifies for deletion\n", item.String())
+		}
+	}
+}
+
 func (m *cleanupManager) skipStageIDsThatAreUsedInKubernetes(ctx context.Context) error {
 	deployedDockerImagesNames, err := m.deployedDockerImagesNames(ctx)
 	if err != nil {
 		return err
 	}
 
+	m.deployedStageIDs = map[string]bool{}
 	skippedDeployedImages := map[string]bool{}
 	for imageName, stageIDCommitList := range m.imageNameStageIDCommitListToCleanup {
 	Loop:
@@ -267,6 +368,7 @@ func (m *cleanupManager) skipStageIDsThatAreUsedInKubernetes(ctx context.Context
 			for _, deployedDockerImageName := range deployedDockerImagesNames {
 				if deployedDockerImageName == dockerImageName {
 					m.keepImageNameStageID(imageName, stageID)
+					m.deployedStageIDs[stageID] = true
 
 					if !skippedDeployedImages[stageID] {
 						logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
@@ -284,11 +386,28 @@ func (m *cleanupManager) skipStageIDsThatAreUsedInKubernetes(ctx context.Context
 }
 
 func (m *cleanupManager) deployedDockerImagesNames(ctx context.Context) ([]string, error) {
-	var deployedDockerImagesNames []string
+	if m.deployedDockerImages == nil {
+		deployedDockerImagesNames, err := m.fetchDeployedDockerImagesNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		m.deployedDockerImages = deployedDockerImagesNames
+	}
+
+	return m.deployedDockerImages, nil
+}
+
+func (m *cleanupManager) fetchDeployedDockerImagesNames(ctx context.Context) ([]string, error) {
+	deployedDockerImagesNames := append([]string{}, m.AllowListImages...)
+	if m.WithoutKube {
+		return deployedDockerImagesNames, nil
+	}
+
 	for _, contextClient := range m.KubernetesContextClients {
 		if err := logboek.Context(ctx).LogProcessInline("Getting deployed docker images (context %s)", contextClient.ContextName).
 			DoError(func() error {
-				kubernetesClientDeployedDockerImagesNames, err := allow_list.DeployedDockerImages(contextClient.Client, m.KubernetesNamespaceRestrictionByContext[contextClient.ContextName])
+				kubernetesClientDeployedDockerImagesNames, err := allow_list.DeployedDockerImages(ctx, contextClient.Client, m.KubernetesNamespaceRestrictionByContext[contextClient.ContextName], m.GitHistoryBasedCleanupOptions.AllowList)
 				if err != nil {
 					return fmt.Errorf("cannot get deployed imagesStageList: %s", err)
 				}
@@ -310,17 +429,52 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 		return fmt.Errorf("git plain open failed: %s", err)
 	}
 
-	var referencesToScan []*git_history_based_cleanup.ReferenceToScan
-	if err := logboek.Context(ctx).Default().LogProcess("Preparing references to scan").DoError(func() error {
-		referencesToScan, err = git_history_based_cleanup.ReferencesToScan(ctx, gitRepository, m.GitHistoryBasedCleanupOptions.KeepPolicies)
+	// the images with the same keep policies share the references to scan
+	var allKeepPolicies []*config.MetaCleanupKeepPolicy
+	referencesToScanByPolicies := map[string][]*git_history_based_cleanup.ReferenceToScan{}
+	prepareReferencesToScan := func(processMsg string, keepPolicies []*config.MetaCleanupKeepPolicy) error {
+		policiesKey := keepPoliciesKey(keepPolicies)
+		if _, ok := referencesToScanByPolicies[policiesKey]; ok {
+			return nil
+		}
+
+		return logboek.Context(ctx).Default().LogProcess(processMsg).DoError(func() error {
+			referencesToScan, err := git_history_based_cleanup.ReferencesToScan(ctx, gitRepository, keepPolicies)
+			if err != nil {
+				return err
+			}
+
+			referencesToScanByPolicies[policiesKey] = referencesToScan
+			allKeepPolicies = append(allKeepPolicies, keepPolicies...)
+
+			return nil
+		})
+	}
+
+	if err := prepareReferencesToScan("Preparing references to scan", m.GitHistoryBasedCleanupOptions.KeepPolicies); err != nil {
 		return err
-	}); err != nil {
+	}
+
+	for _, imageName := range m.ImageNameList {
+		if m.ImagesCleanupOptions[imageName] == nil {
+			continue
+		}
+
+		if err := prepareReferencesToScan(fmt.Sprintf("Preparing references to scan for image %s", logging.ImageLogName(imageName, false)), m.imageKeepPolicies(imageName)); err != nil {
+			return err
+		}
+	}
+
+	scanStatePath := git_history_based_cleanup.ScanStatePath(m.ProjectName, m.StorageManager.StagesStorage.String())
+	scanState, err := git_history_based_cleanup.LoadScanState(ctx, scanStatePath, allKeepPolicies)
+	if err != nil {
 		return err
 	}
 
 	for imageName, stageIDCommitList := range m.imageNameStageIDCommitListToCleanup {
 		var reachedStageIDs []string
 		var hitStageIDCommitList map[string][]string
+		referencesToScan := referencesToScanByPolicies[keepPoliciesKey(m.imageKeepPolicies(imageName))]
 		if err := logboek.Context(ctx).LogProcess(logging.ImageLogProcessName(imageName, false)).DoError(func() error {
 			if logboek.Context(ctx).Streams().Width() > 90 {
 				m.printStageIDCommitListTable(ctx, imageName)
@@ -328,7 +482,7 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 
 			if err := logboek.Context(ctx).LogProcess("Scanning git references history").DoError(func() error {
 				if len(stageIDCommitList) != 0 {
-					reachedStageIDs, hitStageIDCommitList, err = git_history_based_cleanup.ScanReferencesHistory(ctx, gitRepository, referencesToScan, stageIDCommitList)
+					reachedStageIDs, hitStageIDCommitList, err = git_history_based_cleanup.ScanReferencesHistory(ctx, gitRepository, referencesToScan, stageIDCommitList, scanState.ImageScan(imageName, stageIDCommitList))
 				} else {
 					logboek.Context(ctx).LogLn("Scanning stopped due to nothing to seek")
 				}
@@ -370,9 +524,36 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 		return err
 	}
 
+	if err := scanState.Save(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func (m *cleanupManager) imageKeepPolicies(imageName string) []*config.MetaCleanupKeepPolicy {
+	imageCleanup := m.ImagesCleanupOptions[imageName]
+	if imageCleanup == nil {
+		return m.GitHistoryBasedCleanupOptions.KeepPolicies
+	}
+
+	if imageCleanup.KeepPoliciesStrategy == config.ExtendKeepPoliciesStrategy {
+		keepPolicies := append([]*config.MetaCleanupKeepPolicy{}, git_history_based_cleanup.KeepPoliciesOrDefault(m.GitHistoryBasedCleanupOptions.KeepPolicies)...)
+		return append(keepPolicies, imageCleanup.KeepPolicies...)
+	}
+
+	return imageCleanup.KeepPolicies
+}
+
+func keepPoliciesKey(keepPolicies []*config.MetaCleanupKeepPolicy) string {
+	var parts []string
+	for _, policy := range keepPolicies {
+		parts = append(parts, policy.String())
+	}
+
+	return strings.Join(parts, "\n")
+}
+
 func (m *cleanupManager) printStageIDCommitListTable(ctx context.Context, imageName string) {
 	stageIDCommitList := m.imageNameStageIDCommitListToCleanup[imageName]
 
@@ -436,10 +617,59 @@ func (m *cleanupManager) deleteStages(ctx context.Context, stages []*image.Stage
 		},
 	}
 
-	return deleteStages(ctx, m.StorageManager, m.DryRun, deleteStageOptions, stages)
+	var stagesToDelete []*image.StageDescription
+	for _, stageDesc := range stages {
+		if m.planItem(ctx, m.newStagePlanItem(stageDesc)) {
+			stagesToDelete = append(stagesToDelete, stageDesc)
+		}
+	}
+
+	if m.ArchiveStagesStorage != nil && !m.DryRun {
+		stagesToDelete = m.archiveStages(ctx, stagesToDelete)
+	}
+
+	return deleteStages(ctx, m.StorageManager, m.audit, m.DryRun, deleteStageOptions, stagesToDelete)
+}
+
+// archiveStages copies the stages into the archive stages storage and returns the archived stages,
+// the stage which has not been archived is not deleted
+func (m *cleanupManager) archiveStages(ctx context.Context, stages []*image.StageDescription) []*image.StageDescription {
+	var archivedStages []*image.StageDescription
+	for _, stageDesc := range stages {
+		if err := m.StorageManager.StagesStorageManager.ArchiveStage(ctx, stageDesc, m.ArchiveStagesStorage, m.ContainerRuntime); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping stage %s deletion: unable to archive stage: %s\n", stageDesc.StageID.String(), err)
+			continue
+		}
+
+		archivedStages = append(archivedStages, stageDesc)
+	}
+
+	return archivedStages
 }
 
-func deleteStages(ctx context.Context, storageManager *manager.StorageManager, dryRun bool, deleteStageOptions manager.ForEachDeleteStageOptions, stages []*image.StageDescription) error {
+func (m *cleanupManager) newStagePlanItem(stageDesc *image.StageDescription) *CleanupPlanItem {
+	createdAt := stageDesc.Info.GetCreatedAt().UTC()
+	item := &CleanupPlanItem{
+		Kind:            CleanupPlanItemStage,
+		Reason:          CleanupPlanReasonNotUsed,
+		Details:         "not used in Kubernetes and not related to the image metadata kept by the git history-based cleanup",
+		StageID:         stageDesc.Info.Tag,
+		DockerImageName: stageDesc.Info.Name,
+		StageCreatedAt:  &createdAt,
+	}
+
+	if m.quotaExceededStageIDs[stageDesc.Info.Tag] {
+		item.Reason = CleanupPlanReasonQuota
+		item.Details = "exceeds the cleanup quotas"
+	} else if m.KeepStagesBuiltWithinLastNHours != 0 {
+		item.Reason = CleanupPlanReasonAge
+		item.Details = fmt.Sprintf("%s, built more than %d hours ago", item.Details, m.KeepStagesBuiltWithinLastNHours)
+	}
+
+	return item
+}
+
+func deleteStages(ctx context.Context, storageManager *manager.StorageManager, audit *AuditLog, dryRun bool, deleteStageOptions manager.ForEachDeleteStageOptions, stages []*image.StageDescription) error {
 	if dryRun {
 		for _, stageDesc := range stages {
 			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageDesc.Info.Tag)
@@ -459,6 +689,7 @@ func deleteStages(ctx context.Context, storageManager *manager.StorageManager, d
 			return nil
 		}
 
+		audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemStage, StageID: stageDesc.Info.Tag, DockerImageName: stageDesc.Info.Name})
 		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageDesc.Info.Tag)
 
 		return nil
@@ -495,7 +726,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 		if len(stageIDCommitListToDelete) != 0 {
 			if err := logboek.Context(ctx).Info().LogProcess("Cleaning up metadata").DoError(func() error {
-				return m.deleteImageMetadata(ctx, imageName, stageIDCommitListToDelete, true)
+				return m.deleteImageMetadata(ctx, imageName, stageIDCommitListToDelete, CleanupPlanReasonGitHistoryPolicy, true)
 			}); err != nil {
 				return err
 			}
@@ -504,7 +735,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 	if len(nonexistentStageIDCommitList) != 0 {
 		if err := logboek.Context(ctx).Info().LogProcess("Deleting metadata for nonexistent stageIDs").DoError(func() error {
-			return m.deleteImageMetadata(ctx, imageName, nonexistentStageIDCommitList, false)
+			return m.deleteImageMetadata(ctx, imageName, nonexistentStageIDCommitList, CleanupPlanReasonNonexistentStage, false)
 		}); err != nil {
 			return err
 		}
@@ -512,7 +743,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 	if len(stageIDNonexistentCommitList) != 0 {
 		if err := logboek.Context(ctx).Info().LogProcess("Deleting metadata for nonexistent commits").DoError(func() error {
-			return m.deleteImageMetadata(ctx, imageName, stageIDNonexistentCommitList, false)
+			return m.deleteImageMetadata(ctx, imageName, stageIDNonexistentCommitList, CleanupPlanReasonNonexistentCommit, false)
 		}); err != nil {
 			return err
 		}
@@ -528,7 +759,7 @@ func (m *cleanupManager) cleanupNonexistentImageMetadata(ctx context.Context) er
 
 	return logboek.Context(ctx).Default().LogProcess("Deleting metadata for nonexistent images").DoError(func() error {
 		for imageName, stageIDCommitList := range m.nonexistentImageNameStageIDCommitList {
-			if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, false); err != nil {
+			if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, CleanupPlanReasonNonexistentImage, false); err != nil {
 				return err
 			}
 		}
@@ -537,19 +768,54 @@ func (m *cleanupManager) cleanupNonexistentImageMetadata(ctx context.Context) er
 	})
 }
 
-func (m *cleanupManager) deleteImageMetadata(ctx context.Context, imageName string, stageIDCommitList map[string][]string, updateCache bool) error {
-	if err := deleteImageMetadata(ctx, m.ProjectName, m.StorageManager, imageName, stageIDCommitList, m.DryRun); err != nil {
+func (m *cleanupManager) deleteImageMetadata(ctx context.Context, imageName string, stageIDCommitList map[string][]string, reason CleanupPlanReason, updateCache bool) error {
+	stageIDCommitListToDelete := map[string][]string{}
+	for stageID, commitList := range stageIDCommitList {
+		for _, commit := range commitList {
+			item := &CleanupPlanItem{
+				Kind:      CleanupPlanItemImageMetadata,
+				Reason:    reason,
+				ImageName: imageName,
+				StageID:   stageID,
+				Commit:    commit,
+			}
+
+			if m.planItem(ctx, item) {
+				stageIDCommitListToDelete[stageID] = append(stageIDCommitListToDelete[stageID], commit)
+			}
+		}
+	}
+
+	if m.ArchiveStagesStorage != nil && !m.DryRun && isImageMetadataArchivable(reason) {
+		if err := m.StorageManager.StagesStorageManager.ArchiveImageMetadata(ctx, imageName, stageIDCommitListToDelete, m.ArchiveStagesStorage); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping image %s metadata deletion: unable to archive metadata: %s\n", imageName, err)
+			return nil
+		}
+	}
+
+	if err := deleteImageMetadata(ctx, m.ProjectName, m.StorageManager, m.audit, imageName, stageIDCommitListToDelete, m.DryRun); err != nil {
 		return err
 	}
 
 	if updateCache {
-		m.deleteImageMetadataFromCache(imageName, stageIDCommitList)
+		m.deleteImageMetadataFromCache(imageName, stageIDCommitListToDelete)
 	}
 
 	return nil
 }
 
-func deleteImageMetadata(ctx context.Context, projectName string, storageManager *manager.StorageManager, imageNameOrID string, stageIDCommitList map[string][]string, dryRun bool) error {
+// isImageMetadataArchivable checks that the metadata refers to the existing image and stage,
+// the metadata of nonexistent objects cannot be restored
+func isImageMetadataArchivable(reason CleanupPlanReason) bool {
+	switch reason {
+	case CleanupPlanReasonNonexistentImage, CleanupPlanReasonNonexistentStage:
+		return false
+	default:
+		return true
+	}
+}
+
+func deleteImageMetadata(ctx context.Context, projectName string, storageManager *manager.StorageManager, audit *AuditLog, imageNameOrID string, stageIDCommitList map[string][]string, dryRun bool) error {
 	if dryRun {
 		for stageID, commitList := range stageIDCommitList {
 			logboek.Context(ctx).Info().LogFDetails("  imageName: %s\n", imageNameOrID)
@@ -571,6 +837,7 @@ func deleteImageMetadata(ctx context.Context, projectName string, storageManager
 			return nil
 		}
 
+		audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, ImageName: imageNameOrID, StageID: stageID, Commit: commit})
 		logboek.Context(ctx).Info().LogFDetails("  imageName: %s\n", imageNameOrID)
 		logboek.Context(ctx).Info().LogFDetails("  stageID: %s\n", stageID)
 		logboek.Context(ctx).Info().LogFDetails("  commit: %s\n", commit)
@@ -586,6 +853,14 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 		return fmt.Errorf("unable to init imports metadata: %s", err)
 	}
 
+	if m.LocalGit != nil && !m.GitHistoryBasedCleanupOptions.Quotas.IsEmpty() {
+		if err := logboek.Context(ctx).LogProcess("Applying cleanup quotas").DoError(func() error {
+			return m.applyQuotas(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
 	stagesToDelete := m.stages
 	for _, stageIDCommitList := range m.imageNameStageIDCommitList {
 		for stageID, _ := range stageIDCommitList {
@@ -622,6 +897,24 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 		}
 	}
 
+	stagesToDelete, notUsedIndexes, brokenIndexes := m.handleImagesIndexes(ctx, stagesToDelete)
+
+	if len(notUsedIndexes) != 0 {
+		if err := logboek.Context(ctx).Default().LogProcess("Deleting images indexes").DoError(func() error {
+			return m.deleteImagesIndexes(ctx, notUsedIndexes, CleanupPlanReasonNotUsed, "all the platform stages of the index are deleted")
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(brokenIndexes) != 0 {
+		if err := logboek.Context(ctx).Default().LogProcess("Deleting images indexes for nonexistent stages").DoError(func() error {
+			return m.deleteImagesIndexes(ctx, brokenIndexes, CleanupPlanReasonNonexistentStage, "the index references the nonexistent platform stages")
+		}); err != nil {
+			return err
+		}
+	}
+
 	if len(stagesToDelete) != 0 {
 		if err := logboek.Context(ctx).Default().LogProcess("Deleting stages tags").DoError(func() error {
 			return m.deleteStages(ctx, stagesToDelete)
@@ -632,7 +925,7 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 
 	if len(m.nonexistentImportMetadataIDs) != 0 {
 		if err := logboek.Context(ctx).Default().LogProcess("Cleaning imports metadata").DoError(func() error {
-			return m.deleteImportsMetadata(ctx, m.nonexistentImportMetadataIDs)
+			return m.deleteImportsMetadata(ctx, m.nonexistentImportMetadataIDs, CleanupPlanReasonNonexistentSourceStage)
 		}); err != nil {
 			return err
 		}
@@ -658,7 +951,7 @@ func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
 		if metadata == nil {
 			if err := logboek.Context(ctx).Warn().LogProcess("Deleting invalid import metadata %s", metadataID).
 				DoError(func() error {
-					return m.deleteImportsMetadata(ctx, []string{metadataID})
+					return m.deleteImportsMetadata(ctx, []string{metadataID}, CleanupPlanReasonInvalidImportMetadata)
 				}); err != nil {
 				return fmt.Errorf("unable to delete import metadata %s: %s", metadataID, err)
 			}
@@ -689,11 +982,18 @@ func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
 	})
 }
 
-func (m *cleanupManager) deleteImportsMetadata(ctx context.Context, importMetadataIDs []string) error {
-	return deleteImportsMetadata(ctx, m.ProjectName, m.StorageManager, importMetadataIDs, m.DryRun)
+func (m *cleanupManager) deleteImportsMetadata(ctx context.Context, importMetadataIDs []string, reason CleanupPlanReason) error {
+	var importMetadataIDsToDelete []string
+	for _, importMetadataID := range importMetadataIDs {
+		if m.planItem(ctx, &CleanupPlanItem{Kind: CleanupPlanItemImportMetadata, Reason: reason, ImportMetadataID: importMetadataID}) {
+			importMetadataIDsToDelete = append(importMetadataIDsToDelete, importMetadataID)
+		}
+	}
+
+	return deleteImportsMetadata(ctx, m.ProjectName, m.StorageManager, m.audit, importMetadataIDsToDelete, m.DryRun)
 }
 
-func deleteImportsMetadata(ctx context.Context, projectName string, storageManager *manager.StorageManager, importMetadataIDs []string, dryRun bool) error {
+func deleteImportsMetadata(ctx context.Context, projectName string, storageManager *manager.StorageManager, audit *AuditLog, importMetadataIDs []string, dryRun bool) error {
 	if dryRun {
 		for _, importMetadataID := range importMetadataIDs {
 			logboek.Context(ctx).Info().LogFDetails("  importMetadataID: %s\n", importMetadataID)
@@ -713,6 +1013,7 @@ func deleteImportsMetadata(ctx context.Context, projectName string, storageManag
 			return nil
 		}
 
+		audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemImportMetadata, ImportMetadataID: importMetadataID})
 		logboek.Context(ctx).Info().LogFDetails("  importMetadataID: %s\n", importMetadataID)
 
 		return nil
@@ -0,0 +1,144 @@
+package cleaning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+const (
+	// the older records are deleted from the stages storage on each save
+	CleanupAuditRecordsKeepLast   = 100
+	CleanupAuditRecordsKeepWithin = 90 * 24 * time.Hour
+)
+
+// AuditLog collects the objects deleted from the stages storage, the collected items are saved
+// into the stages storage as a single cleanup audit record at the end of the command run
+type AuditLog struct {
+	record *storage.CleanupAuditRecord
+	// expected are the planned items by the object, the deleted object takes the reason of the planned item
+	expected      map[string]*CleanupPlanItem
+	defaultReason CleanupPlanReason
+	mutex         sync.Mutex
+}
+
+// NewAuditLog creates the log of the command, defaultReason is used for the objects which have been deleted without planning
+func NewAuditLog(command string, defaultReason CleanupPlanReason) *AuditLog {
+	return &AuditLog{
+		record:        storage.NewCleanupAuditRecord(command),
+		expected:      map[string]*CleanupPlanItem{},
+		defaultReason: defaultReason,
+	}
+}
+
+func (l *AuditLog) expect(item *CleanupPlanItem) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.expected[item.String()] = item
+}
+
+// Deleted records the object, only the kind and the object identification fields of the item are required
+func (l *AuditLog) Deleted(item *CleanupPlanItem) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if expectedItem, ok := l.expected[item.String()]; ok {
+		item = expectedItem
+	} else if item.Reason == "" {
+		item.Reason = l.defaultReason
+	}
+
+	l.record.AddItem(&storage.CleanupAuditRecordItem{
+		Kind:    string(item.Kind),
+		Object:  item.String(),
+		Reason:  string(item.Reason),
+		Details: item.Details,
+	})
+}
+
+// Save puts the record into the stages storage, nothing is saved if no object has been deleted
+func (l *AuditLog) Save(ctx context.Context, projectName string, stagesStorage storage.StagesStorage) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.record.Items) == 0 {
+		return nil
+	}
+
+	if err := stagesStorage.PutCleanupAuditRecord(ctx, projectName, l.record); err != nil {
+		return fmt.Errorf("unable to save cleanup audit record: %s", err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Cleanup audit record %s with %d deleted objects has been saved\n", l.record.ID, len(l.record.Items))
+
+	deleteOutdatedCleanupAuditRecords(ctx, projectName, stagesStorage)
+
+	return nil
+}
+
+// deleteOutdatedCleanupAuditRecords keeps the history bounded, the failures are not fatal for the command
+func deleteOutdatedCleanupAuditRecords(ctx context.Context, projectName string, stagesStorage storage.StagesStorage) {
+	ids, err := stagesStorage.GetCleanupAuditRecordIDs(ctx, projectName)
+	if err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Unable to get cleanup audit records: %s\n", err)
+		return
+	}
+
+	for _, id := range outdatedCleanupAuditRecordIDs(ids, CleanupAuditRecordsKeepLast, CleanupAuditRecordsKeepWithin, time.Now()) {
+		if err := stagesStorage.DeleteCleanupAuditRecord(ctx, projectName, id); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Unable to delete outdated cleanup audit record %s: %s\n", id, err)
+			continue
+		}
+
+		logboek.Context(ctx).Info().LogF("Outdated cleanup audit record %s has been deleted\n", id)
+	}
+}
+
+// outdatedCleanupAuditRecordIDs returns the records beyond the keepLast latest records and the records older than keepWithin,
+// the records with unexpected IDs are kept
+func outdatedCleanupAuditRecordIDs(ids []string, keepLast int, keepWithin time.Duration, now time.Time) []string {
+	createdAtByID := map[string]time.Time{}
+	var validIDs []string
+	for _, id := range ids {
+		createdAt, err := storage.GetCleanupAuditRecordIDTime(id)
+		if err != nil {
+			continue
+		}
+
+		createdAtByID[id] = createdAt
+		validIDs = append(validIDs, id)
+	}
+
+	sort.SliceStable(validIDs, func(i, j int) bool {
+		return createdAtByID[validIDs[i]].After(createdAtByID[validIDs[j]])
+	})
+
+	var res []string
+	for i, id := range validIDs {
+		if i >= keepLast || now.Sub(createdAtByID[id]) > keepWithin {
+			res = append(res, id)
+		}
+	}
+
+	return res
+}
+
+// saveAfterRun saves the deleted objects even if the command has failed, the command error takes precedence
+func (l *AuditLog) saveAfterRun(ctx context.Context, projectName string, stagesStorage storage.StagesStorage, runErr error) error {
+	if err := l.Save(ctx, projectName, stagesStorage); err != nil {
+		if runErr == nil {
+			return err
+		}
+
+		logboek.Context(ctx).Warn().LogF("WARNING: %s\n", err)
+	}
+
+	return runErr
+}
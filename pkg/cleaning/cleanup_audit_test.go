@@ -0,0 +1,60 @@
+package cleaning
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutdatedCleanupAuditRecordIDs(t *testing.T) {
+	now := time.Date(2021, 1, 30, 0, 0, 0, 0, time.UTC)
+	recordID := func(daysAgo int, suffix string) string {
+		return fmt.Sprintf("%d-%s", now.Add(-time.Duration(daysAgo)*24*time.Hour).UnixNano()/int64(time.Millisecond), suffix)
+	}
+
+	tests := []struct {
+		name       string
+		ids        []string
+		keepLast   int
+		keepWithin time.Duration
+		expected   []string
+	}{
+		{
+			name:       "all records are kept",
+			ids:        []string{recordID(1, "a"), recordID(2, "b")},
+			keepLast:   10,
+			keepWithin: 30 * 24 * time.Hour,
+			expected:   nil,
+		},
+		{
+			name:       "records beyond the last ones are deleted",
+			ids:        []string{recordID(3, "c"), recordID(1, "a"), recordID(4, "d"), recordID(2, "b")},
+			keepLast:   2,
+			keepWithin: 30 * 24 * time.Hour,
+			expected:   []string{recordID(3, "c"), recordID(4, "d")},
+		},
+		{
+			name:       "records older than allowed are deleted",
+			ids:        []string{recordID(40, "c"), recordID(1, "a"), recordID(31, "b")},
+			keepLast:   10,
+			keepWithin: 30 * 24 * time.Hour,
+			expected:   []string{recordID(31, "b"), recordID(40, "c")},
+		},
+		{
+			name:       "records with unexpected ids are kept",
+			ids:        []string{"unexpected", recordID(40, "a")},
+			keepLast:   10,
+			keepWithin: 30 * 24 * time.Hour,
+			expected:   []string{recordID(40, "a")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ids := outdatedCleanupAuditRecordIDs(tt.ids, tt.keepLast, tt.keepWithin, now); !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ids)
+			}
+		})
+	}
+}
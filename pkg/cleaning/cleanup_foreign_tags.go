@@ -0,0 +1,183 @@
+package cleaning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/image"
+)
+
+type foreignTagToDelete struct {
+	info *image.Info
+	rule *config.MetaCleanupForeignTagsDeleteRule
+}
+
+// cleanupForeignTags reports the repo tags which are neither stages nor werf records and deletes the tags matching the delete rules.
+// The tag is deleted by the manifest digest, thus the tags sharing the manifest with a stage, an image index or a kept tag are never deleted.
+func (m *cleanupManager) cleanupForeignTags(ctx context.Context) error {
+	stagesStorage := m.StorageManager.StagesStorage
+
+	tags, err := stagesStorage.GetForeignTags(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get foreign tags: %s", err)
+	}
+
+	if len(tags) == 0 {
+		logboek.Context(ctx).Default().LogLnDetails("No foreign tags found")
+		return nil
+	}
+	sort.Strings(tags)
+
+	var deployedDockerImagesNames []string
+	if !m.WithoutKube || len(m.AllowListImages) != 0 {
+		deployedDockerImagesNames, err = m.deployedDockerImagesNames(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	keptDigests := map[string]bool{}
+	for _, stageDesc := range m.stages {
+		keptDigests[stageDesc.Info.RepoDigest] = true
+	}
+	for _, indexDesc := range m.imagesIndexes {
+		keptDigests[indexDesc.Info.RepoDigest] = true
+	}
+
+	var rows [][]interface{}
+	var candidates []*foreignTagToDelete
+	for _, tag := range tags {
+		info, err := stagesStorage.GetForeignTagInfo(ctx, m.ProjectName, tag)
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping foreign tag %s: %s\n", tag, err)
+			continue
+		} else if info == nil {
+			continue
+		}
+
+		createdAt := info.GetCreatedAt().UTC().Format(time.RFC3339)
+
+		rule := matchForeignTagDeleteRule(m.GitHistoryBasedCleanupOptions.ForeignTags.DeleteRules, info)
+		switch {
+		case rule == nil:
+			keptDigests[info.RepoDigest] = true
+			rows = append(rows, []interface{}{tag, createdAt, "keep: no delete rule matched"})
+		case isForeignTagUsedInKubernetes(info, deployedDockerImagesNames):
+			keptDigests[info.RepoDigest] = true
+			rows = append(rows, []interface{}{tag, createdAt, "keep: used in Kubernetes"})
+		default:
+			candidates = append(candidates, &foreignTagToDelete{info: info, rule: rule})
+		}
+	}
+
+	var infosToDelete []*image.Info
+	for _, candidate := range candidates {
+		info := candidate.info
+		createdAt := info.GetCreatedAt().UTC().Format(time.RFC3339)
+
+		if keptDigests[info.RepoDigest] {
+			rows = append(rows, []interface{}{info.Tag, createdAt, "keep: manifest is shared with a stage or a kept tag"})
+			continue
+		}
+
+		rows = append(rows, []interface{}{info.Tag, createdAt, fmt.Sprintf("delete: %s", candidate.rule.String())})
+
+		if m.planItem(ctx, &CleanupPlanItem{
+			Kind:            CleanupPlanItemForeignTag,
+			Reason:          CleanupPlanReasonForeignTagRule,
+			Details:         fmt.Sprintf("matches the foreign tags delete rule %s", candidate.rule.String()),
+			ForeignTag:      info.Tag,
+			DockerImageName: info.Name,
+		}) {
+			infosToDelete = append(infosToDelete, info)
+		}
+	}
+
+	printForeignTagsTable(ctx, rows)
+
+	return m.deleteForeignTags(ctx, infosToDelete)
+}
+
+func (m *cleanupManager) deleteForeignTags(ctx context.Context, infos []*image.Info) error {
+	deletedDigests := map[string]bool{}
+	for _, info := range infos {
+		if m.DryRun || deletedDigests[info.RepoDigest] {
+			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", info.Tag)
+			logboek.Context(ctx).LogOptionalLn()
+			continue
+		}
+
+		if err := m.StorageManager.StagesStorage.DeleteForeignTag(ctx, m.ProjectName, info); err != nil {
+			if err := handleDeletionError(err); err != nil {
+				return err
+			}
+
+			logboek.Context(ctx).Warn().LogF("WARNING: Foreign tag %s deletion failed: %s\n", info.Tag, err)
+
+			continue
+		}
+
+		// the other tags of the same manifest have been deleted as well
+		deletedDigests[info.RepoDigest] = true
+
+		m.audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemForeignTag, ForeignTag: info.Tag})
+		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", info.Tag)
+	}
+
+	return nil
+}
+
+func matchForeignTagDeleteRule(rules []*config.MetaCleanupForeignTagsDeleteRule, info *image.Info) *config.MetaCleanupForeignTagsDeleteRule {
+	for _, rule := range rules {
+		if !rule.TagRegexp.MatchString(info.Tag) {
+			continue
+		}
+
+		if rule.OlderThan != nil && time.Since(info.GetCreatedAt()) < *rule.OlderThan {
+			continue
+		}
+
+		return rule
+	}
+
+	return nil
+}
+
+func isForeignTagUsedInKubernetes(info *image.Info, deployedDockerImagesNames []string) bool {
+	for _, deployedDockerImageName := range deployedDockerImagesNames {
+		if deployedDockerImageName == info.Name {
+			return true
+		}
+
+		if info.RepoDigest != "" && strings.HasPrefix(deployedDockerImageName, info.Repository) && strings.HasSuffix(deployedDockerImageName, "@"+info.RepoDigest) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printForeignTagsTable(ctx context.Context, rows [][]interface{}) {
+	if len(rows) == 0 {
+		return
+	}
+
+	tbl := table.New("Tag", "Created", "Decision")
+	tbl.WithWriter(logboek.Context(ctx).ProxyOutStream())
+	tbl.WithHeaderFormatter(color.New(color.Underline).SprintfFunc())
+	for _, row := range rows {
+		tbl.AddRow(row...)
+	}
+	tbl.Print()
+
+	logboek.Context(ctx).LogOptionalLn()
+}
@@ -0,0 +1,243 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+type foreignTagsTestStagesStorage struct {
+	storage.StagesStorage
+	infos       map[string]*image.Info
+	deletedTags []string
+}
+
+func (s *foreignTagsTestStagesStorage) GetForeignTags(_ context.Context, _ string) ([]string, error) {
+	var tags []string
+	for tag := range s.infos {
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (s *foreignTagsTestStagesStorage) GetForeignTagInfo(_ context.Context, _, tag string) (*image.Info, error) {
+	return s.infos[tag], nil
+}
+
+func (s *foreignTagsTestStagesStorage) DeleteForeignTag(_ context.Context, _ string, info *image.Info) error {
+	s.deletedTags = append(s.deletedTags, info.Tag)
+	return nil
+}
+
+func newForeignTagsTestInfo(tag, repoDigest string, createdAt time.Time) *image.Info {
+	info := &image.Info{Name: "repo:" + tag, Repository: "repo", Tag: tag, RepoDigest: repoDigest}
+	info.SetCreatedAtUnixNano(createdAt.UnixNano())
+
+	return info
+}
+
+func TestCleanupForeignTags(t *testing.T) {
+	now := time.Now()
+	deleteAllRules := []*config.MetaCleanupForeignTagsDeleteRule{{TagRegexp: regexp.MustCompile(`^tmp-`)}}
+
+	tests := []struct {
+		name                 string
+		infos                []*image.Info
+		stages               []*image.StageDescription
+		imagesIndexes        []*image.ImageIndexDescription
+		allowListImages      []string
+		deleteRules          []*config.MetaCleanupForeignTagsDeleteRule
+		approvedPlanItems    []*CleanupPlanItem
+		expectedDeletedTags  []string
+		expectedPlannedItems []string
+	}{
+		{
+			name:                 "tags matching the delete rules are deleted",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:1", now), newForeignTagsTestInfo("release", "sha256:2", now)},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  []string{"tmp-1"},
+			expectedPlannedItems: []string{"tmp-1"},
+		},
+		{
+			name:                 "tag sharing the manifest with a stage is kept",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:stage", now), newForeignTagsTestInfo("tmp-2", "sha256:2", now)},
+			stages:               []*image.StageDescription{{Info: &image.Info{Tag: "digest-1", RepoDigest: "sha256:stage"}}},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  []string{"tmp-2"},
+			expectedPlannedItems: []string{"tmp-2"},
+		},
+		{
+			name:                 "tag sharing the manifest with an image index is kept",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:index", now)},
+			imagesIndexes:        []*image.ImageIndexDescription{{Info: &image.Info{Tag: "image-index-1", RepoDigest: "sha256:index"}}},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  nil,
+			expectedPlannedItems: nil,
+		},
+		{
+			name:                 "tag sharing the manifest with a tag not matching the delete rules is kept",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:1", now), newForeignTagsTestInfo("release", "sha256:1", now)},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  nil,
+			expectedPlannedItems: nil,
+		},
+		{
+			name:                 "tag sharing the manifest with a tag used in Kubernetes is kept",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:1", now), newForeignTagsTestInfo("tmp-2", "sha256:1", now)},
+			allowListImages:      []string{"repo:tmp-2"},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  nil,
+			expectedPlannedItems: nil,
+		},
+		{
+			name:                 "manifest of the several tags is deleted once",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:1", now), newForeignTagsTestInfo("tmp-2", "sha256:1", now)},
+			deleteRules:          deleteAllRules,
+			expectedDeletedTags:  []string{"tmp-1"},
+			expectedPlannedItems: []string{"tmp-1", "tmp-2"},
+		},
+		{
+			name:                 "tag not found in the approved plan is kept",
+			infos:                []*image.Info{newForeignTagsTestInfo("tmp-1", "sha256:1", now), newForeignTagsTestInfo("tmp-2", "sha256:2", now)},
+			deleteRules:          deleteAllRules,
+			approvedPlanItems:    []*CleanupPlanItem{{Kind: CleanupPlanItemForeignTag, ForeignTag: "tmp-2"}},
+			expectedDeletedTags:  []string{"tmp-2"},
+			expectedPlannedItems: []string{"tmp-1", "tmp-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stagesStorage := &foreignTagsTestStagesStorage{infos: map[string]*image.Info{}}
+			for _, info := range tt.infos {
+				stagesStorage.infos[info.Tag] = info
+			}
+
+			m := &cleanupManager{
+				stages:                        tt.stages,
+				imagesIndexes:                 tt.imagesIndexes,
+				plan:                          NewCleanupPlan("project", "stages-storage"),
+				audit:                         NewAuditLog("werf cleanup", ""),
+				ProjectName:                   "project",
+				StorageManager:                manager.NewStorageManager("project", stagesStorage, nil, nil, nil),
+				WithoutKube:                   true,
+				AllowListImages:               tt.allowListImages,
+				GitHistoryBasedCleanupOptions: config.MetaCleanup{ForeignTags: &config.MetaCleanupForeignTags{DeleteRules: tt.deleteRules}},
+			}
+
+			if tt.approvedPlanItems != nil {
+				m.ApprovedPlan = NewCleanupPlan("project", "stages-storage")
+				m.ApprovedPlan.Items = tt.approvedPlanItems
+			}
+
+			if err := m.cleanupForeignTags(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(stagesStorage.deletedTags, tt.expectedDeletedTags) {
+				t.Errorf("expected deleted tags %v, got %v", tt.expectedDeletedTags, stagesStorage.deletedTags)
+			}
+
+			var plannedItems []string
+			for _, item := range m.plan.Items {
+				plannedItems = append(plannedItems, item.ForeignTag)
+			}
+			sort.Strings(plannedItems)
+
+			if !reflect.DeepEqual(plannedItems, tt.expectedPlannedItems) {
+				t.Errorf("expected planned tags %v, got %v", tt.expectedPlannedItems, plannedItems)
+			}
+		})
+	}
+}
+
+func TestMatchForeignTagDeleteRule(t *testing.T) {
+	hour := time.Hour
+	now := time.Now()
+
+	rules := []*config.MetaCleanupForeignTagsDeleteRule{
+		{TagRegexp: regexp.MustCompile(`^pr-`), OlderThan: &hour},
+		{TagRegexp: regexp.MustCompile(`^tmp-`)},
+	}
+
+	tests := []struct {
+		name         string
+		info         *image.Info
+		expectedRule *config.MetaCleanupForeignTagsDeleteRule
+	}{
+		{
+			name:         "tag matching the regexp",
+			info:         newForeignTagsTestInfo("tmp-1", "sha256:1", now),
+			expectedRule: rules[1],
+		},
+		{
+			name:         "tag older than the rule period",
+			info:         newForeignTagsTestInfo("pr-1", "sha256:1", now.Add(-2*time.Hour)),
+			expectedRule: rules[0],
+		},
+		{
+			name: "tag newer than the rule period",
+			info: newForeignTagsTestInfo("pr-1", "sha256:1", now),
+		},
+		{
+			name: "tag not matching the regexp",
+			info: newForeignTagsTestInfo("release", "sha256:1", now.Add(-2*time.Hour)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rule := matchForeignTagDeleteRule(rules, tt.info); rule != tt.expectedRule {
+				t.Errorf("expected rule %v, got %v", tt.expectedRule, rule)
+			}
+		})
+	}
+}
+
+func TestIsForeignTagUsedInKubernetes(t *testing.T) {
+	info := newForeignTagsTestInfo("tmp-1", "sha256:1", time.Now())
+
+	tests := []struct {
+		name                      string
+		deployedDockerImagesNames []string
+		expected                  bool
+	}{
+		{
+			name:                      "used by the name",
+			deployedDockerImagesNames: []string{"repo:tmp-1"},
+			expected:                  true,
+		},
+		{
+			name:                      "used by the repo digest",
+			deployedDockerImagesNames: []string{"repo:release@sha256:1"},
+			expected:                  true,
+		},
+		{
+			name:                      "digest of another repo",
+			deployedDockerImagesNames: []string{"other:tmp-1@sha256:1"},
+			expected:                  false,
+		},
+		{
+			name:                      "not used",
+			deployedDockerImagesNames: []string{"repo:tmp-2", "repo@sha256:2"},
+			expected:                  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if used := isForeignTagUsedInKubernetes(info, tt.deployedDockerImagesNames); used != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, used)
+			}
+		})
+	}
+}
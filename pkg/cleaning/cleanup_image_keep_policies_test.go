@@ -0,0 +1,86 @@
+package cleaning
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/werf/werf/pkg/cleaning/git_history_based_cleanup"
+	"github.com/werf/werf/pkg/config"
+)
+
+func TestImageKeepPolicies(t *testing.T) {
+	newKeepPolicy := func(branch string) *config.MetaCleanupKeepPolicy {
+		return &config.MetaCleanupKeepPolicy{References: config.MetaCleanupKeepPolicyReferences{BranchRegexp: regexp.MustCompile(branch)}}
+	}
+
+	metaPolicy := newKeepPolicy("main")
+	imagePolicy := newKeepPolicy("release")
+
+	var defaultKeepPolicies []string
+	for _, policy := range git_history_based_cleanup.KeepPoliciesOrDefault(nil) {
+		defaultKeepPolicies = append(defaultKeepPolicies, policy.String())
+	}
+
+	tests := []struct {
+		name                 string
+		metaKeepPolicies     []*config.MetaCleanupKeepPolicy
+		imageCleanup         *config.ImageCleanup
+		expectedKeepPolicies []string
+	}{
+		{
+			name:                 "image without the cleanup section uses the meta policies",
+			metaKeepPolicies:     []*config.MetaCleanupKeepPolicy{metaPolicy},
+			expectedKeepPolicies: []string{metaPolicy.String()},
+		},
+		{
+			name:                 "image policies override the meta policies",
+			metaKeepPolicies:     []*config.MetaCleanupKeepPolicy{metaPolicy},
+			imageCleanup:         &config.ImageCleanup{KeepPolicies: []*config.MetaCleanupKeepPolicy{imagePolicy}, KeepPoliciesStrategy: config.OverrideKeepPoliciesStrategy},
+			expectedKeepPolicies: []string{imagePolicy.String()},
+		},
+		{
+			name:                 "image policies extend the meta policies",
+			metaKeepPolicies:     []*config.MetaCleanupKeepPolicy{metaPolicy},
+			imageCleanup:         &config.ImageCleanup{KeepPolicies: []*config.MetaCleanupKeepPolicy{imagePolicy}, KeepPoliciesStrategy: config.ExtendKeepPoliciesStrategy},
+			expectedKeepPolicies: []string{metaPolicy.String(), imagePolicy.String()},
+		},
+		{
+			name:                 "image policies extend the default policies",
+			imageCleanup:         &config.ImageCleanup{KeepPolicies: []*config.MetaCleanupKeepPolicy{imagePolicy}, KeepPoliciesStrategy: config.ExtendKeepPoliciesStrategy},
+			expectedKeepPolicies: append(append([]string{}, defaultKeepPolicies...), imagePolicy.String()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &cleanupManager{
+				GitHistoryBasedCleanupOptions: config.MetaCleanup{KeepPolicies: tt.metaKeepPolicies},
+				ImagesCleanupOptions:          map[string]*config.ImageCleanup{"app": tt.imageCleanup},
+			}
+
+			var keepPolicies []string
+			for _, policy := range m.imageKeepPolicies("app") {
+				keepPolicies = append(keepPolicies, policy.String())
+			}
+
+			if !reflect.DeepEqual(keepPolicies, tt.expectedKeepPolicies) {
+				t.Errorf("expected keep policies %v, got %v", tt.expectedKeepPolicies, keepPolicies)
+			}
+		})
+	}
+}
+
+func TestKeepPoliciesKey(t *testing.T) {
+	mainPolicy := &config.MetaCleanupKeepPolicy{References: config.MetaCleanupKeepPolicyReferences{BranchRegexp: regexp.MustCompile("main")}}
+	releasePolicy := &config.MetaCleanupKeepPolicy{References: config.MetaCleanupKeepPolicyReferences{BranchRegexp: regexp.MustCompile("release")}}
+	sameMainPolicy := &config.MetaCleanupKeepPolicy{References: config.MetaCleanupKeepPolicyReferences{BranchRegexp: regexp.MustCompile("main")}}
+
+	if keepPoliciesKey([]*config.MetaCleanupKeepPolicy{mainPolicy, releasePolicy}) != keepPoliciesKey([]*config.MetaCleanupKeepPolicy{sameMainPolicy, releasePolicy}) {
+		t.Errorf("expected the equal policies to share the references to scan")
+	}
+
+	if keepPoliciesKey([]*config.MetaCleanupKeepPolicy{mainPolicy}) == keepPoliciesKey([]*config.MetaCleanupKeepPolicy{mainPolicy, releasePolicy}) {
+		t.Errorf("expected the extended policies not to share the references to scan")
+	}
+}
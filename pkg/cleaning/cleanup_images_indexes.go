@@ -0,0 +1,164 @@
+package cleaning
+
+import (
+	"context"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// initImagesIndexes fetches the multi-platform images indexes, only the repo stages storage has the indexes
+func (m *cleanupManager) initImagesIndexes(ctx context.Context) error {
+	repoStagesStorage, ok := m.StorageManager.StagesStorage.(*storage.RepoStagesStorage)
+	if !ok {
+		return nil
+	}
+
+	indexes, err := repoStagesStorage.GetImageIndexDescriptionList(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.imagesIndexes = indexes
+
+	return nil
+}
+
+// handleImagesIndexes excludes all the platform stages of the live index from the stages to delete to keep the index complete.
+// The index is live if it is used in Kubernetes or at least one of its platform stages is kept.
+// The indexes which platform stages are deleted and the indexes referencing nonexistent stages are returned to be deleted.
+func (m *cleanupManager) handleImagesIndexes(ctx context.Context, stagesToDelete []*image.StageDescription) ([]*image.StageDescription, []*image.ImageIndexDescription, []*image.ImageIndexDescription) {
+	var notUsedIndexes, brokenIndexes []*image.ImageIndexDescription
+	var excludedStages []*image.StageDescription
+
+	handledIndexes := map[*image.ImageIndexDescription]bool{}
+	for {
+		var isStagesToDeleteChanged bool
+
+		for _, indexDesc := range m.imagesIndexes {
+			// the index without the stages references has been published by the previous werf version
+			if handledIndexes[indexDesc] || len(indexDesc.StageIDs) == 0 {
+				continue
+			}
+
+			var indexStagesToDelete []*image.StageDescription
+			isLive := m.isImageIndexUsedInKubernetes(indexDesc)
+			isBroken := false
+			for _, stageID := range indexDesc.StageIDs {
+				if !m.isStageExist(stageID) {
+					isBroken = true
+					break
+				}
+
+				if stageDesc := findStageByTag(stagesToDelete, stageID); stageDesc != nil {
+					indexStagesToDelete = append(indexStagesToDelete, stageDesc)
+				} else {
+					isLive = true
+				}
+			}
+
+			if isBroken {
+				brokenIndexes = append(brokenIndexes, indexDesc)
+				handledIndexes[indexDesc] = true
+				continue
+			}
+
+			if !isLive {
+				continue
+			}
+
+			for _, stageDesc := range indexStagesToDelete {
+				var excludedStagesByStage []*image.StageDescription
+				stagesToDelete, excludedStagesByStage = m.excludeStageAndRelativesByStage(stagesToDelete, stageDesc)
+				excludedStages = append(excludedStages, excludedStagesByStage...)
+				isStagesToDeleteChanged = true
+			}
+
+			handledIndexes[indexDesc] = true
+		}
+
+		// the excluded stages can make live the other indexes referencing them
+		if !isStagesToDeleteChanged {
+			break
+		}
+	}
+
+	for _, indexDesc := range m.imagesIndexes {
+		if !handledIndexes[indexDesc] && len(indexDesc.StageIDs) != 0 {
+			notUsedIndexes = append(notUsedIndexes, indexDesc)
+		}
+	}
+
+	if len(excludedStages) != 0 {
+		logboek.Context(ctx).Default().LogBlock("Saved stages of the images indexes").Do(func() {
+			for _, stage := range excludedStages {
+				logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stage.Info.Tag)
+				logboek.Context(ctx).LogOptionalLn()
+			}
+		})
+	}
+
+	return stagesToDelete, notUsedIndexes, brokenIndexes
+}
+
+func (m *cleanupManager) isImageIndexUsedInKubernetes(indexDesc *image.ImageIndexDescription) bool {
+	for _, deployedDockerImageName := range m.deployedDockerImages {
+		if deployedDockerImageName == indexDesc.Info.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *cleanupManager) deleteImagesIndexes(ctx context.Context, indexes []*image.ImageIndexDescription, reason CleanupPlanReason, details string) error {
+	var indexesToDelete []*image.ImageIndexDescription
+	for _, indexDesc := range indexes {
+		if m.planItem(ctx, &CleanupPlanItem{
+			Kind:            CleanupPlanItemImageIndex,
+			Reason:          reason,
+			Details:         details,
+			ImageIndex:      indexDesc.Info.Tag,
+			DockerImageName: indexDesc.Info.Name,
+		}) {
+			indexesToDelete = append(indexesToDelete, indexDesc)
+		}
+	}
+
+	return deleteImagesIndexes(ctx, m.StorageManager.StagesStorage, m.audit, m.DryRun, indexesToDelete)
+}
+
+func deleteImagesIndexes(ctx context.Context, stagesStorage storage.StagesStorage, audit *AuditLog, dryRun bool, indexes []*image.ImageIndexDescription) error {
+	for _, indexDesc := range indexes {
+		if !dryRun {
+			if err := stagesStorage.(*storage.RepoStagesStorage).DeleteImageIndex(ctx, indexDesc); err != nil {
+				if err := handleDeletionError(err); err != nil {
+					return err
+				}
+
+				logboek.Context(ctx).Warn().LogF("WARNING: Image index %s deletion failed: %s\n", indexDesc.Info.Name, err)
+
+				continue
+			}
+
+			audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemImageIndex, ImageIndex: indexDesc.Info.Tag, DockerImageName: indexDesc.Info.Name})
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", indexDesc.Info.Tag)
+		logboek.Context(ctx).LogOptionalLn()
+	}
+
+	return nil
+}
+
+func findStageByTag(stages []*image.StageDescription, tag string) *image.StageDescription {
+	for _, stage := range stages {
+		if stage.Info.Tag == tag {
+			return stage
+		}
+	}
+
+	return nil
+}
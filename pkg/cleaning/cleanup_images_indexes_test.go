@@ -0,0 +1,137 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestHandleImagesIndexes(t *testing.T) {
+	newIndex := func(tag string, stageIDs ...string) *image.ImageIndexDescription {
+		return &image.ImageIndexDescription{StageIDs: stageIDs, Info: &image.Info{Name: "repo:" + tag, Tag: tag}}
+	}
+
+	indexTags := func(indexes []*image.ImageIndexDescription) []string {
+		var res []string
+		for _, indexDesc := range indexes {
+			res = append(res, indexDesc.Info.Tag)
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	tests := []struct {
+		name                   string
+		stages                 []*image.StageDescription
+		stageIDsToDelete       []string
+		imagesIndexes          []*image.ImageIndexDescription
+		deployedDockerImages   []string
+		expectedStagesToDelete []string
+		expectedNotUsedIndexes []string
+		expectedBrokenIndexes  []string
+	}{
+		{
+			name: "kept platform stage keeps the other platform stages of the index",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+				newQuotasTestStage("arm64-parent", "", 10, 1),
+				newQuotasTestStage("arm64", "arm64-parent", 10, 1),
+				newQuotasTestStage("other", "", 10, 1),
+			},
+			stageIDsToDelete:       []string{"arm64-parent", "arm64", "other"},
+			imagesIndexes:          []*image.ImageIndexDescription{newIndex("image-index-1", "amd64", "arm64")},
+			expectedStagesToDelete: []string{"other"},
+		},
+		{
+			name: "index of the deleted stages is deleted",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+				newQuotasTestStage("arm64", "", 10, 1),
+			},
+			stageIDsToDelete:       []string{"amd64", "arm64"},
+			imagesIndexes:          []*image.ImageIndexDescription{newIndex("image-index-1", "amd64", "arm64")},
+			expectedStagesToDelete: []string{"amd64", "arm64"},
+			expectedNotUsedIndexes: []string{"image-index-1"},
+		},
+		{
+			name: "index used in Kubernetes keeps its stages",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+				newQuotasTestStage("arm64", "", 10, 1),
+			},
+			stageIDsToDelete:       []string{"amd64", "arm64"},
+			imagesIndexes:          []*image.ImageIndexDescription{newIndex("image-index-1", "amd64", "arm64")},
+			deployedDockerImages:   []string{"repo:image-index-1"},
+			expectedStagesToDelete: nil,
+		},
+		{
+			name: "index referencing nonexistent stage is deleted",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+			},
+			stageIDsToDelete:       nil,
+			imagesIndexes:          []*image.ImageIndexDescription{newIndex("image-index-1", "amd64", "arm64")},
+			expectedStagesToDelete: nil,
+			expectedBrokenIndexes:  []string{"image-index-1"},
+		},
+		{
+			name: "stages kept by the live index make live the other index",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+				newQuotasTestStage("arm64", "", 10, 1),
+				newQuotasTestStage("s390x", "", 10, 1),
+			},
+			stageIDsToDelete: []string{"arm64", "s390x"},
+			imagesIndexes: []*image.ImageIndexDescription{
+				newIndex("image-index-2", "arm64", "s390x"),
+				newIndex("image-index-1", "amd64", "arm64"),
+			},
+			expectedStagesToDelete: nil,
+		},
+		{
+			name: "index without stages references is kept as is",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("amd64", "", 10, 1),
+			},
+			stageIDsToDelete:       []string{"amd64"},
+			imagesIndexes:          []*image.ImageIndexDescription{newIndex("image-index-1")},
+			expectedStagesToDelete: []string{"amd64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &cleanupManager{
+				stages:               tt.stages,
+				imagesIndexes:        tt.imagesIndexes,
+				deployedDockerImages: tt.deployedDockerImages,
+			}
+
+			var stagesToDelete []*image.StageDescription
+			for _, stageID := range tt.stageIDsToDelete {
+				stagesToDelete = append(stagesToDelete, m.mustGetStage(stageID))
+			}
+
+			stagesToDelete, notUsedIndexes, brokenIndexes := m.handleImagesIndexes(context.Background(), stagesToDelete)
+
+			var stageIDs []string
+			for _, stageDesc := range stagesToDelete {
+				stageIDs = append(stageIDs, stageDesc.Info.Tag)
+			}
+			sort.Strings(stageIDs)
+
+			if !reflect.DeepEqual(stageIDs, tt.expectedStagesToDelete) {
+				t.Errorf("expected stages to delete %v, got %v", tt.expectedStagesToDelete, stageIDs)
+			}
+			if tags := indexTags(notUsedIndexes); !reflect.DeepEqual(tags, tt.expectedNotUsedIndexes) {
+				t.Errorf("expected not used indexes %v, got %v", tt.expectedNotUsedIndexes, tags)
+			}
+			if tags := indexTags(brokenIndexes); !reflect.DeepEqual(tags, tt.expectedBrokenIndexes) {
+				t.Errorf("expected broken indexes %v, got %v", tt.expectedBrokenIndexes, tags)
+			}
+		})
+	}
+}
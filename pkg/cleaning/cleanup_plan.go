@@ -0,0 +1,182 @@
+package cleaning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+type CleanupPlanItemKind string
+
+const (
+	CleanupPlanItemStage          CleanupPlanItemKind = "stage"
+	CleanupPlanItemImageMetadata  CleanupPlanItemKind = "image-metadata"
+	CleanupPlanItemImportMetadata CleanupPlanItemKind = "import-metadata"
+	CleanupPlanItemForeignTag     CleanupPlanItemKind = "foreign-tag"
+	CleanupPlanItemManagedImage   CleanupPlanItemKind = "managed-image"
+	CleanupPlanItemImageIndex     CleanupPlanItemKind = "image-index"
+)
+
+type CleanupPlanReason string
+
+const (
+	// CleanupPlanReasonGitHistoryPolicy is used for image metadata commits which are not kept by the git history-based cleanup policies
+	CleanupPlanReasonGitHistoryPolicy  CleanupPlanReason = "git-history-policy"
+	CleanupPlanReasonNonexistentCommit CleanupPlanReason = "nonexistent-commit"
+	CleanupPlanReasonNonexistentStage  CleanupPlanReason = "nonexistent-stage"
+	CleanupPlanReasonNonexistentImage  CleanupPlanReason = "nonexistent-image"
+	// CleanupPlanReasonNotUsed is used for stages which are neither used in Kubernetes nor related to the kept image metadata
+	CleanupPlanReasonNotUsed CleanupPlanReason = "not-used"
+	// CleanupPlanReasonAge is used for not used stages which have been built earlier than the keep period
+	CleanupPlanReasonAge CleanupPlanReason = "age"
+	// CleanupPlanReasonQuota is used for image metadata and stages which are kept by the git history-based cleanup policies,
+	// but exceed the cleanup quotas
+	CleanupPlanReasonQuota                  CleanupPlanReason = "quota"
+	CleanupPlanReasonNonexistentSourceStage CleanupPlanReason = "nonexistent-source-stage"
+	CleanupPlanReasonInvalidImportMetadata  CleanupPlanReason = "invalid-import-metadata"
+	// CleanupPlanReasonForeignTagRule is used for foreign tags which match the foreign tags delete rules
+	CleanupPlanReasonForeignTagRule CleanupPlanReason = "foreign-tag-rule"
+	// CleanupPlanReasonPurge and CleanupPlanReasonManualRemoval are used only in the cleanup audit log
+	CleanupPlanReasonPurge         CleanupPlanReason = "purge"
+	CleanupPlanReasonManualRemoval CleanupPlanReason = "manual-removal"
+)
+
+// CleanupPlan is the list of items which cleanup deletes, the plan is written by the planning cleanup run
+// and the apply run deletes only the items of the reviewed plan which still qualify for deletion.
+type CleanupPlan struct {
+	ProjectName   string
+	StagesStorage string
+	CreatedAt     time.Time
+	Items         []*CleanupPlanItem
+
+	// itemsByKey indexes the first indexedItemsCount items, the items appended to the Items directly are indexed on the next lookup
+	itemsByKey        map[cleanupPlanItemKey]bool
+	indexedItemsCount int
+}
+
+type CleanupPlanItem struct {
+	Kind    CleanupPlanItemKind
+	Reason  CleanupPlanReason
+	Details string `json:",omitempty"`
+
+	ImageName        string     `json:",omitempty"`
+	StageID          string     `json:",omitempty"`
+	Commit           string     `json:",omitempty"`
+	ImportMetadataID string     `json:",omitempty"`
+	ForeignTag       string     `json:",omitempty"`
+	ImageIndex       string     `json:",omitempty"`
+	DockerImageName  string     `json:",omitempty"`
+	StageCreatedAt   *time.Time `json:",omitempty"`
+}
+
+func NewCleanupPlan(projectName, stagesStorage string) *CleanupPlan {
+	return &CleanupPlan{
+		ProjectName:   projectName,
+		StagesStorage: stagesStorage,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+func ReadCleanupPlan(path string) (*CleanupPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cleanup plan %s: %s", path, err)
+	}
+
+	plan := &CleanupPlan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("unable to parse cleanup plan %s: %s", path, err)
+	}
+
+	return plan, nil
+}
+
+func (plan *CleanupPlan) Save(path string) error {
+	data, err := json.MarshalIndent(plan, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal cleanup plan: %s", err)
+	}
+	data = append(data, []byte("\n")...)
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("unable to write cleanup plan %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func (plan *CleanupPlan) Validate(projectName, stagesStorage string) error {
+	if plan.ProjectName != projectName {
+		return fmt.Errorf("cleanup plan has been created for the project %q, not %q", plan.ProjectName, projectName)
+	}
+
+	if plan.StagesStorage != stagesStorage {
+		return fmt.Errorf("cleanup plan has been created for the repo %q, not %q", plan.StagesStorage, stagesStorage)
+	}
+
+	return nil
+}
+
+func (plan *CleanupPlan) HasItem(item *CleanupPlanItem) bool {
+	plan.indexItems()
+	return plan.itemsByKey[item.key()]
+}
+
+func (plan *CleanupPlan) addItem(item *CleanupPlanItem) {
+	plan.Items = append(plan.Items, item)
+}
+
+func (plan *CleanupPlan) indexItems() {
+	if plan.itemsByKey == nil || plan.indexedItemsCount > len(plan.Items) {
+		plan.itemsByKey = map[cleanupPlanItemKey]bool{}
+		plan.indexedItemsCount = 0
+	}
+
+	for _, item := range plan.Items[plan.indexedItemsCount:] {
+		plan.itemsByKey[item.key()] = true
+	}
+	plan.indexedItemsCount = len(plan.Items)
+}
+
+// cleanupPlanItemKey identifies the deleted object, the reason may differ between the planning and the apply runs
+type cleanupPlanItemKey struct {
+	Kind             CleanupPlanItemKind
+	ImageName        string
+	StageID          string
+	Commit           string
+	ImportMetadataID string
+	ForeignTag       string
+	ImageIndex       string
+}
+
+func (item *CleanupPlanItem) key() cleanupPlanItemKey {
+	return cleanupPlanItemKey{
+		Kind:             item.Kind,
+		ImageName:        item.ImageName,
+		StageID:          item.StageID,
+		Commit:           item.Commit,
+		ImportMetadataID: item.ImportMetadataID,
+		ForeignTag:       item.ForeignTag,
+		ImageIndex:       item.ImageIndex,
+	}
+}
+
+func (item *CleanupPlanItem) String() string {
+	switch item.Kind {
+	case CleanupPlanItemStage:
+		return fmt.Sprintf("stage %s", item.StageID)
+	case CleanupPlanItemImageMetadata:
+		return fmt.Sprintf("image %s metadata stage ID %s commit %s", item.ImageName, item.StageID, item.Commit)
+	case CleanupPlanItemImportMetadata:
+		return fmt.Sprintf("import metadata %s", item.ImportMetadataID)
+	case CleanupPlanItemForeignTag:
+		return fmt.Sprintf("foreign tag %s", item.ForeignTag)
+	case CleanupPlanItemManagedImage:
+		return fmt.Sprintf("managed image %s", item.ImageName)
+	case CleanupPlanItemImageIndex:
+		return fmt.Sprintf("image index %s", item.ImageIndex)
+	default:
+		return string(item.Kind)
+	}
+}
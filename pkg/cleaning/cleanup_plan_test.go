@@ -0,0 +1,93 @@
+package cleaning
+
+import (
+	"testing"
+)
+
+func TestCleanupPlanHasItem(t *testing.T) {
+	plan := NewCleanupPlan("project", "stages-storage")
+	plan.addItem(&CleanupPlanItem{Kind: CleanupPlanItemStage, Reason: CleanupPlanReasonNotUsed, StageID: "digest-1"})
+	plan.addItem(&CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, Reason: CleanupPlanReasonGitHistoryPolicy, ImageName: "app", StageID: "digest-1", Commit: "commit-1"})
+	plan.addItem(&CleanupPlanItem{Kind: CleanupPlanItemForeignTag, Reason: CleanupPlanReasonForeignTagRule, ForeignTag: "latest"})
+	plan.addItem(&CleanupPlanItem{Kind: CleanupPlanItemImageIndex, Reason: CleanupPlanReasonNotUsed, ImageIndex: "image-index-1"})
+
+	tests := []struct {
+		name     string
+		item     *CleanupPlanItem
+		expected bool
+	}{
+		{
+			name:     "same stage with another reason",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemStage, Reason: CleanupPlanReasonQuota, Details: "exceeds the cleanup quotas", StageID: "digest-1"},
+			expected: true,
+		},
+		{
+			name:     "another stage",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemStage, StageID: "digest-2"},
+			expected: false,
+		},
+		{
+			name:     "same image metadata",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, ImageName: "app", StageID: "digest-1", Commit: "commit-1"},
+			expected: true,
+		},
+		{
+			name:     "image metadata of another commit",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, ImageName: "app", StageID: "digest-1", Commit: "commit-2"},
+			expected: false,
+		},
+		{
+			name:     "image metadata of another image",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, ImageName: "worker", StageID: "digest-1", Commit: "commit-1"},
+			expected: false,
+		},
+		{
+			name:     "same id of another kind",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageMetadata, StageID: "digest-1"},
+			expected: false,
+		},
+		{
+			name:     "same foreign tag",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemForeignTag, ForeignTag: "latest"},
+			expected: true,
+		},
+		{
+			name:     "same image index",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageIndex, ImageIndex: "image-index-1"},
+			expected: true,
+		},
+		{
+			name:     "another image index",
+			item:     &CleanupPlanItem{Kind: CleanupPlanItemImageIndex, ImageIndex: "image-index-2"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if hasItem := plan.HasItem(tt.item); hasItem != tt.expected {
+				t.Errorf("expected HasItem(%s) to be %v, got %v", tt.item.String(), tt.expected, hasItem)
+			}
+		})
+	}
+}
+
+func TestCleanupPlanHasItemAfterItemsChange(t *testing.T) {
+	item := &CleanupPlanItem{Kind: CleanupPlanItemStage, StageID: "digest-1"}
+
+	plan := NewCleanupPlan("project", "stages-storage")
+	if plan.HasItem(item) {
+		t.Fatalf("expected empty plan not to have %s", item.String())
+	}
+
+	plan.addItem(item)
+	if !plan.HasItem(item) {
+		t.Fatalf("expected plan to have the added %s", item.String())
+	}
+
+	// the items of the read plan can be replaced
+	plan.Items = nil
+	if plan.HasItem(item) {
+		t.Fatalf("expected plan without items not to have %s", item.String())
+	}
+}
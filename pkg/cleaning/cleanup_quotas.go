@@ -0,0 +1,206 @@
+package cleaning
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// applyQuotas deletes image metadata of the oldest stages kept by the git history-based cleanup policies
+// until the quotas are satisfied, so that these stages are deleted with the unused stages.
+// Stages used in Kubernetes and stages built within the keep period are never deleted by quotas.
+func (m *cleanupManager) applyQuotas(ctx context.Context) error {
+	quotas := m.GitHistoryBasedCleanupOptions.Quotas
+	m.quotaExceededStageIDs = map[string]bool{}
+
+	if quotas.MaxStagesPerImage != nil {
+		for imageName := range m.imageNameStageIDCommitList {
+			stageIDs := m.sortStageIDsByCreationTime(m.imageStageIDs(imageName))
+
+			stagesCount := len(stageIDs)
+			for _, stageID := range stageIDs {
+				if stagesCount <= *quotas.MaxStagesPerImage {
+					break
+				}
+
+				if m.isStageProtectedFromQuotas(stageID) {
+					continue
+				}
+
+				if err := m.deleteQuotaExceededImageMetadata(ctx, []string{imageName}, stageID); err != nil {
+					return err
+				}
+
+				// the deletion is skipped if it is not approved by the cleanup plan
+				if _, ok := m.imageNameStageIDCommitList[imageName][stageID]; !ok {
+					stagesCount--
+				}
+			}
+		}
+	}
+
+	if quotas.MaxStagesSize != nil {
+		var stageIDs []string
+		for imageName := range m.imageNameStageIDCommitList {
+			for _, stageID := range m.imageStageIDs(imageName) {
+				if !m.isStageProtectedFromQuotas(stageID) {
+					stageIDs = append(stageIDs, stageID)
+				}
+			}
+		}
+
+		keptStages := m.newKeptStagesSize()
+		for _, stageID := range m.sortStageIDsByCreationTime(stageIDs) {
+			if keptStages.size <= *quotas.MaxStagesSize {
+				break
+			}
+
+			var imageNames []string
+			for imageName, stageIDCommitList := range m.imageNameStageIDCommitList {
+				if _, ok := stageIDCommitList[stageID]; ok {
+					imageNames = append(imageNames, imageName)
+				}
+			}
+
+			if err := m.deleteQuotaExceededImageMetadata(ctx, imageNames, stageID); err != nil {
+				return err
+			}
+
+			if !m.isStageIDKept(stageID) {
+				keptStages.release(stageID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteQuotaExceededImageMetadata deletes the image metadata of the stage,
+// the stage is marked as exceeding the quotas only if it is not kept by any image metadata after the deletion
+func (m *cleanupManager) deleteQuotaExceededImageMetadata(ctx context.Context, imageNames []string, stageID string) error {
+	for _, imageName := range imageNames {
+		stageIDCommitList := map[string][]string{stageID: m.imageNameStageIDCommitList[imageName][stageID]}
+		if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, CleanupPlanReasonQuota, true); err != nil {
+			return err
+		}
+	}
+
+	if !m.isStageIDKept(stageID) {
+		m.quotaExceededStageIDs[stageID] = true
+
+		logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
+		logboek.Context(ctx).LogOptionalLn()
+	}
+
+	return nil
+}
+
+func (m *cleanupManager) imageStageIDs(imageName string) []string {
+	var stageIDs []string
+	for stageID := range m.imageNameStageIDCommitList[imageName] {
+		stageIDs = append(stageIDs, stageID)
+	}
+
+	return stageIDs
+}
+
+func (m *cleanupManager) isStageProtectedFromQuotas(stageID string) bool {
+	if m.deployedStageIDs[stageID] {
+		return true
+	}
+
+	if m.KeepStagesBuiltWithinLastNHours != 0 {
+		stage := m.mustGetStage(stageID)
+		if time.Since(stage.Info.GetCreatedAt()).Hours() <= float64(m.KeepStagesBuiltWithinLastNHours) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortStageIDsByCreationTime sorts stage IDs from the oldest to the newest
+func (m *cleanupManager) sortStageIDsByCreationTime(stageIDs []string) []string {
+	sort.SliceStable(stageIDs, func(i, j int) bool {
+		iStage := m.mustGetStage(stageIDs[i])
+		jStage := m.mustGetStage(stageIDs[j])
+		return iStage.Info.CreatedAtUnixNano < jStage.Info.CreatedAtUnixNano
+	})
+
+	return stageIDs
+}
+
+func (m *cleanupManager) isStageIDKept(stageID string) bool {
+	for _, stageIDCommitList := range m.imageNameStageIDCommitList {
+		if _, ok := stageIDCommitList[stageID]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keptStagesSize is the size of the stages related to the image metadata,
+// the size of each stage is calculated without the size of the parent stage to count the shared layers once.
+// The stages are counted by references, so the released stages are subtracted without recalculating the whole set.
+type keptStagesSize struct {
+	size            int64
+	stageOwnSizes   map[*image.StageDescription]int64
+	stageRelatives  map[string][]*image.StageDescription
+	stageReferences map[*image.StageDescription]int
+}
+
+func (m *cleanupManager) newKeptStagesSize() *keptStagesSize {
+	kept := &keptStagesSize{
+		stageOwnSizes:   map[*image.StageDescription]int64{},
+		stageRelatives:  map[string][]*image.StageDescription{},
+		stageReferences: map[*image.StageDescription]int{},
+	}
+
+	for _, stage := range m.stages {
+		kept.stageOwnSizes[stage] = stage.Info.Size
+		if parentStage := findStageByImageID(m.stages, stage.Info.ParentID); parentStage != nil && parentStage.Info.Size <= stage.Info.Size {
+			kept.stageOwnSizes[stage] -= parentStage.Info.Size
+		}
+	}
+
+	for _, stageIDCommitList := range m.imageNameStageIDCommitList {
+		for stageID := range stageIDCommitList {
+			if _, ok := kept.stageRelatives[stageID]; ok {
+				continue
+			}
+
+			_, relatives := m.excludeStageAndRelativesByImageID(m.stages, m.mustGetStage(stageID).Info.ID)
+			kept.stageRelatives[stageID] = relatives
+
+			for _, stage := range relatives {
+				if kept.stageReferences[stage] == 0 {
+					kept.size += kept.stageOwnSizes[stage]
+				}
+				kept.stageReferences[stage]++
+			}
+		}
+	}
+
+	return kept
+}
+
+// release subtracts the size of the stage relatives which are not referenced by the other kept stages
+func (kept *keptStagesSize) release(stageID string) {
+	relatives, ok := kept.stageRelatives[stageID]
+	if !ok {
+		return
+	}
+	delete(kept.stageRelatives, stageID)
+
+	for _, stage := range relatives {
+		kept.stageReferences[stage]--
+		if kept.stageReferences[stage] == 0 {
+			kept.size -= kept.stageOwnSizes[stage]
+		}
+	}
+}
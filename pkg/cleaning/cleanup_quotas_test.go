@@ -0,0 +1,167 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/image"
+)
+
+func newQuotasTestStage(stageID, parentStageID string, size int64, createdAtUnix int64) *image.StageDescription {
+	info := &image.Info{Tag: stageID, ID: "id-" + stageID, Size: size}
+	if parentStageID != "" {
+		info.ParentID = "id-" + parentStageID
+	}
+	info.SetCreatedAtUnix(createdAtUnix)
+
+	return &image.StageDescription{Info: info}
+}
+
+func TestApplyQuotas(t *testing.T) {
+	int64Ptr := func(v int64) *int64 { return &v }
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name                        string
+		stages                      []*image.StageDescription
+		imageNameStageIDCommitList  map[string]map[string][]string
+		deployedStageIDs            map[string]bool
+		quotas                      config.MetaCleanupQuotas
+		approvedPlanItems           []*CleanupPlanItem
+		expectedQuotaExceeded       map[string]bool
+		expectedImageStageIDCommits map[string]map[string][]string
+	}{
+		{
+			name: "max stages per image keeps the newest stages",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 10, 1),
+				newQuotasTestStage("s2", "", 10, 2),
+				newQuotasTestStage("s3", "", 10, 3),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app": {"s1": {"c1"}, "s2": {"c2"}, "s3": {"c3"}},
+			},
+			quotas:                      config.MetaCleanupQuotas{MaxStagesPerImage: intPtr(1)},
+			expectedQuotaExceeded:       map[string]bool{"s1": true, "s2": true},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {"s3": {"c3"}}},
+		},
+		{
+			name: "max stages per image does not delete deployed stages",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 10, 1),
+				newQuotasTestStage("s2", "", 10, 2),
+				newQuotasTestStage("s3", "", 10, 3),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app": {"s1": {"c1"}, "s2": {"c2"}, "s3": {"c3"}},
+			},
+			deployedStageIDs:            map[string]bool{"s1": true},
+			quotas:                      config.MetaCleanupQuotas{MaxStagesPerImage: intPtr(2)},
+			expectedQuotaExceeded:       map[string]bool{"s2": true},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {"s1": {"c1"}, "s3": {"c3"}}},
+		},
+		{
+			name: "max stages size counts shared parent stages once",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 100, 1),
+				newQuotasTestStage("s2", "s1", 150, 2),
+				newQuotasTestStage("s3", "", 300, 3),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app":    {"s1": {"c1"}, "s2": {"c2"}},
+				"worker": {"s3": {"c3"}},
+			},
+			quotas:                      config.MetaCleanupQuotas{MaxStagesSize: int64Ptr(400)},
+			expectedQuotaExceeded:       map[string]bool{"s1": true, "s2": true},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {}, "worker": {"s3": {"c3"}}},
+		},
+		{
+			name: "max stages size is not exceeded",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 100, 1),
+				newQuotasTestStage("s2", "s1", 150, 2),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app": {"s1": {"c1"}, "s2": {"c2"}},
+			},
+			quotas:                      config.MetaCleanupQuotas{MaxStagesSize: int64Ptr(150)},
+			expectedQuotaExceeded:       map[string]bool{},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {"s1": {"c1"}, "s2": {"c2"}}},
+		},
+		{
+			name: "max stages per image counts only the deletions approved by the plan",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 10, 1),
+				newQuotasTestStage("s2", "", 10, 2),
+				newQuotasTestStage("s3", "", 10, 3),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app": {"s1": {"c1"}, "s2": {"c2"}, "s3": {"c3"}},
+			},
+			quotas: config.MetaCleanupQuotas{MaxStagesPerImage: intPtr(1)},
+			approvedPlanItems: []*CleanupPlanItem{
+				{Kind: CleanupPlanItemImageMetadata, ImageName: "app", StageID: "s1", Commit: "c1"},
+			},
+			expectedQuotaExceeded:       map[string]bool{"s1": true},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {"s2": {"c2"}, "s3": {"c3"}}},
+		},
+		{
+			name: "max stages size releases only the stages which deletion is approved by the plan",
+			stages: []*image.StageDescription{
+				newQuotasTestStage("s1", "", 100, 1),
+				newQuotasTestStage("s2", "", 100, 2),
+				newQuotasTestStage("s3", "", 100, 3),
+			},
+			imageNameStageIDCommitList: map[string]map[string][]string{
+				"app": {"s1": {"c1"}, "s2": {"c2"}, "s3": {"c3"}},
+			},
+			quotas: config.MetaCleanupQuotas{MaxStagesSize: int64Ptr(200)},
+			approvedPlanItems: []*CleanupPlanItem{
+				{Kind: CleanupPlanItemImageMetadata, ImageName: "app", StageID: "s2", Commit: "c2"},
+			},
+			expectedQuotaExceeded:       map[string]bool{"s2": true},
+			expectedImageStageIDCommits: map[string]map[string][]string{"app": {"s1": {"c1"}, "s3": {"c3"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &cleanupManager{
+				stages:                              tt.stages,
+				imageNameStageIDCommitList:          tt.imageNameStageIDCommitList,
+				imageNameStageIDCommitListToCleanup: map[string]map[string][]string{},
+				deployedStageIDs:                    tt.deployedStageIDs,
+				plan:                                NewCleanupPlan("project", "stages-storage"),
+				audit:                               NewAuditLog("werf cleanup", ""),
+				GitHistoryBasedCleanupOptions:       config.MetaCleanup{Quotas: tt.quotas},
+				DryRun:                              true,
+			}
+
+			if tt.approvedPlanItems != nil {
+				m.ApprovedPlan = NewCleanupPlan("project", "stages-storage")
+				m.ApprovedPlan.Items = tt.approvedPlanItems
+			}
+
+			for imageName, stageIDCommitList := range tt.imageNameStageIDCommitList {
+				m.imageNameStageIDCommitListToCleanup[imageName] = map[string][]string{}
+				for stageID, commitList := range stageIDCommitList {
+					m.imageNameStageIDCommitListToCleanup[imageName][stageID] = commitList
+				}
+			}
+
+			if err := m.applyQuotas(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(m.quotaExceededStageIDs, tt.expectedQuotaExceeded) {
+				t.Errorf("unexpected quota exceeded stages %v, expected %v", m.quotaExceededStageIDs, tt.expectedQuotaExceeded)
+			}
+
+			if !reflect.DeepEqual(m.imageNameStageIDCommitList, tt.expectedImageStageIDCommits) {
+				t.Errorf("unexpected kept image metadata %v, expected %v", m.imageNameStageIDCommitList, tt.expectedImageStageIDCommits)
+			}
+		})
+	}
+}
@@ -123,51 +123,7 @@ func ReferencesToScan(ctx context.Context, gitRepository *git.Repository, keepPo
 	}
 
 	// Apply user or default policies
-	if len(keepPolicies) == 0 {
-		tagLast := 10
-		keepPolicies = append(keepPolicies, &config.MetaCleanupKeepPolicy{
-			References: config.MetaCleanupKeepPolicyReferences{
-				TagRegexp: regexp.MustCompile(".*"),
-				Limit: &config.MetaCleanupKeepPolicyLimit{
-					Last: &tagLast,
-				},
-			},
-		})
-
-		branchLast := 10
-		branchIn := time.Hour * 24 * 7
-		branchImagesPerReferenceLast := 2
-		branchImagesPerReferenceIn := time.Hour * 24 * 7
-		keepPolicies = append(keepPolicies, &config.MetaCleanupKeepPolicy{
-			References: config.MetaCleanupKeepPolicyReferences{
-				BranchRegexp: regexp.MustCompile(".*"),
-				Limit: &config.MetaCleanupKeepPolicyLimit{
-					Last:     &branchLast,
-					In:       &branchIn,
-					Operator: &config.AndOperator,
-				},
-			},
-			ImagesPerReference: config.MetaCleanupKeepPolicyImagesPerReference{
-				MetaCleanupKeepPolicyLimit: config.MetaCleanupKeepPolicyLimit{
-					Last:     &branchImagesPerReferenceLast,
-					In:       &branchImagesPerReferenceIn,
-					Operator: &config.AndOperator,
-				},
-			},
-		})
-
-		mainBranchImagesPerReferenceLast := 10
-		keepPolicies = append(keepPolicies, &config.MetaCleanupKeepPolicy{
-			References: config.MetaCleanupKeepPolicyReferences{
-				BranchRegexp: regexp.MustCompile("^(master|staging|production)$"),
-			},
-			ImagesPerReference: config.MetaCleanupKeepPolicyImagesPerReference{
-				MetaCleanupKeepPolicyLimit: config.MetaCleanupKeepPolicyLimit{
-					Last: &mainBranchImagesPerReferenceLast,
-				},
-			},
-		})
-	}
+	keepPolicies = KeepPoliciesOrDefault(keepPolicies)
 
 	var resultTagsRefs, resultBranchesRefs []*ReferenceToScan
 	for _, policy := range keepPolicies {
@@ -204,6 +160,61 @@ func ReferencesToScan(ctx context.Context, gitRepository *git.Repository, keepPo
 	return result, nil
 }
 
+// KeepPoliciesOrDefault returns the default keep policies if no user policies are specified
+func KeepPoliciesOrDefault(keepPolicies []*config.MetaCleanupKeepPolicy) []*config.MetaCleanupKeepPolicy {
+	if len(keepPolicies) != 0 {
+		return keepPolicies
+	}
+
+	var defaultKeepPolicies []*config.MetaCleanupKeepPolicy
+
+	tagLast := 10
+	defaultKeepPolicies = append(defaultKeepPolicies, &config.MetaCleanupKeepPolicy{
+		References: config.MetaCleanupKeepPolicyReferences{
+			TagRegexp: regexp.MustCompile(".*"),
+			Limit: &config.MetaCleanupKeepPolicyLimit{
+				Last: &tagLast,
+			},
+		},
+	})
+
+	branchLast := 10
+	branchIn := time.Hour * 24 * 7
+	branchImagesPerReferenceLast := 2
+	branchImagesPerReferenceIn := time.Hour * 24 * 7
+	defaultKeepPolicies = append(defaultKeepPolicies, &config.MetaCleanupKeepPolicy{
+		References: config.MetaCleanupKeepPolicyReferences{
+			BranchRegexp: regexp.MustCompile(".*"),
+			Limit: &config.MetaCleanupKeepPolicyLimit{
+				Last:     &branchLast,
+				In:       &branchIn,
+				Operator: &config.AndOperator,
+			},
+		},
+		ImagesPerReference: config.MetaCleanupKeepPolicyImagesPerReference{
+			MetaCleanupKeepPolicyLimit: config.MetaCleanupKeepPolicyLimit{
+				Last:     &branchImagesPerReferenceLast,
+				In:       &branchImagesPerReferenceIn,
+				Operator: &config.AndOperator,
+			},
+		},
+	})
+
+	mainBranchImagesPerReferenceLast := 10
+	defaultKeepPolicies = append(defaultKeepPolicies, &config.MetaCleanupKeepPolicy{
+		References: config.MetaCleanupKeepPolicyReferences{
+			BranchRegexp: regexp.MustCompile("^(master|staging|production)$"),
+		},
+		ImagesPerReference: config.MetaCleanupKeepPolicyImagesPerReference{
+			MetaCleanupKeepPolicyLimit: config.MetaCleanupKeepPolicyLimit{
+				Last: &mainBranchImagesPerReferenceLast,
+			},
+		},
+	})
+
+	return defaultKeepPolicies
+}
+
 func selectBranchReferencesByRegexp(branchesRefs []*ReferenceToScan, regexp *regexp.Regexp) []*ReferenceToScan {
 	var result []*ReferenceToScan
 
@@ -0,0 +1,210 @@
+package git_history_based_cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/slug"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const ScanStateCacheVersion = "2"
+
+// ScanState is the persisted result of the branches history scan. For each image the state keeps the expected commits
+// reached from the branches heads, thus the next scan walks only the commits added to the branch since the previous scan.
+// Only the heads, the scan limits and the reached expected commits are saved, the walked commits are not.
+type ScanState struct {
+	PoliciesDigest string
+	Images         map[string]*ImageScanState
+
+	newImages map[string]*ImageScanState
+	path      string
+}
+
+type ImageScanState struct {
+	// ExpectedCommits are the commits of the image metadata which have been looked for by the previous scan
+	ExpectedCommits []string
+	References      map[string]*ReferenceScanState
+}
+
+type ReferenceScanState struct {
+	HeadCommit string
+	// ScanDepthLimit and StopCommits are the limits of the scan, the reached commits are reused only with the same limits
+	ScanDepthLimit int
+	StopCommits    []string
+	// ReachedCommits are the expected commits reached from the head commit in the scan order
+	ReachedCommits []string
+}
+
+func ScanStatePath(projectName, stagesStorageAddress string) string {
+	return filepath.Join(werf.GetLocalCacheDir(), "git_history_based_cleanup", ScanStateCacheVersion, slug.Slug(projectName), util.Sha256Hash(stagesStorageAddress))
+}
+
+// LoadScanState reads the state of the previous scan, the state is reset when the keep policies have been changed
+func LoadScanState(ctx context.Context, path string, keepPolicies []*config.MetaCleanupKeepPolicy) (*ScanState, error) {
+	policiesDigest := keepPoliciesDigest(keepPolicies)
+	newState := &ScanState{PoliciesDigest: policiesDigest, newImages: map[string]*ImageScanState{}, path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return newState, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", path, err)
+	}
+
+	state := &ScanState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: invalid git history scan state in file %s: %s: resetting state\n", path, err)
+		return newState, nil
+	}
+
+	if state.PoliciesDigest != policiesDigest {
+		logboek.Context(ctx).Info().LogLn("Keep policies have been changed: resetting git history scan state")
+		return newState, nil
+	}
+
+	state.newImages = map[string]*ImageScanState{}
+	state.path = path
+
+	return state, nil
+}
+
+// Save replaces the previous state with the states of the images scanned since the state has been loaded
+func (state *ScanState) Save() error {
+	data, err := json.Marshal(&ScanState{PoliciesDigest: state.PoliciesDigest, Images: state.newImages})
+	if err != nil {
+		return fmt.Errorf("unable to marshal git history scan state: %s", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(state.path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(state.path), err)
+	}
+
+	tmpPath := state.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, append(data, []byte("\n")...), 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, state.path); err != nil {
+		return fmt.Errorf("unable to rename %s to %s: %s", tmpPath, state.path, err)
+	}
+
+	return nil
+}
+
+// ImageScan returns the scan of the image expected commits. The previous image state is reused only when
+// there are no new expected commits, because the previous scan has not looked for them in the already walked history.
+func (state *ScanState) ImageScan(imageName string, expectedStageIDCommitList map[string][]string) *ImageScan {
+	expectedCommits := map[string]bool{}
+	for _, commitList := range expectedStageIDCommitList {
+		for _, commit := range commitList {
+			expectedCommits[commit] = true
+		}
+	}
+
+	imageScan := &ImageScan{
+		state: &ImageScanState{
+			ExpectedCommits: sortedKeys(expectedCommits),
+			References:      map[string]*ReferenceScanState{},
+		},
+	}
+	state.newImages[imageName] = imageScan.state
+
+	if prevState, ok := state.Images[imageName]; ok {
+		prevExpectedCommits := stringSet(prevState.ExpectedCommits)
+		for commit := range expectedCommits {
+			if !prevExpectedCommits[commit] {
+				return imageScan
+			}
+		}
+
+		imageScan.prevState = prevState
+	}
+
+	return imageScan
+}
+
+// ImageScan provides the previous scan results of the image references and collects the new ones
+type ImageScan struct {
+	prevState *ImageScanState
+	state     *ImageScanState
+}
+
+// prevReferenceState returns the previous scan state of the reference which has been scanned with the same limits
+func (scan *ImageScan) prevReferenceState(ref *ReferenceToScan, stopCommitList []string) *ReferenceScanState {
+	if scan == nil || scan.prevState == nil || !isReferenceScanStateSupported(ref) {
+		return nil
+	}
+
+	refState, ok := scan.prevState.References[referenceScanStateKey(ref)]
+	if !ok {
+		return nil
+	}
+
+	if refState.ScanDepthLimit != ref.scanDepthLimit || !reflect.DeepEqual(stringSet(refState.StopCommits), stringSet(stopCommitList)) {
+		return nil
+	}
+
+	return refState
+}
+
+func (scan *ImageScan) setReferenceState(ref *ReferenceToScan, stopCommitList, reachedCommits []string) {
+	if scan == nil || !isReferenceScanStateSupported(ref) {
+		return
+	}
+
+	scan.state.References[referenceScanStateKey(ref)] = &ReferenceScanState{
+		HeadCommit:     ref.HeadCommit.Hash.String(),
+		ScanDepthLimit: ref.scanDepthLimit,
+		StopCommits:    sortedKeys(stringSet(stopCommitList)),
+		ReachedCommits: append([]string{}, reachedCommits...),
+	}
+}
+
+// isReferenceScanStateSupported checks that the reference history is scanned, tags are scanned only by the head commit
+func isReferenceScanStateSupported(ref *ReferenceToScan) bool {
+	return ref.scanDepthLimit == -1
+}
+
+// referenceScanStateKey distinguishes the same reference matched by the keep policies with different images limits
+func referenceScanStateKey(ref *ReferenceToScan) string {
+	return fmt.Sprintf("%s %s", ref.Name().String(), ref.imagesCleanupKeepPolicy.String())
+}
+
+func keepPoliciesDigest(keepPolicies []*config.MetaCleanupKeepPolicy) string {
+	var args []string
+	for _, policy := range keepPolicies {
+		args = append(args, policy.String())
+	}
+
+	return util.Sha256Hash(args...)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func stringSet(list []string) map[string]bool {
+	set := map[string]bool{}
+	for _, s := range list {
+		set[s] = true
+	}
+
+	return set
+}
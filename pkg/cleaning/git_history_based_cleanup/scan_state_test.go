@@ -0,0 +1,191 @@
+package git_history_based_cleanup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+type scanStateTestRepo struct {
+	t             *testing.T
+	gitRepository *git.Repository
+	commitTime    time.Time
+}
+
+func newScanStateTestRepo(t *testing.T) *scanStateTestRepo {
+	gitRepository, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &scanStateTestRepo{t: t, gitRepository: gitRepository, commitTime: time.Now().Add(-time.Hour)}
+}
+
+func (r *scanStateTestRepo) commit(parents ...string) string {
+	storer := r.gitRepository.Storer
+
+	treeObj := storer.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(treeObj); err != nil {
+		r.t.Fatal(err)
+	}
+	treeHash, err := storer.SetEncodedObject(treeObj)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	r.commitTime = r.commitTime.Add(time.Minute)
+	signature := object.Signature{Name: "test", Email: "test@example.com", When: r.commitTime}
+
+	var parentHashes []plumbing.Hash
+	for _, parent := range parents {
+		parentHashes = append(parentHashes, plumbing.NewHash(parent))
+	}
+
+	commitObj := storer.NewEncodedObject()
+	if err := (&object.Commit{Author: signature, Committer: signature, Message: r.commitTime.String(), TreeHash: treeHash, ParentHashes: parentHashes}).Encode(commitObj); err != nil {
+		r.t.Fatal(err)
+	}
+	commitHash, err := storer.SetEncodedObject(commitObj)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	return commitHash.String()
+}
+
+func (r *scanStateTestRepo) branch(name, headCommit string) *ReferenceToScan {
+	commit, err := r.gitRepository.CommitObject(plumbing.NewHash(headCommit))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	return &ReferenceToScan{
+		Reference:            plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", name), commit.Hash),
+		HeadCommit:           commit,
+		referenceScanOptions: referenceScanOptions{scanDepthLimit: -1},
+	}
+}
+
+func (r *scanStateTestRepo) scan(refs []*ReferenceToScan, expectedStageIDCommitList map[string][]string, imageScan *ImageScan) []string {
+	reachedStageIDs, _, err := ScanReferencesHistory(context.Background(), r.gitRepository, refs, expectedStageIDCommitList, imageScan)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	sort.Strings(reachedStageIDs)
+
+	return reachedStageIDs
+}
+
+func loadTestScanState(t *testing.T, path string, keepPolicies []*config.MetaCleanupKeepPolicy) *ScanState {
+	state, err := LoadScanState(context.Background(), path, keepPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return state
+}
+
+func TestScanState(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-scan-state-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	statePath := filepath.Join(tmpDir, "state")
+
+	r := newScanStateTestRepo(t)
+	c1 := r.commit()
+	c2 := r.commit(c1)
+	c3 := r.commit(c2)
+	expected := map[string][]string{"stage-1": {c1}, "stage-2": {c3}}
+
+	state := loadTestScanState(t, statePath, nil)
+	main := r.branch("main", c3)
+	if reached := r.scan([]*ReferenceToScan{main}, expected, state.ImageScan("app", expected)); !reflect.DeepEqual(reached, []string{"stage-1", "stage-2"}) {
+		t.Fatalf("unexpected reached stages %v", reached)
+	}
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	state = loadTestScanState(t, statePath, nil)
+	refState := state.Images["app"].References[referenceScanStateKey(main)]
+	if refState == nil || refState.HeadCommit != c3 || refState.ScanDepthLimit != -1 || len(refState.StopCommits) != 0 || !reflect.DeepEqual(refState.ReachedCommits, []string{c3, c1}) {
+		t.Fatalf("unexpected saved reference state %#v", refState)
+	}
+
+	t.Run("previous state is reused when only new commits are added", func(t *testing.T) {
+		// the previous state is faked to check that the already scanned history is not walked again
+		state.Images["app"].References[referenceScanStateKey(main)].ReachedCommits = []string{c3}
+
+		c4 := r.commit(c3)
+		if reached := r.scan([]*ReferenceToScan{r.branch("main", c4)}, expected, state.ImageScan("app", expected)); !reflect.DeepEqual(reached, []string{"stage-2"}) {
+			t.Errorf("expected the previous state to be reused, got reached stages %v", reached)
+		}
+	})
+
+	t.Run("previous state is not reused when the branch has been force pushed", func(t *testing.T) {
+		c5 := r.commit(c1)
+		if reached := r.scan([]*ReferenceToScan{r.branch("main", c5)}, expected, state.ImageScan("app", expected)); !reflect.DeepEqual(reached, []string{"stage-1"}) {
+			t.Errorf("expected the whole history to be scanned, got reached stages %v", reached)
+		}
+	})
+
+	t.Run("previous state is not reused when there are new expected commits", func(t *testing.T) {
+		imageScan := state.ImageScan("app", map[string][]string{"stage-1": {c1}, "stage-2": {c3}, "stage-3": {c2}})
+		if imageScan.prevReferenceState(main, nil) != nil {
+			t.Errorf("expected the previous state not to be reused")
+		}
+	})
+
+	t.Run("previous state is reused only with the same scan limits", func(t *testing.T) {
+		imageScan := state.ImageScan("app", map[string][]string{"stage-1": {c1}})
+		if imageScan.prevReferenceState(main, nil) == nil {
+			t.Errorf("expected the previous state to be reused with the same limits")
+		}
+
+		if imageScan.prevReferenceState(main, []string{c2}) != nil {
+			t.Errorf("expected the previous state not to be reused with another stop commits")
+		}
+
+		tag := r.branch("main", c3)
+		tag.scanDepthLimit = 1
+		if imageScan.prevReferenceState(tag, nil) != nil {
+			t.Errorf("expected the previous state not to be reused with another scan depth limit")
+		}
+	})
+
+	t.Run("only scanned images are saved", func(t *testing.T) {
+		state := loadTestScanState(t, statePath, nil)
+		state.ImageScan("backend", map[string][]string{"stage-1": {c1}})
+		if err := state.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		state = loadTestScanState(t, statePath, nil)
+		if _, ok := state.Images["app"]; ok || len(state.Images) != 1 {
+			t.Errorf("unexpected saved images %v", state.Images)
+		}
+	})
+
+	t.Run("state is reset when the keep policies are changed", func(t *testing.T) {
+		keepPolicy := &config.MetaCleanupKeepPolicy{References: config.MetaCleanupKeepPolicyReferences{BranchRegexp: regexp.MustCompile(".*")}}
+		state := loadTestScanState(t, statePath, []*config.MetaCleanupKeepPolicy{keepPolicy})
+		if len(state.Images) != 0 {
+			t.Errorf("expected the state to be reset, got images %v", state.Images)
+		}
+	})
+}
@@ -17,7 +17,9 @@ import (
 	"github.com/werf/werf/pkg/util"
 )
 
-func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, refs []*ReferenceToScan, expectedStageIDCommitList map[string][]string) ([]string, map[string][]string, error) {
+// ScanReferencesHistory scans the references history for the expected commits,
+// the branches history walked by the previous scan of the image is not walked again
+func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, refs []*ReferenceToScan, expectedStageIDCommitList map[string][]string, imageScan *ImageScan) ([]string, map[string][]string, error) {
 	var reachedStageIDs []string
 	var stopCommitList []string
 	stageIDHitCommitList := map[string][]string{}
@@ -38,7 +40,7 @@ func ScanReferencesHistory(ctx context.Context, gitRepository *git.Repository, r
 		}
 
 		if err := logboek.Context(ctx).Info().LogProcess(logProcessMessage).DoError(func() error {
-			refReachedStageIDs, refStopCommitList, refStageIDHitCommitList, err = scanReferenceHistory(ctx, gitRepository, ref, expectedStageIDCommitList, stopCommitList)
+			refReachedStageIDs, refStopCommitList, refStageIDHitCommitList, err = scanReferenceHistory(ctx, gitRepository, ref, expectedStageIDCommitList, stopCommitList, imageScan)
 			if err != nil {
 				return fmt.Errorf("scan reference history failed: %s", err)
 			}
@@ -99,6 +101,7 @@ type commitHistoryScanner struct {
 	reachedStageIDCommitList  map[string][]string
 	reachedCommitList         []string
 	stopCommitList            []string
+	reachedStopCommitList     []string
 	isAlreadyScannedCommit    map[string]bool
 	scanDepth                 int
 
@@ -116,7 +119,7 @@ func (s *commitHistoryScanner) reachedStageIDList() []string {
 	return reachedStageIDList
 }
 
-func scanReferenceHistory(ctx context.Context, gitRepository *git.Repository, ref *ReferenceToScan, expectedStageIDCommitList map[string][]string, stopCommitList []string) ([]string, []string, map[string][]string, error) {
+func scanReferenceHistory(ctx context.Context, gitRepository *git.Repository, ref *ReferenceToScan, expectedStageIDCommitList map[string][]string, stopCommitList []string, imageScan *ImageScan) ([]string, []string, map[string][]string, error) {
 	filteredExpectedStageIDCommitList := applyImagesCleanupInPolicy(gitRepository, expectedStageIDCommitList, ref.imagesCleanupKeepPolicy.In)
 
 	refExpectedStageIDCommitList := map[string][]string{}
@@ -132,20 +135,41 @@ func scanReferenceHistory(ctx context.Context, gitRepository *git.Repository, re
 		return []string{}, stopCommitList, map[string][]string{}, nil
 	}
 
-	s := &commitHistoryScanner{
-		gitRepository:             gitRepository,
-		expectedStageIDCommitList: refExpectedStageIDCommitList,
-		reachedStageIDCommitList:  map[string][]string{},
-		stopCommitList:            stopCommitList,
+	newScanner := func() *commitHistoryScanner {
+		return &commitHistoryScanner{
+			gitRepository:             gitRepository,
+			expectedStageIDCommitList: refExpectedStageIDCommitList,
+			reachedStageIDCommitList:  map[string][]string{},
+			stopCommitList:            stopCommitList,
 
-		referenceScanOptions:   ref.referenceScanOptions,
-		isAlreadyScannedCommit: map[string]bool{},
+			referenceScanOptions:   ref.referenceScanOptions,
+			isAlreadyScannedCommit: map[string]bool{},
+		}
+	}
+
+	s := newScanner()
+	isScanned := false
+	if prevRefState := imageScan.prevReferenceState(ref, stopCommitList); prevRefState != nil {
+		var err error
+		isScanned, err = s.scanCommitHistorySincePrevScan(ctx, ref.HeadCommit.Hash.String(), prevRefState)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("scan commit %s history failed: %s", ref.HeadCommit.Hash.String(), err)
+		}
+
+		if !isScanned {
+			logboek.Context(ctx).Info().LogF("Previous head commit %s is not reachable: scanning the whole history\n", prevRefState.HeadCommit)
+			s = newScanner()
+		}
 	}
 
-	if err := s.scanCommitHistory(ctx, ref.HeadCommit.Hash.String()); err != nil {
-		return nil, nil, nil, fmt.Errorf("scan commit %s history failed: %s", ref.HeadCommit.Hash.String(), err)
+	if !isScanned {
+		if err := s.scanCommitHistory(ctx, ref.HeadCommit.Hash.String()); err != nil {
+			return nil, nil, nil, fmt.Errorf("scan commit %s history failed: %s", ref.HeadCommit.Hash.String(), err)
+		}
 	}
 
+	imageScan.setReferenceState(ref, stopCommitList, s.reachedCommitList)
+
 	isImagesCleanupKeepPolicyLastWithoutLimit := s.referenceScanOptions.imagesCleanupKeepPolicy.Last != nil && *s.referenceScanOptions.imagesCleanupKeepPolicy.Last != -1
 	if isImagesCleanupKeepPolicyLastWithoutLimit {
 		if len(s.reachedStageIDList()) == *s.referenceScanOptions.imagesCleanupKeepPolicy.Last {
@@ -326,10 +350,13 @@ func (s *commitHistoryScanner) scanCommitHistory(ctx context.Context, commit str
 
 			if s.isStopCommit(commit) {
 				logboek.Context(ctx).Debug().LogF("Stop scanning commit history %s due to stop commit reached\n", commit)
+				s.reachedStopCommitList = append(s.reachedStopCommitList, commit)
 				continue
 			}
 
-			commitParents, err := s.handleCommit(ctx, commit)
+			s.handleCommit(ctx, commit)
+
+			commitParents, err := s.commitParents(commit)
 			if err != nil {
 				return err
 			}
@@ -359,7 +386,47 @@ func (s *commitHistoryScanner) scanCommitHistory(ctx context.Context, commit str
 	return nil
 }
 
-func (s *commitHistoryScanner) handleCommit(ctx context.Context, commit string) ([]string, error) {
+// scanCommitHistorySincePrevScan walks only the commits added since the previous scan which has been performed
+// with the same limits, the expected commits reachable from the previous head are taken from the previous scan.
+// Returns false when the previous head is not reachable from the head (e.g. the branch has been force pushed).
+func (s *commitHistoryScanner) scanCommitHistorySincePrevScan(ctx context.Context, headCommit string, prevRefState *ReferenceScanState) (bool, error) {
+	if headCommit != prevRefState.HeadCommit {
+		stopCommitList := s.stopCommitList
+		s.stopCommitList = append(append([]string{}, stopCommitList...), prevRefState.HeadCommit)
+		err := s.scanCommitHistory(ctx, headCommit)
+		s.stopCommitList = stopCommitList
+		if err != nil {
+			return false, err
+		}
+
+		if !util.IsStringsContainValue(s.reachedStopCommitList, prevRefState.HeadCommit) && len(s.expectedStageIDCommitList) != len(s.reachedStageIDCommitList) {
+			return false, nil
+		}
+	}
+
+	logboek.Context(ctx).Info().LogF("Previous scan of head commit %s has been used\n", prevRefState.HeadCommit)
+	s.handleReachedCommits(ctx, prevRefState.ReachedCommits)
+
+	return true, nil
+}
+
+// handleReachedCommits handles the expected commits reachable from the reference head without walking the history
+func (s *commitHistoryScanner) handleReachedCommits(ctx context.Context, commits []string) {
+	for _, commit := range commits {
+		if len(s.expectedStageIDCommitList) == len(s.reachedStageIDCommitList) {
+			logboek.Context(ctx).Debug().LogLn("Stop handling reached commits due to all expected tags reached")
+			break
+		}
+
+		if util.IsStringsContainValue(s.reachedCommitList, commit) {
+			continue
+		}
+
+		s.handleCommit(ctx, commit)
+	}
+}
+
+func (s *commitHistoryScanner) handleCommit(ctx context.Context, commit string) {
 	var isReachedCommit bool
 
 outerLoop:
@@ -410,7 +477,9 @@ outerLoop:
 	if isReachedCommit {
 		s.reachedCommitList = append(s.reachedCommitList, commit)
 	}
+}
 
+func (s *commitHistoryScanner) commitParents(commit string) ([]string, error) {
 	co, err := s.gitRepository.CommitObject(plumbing.NewHash(commit))
 	if err != nil {
 		return nil, fmt.Errorf("commit hash %s resolve failed: %s", commit, err)
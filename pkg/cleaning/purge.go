@@ -17,11 +17,19 @@ type PurgeOptions struct {
 }
 
 func Purge(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options PurgeOptions) error {
-	return newPurgeManager(projectName, storageManager, options).run(ctx)
+	m := newPurgeManager(projectName, storageManager, options)
+	err := m.run(ctx)
+
+	if m.DryRun {
+		return err
+	}
+
+	return m.audit.saveAfterRun(ctx, projectName, storageManager.StagesStorage, err)
 }
 
 func newPurgeManager(projectName string, storageManager *manager.StorageManager, options PurgeOptions) *purgeManager {
 	return &purgeManager{
+		audit:                         NewAuditLog("werf purge", CleanupPlanReasonPurge),
 		StorageManager:                storageManager,
 		ProjectName:                   projectName,
 		RmContainersThatUseWerfImages: options.RmContainersThatUseWerfImages,
@@ -30,6 +38,8 @@ func newPurgeManager(projectName string, storageManager *manager.StorageManager,
 }
 
 type purgeManager struct {
+	audit *AuditLog
+
 	StorageManager                *manager.StorageManager
 	ProjectName                   string
 	RmContainersThatUseWerfImages bool
@@ -37,6 +47,19 @@ type purgeManager struct {
 }
 
 func (m *purgeManager) run(ctx context.Context) error {
+	if repoStagesStorage, ok := m.StorageManager.StagesStorage.(*storage.RepoStagesStorage); ok {
+		if err := logboek.Context(ctx).Default().LogProcess("Deleting images indexes").DoError(func() error {
+			indexes, err := repoStagesStorage.GetImageIndexDescriptionList(ctx)
+			if err != nil {
+				return err
+			}
+
+			return deleteImagesIndexes(ctx, repoStagesStorage, m.audit, m.DryRun, indexes)
+		}); err != nil {
+			return err
+		}
+	}
+
 	if err := logboek.Context(ctx).Default().LogProcess("Deleting stages").DoError(func() error {
 		stages, err := m.StorageManager.GetStageDescriptionList(ctx)
 		if err != nil {
@@ -106,11 +129,11 @@ func (m *purgeManager) deleteStages(ctx context.Context, stages []*image.StageDe
 		},
 	}
 
-	return deleteStages(ctx, m.StorageManager, m.DryRun, deleteStageOptions, stages)
+	return deleteStages(ctx, m.StorageManager, m.audit, m.DryRun, deleteStageOptions, stages)
 }
 
 func (m *purgeManager) deleteImportsMetadata(ctx context.Context, importsMetadataIDs []string) error {
-	return deleteImportsMetadata(ctx, m.ProjectName, m.StorageManager, importsMetadataIDs, m.DryRun)
+	return deleteImportsMetadata(ctx, m.ProjectName, m.StorageManager, m.audit, importsMetadataIDs, m.DryRun)
 }
 
 func (m *purgeManager) deleteManagedImages(ctx context.Context, managedImages []string) error {
@@ -133,6 +156,7 @@ func (m *purgeManager) deleteManagedImages(ctx context.Context, managedImages []
 			return nil
 		}
 
+		m.audit.Deleted(&CleanupPlanItem{Kind: CleanupPlanItemManagedImage, ImageName: managedImage})
 		logboek.Context(ctx).Default().LogFDetails("  name: %s\n", logging.ImageLogName(managedImage, false))
 
 		return nil
@@ -140,5 +164,5 @@ func (m *purgeManager) deleteManagedImages(ctx context.Context, managedImages []
 }
 
 func (m *purgeManager) deleteImageMetadata(ctx context.Context, imageNameOrID string, stageIDCommitList map[string][]string) error {
-	return deleteImageMetadata(ctx, m.ProjectName, m.StorageManager, imageNameOrID, stageIDCommitList, m.DryRun)
+	return deleteImageMetadata(ctx, m.ProjectName, m.StorageManager, m.audit, imageNameOrID, stageIDCommitList, m.DryRun)
 }
@@ -100,6 +100,29 @@ func InterfaceToStringArray(stringOrStringArray interface{}, configSection inter
 	}
 }
 
+func validatePlatforms(platforms []string, doc *doc) error {
+	platformsMap := map[string]bool{}
+	for _, platform := range platforms {
+		parts := strings.Split(platform, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return newDetailedConfigError(fmt.Sprintf("invalid platform `%s`: expected `OS/ARCH[/VARIANT]`!", platform), nil, doc)
+		}
+
+		for _, part := range parts {
+			if part == "" {
+				return newDetailedConfigError(fmt.Sprintf("invalid platform `%s`: expected `OS/ARCH[/VARIANT]`!", platform), nil, doc)
+			}
+		}
+
+		if platformsMap[platform] {
+			return newDetailedConfigError(fmt.Sprintf("duplicated platform `%s`!", platform), nil, doc)
+		}
+		platformsMap[platform] = true
+	}
+
+	return nil
+}
+
 // Stack for setting parents in UnmarshalYAML calls
 // Set this to util.NewStack before yaml.Unmarshal
 var parentStack *util.Stack
@@ -0,0 +1,45 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type validatePlatformsEntry struct {
+	platforms   []string
+	expectedErr bool
+}
+
+var _ = DescribeTable("validating platforms", func(e validatePlatformsEntry) {
+	err := validatePlatforms(e.platforms, &doc{Content: []byte("image: app\n"), RenderFilePath: "werf.yaml"})
+	if e.expectedErr {
+		Ω(err).Should(HaveOccurred())
+	} else {
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+},
+	Entry("no platforms", validatePlatformsEntry{
+		platforms: nil,
+	}),
+	Entry("os and architecture", validatePlatformsEntry{
+		platforms: []string{"linux/amd64", "linux/arm64"},
+	}),
+	Entry("os, architecture and variant", validatePlatformsEntry{
+		platforms: []string{"linux/arm/v7"},
+	}),
+	Entry("only os", validatePlatformsEntry{
+		platforms:   []string{"linux"},
+		expectedErr: true,
+	}),
+	Entry("empty architecture", validatePlatformsEntry{
+		platforms:   []string{"linux/"},
+		expectedErr: true,
+	}),
+	Entry("too many parts", validatePlatformsEntry{
+		platforms:   []string{"linux/arm/v7/extra"},
+		expectedErr: true,
+	}),
+	Entry("duplicated platform", validatePlatformsEntry{
+		platforms:   []string{"linux/amd64", "linux/amd64"},
+		expectedErr: true,
+	}))
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+type DockerfileSecret struct {
+	ID  string
+	Env string
+	Src string
+
+	raw *rawDockerfileSecret
+}
+
+func (c *DockerfileSecret) validate(giterminismManager giterminism_manager.Interface) error {
+	if c.ID == "" {
+		return newDetailedConfigError("`id: ID` required for secret!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	if c.Env != "" && c.Src != "" {
+		return newDetailedConfigError("cannot use `env: NAME` and `src: PATH` at the same time for secret!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	} else if c.Env == "" && c.Src == "" {
+		return newDetailedConfigError("`env: NAME` or `src: PATH` required for secret!", c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	var err error
+	if c.Env != "" {
+		err = giterminismManager.Inspector().InspectConfigDockerfileSecretEnv(c.Env)
+	} else {
+		err = giterminismManager.Inspector().InspectConfigDockerfileSecretSrc(c.Src)
+	}
+
+	if err != nil {
+		return newDetailedConfigError(err.Error(), c.raw, c.raw.rawImageFromDockerfile.doc)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package config
+
+// ImageCleanup is the image cleanup section which overrides or extends the meta cleanup keep policies for the image
+type ImageCleanup struct {
+	KeepPolicies         []*MetaCleanupKeepPolicy
+	KeepPoliciesStrategy KeepPoliciesStrategy
+}
+
+type KeepPoliciesStrategy string
+
+var (
+	OverrideKeepPoliciesStrategy KeepPoliciesStrategy = "Override"
+	ExtendKeepPoliciesStrategy   KeepPoliciesStrategy = "Extend"
+)
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"path/filepath"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -16,6 +17,10 @@ type ImageFromDockerfile struct {
 	AddHost        []string
 	Network        string
 	SSH            string
+	Secrets        []*DockerfileSecret
+	Platform       []string
+	Staged         bool
+	Cleanup        *ImageCleanup
 
 	raw *rawImageFromDockerfile
 }
@@ -29,6 +34,18 @@ func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.In
 		return newDetailedConfigError("`contextAddFile: [PATH, ...]|PATH` each path should be relative to context!", nil, c.raw.doc)
 	}
 
+	if err := validatePlatforms(c.Platform, c.raw.doc); err != nil {
+		return err
+	}
+
+	secretsIDs := map[string]bool{}
+	for _, secret := range c.Secrets {
+		if secretsIDs[secret.ID] {
+			return newDetailedConfigError(fmt.Sprintf("duplicate secret id %q!", secret.ID), nil, c.raw.doc)
+		}
+		secretsIDs[secret.ID] = true
+	}
+
 	if len(c.ContextAddFile) != 0 {
 		for _, contextAddFile := range c.ContextAddFile {
 			if err := giterminismManager.Inspector().InspectConfigDockerfileContextAddFile(filepath.Join(c.Context, contextAddFile)); err != nil {
@@ -43,3 +60,7 @@ func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.In
 func (c *ImageFromDockerfile) GetName() string {
 	return c.Name
 }
+
+func (c *ImageFromDockerfile) GetPlatform() []string {
+	return c.Platform
+}
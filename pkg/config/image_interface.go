@@ -2,4 +2,5 @@ package config
 
 type ImageInterface interface {
 	GetName() string
+	GetPlatform() []string
 }
@@ -9,6 +9,62 @@ import (
 
 type MetaCleanup struct {
 	KeepPolicies []*MetaCleanupKeepPolicy
+	Quotas       MetaCleanupQuotas
+	AllowList    MetaCleanupAllowList
+	// ForeignTags enables the cleanup of the repo tags which are neither werf stages nor werf metadata
+	ForeignTags *MetaCleanupForeignTags
+}
+
+type MetaCleanupForeignTags struct {
+	DeleteRules []*MetaCleanupForeignTagsDeleteRule
+}
+
+type MetaCleanupForeignTagsDeleteRule struct {
+	TagRegexp *regexp.Regexp
+	OlderThan *time.Duration
+}
+
+func (r *MetaCleanupForeignTagsDeleteRule) String() string {
+	parts := []string{fmt.Sprintf("tag=%s", r.TagRegexp.String())}
+
+	if r.OlderThan != nil {
+		parts = append(parts, fmt.Sprintf("olderThan=%s", r.OlderThan.String()))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// MetaCleanupAllowList extends the images used in Kubernetes which are always kept by cleanup
+type MetaCleanupAllowList struct {
+	KubernetesResources []*MetaCleanupAllowListKubernetesResource
+	// HelmReleaseRevisions is the number of the last revisions of each helm release whose images are kept
+	HelmReleaseRevisions int
+}
+
+type MetaCleanupAllowListKubernetesResource struct {
+	Group      string
+	Version    string
+	Resource   string
+	ImagePaths []string
+}
+
+func (r *MetaCleanupAllowListKubernetesResource) String() string {
+	if r.Group == "" {
+		return fmt.Sprintf("%s/%s", r.Version, r.Resource)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", r.Group, r.Version, r.Resource)
+}
+
+// MetaCleanupQuotas limit the stages kept by the git history-based cleanup policies
+type MetaCleanupQuotas struct {
+	// MaxStagesSize is the max size of the project stages in bytes
+	MaxStagesSize     *int64
+	MaxStagesPerImage *int
+}
+
+func (q *MetaCleanupQuotas) IsEmpty() bool {
+	return q.MaxStagesSize == nil && q.MaxStagesPerImage == nil
 }
 
 type MetaCleanupKeepPolicy struct {
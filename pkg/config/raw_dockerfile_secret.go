@@ -0,0 +1,45 @@
+package config
+
+import "github.com/werf/werf/pkg/giterminism_manager"
+
+type rawDockerfileSecret struct {
+	ID  string `yaml:"id,omitempty"`
+	Env string `yaml:"env,omitempty"`
+	Src string `yaml:"src,omitempty"`
+
+	rawImageFromDockerfile *rawImageFromDockerfile `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawDockerfileSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawImageFromDockerfile); ok {
+		c.rawImageFromDockerfile = parent
+	}
+
+	type plain rawDockerfileSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawImageFromDockerfile.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawDockerfileSecret) toDirective(giterminismManager giterminism_manager.Interface) (secret *DockerfileSecret, err error) {
+	secret = &DockerfileSecret{}
+	secret.ID = c.ID
+	secret.Env = c.Env
+	secret.Src = c.Src
+
+	secret.raw = c
+
+	if err := secret.validate(giterminismManager); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import "fmt"
+
+type rawImageCleanup struct {
+	KeepPolicies         []*rawMetaCleanupKeepPolicy `yaml:"keepPolicies,omitempty"`
+	KeepPoliciesStrategy *string                     `yaml:"keepPoliciesStrategy,omitempty"`
+
+	doc *doc `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawImageCleanup) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.doc = parent.doc
+	} else if parent, ok := parentStack.Peek().(*rawImageFromDockerfile); ok {
+		c.doc = parent.doc
+	}
+
+	parentStack.Push(c)
+	type plain rawImageCleanup
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	if len(c.KeepPolicies) == 0 {
+		return newDetailedConfigError("at least one keep policy `keepPolicies: [POLICY, ...]` required for the image cleanup section!", c, c.doc)
+	}
+
+	if c.KeepPoliciesStrategy != nil {
+		if *c.KeepPoliciesStrategy != string(OverrideKeepPoliciesStrategy) && *c.KeepPoliciesStrategy != string(ExtendKeepPoliciesStrategy) {
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `keepPoliciesStrategy: Override|Extend`!", *c.KeepPoliciesStrategy), c, c.doc)
+		}
+	}
+
+	return nil
+}
+
+func (c *rawImageCleanup) toDirective() *ImageCleanup {
+	imageCleanup := &ImageCleanup{KeepPoliciesStrategy: OverrideKeepPoliciesStrategy}
+
+	for _, policy := range c.KeepPolicies {
+		imageCleanup.KeepPolicies = append(imageCleanup.KeepPolicies, policy.toMetaCleanupKeepPolicy())
+	}
+
+	if c.KeepPoliciesStrategy != nil {
+		imageCleanup.KeepPoliciesStrategy = KeepPoliciesStrategy(*c.KeepPoliciesStrategy)
+	}
+
+	return imageCleanup
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+type imageCleanupEntry struct {
+	cleanup              string
+	expectedKeepPolicies []string
+	expectedStrategy     KeepPoliciesStrategy
+	expectedErr          string
+}
+
+var _ = DescribeTable("parsing image cleanup", func(e imageCleanupEntry) {
+	d := &doc{Content: []byte("image: app\nfrom: alpine\ncleanup:\n" + e.cleanup), RenderFilePath: "werf.yaml"}
+	raw := &rawStapelImage{doc: d}
+	parentStack = util.NewStack()
+
+	err := yaml.UnmarshalStrict(d.Content, &raw)
+	if e.expectedErr != "" {
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(e.expectedErr))
+		return
+	}
+
+	Ω(err).ShouldNot(HaveOccurred())
+
+	imageCleanup := raw.RawCleanup.toDirective()
+
+	var keepPolicies []string
+	for _, policy := range imageCleanup.KeepPolicies {
+		keepPolicies = append(keepPolicies, policy.String())
+	}
+
+	Ω(keepPolicies).Should(Equal(e.expectedKeepPolicies))
+	Ω(imageCleanup.KeepPoliciesStrategy).Should(Equal(e.expectedStrategy))
+},
+	Entry("override strategy by default", imageCleanupEntry{
+		cleanup:              "  keepPolicies:\n  - references:\n      branch: /main/\n",
+		expectedKeepPolicies: []string{"references={branch=^main$}"},
+		expectedStrategy:     OverrideKeepPoliciesStrategy,
+	}),
+	Entry("extend strategy", imageCleanupEntry{
+		cleanup:              "  keepPoliciesStrategy: Extend\n  keepPolicies:\n  - references:\n      tag: /v.*/\n  - references:\n      branch: /main/\n",
+		expectedKeepPolicies: []string{"references={tag=^v.*$}", "references={branch=^main$}"},
+		expectedStrategy:     ExtendKeepPoliciesStrategy,
+	}),
+	Entry("explicit override strategy", imageCleanupEntry{
+		cleanup:              "  keepPoliciesStrategy: Override\n  keepPolicies:\n  - references:\n      tag: /v.*/\n",
+		expectedKeepPolicies: []string{"references={tag=^v.*$}"},
+		expectedStrategy:     OverrideKeepPoliciesStrategy,
+	}),
+	Entry("unsupported strategy", imageCleanupEntry{
+		cleanup:     "  keepPoliciesStrategy: Merge\n  keepPolicies:\n  - references:\n      tag: /v.*/\n",
+		expectedErr: "unsupported value \"Merge\" for `keepPoliciesStrategy: Override|Extend`",
+	}),
+	Entry("no keep policies", imageCleanupEntry{
+		cleanup:     "  keepPoliciesStrategy: Extend\n",
+		expectedErr: "at least one keep policy `keepPolicies: [POLICY, ...]` required for the image cleanup section",
+	}),
+	Entry("unknown field", imageCleanupEntry{
+		cleanup:     "  keepPolicies:\n  - references:\n      tag: /v.*/\n  quotas: {}\n",
+		expectedErr: "quotas",
+	}))
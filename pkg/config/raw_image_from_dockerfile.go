@@ -16,6 +16,10 @@ type rawImageFromDockerfile struct {
 	AddHost        interface{}            `yaml:"addHost,omitempty"`
 	Network        string                 `yaml:"network,omitempty"`
 	SSH            string                 `yaml:"ssh,omitempty"`
+	Secrets        []*rawDockerfileSecret `yaml:"secrets,omitempty"`
+	Platform       interface{}            `yaml:"platform,omitempty"`
+	Staged         bool                   `yaml:"staged,omitempty"`
+	Cleanup        *rawImageCleanup       `yaml:"cleanup,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -101,6 +105,26 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(giterminismManag
 	image.Network = c.Network
 	image.SSH = c.SSH
 
+	for _, rawSecret := range c.Secrets {
+		if secret, err := rawSecret.toDirective(giterminismManager); err != nil {
+			return nil, err
+		} else {
+			image.Secrets = append(image.Secrets, secret)
+		}
+	}
+
+	if platform, err := InterfaceToStringArray(c.Platform, nil, c.doc); err != nil {
+		return nil, err
+	} else {
+		image.Platform = platform
+	}
+
+	image.Staged = c.Staged
+
+	if c.Cleanup != nil {
+		image.Cleanup = c.Cleanup.toDirective()
+	}
+
 	image.raw = c
 
 	if err := image.validate(giterminismManager); err != nil {
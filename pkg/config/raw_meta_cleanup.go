@@ -5,10 +5,16 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/util/jsonpath"
 )
 
 type rawMetaCleanup struct {
 	KeepPolicies []*rawMetaCleanupKeepPolicy `yaml:"keepPolicies,omitempty"`
+	Quotas       *rawMetaCleanupQuotas       `yaml:"quotas,omitempty"`
+	AllowList    *rawMetaCleanupAllowList    `yaml:"allowList,omitempty"`
+	ForeignTags  *rawMetaCleanupForeignTags  `yaml:"foreignTags,omitempty"`
 
 	rawMeta               *rawMeta
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
@@ -18,7 +24,8 @@ type rawMetaCleanupKeepPolicy struct {
 	References         *rawMetaCleanupKeepPolicyReferences         `yaml:"references,omitempty"`
 	ImagesPerReference *rawMetaCleanupKeepPolicyImagesPerReference `yaml:"imagesPerReference,omitempty"`
 
-	rawMetaCleanup        *rawMetaCleanup
+	doc *doc `yaml:"-"` // parent
+
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
 }
 
@@ -30,7 +37,8 @@ type rawMetaCleanupKeepPolicyReferences struct {
 	TagRegexp    *regexp.Regexp `yaml:"-"`
 	BranchRegexp *regexp.Regexp `yaml:"-"`
 
-	rawMetaCleanup        *rawMetaCleanup
+	doc *doc `yaml:"-"` // parent
+
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
 }
 
@@ -41,6 +49,50 @@ type rawMetaCleanupKeepPolicyReferencesLimit struct {
 	In       *time.Duration `yaml:"in,omitempty"`
 	Operator *string        `yaml:"operator,omitempty"`
 
+	doc *doc `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupQuotas struct {
+	MaxStagesSize     string `yaml:"maxStagesSize,omitempty"`
+	MaxStagesPerImage *int   `yaml:"maxStagesPerImage,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupAllowList struct {
+	KubernetesResources  []*rawMetaCleanupAllowListKubernetesResource `yaml:"kubernetesResources,omitempty"`
+	HelmReleaseRevisions *int                                         `yaml:"helmReleaseRevisions,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupAllowListKubernetesResource struct {
+	Group      string   `yaml:"group,omitempty"`
+	Version    string   `yaml:"version,omitempty"`
+	Resource   string   `yaml:"resource,omitempty"`
+	ImagePaths []string `yaml:"imagePaths,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupForeignTags struct {
+	DeleteRules []*rawMetaCleanupForeignTagsDeleteRule `yaml:"deleteRules,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupForeignTagsDeleteRule struct {
+	Tag       string         `yaml:"tag,omitempty"`
+	OlderThan *time.Duration `yaml:"olderThan,omitempty"`
+
+	TagRegexp *regexp.Regexp `yaml:"-"`
+
 	rawMetaCleanup        *rawMetaCleanup
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
 }
@@ -67,7 +119,9 @@ func (c *rawMetaCleanup) UnmarshalYAML(unmarshal func(interface{}) error) error
 
 func (c *rawMetaCleanupKeepPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
-		c.rawMetaCleanup = parent
+		c.doc = parent.rawMeta.doc
+	} else if parent, ok := parentStack.Peek().(*rawImageCleanup); ok {
+		c.doc = parent.doc
 	}
 
 	parentStack.Push(c)
@@ -78,12 +132,12 @@ func (c *rawMetaCleanupKeepPolicy) UnmarshalYAML(unmarshal func(interface{}) err
 		return err
 	}
 
-	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
 		return err
 	}
 
 	if c.References == nil {
-		return newDetailedConfigError("cleanup keep policy must have references section!", c, c.rawMetaCleanup.rawMeta.doc)
+		return newDetailedConfigError("cleanup keep policy must have references section!", c, c.doc)
 	}
 
 	return nil
@@ -91,7 +145,7 @@ func (c *rawMetaCleanupKeepPolicy) UnmarshalYAML(unmarshal func(interface{}) err
 
 func (c *rawMetaCleanupKeepPolicyReferences) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if parent, ok := parentStack.Peek().(*rawMetaCleanupKeepPolicy); ok {
-		c.rawMetaCleanup = parent.rawMetaCleanup
+		c.doc = parent.doc
 	}
 
 	parentStack.Push(c)
@@ -102,14 +156,14 @@ func (c *rawMetaCleanupKeepPolicyReferences) UnmarshalYAML(unmarshal func(interf
 		return err
 	}
 
-	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
 		return err
 	}
 
 	if c.Tag == "" && c.Branch == "" {
-		return newDetailedConfigError("tag `tag: string|REGEX` or branch `branch: string|REGEX` required for cleanup keep policy!", c, c.rawMetaCleanup.rawMeta.doc)
+		return newDetailedConfigError("tag `tag: string|REGEX` or branch `branch: string|REGEX` required for cleanup keep policy!", c, c.doc)
 	} else if c.Tag != "" && c.Branch != "" {
-		return newDetailedConfigError("specify only tag `tag: string|REGEX` or branch `branch: string|REGEX` for cleanup keep policy!", c, c.rawMetaCleanup.rawMeta.doc)
+		return newDetailedConfigError("specify only tag `tag: string|REGEX` or branch `branch: string|REGEX` for cleanup keep policy!", c, c.doc)
 	}
 
 	if c.Branch != "" {
@@ -131,9 +185,63 @@ func (c *rawMetaCleanupKeepPolicyReferences) UnmarshalYAML(unmarshal func(interf
 	return nil
 }
 
+func (c *rawMetaCleanupForeignTags) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupForeignTags
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawMetaCleanupForeignTagsDeleteRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanupForeignTags); ok {
+		c.rawMetaCleanup = parent.rawMetaCleanup
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupForeignTagsDeleteRule
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Tag == "" {
+		return newDetailedConfigError("tag `tag: string|REGEX` required for the foreign tags delete rule!", c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	regex, err := compileCleanupRegexp(c.Tag)
+	if err != nil {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %q for `tag: string|REGEX`!", c.Tag), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+	c.TagRegexp = regex
+
+	if c.OlderThan != nil && *c.OlderThan < 0 {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %q for `olderThan: duration`: the value cannot be negative!", c.OlderThan.String()), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	return nil
+}
+
 func (c *rawMetaCleanupKeepPolicyReferencesLimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if parent, ok := parentStack.Peek().(*rawMetaCleanupKeepPolicyReferences); ok {
-		c.rawMetaCleanup = parent.rawMetaCleanup
+		c.doc = parent.doc
 	}
 
 	parentStack.Push(c)
@@ -144,13 +252,13 @@ func (c *rawMetaCleanupKeepPolicyReferencesLimit) UnmarshalYAML(unmarshal func(i
 		return err
 	}
 
-	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
 		return err
 	}
 
 	if c.Operator != nil {
 		if *c.Operator != "Or" && *c.Operator != "And" {
-			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `operator: Or|And`!", *c.Operator), c, c.rawMetaCleanup.rawMeta.doc)
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `operator: Or|And`!", *c.Operator), c, c.doc)
 		}
 	} else if c.In != nil && c.Last != nil {
 		defaultOperator := "And"
@@ -160,7 +268,111 @@ func (c *rawMetaCleanupKeepPolicyReferencesLimit) UnmarshalYAML(unmarshal func(i
 	return nil
 }
 
+func (c *rawMetaCleanupQuotas) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupQuotas
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.MaxStagesSize != "" {
+		if quantity, err := resource.ParseQuantity(c.MaxStagesSize); err != nil || quantity.Sign() < 0 {
+			return newDetailedConfigError(fmt.Sprintf("invalid value %q for `maxStagesSize: SIZE` (for example 500Mi or 10Gi)!", c.MaxStagesSize), c, c.rawMetaCleanup.rawMeta.doc)
+		}
+	}
+
+	if c.MaxStagesPerImage != nil && *c.MaxStagesPerImage < 0 {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %d for `maxStagesPerImage: int`: the value cannot be negative!", *c.MaxStagesPerImage), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaCleanupAllowList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupAllowList
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.HelmReleaseRevisions != nil && *c.HelmReleaseRevisions < 0 {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %d for `helmReleaseRevisions: int`: the value cannot be negative!", *c.HelmReleaseRevisions), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaCleanupAllowListKubernetesResource) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanupAllowList); ok {
+		c.rawMetaCleanup = parent.rawMetaCleanup
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupAllowListKubernetesResource
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Version == "" || c.Resource == "" {
+		return newDetailedConfigError("version `version: string` and resource `resource: string` required for the allow list Kubernetes resource!", c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	if len(c.ImagePaths) == 0 {
+		return newDetailedConfigError("at least one JSONPath `imagePaths: [JSONPATH, ...]` required for the allow list Kubernetes resource!", c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	for ind, imagePath := range c.ImagePaths {
+		// relaxed JSONPath like kubectl supports: .spec.image is the same as {.spec.image}
+		if !strings.HasPrefix(imagePath, "{") {
+			imagePath = fmt.Sprintf("{%s}", imagePath)
+			c.ImagePaths[ind] = imagePath
+		}
+
+		if err := jsonpath.New("").Parse(imagePath); err != nil {
+			return newDetailedConfigError(fmt.Sprintf("invalid JSONPath %q for `imagePaths: [JSONPATH, ...]`: %s!", imagePath, err), c, c.rawMetaCleanup.rawMeta.doc)
+		}
+	}
+
+	return nil
+}
+
 func (c *rawMetaCleanupKeepPolicyReferences) processRegexpString(name, configValue string) (*regexp.Regexp, error) {
+	regex, err := compileCleanupRegexp(configValue)
+	if err != nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("invalid value %q for `%s: string|REGEX`!", configValue, name), c, c.doc)
+	}
+
+	return regex, nil
+}
+
+// compileCleanupRegexp compiles the value in the /REGEX/ form or the exact string value
+func compileCleanupRegexp(configValue string) (*regexp.Regexp, error) {
 	var value string
 	if strings.HasPrefix(configValue, "/") && strings.HasSuffix(configValue, "/") {
 		value = strings.TrimPrefix(configValue, "/")
@@ -169,13 +381,7 @@ func (c *rawMetaCleanupKeepPolicyReferences) processRegexpString(name, configVal
 		value = regexp.QuoteMeta(configValue)
 	}
 
-	expr := fmt.Sprintf("^%s$", value)
-	regex, err := regexp.Compile(expr)
-	if err != nil {
-		return nil, newDetailedConfigError(fmt.Sprintf("invalid value %q for `%s: string|REGEX`!", configValue, name), c, c.rawMetaCleanup.rawMeta.doc)
-	}
-
-	return regex, nil
+	return regexp.Compile(fmt.Sprintf("^%s$", value))
 }
 
 func (c *rawMetaCleanup) toMetaCleanup() MetaCleanup {
@@ -185,9 +391,66 @@ func (c *rawMetaCleanup) toMetaCleanup() MetaCleanup {
 		metaCleanup.KeepPolicies = append(metaCleanup.KeepPolicies, policy.toMetaCleanupKeepPolicy())
 	}
 
+	if c.Quotas != nil {
+		metaCleanup.Quotas = c.Quotas.toMetaCleanupQuotas()
+	}
+
+	if c.AllowList != nil {
+		metaCleanup.AllowList = c.AllowList.toMetaCleanupAllowList()
+	}
+
+	if c.ForeignTags != nil {
+		metaCleanup.ForeignTags = c.ForeignTags.toMetaCleanupForeignTags()
+	}
+
 	return metaCleanup
 }
 
+func (c *rawMetaCleanupForeignTags) toMetaCleanupForeignTags() *MetaCleanupForeignTags {
+	foreignTags := &MetaCleanupForeignTags{}
+
+	for _, rule := range c.DeleteRules {
+		foreignTags.DeleteRules = append(foreignTags.DeleteRules, &MetaCleanupForeignTagsDeleteRule{
+			TagRegexp: rule.TagRegexp,
+			OlderThan: rule.OlderThan,
+		})
+	}
+
+	return foreignTags
+}
+
+func (c *rawMetaCleanupAllowList) toMetaCleanupAllowList() MetaCleanupAllowList {
+	allowList := MetaCleanupAllowList{}
+
+	for _, resource := range c.KubernetesResources {
+		allowList.KubernetesResources = append(allowList.KubernetesResources, &MetaCleanupAllowListKubernetesResource{
+			Group:      resource.Group,
+			Version:    resource.Version,
+			Resource:   resource.Resource,
+			ImagePaths: resource.ImagePaths,
+		})
+	}
+
+	if c.HelmReleaseRevisions != nil {
+		allowList.HelmReleaseRevisions = *c.HelmReleaseRevisions
+	}
+
+	return allowList
+}
+
+func (c *rawMetaCleanupQuotas) toMetaCleanupQuotas() MetaCleanupQuotas {
+	quotas := MetaCleanupQuotas{}
+	quotas.MaxStagesPerImage = c.MaxStagesPerImage
+
+	if c.MaxStagesSize != "" {
+		quantity := resource.MustParse(c.MaxStagesSize)
+		maxStagesSize := quantity.Value()
+		quotas.MaxStagesSize = &maxStagesSize
+	}
+
+	return quotas
+}
+
 func (c *rawMetaCleanupKeepPolicy) toMetaCleanupKeepPolicy() *MetaCleanupKeepPolicy {
 	policy := &MetaCleanupKeepPolicy{}
 
@@ -0,0 +1,71 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+type quotasEntry struct {
+	quotas         string
+	expectedQuotas MetaCleanupQuotas
+	expectedErr    string
+}
+
+var _ = DescribeTable("parsing cleanup quotas", func(e quotasEntry) {
+	d := &doc{Content: []byte("configVersion: 1\nproject: test\ncleanup:\n  quotas:\n" + e.quotas), RenderFilePath: "werf.yaml"}
+	raw := &rawMeta{doc: d}
+	parentStack = util.NewStack()
+
+	err := yaml.UnmarshalStrict(d.Content, &raw)
+	if e.expectedErr != "" {
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(e.expectedErr))
+		return
+	}
+
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(raw.toMeta().Cleanup.Quotas).Should(Equal(e.expectedQuotas))
+},
+	Entry("max stages size", quotasEntry{
+		quotas:         "    maxStagesSize: 10Gi\n",
+		expectedQuotas: MetaCleanupQuotas{MaxStagesSize: int64Ptr(10 * 1024 * 1024 * 1024)},
+	}),
+	Entry("max stages size in decimal units", quotasEntry{
+		quotas:         "    maxStagesSize: 500M\n",
+		expectedQuotas: MetaCleanupQuotas{MaxStagesSize: int64Ptr(500 * 1000 * 1000)},
+	}),
+	Entry("max stages per image", quotasEntry{
+		quotas:         "    maxStagesPerImage: 5\n",
+		expectedQuotas: MetaCleanupQuotas{MaxStagesPerImage: intPtr(5)},
+	}),
+	Entry("both quotas", quotasEntry{
+		quotas:         "    maxStagesSize: 1Gi\n    maxStagesPerImage: 0\n",
+		expectedQuotas: MetaCleanupQuotas{MaxStagesSize: int64Ptr(1024 * 1024 * 1024), MaxStagesPerImage: intPtr(0)},
+	}),
+	Entry("invalid max stages size", quotasEntry{
+		quotas:      "    maxStagesSize: ten gigabytes\n",
+		expectedErr: "invalid value \"ten gigabytes\" for `maxStagesSize: SIZE`",
+	}),
+	Entry("negative max stages size", quotasEntry{
+		quotas:      "    maxStagesSize: -1Gi\n",
+		expectedErr: "invalid value \"-1Gi\" for `maxStagesSize: SIZE`",
+	}),
+	Entry("negative max stages per image", quotasEntry{
+		quotas:      "    maxStagesPerImage: -1\n",
+		expectedErr: "invalid value -1 for `maxStagesPerImage: int`",
+	}),
+	Entry("unknown field", quotasEntry{
+		quotas:      "    maxImages: 1\n",
+		expectedErr: "maxImages",
+	}))
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -7,19 +7,21 @@ import (
 )
 
 type rawStapelImage struct {
-	Images           []string     `yaml:"-"`
-	Artifact         string       `yaml:"artifact,omitempty"`
-	From             string       `yaml:"from,omitempty"`
-	FromLatest       bool         `yaml:"fromLatest,omitempty"`
-	FromCacheVersion string       `yaml:"fromCacheVersion,omitempty"`
-	FromImage        string       `yaml:"fromImage,omitempty"`
-	FromArtifact     string       `yaml:"fromArtifact,omitempty"`
-	RawGit           []*rawGit    `yaml:"git,omitempty"`
-	RawShell         *rawShell    `yaml:"shell,omitempty"`
-	RawAnsible       *rawAnsible  `yaml:"ansible,omitempty"`
-	RawMount         []*rawMount  `yaml:"mount,omitempty"`
-	RawDocker        *rawDocker   `yaml:"docker,omitempty"`
-	RawImport        []*rawImport `yaml:"import,omitempty"`
+	Images           []string         `yaml:"-"`
+	Artifact         string           `yaml:"artifact,omitempty"`
+	From             string           `yaml:"from,omitempty"`
+	FromLatest       bool             `yaml:"fromLatest,omitempty"`
+	FromCacheVersion string           `yaml:"fromCacheVersion,omitempty"`
+	FromImage        string           `yaml:"fromImage,omitempty"`
+	FromArtifact     string           `yaml:"fromArtifact,omitempty"`
+	RawGit           []*rawGit        `yaml:"git,omitempty"`
+	RawShell         *rawShell        `yaml:"shell,omitempty"`
+	RawAnsible       *rawAnsible      `yaml:"ansible,omitempty"`
+	RawMount         []*rawMount      `yaml:"mount,omitempty"`
+	RawDocker        *rawDocker       `yaml:"docker,omitempty"`
+	RawImport        []*rawImport     `yaml:"import,omitempty"`
+	Platform         interface{}      `yaml:"platform,omitempty"`
+	RawCleanup       *rawImageCleanup `yaml:"cleanup,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -141,6 +143,10 @@ func (c *rawStapelImage) toStapelImageDirective(giterminismManager giterminism_m
 		}
 	}
 
+	if c.RawCleanup != nil {
+		image.Cleanup = c.RawCleanup.toDirective()
+	}
+
 	if err := c.validateStapelImageDirective(image); err != nil {
 		return nil, err
 	}
@@ -195,6 +201,14 @@ func (c *rawStapelImage) validateStapelImageArtifactDirective(imageArtifact *Sta
 		return newDetailedConfigError("`docker` section is not supported for artifact!", nil, c.doc)
 	}
 
+	if c.RawCleanup != nil {
+		return newDetailedConfigError("`cleanup` section is not supported for artifact!", nil, c.doc)
+	}
+
+	if len(imageArtifact.Platform) != 0 {
+		return newDetailedConfigError("`platform` directive is not supported for artifact: artifact is built for the platform of the image that imports it!", nil, c.doc)
+	}
+
 	if err := imageArtifact.validate(); err != nil {
 		return err
 	}
@@ -213,6 +227,12 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 	imageBase.FromLatest = c.FromLatest
 	imageBase.FromCacheVersion = c.FromCacheVersion
 
+	if platform, err := InterfaceToStringArray(c.Platform, nil, c.doc); err != nil {
+		return nil, err
+	} else {
+		imageBase.Platform = platform
+	}
+
 	for _, git := range c.RawGit {
 		if git.gitType() == "local" {
 			if gitLocal, err := git.toGitLocalDirective(); err != nil {
@@ -8,7 +8,8 @@ import (
 
 type StapelImage struct {
 	*StapelImageBase
-	Docker *Docker
+	Docker  *Docker
+	Cleanup *ImageCleanup
 }
 
 func (c *StapelImage) validate() error {
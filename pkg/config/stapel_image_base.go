@@ -21,6 +21,7 @@ type StapelImageBase struct {
 	Ansible          *Ansible
 	Mount            []*Mount
 	Import           []*Import
+	Platform         []string
 
 	raw *rawStapelImage
 }
@@ -29,6 +30,10 @@ func (c *StapelImageBase) GetName() string {
 	return c.Name
 }
 
+func (c *StapelImageBase) GetPlatform() []string {
+	return c.Platform
+}
+
 func (c *StapelImageBase) imports() []*Import {
 	return c.Import
 }
@@ -106,6 +111,10 @@ func (c *StapelImageBase) validate(giterminismManager giterminism_manager.Interf
 		logboek.Context(context.Background()).Warn().LogLn("WARNING: Do not use artifacts as a base for other images and artifacts. The feature is deprecated, and the directive 'fromArtifact' will be completely removed in version v1.3.\n\nCareless use of artifacts may lead to difficult to trace issues that may arise long after the configuration has been written. The artifact image is cached after the first build and ignores any changes in the project git repository unless the user has explicitly specified stage dependencies. As found, this behavior is completely unexpected for users despite the fact that it is absolutely correct in the werf logic.")
 	}
 
+	if err := validatePlatforms(c.Platform, c.raw.doc); err != nil {
+		return err
+	}
+
 	// TODO: валидацию формата `From`
 
 	return nil
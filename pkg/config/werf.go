@@ -71,6 +71,19 @@ func (c *WerfConfig) GetDockerfileImage(imageName string) *ImageFromDockerfile {
 	return nil
 }
 
+// GetImageCleanup returns the cleanup section of the image, nil if the image uses the meta cleanup keep policies
+func (c *WerfConfig) GetImageCleanup(imageName string) *ImageCleanup {
+	if i := c.GetStapelImage(imageName); i != nil {
+		return i.Cleanup
+	}
+
+	if i := c.GetDockerfileImage(imageName); i != nil {
+		return i.Cleanup
+	}
+
+	return nil
+}
+
 func (c *WerfConfig) GetArtifact(imageName string) *StapelImageArtifact {
 	for _, artifact := range c.Artifacts {
 		if artifact.Name == imageName {
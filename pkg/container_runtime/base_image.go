@@ -15,13 +15,13 @@ type baseImage struct {
 	inspect   *types.ImageInspect
 	stageDesc *image.StageDescription
 
-	LocalDockerServerRuntime *LocalDockerServerRuntime
+	ContainerRuntime ContainerRuntime
 }
 
-func newBaseImage(name string, localDockerServerRuntime *LocalDockerServerRuntime) *baseImage {
+func newBaseImage(name string, containerRuntime ContainerRuntime) *baseImage {
 	image := &baseImage{}
 	image.name = name
-	image.LocalDockerServerRuntime = localDockerServerRuntime
+	image.ContainerRuntime = containerRuntime
 	return image
 }
 
@@ -34,7 +34,7 @@ func (i *baseImage) SetName(name string) {
 }
 
 func (i *baseImage) MustResetInspect(ctx context.Context) error {
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.Name()); err != nil {
+	if inspect, err := i.ContainerRuntime.GetImageInspect(ctx, i.Name()); err != nil {
 		return fmt.Errorf("unable to get inspect for image %s: %s", i.Name(), err)
 	} else {
 		i.SetInspect(inspect)
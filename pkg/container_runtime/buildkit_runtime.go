@@ -0,0 +1,492 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/go-connections/nat"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/uuid"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/buildkit"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// BuildkitRuntime builds images with buildkit through buildctl and does not require docker server.
+// Built images are exported into the OCI layout and pushed directly into the stages storage,
+// all other images are inspected remotely, so that only registry based stages storage is supported.
+type BuildkitRuntime struct {
+	mux          sync.Mutex
+	builtImages  map[string]*buildkitBuiltImage
+	imageSources map[string]string
+}
+
+type buildkitBuiltImage struct {
+	buildDir string
+	image    v1.Image
+	inspect  *types.ImageInspect
+}
+
+func NewBuildkitRuntime() *BuildkitRuntime {
+	return &BuildkitRuntime{
+		builtImages:  map[string]*buildkitBuiltImage{},
+		imageSources: map[string]string{},
+	}
+}
+
+func (runtime *BuildkitRuntime) GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error) {
+	if builtImage := runtime.getBuiltImage(ref); builtImage != nil {
+		return builtImage.inspect, nil
+	}
+
+	img, err := docker_registry.API().GetRepoImageObject(ctx, runtime.resolveImageSource(ref))
+	if err != nil {
+		if docker_registry.IsManifestUnknownError(err) || docker_registry.IsNameUnknownError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	inspect, err := newImageInspectFromImageObject(ref, img)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect image %s: %s", ref, err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	repository, _ := image.ParseRepositoryAndTag(ref)
+	inspect.RepoDigests = []string{fmt.Sprintf("%s@%s", repository, digest.String())}
+
+	return inspect, nil
+}
+
+func (runtime *BuildkitRuntime) PullImage(ctx context.Context, ref string) error {
+	logboek.Context(ctx).Debug().LogF("-- BuildkitRuntime.PullImage %s: skipped, buildkit pulls images on demand\n", ref)
+	return nil
+}
+
+func (runtime *BuildkitRuntime) RefreshImageObject(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return err
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+	return nil
+}
+
+func (runtime *BuildkitRuntime) PullImageFromRegistry(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return fmt.Errorf("unable to get inspect of image %s: %s", dockerImage.Image.Name(), err)
+	} else if inspect == nil {
+		return fmt.Errorf("unable to get inspect of image %s: image not found", dockerImage.Image.Name())
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) PushImage(ctx context.Context, img Image, opts PushImageOptions) (*PushImageReport, error) {
+	dockerImage := img.(*DockerImage)
+
+	source := runtime.resolveImageSource(dockerImage.Image.Name())
+	if source == dockerImage.Image.Name() {
+		return nil, fmt.Errorf("unable to push image %s: there is no source image in the %s container runtime", dockerImage.Image.Name(), runtime.String())
+	}
+
+	var report *PushImageReport
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
+		writeReport, err := docker_registry.API().CopyRepoImage(ctx, source, dockerImage.Image.Name(), docker_registry.WriteRepoImageOptions{MountFromRepos: opts.MountFromRepos})
+		if err != nil {
+			return err
+		}
+
+		report = &PushImageReport{UploadedBytes: writeReport.UploadedBytes}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (runtime *BuildkitRuntime) PushBuiltImage(ctx context.Context, img Image, opts PushImageOptions) (*PushImageReport, error) {
+	dockerImage := img.(*DockerImage)
+
+	builtImage := runtime.getBuiltImage(dockerImage.Image.GetBuiltId())
+	if builtImage == nil || builtImage.image == nil {
+		return nil, fmt.Errorf("unable to push image %s: built image %q not found", dockerImage.Image.Name(), dockerImage.Image.GetBuiltId())
+	}
+
+	var report *PushImageReport
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
+		writeReport, err := docker_registry.API().WriteRepoImageWithReport(ctx, dockerImage.Image.Name(), builtImage.image, docker_registry.WriteRepoImageOptions{MountFromRepos: opts.MountFromRepos})
+		if err != nil {
+			return err
+		}
+
+		report = &PushImageReport{UploadedBytes: writeReport.UploadedBytes}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	// inspect is still needed for the following stages, but the layout is not
+	builtImage.image = nil
+	if err := os.RemoveAll(builtImage.buildDir); err != nil {
+		return nil, fmt.Errorf("unable to remove %s: %s", builtImage.buildDir, err)
+	}
+
+	return report, nil
+}
+
+func (runtime *BuildkitRuntime) TagImageByName(_ context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+	return fmt.Errorf("unable to tag image %s: local images are not supported by the %s container runtime", dockerImage.Image.Name(), runtime.String())
+}
+
+func (runtime *BuildkitRuntime) RenameImage(ctx context.Context, img Image, newImageName string, removeOldName bool) error {
+	dockerImage := img.(*DockerImage)
+
+	logboek.Context(ctx).Debug().LogF("-- BuildkitRuntime.RenameImage %s -> %s\n", dockerImage.Image.Name(), newImageName)
+
+	runtime.mux.Lock()
+	runtime.imageSources[newImageName] = runtime.resolveImageSourceNoLock(dockerImage.Image.Name())
+	if removeOldName {
+		delete(runtime.imageSources, dockerImage.Image.Name())
+	}
+	runtime.mux.Unlock()
+
+	dockerImage.Image.SetName(newImageName)
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) RemoveImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	logboek.Context(ctx).Debug().LogF("-- BuildkitRuntime.RemoveImage %s\n", dockerImage.Image.Name())
+
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	delete(runtime.imageSources, dockerImage.Image.Name())
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) BuildDockerfileImage(ctx context.Context, builder *DockerfileImageBuilder) error {
+	if builder.filePathToStdin == "" {
+		return fmt.Errorf("build context archive is required for the %s container runtime", runtime.String())
+	}
+
+	buildDir, err := newBuildkitBuildDir()
+	if err != nil {
+		return err
+	}
+
+	contextDir := filepath.Join(buildDir, "context")
+	if err := util.ExtractArchive(builder.filePathToStdin, contextDir); err != nil {
+		return fmt.Errorf("unable to extract build context: %s", err)
+	}
+
+	secretsDir := filepath.Join(buildDir, "secrets")
+	dockerBuildArgs, err := prepareSecretsBuildArgs(builder.buildArgs, secretsDir)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(secretsDir)
+
+	buildArgs, err := buildkitDockerfileFrontendArgs(dockerBuildArgs, contextDir)
+	if err != nil {
+		return err
+	}
+
+	if err := runtime.build(ctx, builder.temporalId, buildDir, buildArgs); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(contextDir)
+}
+
+func (runtime *BuildkitRuntime) RemoveDockerfileImage(_ context.Context, builder *DockerfileImageBuilder) error {
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	builtImage, ok := runtime.builtImages[builder.temporalId]
+	if !ok {
+		return nil
+	}
+
+	delete(runtime.builtImages, builder.temporalId)
+
+	if err := os.RemoveAll(builtImage.buildDir); err != nil {
+		return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", builder.temporalId, err)
+	}
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) BuildStapelStageImage(ctx context.Context, img *StageImage, options BuildOptions) error {
+	if options.IntrospectBeforeError || options.IntrospectAfterError {
+		return fmt.Errorf("stage introspection is not supported by the %s container runtime", runtime.String())
+	}
+
+	buildDir, err := newBuildkitBuildDir()
+	if err != nil {
+		return err
+	}
+
+	dockerfile, buildArgs, err := img.container.prepareBuildkitDockerfile(ctx)
+	if err != nil {
+		return err
+	}
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Dockerfile:\n%s\n", dockerfile)
+
+		if len(img.container.prepareAllRunCommands()) != 0 {
+			fmt.Printf("Decoded command:\n%s\n", strings.Join(img.container.prepareAllRunCommands(), " && "))
+		}
+	}
+
+	dockerfileDir := filepath.Join(buildDir, "dockerfile")
+	if err := os.MkdirAll(dockerfileDir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", dockerfileDir, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dockerfileDir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		return fmt.Errorf("unable to write Dockerfile: %s", err)
+	}
+
+	buildArgs = append([]string{
+		"--frontend=dockerfile.v0",
+		fmt.Sprintf("--local=context=%s", dockerfileDir),
+		fmt.Sprintf("--local=dockerfile=%s", dockerfileDir),
+	}, buildArgs...)
+
+	if options.Platform != "" {
+		buildArgs = append(buildArgs, fmt.Sprintf("--opt=platform=%s", options.Platform))
+	}
+
+	builtId := uuid.New().String()
+	if err := runtime.build(ctx, builtId, buildDir, buildArgs); err != nil {
+		if strings.HasPrefix(err.Error(), "buildctl build failed") {
+			logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(img.container.prepareAllRunCommands(), " && "))
+		}
+		return err
+	}
+
+	img.buildImage = newBuildImage(builtId, runtime)
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) String() string {
+	return "buildkit"
+}
+
+func (runtime *BuildkitRuntime) build(ctx context.Context, builtId, buildDir string, buildArgs []string) error {
+	outputArchivePath := filepath.Join(buildDir, "image.tar")
+	buildArgs = append(buildArgs, fmt.Sprintf("--output=type=oci,dest=%s", outputArchivePath))
+
+	if err := buildkit.CliBuild_LiveOutput(ctx, buildArgs...); err != nil {
+		return err
+	}
+
+	layoutDir := filepath.Join(buildDir, "layout")
+	if err := util.ExtractArchive(outputArchivePath, layoutDir); err != nil {
+		return fmt.Errorf("unable to extract built image: %s", err)
+	}
+
+	if err := os.Remove(outputArchivePath); err != nil {
+		return fmt.Errorf("unable to remove %s: %s", outputArchivePath, err)
+	}
+
+	img, err := getImageFromLayout(layoutDir)
+	if err != nil {
+		return fmt.Errorf("unable to read built image: %s", err)
+	}
+
+	inspect, err := newImageInspectFromImageObject(builtId, img)
+	if err != nil {
+		return fmt.Errorf("unable to inspect built image: %s", err)
+	}
+
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	runtime.builtImages[builtId] = &buildkitBuiltImage{
+		buildDir: buildDir,
+		image:    img,
+		inspect:  inspect,
+	}
+
+	return nil
+}
+
+func (runtime *BuildkitRuntime) getBuiltImage(builtId string) *buildkitBuiltImage {
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	return runtime.builtImages[builtId]
+}
+
+func (runtime *BuildkitRuntime) resolveImageSource(ref string) string {
+	runtime.mux.Lock()
+	defer runtime.mux.Unlock()
+
+	return runtime.resolveImageSourceNoLock(ref)
+}
+
+func (runtime *BuildkitRuntime) resolveImageSourceNoLock(ref string) string {
+	if source, ok := runtime.imageSources[ref]; ok {
+		return source
+	}
+	return ref
+}
+
+func newBuildkitBuildDir() (string, error) {
+	dir, err := ioutil.TempDir(werf.GetTmpDir(), "werf-buildkit-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	return dir, nil
+}
+
+func getImageFromLayout(layoutDir string) (v1.Image, error) {
+	index, err := layout.ImageIndexFromPath(layoutDir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(indexManifest.Manifests) != 1 {
+		return nil, fmt.Errorf("expected single image manifest in %s, got %d", layoutDir, len(indexManifest.Manifests))
+	}
+
+	return index.Image(indexManifest.Manifests[0].Digest)
+}
+
+func newImageInspectFromImageObject(ref string, img v1.Image) (*types.ImageInspect, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, l := range manifest.Layers {
+		size += l.Size
+	}
+
+	exposedPorts := nat.PortSet{}
+	for port := range configFile.Config.ExposedPorts {
+		exposedPorts[nat.Port(port)] = struct{}{}
+	}
+
+	return &types.ImageInspect{
+		ID:           manifest.Config.Digest.String(),
+		RepoTags:     []string{ref},
+		Created:      configFile.Created.Format(time.RFC3339Nano),
+		Author:       configFile.Author,
+		Architecture: configFile.Architecture,
+		Os:           configFile.OS,
+		Size:         size,
+		VirtualSize:  size,
+		Config: &container.Config{
+			User:         configFile.Config.User,
+			ExposedPorts: exposedPorts,
+			Env:          configFile.Config.Env,
+			Cmd:          strslice.StrSlice(configFile.Config.Cmd),
+			Image:        configFile.Config.Image,
+			Volumes:      configFile.Config.Volumes,
+			WorkingDir:   configFile.Config.WorkingDir,
+			Entrypoint:   strslice.StrSlice(configFile.Config.Entrypoint),
+			OnBuild:      configFile.Config.OnBuild,
+			Labels:       configFile.Config.Labels,
+			StopSignal:   configFile.Config.StopSignal,
+		},
+	}, nil
+}
+
+func buildkitDockerfileFrontendArgs(dockerBuildArgs []string, contextDir string) ([]string, error) {
+	args := []string{
+		"--frontend=dockerfile.v0",
+		fmt.Sprintf("--local=context=%s", contextDir),
+	}
+
+	dockerfileDir := contextDir
+	var addHosts []string
+	for _, arg := range dockerBuildArgs {
+		parts := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unsupported docker build argument %q", arg)
+		}
+
+		switch key, value := parts[0], parts[1]; key {
+		case "file":
+			dockerfileDir = filepath.Join(contextDir, filepath.Dir(filepath.FromSlash(value)))
+			args = append(args, fmt.Sprintf("--opt=filename=%s", filepath.Base(value)))
+		case "target":
+			args = append(args, fmt.Sprintf("--opt=target=%s", value))
+		case "build-arg":
+			args = append(args, fmt.Sprintf("--opt=build-arg:%s", value))
+		case "label":
+			args = append(args, fmt.Sprintf("--opt=label:%s", value))
+		case "add-host":
+			addHosts = append(addHosts, strings.Replace(value, ":", "=", 1))
+		case "network":
+			args = append(args, fmt.Sprintf("--opt=force-network-mode=%s", value))
+		case "ssh":
+			args = append(args, fmt.Sprintf("--ssh=%s", value))
+		case "secret":
+			args = append(args, fmt.Sprintf("--secret=%s", value))
+		case "platform":
+			args = append(args, fmt.Sprintf("--opt=platform=%s", value))
+		default:
+			return nil, fmt.Errorf("unsupported docker build argument %q", arg)
+		}
+	}
+
+	if len(addHosts) != 0 {
+		args = append(args, fmt.Sprintf("--opt=add-hosts=%s", strings.Join(addHosts, ",")))
+	}
+
+	args = append(args, fmt.Sprintf("--local=dockerfile=%s", dockerfileDir))
+
+	return args, nil
+}
@@ -3,26 +3,44 @@ package container_runtime
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 
+	"github.com/werf/lockgate"
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
 )
 
 type ContainerRuntime interface {
+	GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
+	PullImage(ctx context.Context, ref string) error
+
 	RefreshImageObject(ctx context.Context, img Image) error
 	PullImageFromRegistry(ctx context.Context, img Image) error
+	PushImage(ctx context.Context, img Image, opts PushImageOptions) (*PushImageReport, error)
+	PushBuiltImage(ctx context.Context, img Image, opts PushImageOptions) (*PushImageReport, error)
+	TagImageByName(ctx context.Context, img Image) error
 	RenameImage(ctx context.Context, img Image, newImageName string, removeOldName bool) error
 	RemoveImage(ctx context.Context, img Image) error
+
+	BuildDockerfileImage(ctx context.Context, builder *DockerfileImageBuilder) error
+	RemoveDockerfileImage(ctx context.Context, builder *DockerfileImageBuilder) error
+	BuildStapelStageImage(ctx context.Context, img *StageImage, options BuildOptions) error
+
 	String() string
 }
 
 type LocalDockerServerRuntime struct{}
 
-// GetImageInspect only available for LocalDockerServerRuntime
 func (runtime *LocalDockerServerRuntime) GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error) {
 	inspect, err := docker.ImageInspect(ctx, ref)
 	if client.IsErrNotFound(err) {
@@ -31,7 +49,6 @@ func (runtime *LocalDockerServerRuntime) GetImageInspect(ctx context.Context, re
 	return inspect, err
 }
 
-// PullImage only available for LocalDockerServerRuntime
 func (runtime *LocalDockerServerRuntime) PullImage(ctx context.Context, ref string) error {
 	if err := docker.CliPull(ctx, ref); err != nil {
 		return fmt.Errorf("unable to pull image %s: %s", ref, err)
@@ -110,20 +127,32 @@ func (runtime *LocalDockerServerRuntime) PullImageFromRegistry(ctx context.Conte
 	return nil
 }
 
-func (runtime *LocalDockerServerRuntime) PushImage(ctx context.Context, img Image) error {
+// PushImage copies the image in the registry when the local image has been pulled from one of the mount repos,
+// otherwise the image is pushed by the docker server.
+func (runtime *LocalDockerServerRuntime) PushImage(ctx context.Context, img Image, opts PushImageOptions) (*PushImageReport, error) {
 	dockerImage := img.(*DockerImage)
 
-	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
-		return docker.CliPushWithRetries(ctx, dockerImage.Image.Name())
-	}); err != nil {
-		return err
+	if sourceReference := findRepoDigestInRepos(dockerImage.Image.GetInspect(), opts.MountFromRepos); sourceReference != "" {
+		var report *PushImageReport
+		if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Copying %s to %s", sourceReference, dockerImage.Image.Name())).DoError(func() error {
+			writeReport, err := docker_registry.API().CopyRepoImage(ctx, sourceReference, dockerImage.Image.Name(), docker_registry.WriteRepoImageOptions{MountFromRepos: opts.MountFromRepos})
+			if err != nil {
+				return err
+			}
+
+			report = &PushImageReport{UploadedBytes: writeReport.UploadedBytes}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+
+		return report, nil
 	}
 
-	return nil
+	return runtime.pushImage(ctx, dockerImage.Image.Name())
 }
 
-// PushBuiltImage is only available for LocalDockerServerRuntime
-func (runtime *LocalDockerServerRuntime) PushBuiltImage(ctx context.Context, img Image) error {
+func (runtime *LocalDockerServerRuntime) PushBuiltImage(ctx context.Context, img Image, _ PushImageOptions) (*PushImageReport, error) {
 	dockerImage := img.(*DockerImage)
 
 	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Tagging built image by name %s", dockerImage.Image.Name())).DoError(func() error {
@@ -132,19 +161,36 @@ func (runtime *LocalDockerServerRuntime) PushBuiltImage(ctx context.Context, img
 		}
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
-		return docker.CliPushWithRetries(ctx, dockerImage.Image.Name())
+	return runtime.pushImage(ctx, dockerImage.Image.Name())
+}
+
+// pushImage pushes the image by the docker server, which checks existing blobs and mounts the known ones itself.
+func (runtime *LocalDockerServerRuntime) pushImage(ctx context.Context, ref string) (*PushImageReport, error) {
+	var report *PushImageReport
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", ref)).DoError(func() error {
+		pushReport, err := docker.PushWithRetriesAndReport(ctx, ref)
+		if err != nil {
+			return err
+		}
+
+		uploadedBytes, err := getPushedLayersSize(ctx, ref, pushReport.PushedLayers)
+		if err != nil {
+			return err
+		}
+
+		report = &PushImageReport{UploadedBytes: uploadedBytes}
+		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return report, nil
 }
 
-// TagBuiltImageByName is only available for LocalDockerServerRuntime
 func (runtime *LocalDockerServerRuntime) TagImageByName(ctx context.Context, img Image) error {
 	dockerImage := img.(*DockerImage)
 
@@ -159,14 +205,141 @@ func (runtime *LocalDockerServerRuntime) TagImageByName(ctx context.Context, img
 	return nil
 }
 
-func (runtime *LocalDockerServerRuntime) String() string {
-	return "local-docker-server"
+func (runtime *LocalDockerServerRuntime) BuildDockerfileImage(ctx context.Context, builder *DockerfileImageBuilder) error {
+	buildArgs := append(builder.buildArgs, fmt.Sprintf("--tag=%s", builder.temporalId))
+
+	if builder.buildkit && builder.filePathToStdin != "" {
+		return runtime.buildDockerfileImageWithBuildkit(ctx, builder, buildArgs)
+	}
+
+	if builder.filePathToStdin != "" {
+		buildArgs = append(buildArgs, "-")
+
+		f, err := os.Open(builder.filePathToStdin)
+		if err != nil {
+			return fmt.Errorf("unable to open file: %s", err)
+		}
+		defer f.Close()
+
+		if debugDockerRunCommand() {
+			fmt.Printf("Docker run command:\ndocker build %s < %s\n", strings.Join(buildArgs, " "), builder.filePathToStdin)
+		}
+
+		return docker.CliBuild_LiveOutputWithCustomIn(ctx, f, buildArgs...)
+	}
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Docker run command:\ndocker build %s\n", strings.Join(buildArgs, " "))
+	}
+
+	return docker.CliBuild_LiveOutput(ctx, buildArgs...)
 }
 
-type LocalHostRuntime struct {
-	ContainerRuntime // TODO: kaniko-like builds
+// buildDockerfileImageWithBuildkit passes the extracted build context directory to the docker cli,
+// since BuildKit reads the context archive only from the process stdin.
+func (runtime *LocalDockerServerRuntime) buildDockerfileImageWithBuildkit(ctx context.Context, builder *DockerfileImageBuilder, buildArgs []string) error {
+	buildDir, err := ioutil.TempDir(werf.GetTmpDir(), "werf-docker-build-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	contextDir := filepath.Join(buildDir, "context")
+	if err := util.ExtractArchive(builder.filePathToStdin, contextDir); err != nil {
+		return fmt.Errorf("unable to extract build context: %s", err)
+	}
+
+	buildArgs, err = prepareSecretsBuildArgs(buildArgs, filepath.Join(buildDir, "secrets"))
+	if err != nil {
+		return err
+	}
+
+	for ind, arg := range buildArgs {
+		if strings.HasPrefix(arg, "--file=") {
+			buildArgs[ind] = fmt.Sprintf("--file=%s", filepath.Join(contextDir, filepath.FromSlash(strings.TrimPrefix(arg, "--file="))))
+		}
+	}
+
+	buildArgs = append(buildArgs, contextDir)
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Docker run command:\nDOCKER_BUILDKIT=1 docker build %s\n", strings.Join(buildArgs, " "))
+	}
+
+	return docker.CliBuildWithBuildkit_LiveOutput(ctx, buildArgs...)
+}
+
+func (runtime *LocalDockerServerRuntime) RemoveDockerfileImage(ctx context.Context, builder *DockerfileImageBuilder) error {
+	if err := docker.CliRmi(ctx, builder.temporalId, "--force"); err != nil {
+		return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", builder.temporalId, err)
+	}
+	return nil
+}
+
+func (runtime *LocalDockerServerRuntime) BuildStapelStageImage(ctx context.Context, img *StageImage, options BuildOptions) error {
+	containerLockName := ContainerLockName(img.container.Name())
+	if _, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
+		return fmt.Errorf("failed to lock %s: %s", containerLockName, err)
+	} else {
+		defer werf.ReleaseHostLock(lock)
+	}
+
+	if debugDockerRunCommand() {
+		runArgs, err := img.container.prepareRunArgs(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
+
+		if len(img.container.prepareAllRunCommands()) != 0 {
+			fmt.Printf("Decoded command:\n%s\n", strings.Join(img.container.prepareAllRunCommands(), " && "))
+		}
+	}
+
+	if containerRunErr := img.container.run(ctx); containerRunErr != nil {
+		if strings.HasPrefix(containerRunErr.Error(), "container run failed") {
+			if options.IntrospectBeforeError {
+				logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(img.container.prepareAllRunCommands(), " && "))
+
+				if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
+					return img.introspectBefore(ctx)
+				}); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+			} else if options.IntrospectAfterError {
+				if err := img.Commit(ctx); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+
+				logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(img.container.prepareAllRunCommands(), " && "))
+
+				if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
+					return img.Introspect(ctx)
+				}); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+			}
+
+			if err := img.container.rm(ctx); err != nil {
+				return fmt.Errorf("introspect error failed: %s", err)
+			}
+		}
+
+		return containerRunErr
+	}
+
+	if err := img.Commit(ctx); err != nil {
+		return err
+	}
+
+	if err := img.container.rm(ctx); err != nil {
+		return err
+	}
+
+	return nil
 }
 
-func (runtime *LocalHostRuntime) String() string {
-	return "localhost"
+func (runtime *LocalDockerServerRuntime) String() string {
+	return "local-docker-server"
 }
@@ -2,24 +2,22 @@ package container_runtime
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"strings"
 
 	"github.com/google/uuid"
-
-	"github.com/werf/werf/pkg/docker"
 )
 
 type DockerfileImageBuilder struct {
+	ContainerRuntime ContainerRuntime
+
 	temporalId      string
 	isBuilt         bool
 	buildArgs       []string
 	filePathToStdin string
+	buildkit        bool
 }
 
-func NewDockerfileImageBuilder() *DockerfileImageBuilder {
-	return &DockerfileImageBuilder{temporalId: uuid.New().String()}
+func NewDockerfileImageBuilder(containerRuntime ContainerRuntime) *DockerfileImageBuilder {
+	return &DockerfileImageBuilder{ContainerRuntime: containerRuntime, temporalId: uuid.New().String()}
 }
 
 func (b *DockerfileImageBuilder) GetBuiltId() string {
@@ -37,33 +35,14 @@ func (b *DockerfileImageBuilder) SetFilePathToStdin(path string) {
 	b.filePathToStdin = path
 }
 
-func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
-	buildArgs := append(b.buildArgs, fmt.Sprintf("--tag=%s", b.temporalId))
-
-	if b.filePathToStdin != "" {
-		buildArgs = append(buildArgs, "-")
-
-		f, err := os.Open(b.filePathToStdin)
-		if err != nil {
-			return fmt.Errorf("unable to open file: %s", err)
-		}
-		defer f.Close()
-
-		if debugDockerRunCommand() {
-			fmt.Printf("Docker run command:\ndocker build %s < %s\n", strings.Join(buildArgs, " "), b.filePathToStdin)
-		}
-
-		if err := docker.CliBuild_LiveOutputWithCustomIn(ctx, f, buildArgs...); err != nil {
-			return err
-		}
-	} else {
-		if debugDockerRunCommand() {
-			fmt.Printf("Docker run command:\ndocker build %s\n", strings.Join(buildArgs, " "))
-		}
+// EnableBuildkit is required for secrets and RUN --mount instructions, which are not supported by the legacy docker builder
+func (b *DockerfileImageBuilder) EnableBuildkit() {
+	b.buildkit = true
+}
 
-		if err := docker.CliBuild_LiveOutput(ctx, buildArgs...); err != nil {
-			return err
-		}
+func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
+	if err := b.ContainerRuntime.BuildDockerfileImage(ctx, b); err != nil {
+		return err
 	}
 
 	b.isBuilt = true
@@ -72,8 +51,5 @@ func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
 }
 
 func (b *DockerfileImageBuilder) Cleanup(ctx context.Context) error {
-	if err := docker.CliRmi(ctx, b.temporalId, "--force"); err != nil {
-		return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", b.temporalId, err)
-	}
-	return nil
+	return b.ContainerRuntime.RemoveDockerfileImage(ctx, b)
 }
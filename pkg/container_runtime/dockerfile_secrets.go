@@ -0,0 +1,60 @@
+package container_runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prepareSecretsBuildArgs replaces env sources of --secret build args with files in the secretsDir,
+// because docker and buildctl clients support only file sources of secrets.
+func prepareSecretsBuildArgs(buildArgs []string, secretsDir string) ([]string, error) {
+	var result []string
+	for _, arg := range buildArgs {
+		if !strings.HasPrefix(arg, "--secret=") {
+			result = append(result, arg)
+			continue
+		}
+
+		var id, env, src string
+		for _, field := range strings.Split(strings.TrimPrefix(arg, "--secret="), ",") {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid secret build argument %q", arg)
+			}
+
+			switch parts[0] {
+			case "id":
+				id = parts[1]
+			case "env":
+				env = parts[1]
+			case "src":
+				src = parts[1]
+			default:
+				return nil, fmt.Errorf("invalid secret build argument %q", arg)
+			}
+		}
+
+		if env != "" {
+			value, ok := os.LookupEnv(env)
+			if !ok {
+				return nil, fmt.Errorf("env %s for secret %q is not set", env, id)
+			}
+
+			if err := os.MkdirAll(secretsDir, 0700); err != nil {
+				return nil, fmt.Errorf("unable to create dir %s: %s", secretsDir, err)
+			}
+
+			src = filepath.Join(secretsDir, id)
+			if err := ioutil.WriteFile(src, []byte(value), 0600); err != nil {
+				return nil, fmt.Errorf("unable to write secret %q: %s", id, err)
+			}
+		}
+
+		result = append(result, fmt.Sprintf("--secret=id=%s,src=%s", id, src))
+	}
+
+	return result, nil
+}
@@ -11,6 +11,20 @@ import (
 type BuildOptions struct {
 	IntrospectBeforeError bool
 	IntrospectAfterError  bool
+
+	// Platform is the target platform of the stage image in the OS/ARCH[/VARIANT] format,
+	// native platform is used when empty
+	Platform string
+}
+
+type PushImageOptions struct {
+	// MountFromRepos are the repositories of the same registry which may already contain the image layers
+	MountFromRepos []string
+}
+
+type PushImageReport struct {
+	// UploadedBytes is the size of the layers actually uploaded into the registry
+	UploadedBytes int64
 }
 
 type ImageInterface interface {
@@ -33,6 +47,7 @@ type ImageInterface interface {
 
 	Introspect(ctx context.Context) error
 
+	GetInspect() *types.ImageInspect
 	SetInspect(inspect *types.ImageInspect)
 	IsExistsLocally() bool
 
@@ -0,0 +1,66 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/util"
+)
+
+func findRepoDigestInRepos(inspect *types.ImageInspect, repos []string) string {
+	if inspect == nil {
+		return ""
+	}
+
+	for _, repoDigest := range inspect.RepoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && util.IsStringsContainValue(repos, parts[0]) {
+			return repoDigest
+		}
+	}
+
+	return ""
+}
+
+// getPushedLayersSize calculates the compressed size of the pushed layers by the remote image manifest,
+// the docker server identifies the pushed layers by the short diff ids.
+func getPushedLayersSize(ctx context.Context, ref string, pushedLayers []string) (int64, error) {
+	if len(pushedLayers) == 0 {
+		return 0, nil
+	}
+
+	img, err := docker_registry.API().GetRepoImageObject(ctx, ref)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get image %s: %s", ref, err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return 0, fmt.Errorf("unable to get image %s layers: %s", ref, err)
+	}
+
+	var size int64
+	for _, l := range layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			return 0, fmt.Errorf("unable to get image %s layer diff id: %s", ref, err)
+		}
+
+		if !util.IsStringsContainValue(pushedLayers, stringid.TruncateID(diffID.String())) {
+			continue
+		}
+
+		layerSize, err := l.Size()
+		if err != nil {
+			return 0, fmt.Errorf("unable to get image %s layer %s size: %s", ref, diffID.String(), err)
+		}
+		size += layerSize
+	}
+
+	return size, nil
+}
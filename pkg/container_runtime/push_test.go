@@ -0,0 +1,139 @@
+package container_runtime
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+func TestFindRepoDigestInRepos(t *testing.T) {
+	inspect := &types.ImageInspect{RepoDigests: []string{
+		"registry.example.com/other@sha256:other",
+		"registry.example.com/project@sha256:project",
+	}}
+
+	tests := []struct {
+		name     string
+		inspect  *types.ImageInspect
+		repos    []string
+		expected string
+	}{
+		{
+			name:     "repo digest of the repo",
+			inspect:  inspect,
+			repos:    []string{"registry.example.com/project"},
+			expected: "registry.example.com/project@sha256:project",
+		},
+		{
+			name:     "repo prefix does not match",
+			inspect:  inspect,
+			repos:    []string{"registry.example.com/proj"},
+			expected: "",
+		},
+		{
+			name:     "image does not exist",
+			inspect:  nil,
+			repos:    []string{"registry.example.com/project"},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if repoDigest := findRepoDigestInRepos(tt.inspect, tt.repos); repoDigest != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, repoDigest)
+			}
+		})
+	}
+}
+
+func TestGetPushedLayersSize(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	if err := docker_registry.Init(ctx, true, false, docker_registry.ThrottlingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := strings.TrimPrefix(server.URL, "http://") + "/project:stage"
+	img, err := random.Image(1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := docker_registry.API().WriteRepoImage(ctx, ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var shortDiffIDs []string
+	var sizes []int64
+	for _, l := range layers {
+		diffID, err := l.DiffID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := l.Size()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shortDiffIDs = append(shortDiffIDs, stringid.TruncateID(diffID.String()))
+		sizes = append(sizes, size)
+	}
+
+	tests := []struct {
+		name         string
+		pushedLayers []string
+		expected     int64
+	}{
+		{
+			name:         "no pushed layers",
+			pushedLayers: nil,
+			expected:     0,
+		},
+		{
+			name:         "some layers are pushed",
+			pushedLayers: []string{shortDiffIDs[0], shortDiffIDs[2]},
+			expected:     sizes[0] + sizes[2],
+		},
+		{
+			name:         "all layers are pushed",
+			pushedLayers: shortDiffIDs,
+			expected:     sizes[0] + sizes[1] + sizes[2],
+		},
+		{
+			name:         "unknown layers are not counted",
+			pushedLayers: []string{"unknown"},
+			expected:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := getPushedLayersSize(ctx, ref, tt.pushedLayers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if size != tt.expected {
+				t.Errorf("expected %d bytes, got %d", tt.expected, size)
+			}
+		})
+	}
+}
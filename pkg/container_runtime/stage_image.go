@@ -4,10 +4,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
-
-	"github.com/werf/lockgate"
-	"github.com/werf/werf/pkg/werf"
 
 	"github.com/docker/docker/api/types"
 
@@ -23,16 +19,29 @@ type StageImage struct {
 	container              *StageImageContainer
 	buildImage             *buildImage
 	dockerfileImageBuilder *DockerfileImageBuilder
+	platform               string
 }
 
-func NewStageImage(fromImage *StageImage, name string, localDockerServerRuntime *LocalDockerServerRuntime) *StageImage {
+func NewStageImage(fromImage *StageImage, name string, containerRuntime ContainerRuntime) *StageImage {
 	stage := &StageImage{}
-	stage.baseImage = newBaseImage(name, localDockerServerRuntime)
+	stage.baseImage = newBaseImage(name, containerRuntime)
 	stage.fromImage = fromImage
 	stage.container = newStageImageContainer(stage)
 	return stage
 }
 
+func (i *StageImage) GetFromImage() *StageImage {
+	return i.fromImage
+}
+
+func (i *StageImage) SetPlatform(platform string) {
+	i.platform = platform
+}
+
+func (i *StageImage) GetPlatform() string {
+	return i.platform
+}
+
 func (i *StageImage) Inspect() *types.ImageInspect {
 	return i.inspect
 }
@@ -59,68 +68,12 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 			return err
 		}
 	} else {
-		containerLockName := ContainerLockName(i.container.Name())
-		if _, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
-			return fmt.Errorf("failed to lock %s: %s", containerLockName, err)
-		} else {
-			defer werf.ReleaseHostLock(lock)
-		}
-
-		if debugDockerRunCommand() {
-			runArgs, err := i.container.prepareRunArgs(ctx)
-			if err != nil {
-				return err
-			}
-
-			fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
-
-			if len(i.container.prepareAllRunCommands()) != 0 {
-				fmt.Printf("Decoded command:\n%s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-			}
-		}
-
-		if containerRunErr := i.container.run(ctx); containerRunErr != nil {
-			if strings.HasPrefix(containerRunErr.Error(), "container run failed") {
-				if options.IntrospectBeforeError {
-					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-
-					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.introspectBefore(ctx)
-					}); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
-				} else if options.IntrospectAfterError {
-					if err := i.Commit(ctx); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
-
-					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-
-					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.Introspect(ctx)
-					}); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
-				}
-
-				if err := i.container.rm(ctx); err != nil {
-					return fmt.Errorf("introspect error failed: %s", err)
-				}
-			}
-
-			return containerRunErr
-		}
-
-		if err := i.Commit(ctx); err != nil {
-			return err
-		}
-
-		if err := i.container.rm(ctx); err != nil {
+		if err := i.ContainerRuntime.BuildStapelStageImage(ctx, i, options); err != nil {
 			return err
 		}
 	}
 
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
+	if inspect, err := i.ContainerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
 		return err
 	} else {
 		i.SetInspect(inspect)
@@ -139,7 +92,7 @@ func (i *StageImage) Commit(ctx context.Context) error {
 		return err
 	}
 
-	i.buildImage = newBuildImage(builtId, i.LocalDockerServerRuntime)
+	i.buildImage = newBuildImage(builtId, i.ContainerRuntime)
 
 	return nil
 }
@@ -203,7 +156,13 @@ func (i *StageImage) Tag(ctx context.Context, name string) error {
 }
 
 func (i *StageImage) Pull(ctx context.Context) error {
-	if err := docker.CliPullWithRetries(ctx, i.name); err != nil {
+	var args []string
+	if i.platform != "" {
+		args = append(args, fmt.Sprintf("--platform=%s", i.platform))
+	}
+	args = append(args, i.name)
+
+	if err := docker.CliPullWithRetries(ctx, args...); err != nil {
 		return err
 	}
 
@@ -217,7 +176,7 @@ func (i *StageImage) Push(ctx context.Context) error {
 }
 
 func (i *StageImage) Import(ctx context.Context, name string) error {
-	importedImage := newBaseImage(name, i.LocalDockerServerRuntime)
+	importedImage := newBaseImage(name, i.ContainerRuntime)
 
 	if err := docker.CliPullWithRetries(ctx, name); err != nil {
 		return err
@@ -263,7 +222,7 @@ func (i *StageImage) Export(ctx context.Context, name string) error {
 
 func (i *StageImage) DockerfileImageBuilder() *DockerfileImageBuilder {
 	if i.dockerfileImageBuilder == nil {
-		i.dockerfileImageBuilder = NewDockerfileImageBuilder()
+		i.dockerfileImageBuilder = NewDockerfileImageBuilder(i.ContainerRuntime)
 	}
 	return i.dockerfileImageBuilder
 }
@@ -0,0 +1,113 @@
+package container_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/werf/werf/pkg/stapel"
+)
+
+// prepareBuildkitDockerfile translates stage container run and commit options into the Dockerfile:
+// run options become mounts of the single RUN instruction and commit options become the following instructions.
+func (c *StageImageContainer) prepareBuildkitDockerfile(ctx context.Context) (string, []string, error) {
+	if c.image.fromImage == nil {
+		panic(fmt.Sprintf("runtime error: FromImage should be (%s)", c.image.name))
+	}
+
+	runOptions := newStageContainerOptions()
+	runOptions.Workdir = "/"
+	runOptions.User = "0:0"
+	runOptions = runOptions.merge(c.runOptions)
+
+	for _, volumesFrom := range runOptions.VolumesFrom {
+		if strings.SplitN(volumesFrom, ":", 2)[0] != stapel.ContainerName() {
+			return "", nil, fmt.Errorf("volumes from container %q are not supported by buildkit", volumesFrom)
+		}
+	}
+
+	mounts := []string{
+		fmt.Sprintf("--mount=type=bind,from=%s,source=%s,target=%s", stapel.ImageName(), stapel.ContainerVolume(), stapel.ContainerVolume()),
+	}
+
+	var buildArgs []string
+	for ind, volume := range runOptions.Volume {
+		parts := strings.Split(volume, ":")
+		if len(parts) < 2 {
+			return "", nil, fmt.Errorf("unsupported volume %q: host path and container path expected", volume)
+		}
+
+		hostPath, containerPath := parts[0], parts[1]
+		readOnly := len(parts) > 2 && parts[2] == "ro"
+		mountName := fmt.Sprintf("volume%d", ind)
+
+		stat, err := os.Stat(hostPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to stat volume %q host path: %s", volume, err)
+		}
+
+		var source string
+		switch {
+		case stat.Mode()&os.ModeSocket != 0:
+			mounts = append(mounts, fmt.Sprintf("--mount=type=ssh,id=%s,target=%s", mountName, containerPath))
+			buildArgs = append(buildArgs, fmt.Sprintf("--ssh=%s=%s", mountName, hostPath))
+			continue
+		case stat.IsDir():
+			source = "/"
+		default:
+			source = filepath.Base(hostPath)
+			hostPath = filepath.Dir(hostPath)
+		}
+
+		mount := fmt.Sprintf("--mount=type=bind,from=%s,source=%s,target=%s", mountName, source, containerPath)
+		if !readOnly {
+			mount += ",rw"
+		}
+
+		mounts = append(mounts, mount)
+		buildArgs = append(buildArgs,
+			fmt.Sprintf("--local=%s=%s", mountName, hostPath),
+			fmt.Sprintf("--opt=context:%s=local:%s", mountName, mountName),
+		)
+	}
+
+	var envNames []string
+	for name := range runOptions.Env {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+
+	var runCommands []string
+	for _, name := range envNames {
+		runCommands = append(runCommands, fmt.Sprintf("export %s=%s", name, shellQuote(runOptions.Env[name])))
+	}
+	runCommands = append(runCommands, c.prepareRunCommands()...)
+
+	runInstructionArgs, err := json.Marshal([]string{stapel.BashBinPath(), "-ec", ShelloutPack(strings.Join(runCommands, " && "))})
+	if err != nil {
+		return "", nil, err
+	}
+
+	commitOptions, err := c.prepareCommitOptions(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	instructions := []string{
+		fmt.Sprintf("FROM %s", c.image.fromImage.Name()),
+		fmt.Sprintf("USER %s", runOptions.User),
+		fmt.Sprintf("WORKDIR %s", runOptions.Workdir),
+		fmt.Sprintf("RUN %s %s", strings.Join(mounts, " "), runInstructionArgs),
+	}
+	instructions = append(instructions, commitOptions.commitChanges("[]")...)
+
+	return strings.Join(instructions, "\n") + "\n", buildArgs, nil
+}
+
+func shellQuote(value string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", `'"'"'`))
+}
@@ -203,6 +203,19 @@ func (co *StageImageContainerOptions) toCommitChanges() []string {
 }
 
 func (co *StageImageContainerOptions) prepareCommitChanges(ctx context.Context) ([]string, error) {
+	var emptyEntrypoint string
+	if co.Entrypoint == "" {
+		var err error
+		emptyEntrypoint, err = getEmptyEntrypointInstructionValue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("container options preparing failed: %s", err.Error())
+		}
+	}
+
+	return co.commitChanges(emptyEntrypoint), nil
+}
+
+func (co *StageImageContainerOptions) commitChanges(emptyEntrypoint string) []string {
 	var args []string
 
 	for _, volume := range co.Volume {
@@ -229,15 +242,9 @@ func (co *StageImageContainerOptions) prepareCommitChanges(ctx context.Context)
 		args = append(args, fmt.Sprintf("USER %s", co.User))
 	}
 
-	var entrypoint string
-	var err error
-	if co.Entrypoint != "" {
-		entrypoint = co.Entrypoint
-	} else {
-		entrypoint, err = getEmptyEntrypointInstructionValue(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("container options preparing failed: %s", err.Error())
-		}
+	entrypoint := co.Entrypoint
+	if entrypoint == "" {
+		entrypoint = emptyEntrypoint
 	}
 
 	args = append(args, fmt.Sprintf("ENTRYPOINT %s", entrypoint))
@@ -252,7 +259,7 @@ func (co *StageImageContainerOptions) prepareCommitChanges(ctx context.Context)
 		args = append(args, fmt.Sprintf("HEALTHCHECK %s", co.HealthCheck))
 	}
 
-	return args, nil
+	return args
 }
 
 func getEmptyEntrypointInstructionValue(ctx context.Context) (string, error) {
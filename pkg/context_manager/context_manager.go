@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 
@@ -97,3 +98,33 @@ func AddContextAddFileToContextArchive(ctx context.Context, originalArchivePath
 
 	return destinationArchivePath, nil
 }
+
+func ReplaceFileInContextArchive(ctx context.Context, originalArchivePath string, relPath string, data []byte) (string, error) {
+	destinationArchivePath := GetTmpArchivePath()
+
+	tarEntryName := filepath.ToSlash(relPath)
+	if err := util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, []string{relPath}, func(tw *tar.Writer) error {
+		header := &tar.Header{
+			Name:    tarEntryName,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("unable to write tar header for file %s: %s", tarEntryName, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("unable to write data to tar archive for file %s: %s", tarEntryName, err)
+		}
+
+		logboek.Context(ctx).Debug().LogF("File was replaced: %q\n", tarEntryName)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return destinationArchivePath, nil
+}
@@ -3,9 +3,11 @@ package docker
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/cli/cli/command"
@@ -19,6 +21,11 @@ import (
 )
 
 func CreateImage(ctx context.Context, ref string, labels map[string]string) error {
+	return ImportImage(ctx, ref, nil, labels)
+}
+
+// ImportImage creates the image with the single layer from the filesystem archive, the image is empty if archive is nil
+func ImportImage(ctx context.Context, ref string, archive io.Reader, labels map[string]string) error {
 	var opts types.ImageImportOptions
 
 	if len(labels) > 0 {
@@ -31,10 +38,22 @@ func CreateImage(ctx context.Context, ref string, labels map[string]string) erro
 		opts.Changes = append(opts.Changes, changeOption)
 	}
 
-	_, err := apiCli(ctx).ImageImport(ctx, types.ImageImportSource{SourceName: "-"}, ref, opts)
+	rc, err := apiCli(ctx).ImageImport(ctx, types.ImageImportSource{Source: archive, SourceName: "-"}, ref, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// the import is completed when the progress stream is over
+	_, err = io.Copy(ioutil.Discard, rc)
 	return err
 }
 
+// ImageSave returns the archive of the image in the docker save format
+func ImageSave(ctx context.Context, ref string) (io.ReadCloser, error) {
+	return apiCli(ctx).ImageSave(ctx, []string{ref})
+}
+
 func Images(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
 	images, err := apiCli(ctx).ImageList(ctx, options)
 	if err != nil {
@@ -121,10 +140,16 @@ func doCliPush(c command.Cli, args ...string) error {
 const cliPushMaxAttempts = 10
 
 func doCliPushWithRetries(c command.Cli, args ...string) error {
+	return doWithPushRetries(c, func() error {
+		return doCliPush(c, args...)
+	})
+}
+
+func doWithPushRetries(c command.Cli, push func() error) error {
 	var attempt int
 
 tryPush:
-	if err := doCliPush(c, args...); err != nil {
+	if err := push(); err != nil {
 		if attempt < cliPushMaxAttempts {
 			specificErrors := []string{
 				"Client.Timeout exceeded while awaiting headers",
@@ -190,11 +215,65 @@ func doCliBuild(c command.Cli, args ...string) error {
 	return prepareCliCmd(image.NewBuildCommand(c), args...).Execute()
 }
 
-func CliBuild_LiveOutputWithCustomIn(ctx context.Context, rc io.ReadCloser, args ...string) error {
-	if err := os.Setenv("DOCKER_BUILDKIT", "0"); err != nil {
-		return err
+// dockerBuildkitEnvMutex guards DOCKER_BUILDKIT env which is checked by docker cli during the build:
+// legacy builds could run in parallel, BuildKit builds are exclusive.
+var dockerBuildkitEnvMutex sync.RWMutex
+
+// the user value of DOCKER_BUILDKIT env is restored when the last of parallel legacy builds is done
+var (
+	legacyBuildsMutex            sync.Mutex
+	legacyBuildsNumber           int
+	restoreDockerBuildkitEnvFunc func() error
+)
+
+// setDockerBuildkitEnv sets DOCKER_BUILDKIT env and returns the function restoring the user value or unsetting the env
+func setDockerBuildkitEnv(value string) (func() error, error) {
+	oldValue, isSet := os.LookupEnv("DOCKER_BUILDKIT")
+	if err := os.Setenv("DOCKER_BUILDKIT", value); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		if isSet {
+			return os.Setenv("DOCKER_BUILDKIT", oldValue)
+		}
+
+		return os.Unsetenv("DOCKER_BUILDKIT")
+	}, nil
+}
+
+func CliBuild_LiveOutputWithCustomIn(ctx context.Context, rc io.ReadCloser, args ...string) (err error) {
+	dockerBuildkitEnvMutex.RLock()
+	defer dockerBuildkitEnvMutex.RUnlock()
+
+	legacyBuildsMutex.Lock()
+	if legacyBuildsNumber == 0 {
+		restoreFunc, err := setDockerBuildkitEnv("0")
+		if err != nil {
+			legacyBuildsMutex.Unlock()
+			return err
+		}
+		restoreDockerBuildkitEnvFunc = restoreFunc
 	}
+	legacyBuildsNumber++
+	legacyBuildsMutex.Unlock()
+
+	defer func() {
+		legacyBuildsMutex.Lock()
+		defer legacyBuildsMutex.Unlock()
+
+		legacyBuildsNumber--
+		if legacyBuildsNumber == 0 {
+			if restoreErr := restoreDockerBuildkitEnvFunc(); restoreErr != nil && err == nil {
+				err = restoreErr
+			}
+		}
+	}()
+
+	return cliBuildWithCustomIn(ctx, rc, args...)
+}
 
+func cliBuildWithCustomIn(ctx context.Context, rc io.ReadCloser, args ...string) error {
 	return cliWithCustomOptions(ctx, []command.DockerCliOption{
 		func(cli *command.DockerCli) error {
 			cli.SetIn(streams.NewIn(rc))
@@ -205,6 +284,25 @@ func CliBuild_LiveOutputWithCustomIn(ctx context.Context, rc io.ReadCloser, args
 	})
 }
 
+// CliBuildWithBuildkit_LiveOutput builds with BuildKit, which is required for secrets and RUN --mount instructions.
+// BuildKit reads the build context archive only from the process stdin, so the context should be passed as a directory.
+func CliBuildWithBuildkit_LiveOutput(ctx context.Context, args ...string) error {
+	dockerBuildkitEnvMutex.Lock()
+	defer dockerBuildkitEnvMutex.Unlock()
+
+	restoreFunc, err := setDockerBuildkitEnv("1")
+	if err != nil {
+		return err
+	}
+
+	if err := doCliBuild(cli(ctx), args...); err != nil {
+		_ = restoreFunc()
+		return err
+	}
+
+	return restoreFunc()
+}
+
 func CliBuild_LiveOutput(ctx context.Context, args ...string) error {
 	return doCliBuild(cli(ctx), args...)
 }
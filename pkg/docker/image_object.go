@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"golang.org/x/net/context"
+)
+
+// ImageObject returns the local docker server image as go-containerregistry image object.
+func ImageObject(ctx context.Context, ref string) (v1.Image, error) {
+	parsedRef, err := name.ParseReference(ref, name.WeakValidation)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %s", ref, err)
+	}
+
+	return daemon.Image(parsedRef, daemon.WithClient(apiCli(ctx)))
+}
+
+// LoadImageObject loads go-containerregistry image object into the local docker server by the specified name.
+func LoadImageObject(ctx context.Context, ref string, img v1.Image) error {
+	tag, err := name.NewTag(ref, name.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("parsing tag %q: %s", ref, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.Write(tag, img, pw))
+	}()
+
+	resp, err := apiCli(ctx).ImageLoad(ctx, pr, true)
+	if err != nil {
+		return fmt.Errorf("unable to load image %s: %s", ref, err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return fmt.Errorf("unable to read image %s load response: %s", ref, err)
+	}
+
+	return nil
+}
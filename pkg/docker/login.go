@@ -3,15 +3,33 @@ package docker
 import (
 	"bytes"
 	"context"
+	"fmt"
 
 	"github.com/docker/cli/cli/command/registry"
+	"github.com/google/go-containerregistry/pkg/name"
 
 	"github.com/werf/logboek"
 
 	"github.com/docker/cli/cli/command"
+
+	"github.com/werf/werf/pkg/docker_registry/credentials"
 )
 
+// Login performs docker login into the repo,
+// the credentials from the registry credentials file are used only if username and password are not specified
 func Login(ctx context.Context, username, password, repo string) error {
+	if username == "" && password == "" {
+		authConfig, err := credentials.GetAuthConfig(repo)
+		if err != nil {
+			return err
+		}
+
+		if authConfig != nil && authConfig.Username != "" {
+			logboek.Context(ctx).Debug().LogF("Using credentials for %s from the registry credentials file\n", repo)
+			username, password = authConfig.Username, authConfig.Password
+		}
+	}
+
 	var outb, errb bytes.Buffer
 
 	return cliWithCustomOptions(
@@ -34,3 +52,31 @@ func Login(ctx context.Context, username, password, repo string) error {
 		},
 	)
 }
+
+// LoginRegistriesFromCredentialsFile performs docker login into the registries from the registry credentials file,
+// the registries with bearer or identity tokens are skipped because docker login supports only username and password
+func LoginRegistriesFromCredentialsFile(ctx context.Context) error {
+	for _, registry := range credentials.Registries() {
+		authConfig, err := credentials.GetAuthConfig(registry)
+		if err != nil {
+			return err
+		}
+
+		if authConfig.Username == "" {
+			logboek.Context(ctx).Debug().LogF("Skipping docker login into %s: username and password are not available\n", registry)
+			continue
+		}
+
+		loginAddress := registry
+		if registry == name.DefaultRegistry {
+			// docker login uses the default auth server for docker.io
+			loginAddress = "docker.io"
+		}
+
+		if err := Login(ctx, authConfig.Username, authConfig.Password, loginAddress); err != nil {
+			return fmt.Errorf("unable to login into docker registry %s: %s", registry, err)
+		}
+	}
+
+	return nil
+}
@@ -60,6 +60,20 @@ func ServerVersion(ctx context.Context) (*types.Version, error) {
 	return &version, nil
 }
 
+func Info(ctx context.Context) (*types.Info, error) {
+	info, err := cli(ctx).Client().Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// DaemonHost returns the address of the docker daemon the cli is connected to
+func DaemonHost(ctx context.Context) string {
+	return cli(ctx).Client().DaemonHost()
+}
+
 func newDockerCli(opts []command.DockerCliOption) (command.Cli, error) {
 	newCli, err := command.NewDockerCli(opts...)
 	if err != nil {
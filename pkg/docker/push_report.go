@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/cli/cli/command"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"golang.org/x/net/context"
+)
+
+const pushedLayerStatus = "Pushed"
+
+type PushReport struct {
+	// PushedLayers contains short ids of the layers uploaded into the repo by the docker server,
+	// layers which already exist in the repo or mounted from another repo of the same registry are not included
+	PushedLayers []string
+}
+
+// PushWithRetriesAndReport pushes the image the same way as docker push does
+// and collects the layers statuses from the docker server push progress messages.
+func PushWithRetriesAndReport(ctx context.Context, ref string) (*PushReport, error) {
+	var report *PushReport
+
+	if err := callCliWithAutoOutput(ctx, func(c command.Cli) error {
+		return doWithPushRetries(c, func() error {
+			var err error
+			report, err = doPushWithReport(ctx, c, ref)
+			return err
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func doPushWithReport(ctx context.Context, c command.Cli, ref string) (*PushReport, error) {
+	encodedAuth, err := command.RetrieveAuthTokenFromImage(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := c.Client().ImagePush(ctx, ref, types.ImagePushOptions{RegistryAuth: encodedAuth})
+	if err != nil {
+		return nil, err
+	}
+	defer responseBody.Close()
+
+	pr, pw := io.Pipe()
+	layersStatusCh := make(chan map[string]string)
+	go func() {
+		layersStatusCh <- readPushLayersStatus(pr)
+	}()
+
+	err = jsonmessage.DisplayJSONMessagesToStream(io.TeeReader(responseBody, pw), c.Out(), nil)
+	_ = pw.Close()
+	layersStatus := <-layersStatusCh
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PushReport{}
+	for id, status := range layersStatus {
+		if status == pushedLayerStatus {
+			report.PushedLayers = append(report.PushedLayers, id)
+		}
+	}
+
+	return report, nil
+}
+
+func readPushLayersStatus(r io.Reader) map[string]string {
+	layersStatus := map[string]string{}
+	// the rest of the stream should be read anyway to not block the output
+	defer func() { _, _ = io.Copy(ioutil.Discard, r) }()
+
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			return layersStatus
+		}
+
+		if msg.ID != "" && msg.Status != "" {
+			// the last status of the layer is the result: Pushed, Layer already exists or Mounted from
+			layersStatus[msg.ID] = msg.Status
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -17,6 +16,7 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/docker_registry/credentials"
 	"github.com/werf/werf/pkg/image"
 )
 
@@ -77,6 +77,144 @@ func (api *api) GetRepoImageConfigFile(_ context.Context, reference string) (*v1
 	return imageInfo.ConfigFile()
 }
 
+func (api *api) GetRepoImageObject(_ context.Context, reference string) (v1.Image, error) {
+	img, _, err := api.image(reference)
+	return img, err
+}
+
+func (api *api) WriteRepoImage(ctx context.Context, reference string, img v1.Image) error {
+	_, err := api.WriteRepoImageWithReport(ctx, reference, img, WriteRepoImageOptions{})
+	return err
+}
+
+type WriteRepoImageOptions struct {
+	// MountFromRepos are the repositories of the destination registry to mount missing layers from
+	MountFromRepos []string
+}
+
+type WriteRepoImageReport struct {
+	// UploadedBytes is the size of the layers blobs actually uploaded into the repo,
+	// already existing and mounted blobs are not counted
+	UploadedBytes int64
+}
+
+// WriteRepoImageWithReport writes the image the same way as WriteRepoImage does,
+// but the missing layers are mounted from the first suitable repo of the same registry if possible.
+func (api *api) WriteRepoImageWithReport(_ context.Context, reference string, img v1.Image, opts WriteRepoImageOptions) (*WriteRepoImageReport, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	var mountFromRef name.Reference
+	for _, repo := range opts.MountFromRepos {
+		repoRef, err := name.NewRepository(repo, api.newRepositoryOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("parsing repository %q: %v", repo, err)
+		}
+
+		if repoRef.RegistryStr() == ref.Context().RegistryStr() && repoRef.RepositoryStr() != ref.Context().RepositoryStr() {
+			mountFromRef = repoRef.Tag(name.DefaultTag)
+			break
+		}
+	}
+
+	report := &WriteRepoImageReport{}
+	reportingImg := &uploadReportingImage{Image: img, registry: ref.Context().RegistryStr(), mountFromRef: mountFromRef, uploadedBytes: &report.UploadedBytes}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	err = remote.Write(ref, reportingImg, remote.WithAuthFromKeychain(credentials.Keychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return nil, fmt.Errorf("write to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return report, nil
+}
+
+// CopyRepoImage writes the source image by the destination reference without the local docker server.
+// The layers missing in the destination repo are mounted from the source repo of the same registry or from the MountFromRepos,
+// otherwise the layers are downloaded from the source registry and uploaded into the destination repo.
+func (api *api) CopyRepoImage(ctx context.Context, sourceReference, destinationReference string, opts WriteRepoImageOptions) (*WriteRepoImageReport, error) {
+	img, err := api.GetRepoImageObject(ctx, sourceReference)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.WriteRepoImageWithReport(ctx, destinationReference, img, opts)
+}
+
+func (api *api) WriteRepoImageIndex(_ context.Context, reference string, index v1.ImageIndex) error {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	err = remote.WriteIndex(ref, index, remote.WithAuthFromKeychain(credentials.Keychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return fmt.Errorf("write index to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return nil
+}
+
+func (api *api) TryGetRepoImageIndex(ctx context.Context, reference string) (*image.Info, error) {
+	if indexInfo, err := api.GetRepoImageIndex(ctx, reference); err != nil {
+		if IsManifestUnknownError(err) || IsNameUnknownError(err) {
+			return nil, nil
+		}
+		return indexInfo, err
+	} else {
+		return indexInfo, nil
+	}
+}
+
+func (api *api) GetRepoImageIndex(ctx context.Context, reference string) (*image.Info, error) {
+	indexInfo, _, err := api.GetRepoImageIndexManifest(ctx, reference)
+	return indexInfo, err
+}
+
+// GetRepoImageIndexManifest returns the index info and the index manifest with the descriptors of the platform images
+func (api *api) GetRepoImageIndexManifest(_ context.Context, reference string) (*image.Info, *v1.IndexManifest, error) {
+	ref, err := name.NewTag(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	index, err := remote.Index(ref, remote.WithAuthFromKeychain(credentials.Keychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading index %q: %v", ref, err)
+	}
+
+	digest, err := index.Digest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &image.Info{
+		Name:       reference,
+		Repository: strings.Join([]string{ref.RegistryStr(), ref.RepositoryStr()}, "/"),
+		ID:         digest.String(),
+		Tag:        ref.TagStr(),
+		RepoDigest: digest.String(),
+	}, manifest, nil
+}
+
 func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info, error) {
 	imageInfo, _, err := api.image(reference)
 	if err != nil {
@@ -125,6 +263,7 @@ func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info,
 		ParentID:   configFile.Config.Image,
 		Labels:     configFile.Config.Labels,
 		Size:       totalSize,
+		Platform:   image.PlatformName(configFile.OS, configFile.Architecture, ""),
 	}
 
 	repoImage.SetCreatedAtUnix(configFile.Created.Unix())
@@ -138,7 +277,7 @@ func (api *api) list(reference string) ([]string, error) {
 		return nil, fmt.Errorf("parsing repo %q: %v", reference, err)
 	}
 
-	tags, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(api.getHttpTransport()))
+	tags, err := remote.List(repo, remote.WithAuthFromKeychain(credentials.Keychain), remote.WithTransport(api.getHttpTransport()))
 	if err != nil {
 		return nil, fmt.Errorf("reading tags for %q: %v", repo, err)
 	}
@@ -152,7 +291,7 @@ func (api *api) deleteImageByReference(reference string) error {
 		return fmt.Errorf("parsing reference %q: %v", reference, err)
 	}
 
-	if err := remote.Delete(r, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(api.getHttpTransport())); err != nil {
+	if err := remote.Delete(r, remote.WithAuthFromKeychain(credentials.Keychain), remote.WithTransport(api.getHttpTransport())); err != nil {
 		return fmt.Errorf("deleting image %q: %v", r, err)
 	}
 
@@ -205,7 +344,7 @@ func (api *api) pushImage(_ context.Context, reference string, opts *PushImageOp
 
 	oldDefaultTransport := http.DefaultTransport
 	http.DefaultTransport = api.getHttpTransport()
-	err = remote.Write(ref, img, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	err = remote.Write(ref, img, remote.WithAuthFromKeychain(credentials.Keychain))
 	http.DefaultTransport = oldDefaultTransport
 
 	if err != nil {
@@ -226,7 +365,7 @@ func (api *api) image(reference string) (v1.Image, name.Reference, error) {
 	// FIXME: Needed for the insecure https registry to work.
 	oldDefaultTransport := http.DefaultTransport
 	http.DefaultTransport = api.getHttpTransport()
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(credentials.Keychain))
 	http.DefaultTransport = oldDefaultTransport
 
 	if err != nil {
@@ -252,10 +391,10 @@ func (api *api) parseReferenceOptions() []name.Option {
 }
 
 func (api *api) getHttpTransport() (transport http.RoundTripper) {
-	transport = http.DefaultTransport
+	transport = defaultHttpTransport
 
 	if api.SkipTlsVerifyRegistry {
-		defaultTransport := http.DefaultTransport.(*http.Transport)
+		defaultTransport := defaultHttpTransport.(*http.Transport)
 
 		newTransport := &http.Transport{
 			Proxy:                 defaultTransport.Proxy,
@@ -271,5 +410,5 @@ func (api *api) getHttpTransport() (transport http.RoundTripper) {
 		transport = newTransport
 	}
 
-	return
+	return registryThrottling.transport(transport)
 }
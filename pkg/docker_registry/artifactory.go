@@ -0,0 +1,115 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+const ArtifactoryImplementationName = "artifactory"
+
+type ArtifactoryNotFoundError apiError
+
+var artifactoryPatterns = []string{"^.*\\.jfrog\\.io", "^artifactory\\..*"}
+
+type artifactory struct {
+	*defaultImplementation
+	artifactoryApi
+	artifactoryCredentials
+}
+
+type artifactoryOptions struct {
+	defaultImplementationOptions
+	artifactoryCredentials
+}
+
+type artifactoryCredentials struct {
+	username string
+	password string
+}
+
+func newArtifactory(options artifactoryOptions) (*artifactory, error) {
+	d, err := newDefaultImplementation(options.defaultImplementationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactory := &artifactory{
+		defaultImplementation:  d,
+		artifactoryApi:         newArtifactoryApi(),
+		artifactoryCredentials: options.artifactoryCredentials,
+	}
+
+	return artifactory, nil
+}
+
+func (r *artifactory) Tags(ctx context.Context, reference string) ([]string, error) {
+	tags, err := r.defaultImplementation.Tags(ctx, reference)
+	if err != nil {
+		if IsNameUnknownError(err) || IsNotFoundError(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *artifactory) DeleteRepo(ctx context.Context, reference string) error {
+	hostname, repositoryKey, imagePath, err := r.parseReference(reference)
+	if err != nil {
+		return err
+	}
+
+	return r.deleteItem(ctx, hostname, repositoryKey, imagePath)
+}
+
+// DeleteRepoImage deletes the tag folder from the docker repository,
+// the other tags of the same manifest are not affected unlike the deletion by the digest
+func (r *artifactory) DeleteRepoImage(ctx context.Context, repoImage *image.Info) error {
+	hostname, repositoryKey, imagePath, err := r.parseReference(repoImage.Repository)
+	if err != nil {
+		return err
+	}
+
+	return r.deleteItem(ctx, hostname, repositoryKey, strings.Join([]string{imagePath, repoImage.Tag}, "/"))
+}
+
+func (r *artifactory) deleteItem(ctx context.Context, hostname, repositoryKey, itemPath string) error {
+	resp, err := r.artifactoryApi.DeleteItem(ctx, hostname, repositoryKey, itemPath, r.artifactoryCredentials.username, r.artifactoryCredentials.password)
+	if resp != nil {
+		if resp.StatusCode == http.StatusNotFound {
+			return ArtifactoryNotFoundError{error: err}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *artifactory) String() string {
+	return ArtifactoryImplementationName
+}
+
+// parseReference splits the reference of the repository path method HOSTNAME/REPOSITORY_KEY/IMAGE_PATH
+func (r *artifactory) parseReference(reference string) (string, string, string, error) {
+	parsedReference, err := name.NewRepository(reference)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	parts := strings.SplitN(parsedReference.RepositoryStr(), "/", 2)
+	if len(parts) != 2 {
+		return "", "", "", fmt.Errorf("unexpected reference %s: expected HOSTNAME/REPOSITORY_KEY/IMAGE_PATH", reference)
+	}
+
+	return parsedReference.RegistryStr(), parts[0], parts[1], nil
+}
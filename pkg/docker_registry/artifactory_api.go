@@ -0,0 +1,34 @@
+package docker_registry
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"path"
+)
+
+type artifactoryApi struct{}
+
+func newArtifactoryApi() artifactoryApi {
+	return artifactoryApi{}
+}
+
+func (api *artifactoryApi) DeleteItem(ctx context.Context, hostname, repositoryKey, itemPath, username, password string) (*http.Response, error) {
+	u, err := neturl.Parse("https://" + hostname + "/artifactory")
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, repositoryKey, itemPath)
+	url := u.String()
+
+	resp, _, err := doRequest(ctx, http.MethodDelete, url, nil, doRequestOptions{
+		BasicAuth: doRequestBasicAuth{
+			username: username,
+			password: password,
+		},
+		AcceptedCodes: []int{http.StatusOK, http.StatusNoContent},
+	})
+
+	return resp, err
+}
@@ -0,0 +1,104 @@
+package docker_registry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// BaseImageMirrors maps the registries (e.g. docker.io) and the repositories (e.g. docker.io/library) of the base images
+// to the mirror repositories (e.g. mirror.internal/dockerhub). The base images are fetched from the mirrors,
+// but the canonical names are still used to calculate stage digests, thus the cache remains valid across mirrors.
+type BaseImageMirrors struct {
+	mirrors []*baseImageMirror
+}
+
+type baseImageMirror struct {
+	source string
+	mirror string
+}
+
+// ParseBaseImageMirrors parses the mirrors specified in the SOURCE=MIRROR format,
+// the SOURCE is the registry hostname if it does not contain slash or the repository otherwise
+func ParseBaseImageMirrors(specs []string) (*BaseImageMirrors, error) {
+	m := &BaseImageMirrors{}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("bad base image mirror %q: expected SOURCE=MIRROR", spec)
+		}
+
+		source, err := normalizeBaseImageMirrorSource(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad base image mirror %q source: %s", spec, err)
+		}
+
+		mirror := strings.TrimSuffix(parts[1], "/")
+		if _, err := name.NewRepository(mirror, name.WeakValidation); err != nil {
+			if _, err := name.NewRegistry(mirror, name.WeakValidation); err != nil {
+				return nil, fmt.Errorf("bad base image mirror %q: %s", spec, err)
+			}
+		}
+
+		m.mirrors = append(m.mirrors, &baseImageMirror{source: source, mirror: mirror})
+	}
+
+	// the most specific source is matched first
+	sort.SliceStable(m.mirrors, func(i, j int) bool {
+		return len(m.mirrors[i].source) > len(m.mirrors[j].source)
+	})
+
+	return m, nil
+}
+
+func normalizeBaseImageMirrorSource(source string) (string, error) {
+	parts := strings.SplitN(strings.TrimSuffix(source, "/"), "/", 2)
+
+	registry, err := name.NewRegistry(parts[0], name.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+
+	if len(parts) == 1 {
+		return registry.RegistryStr(), nil
+	}
+
+	// the repository path is kept as is, docker.io/werf must not be expanded to docker.io/library/werf
+	if _, err := name.NewRepository(source, name.WeakValidation); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s", registry.RegistryStr(), parts[1]), nil
+}
+
+// Resolve returns the reference of the mirrored image to fetch or the reference itself if there is no suitable mirror
+func (m *BaseImageMirrors) Resolve(reference string) string {
+	if m == nil || len(m.mirrors) == 0 {
+		return reference
+	}
+
+	ref, err := name.ParseReference(reference, name.WeakValidation)
+	if err != nil {
+		return reference
+	}
+
+	repository := ref.Context().Name()
+	for _, mirror := range m.mirrors {
+		if repository != mirror.source && !strings.HasPrefix(repository, mirror.source+"/") {
+			continue
+		}
+
+		mirroredRepository := mirror.mirror + strings.TrimPrefix(repository, mirror.source)
+		switch r := ref.(type) {
+		case name.Digest:
+			return fmt.Sprintf("%s@%s", mirroredRepository, r.DigestStr())
+		case name.Tag:
+			return fmt.Sprintf("%s:%s", mirroredRepository, r.TagStr())
+		}
+	}
+
+	return reference
+}
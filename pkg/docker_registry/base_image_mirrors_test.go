@@ -0,0 +1,70 @@
+package docker_registry_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+var baseImageMirrorsSpecs = []string{
+	"docker.io=mirror.example.com/dockerhub",
+	"docker.io/werf=mirror.example.com/werf",
+	"ghcr.io=ghcr-mirror.example.com",
+}
+
+type baseImageMirrorsEntry struct {
+	reference   string
+	expectation string
+}
+
+var _ = DescribeTable("resolve base image mirror", func(entry baseImageMirrorsEntry) {
+	mirrors, err := docker_registry.ParseBaseImageMirrors(baseImageMirrorsSpecs)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	Ω(mirrors.Resolve(entry.reference)).Should(Equal(entry.expectation))
+},
+	Entry("official image", baseImageMirrorsEntry{
+		reference:   "alpine",
+		expectation: "mirror.example.com/dockerhub/library/alpine:latest",
+	}),
+	Entry("official image with tag", baseImageMirrorsEntry{
+		reference:   "ubuntu:20.04",
+		expectation: "mirror.example.com/dockerhub/library/ubuntu:20.04",
+	}),
+	Entry("official image with full name", baseImageMirrorsEntry{
+		reference:   "index.docker.io/library/ubuntu",
+		expectation: "mirror.example.com/dockerhub/library/ubuntu:latest",
+	}),
+	Entry("official image with digest", baseImageMirrorsEntry{
+		reference:   "alpine@sha256:1775bebec23e1f3ce486989bfc9ff3c4e951690df84aa9f926497d82f2ffca9d",
+		expectation: "mirror.example.com/dockerhub/library/alpine@sha256:1775bebec23e1f3ce486989bfc9ff3c4e951690df84aa9f926497d82f2ffca9d",
+	}),
+	Entry("the most specific repository mirror", baseImageMirrorsEntry{
+		reference:   "werf/werf:1.2",
+		expectation: "mirror.example.com/werf/werf:1.2",
+	}),
+	Entry("registry mirror", baseImageMirrorsEntry{
+		reference:   "ghcr.io/werf/builder:stable",
+		expectation: "ghcr-mirror.example.com/werf/builder:stable",
+	}),
+	Entry("registry without mirror", baseImageMirrorsEntry{
+		reference:   "quay.io/prometheus/busybox:v1.0",
+		expectation: "quay.io/prometheus/busybox:v1.0",
+	}),
+)
+
+var _ = DescribeTable("parse base image mirrors", func(specs []string, expectedErr bool) {
+	mirrors, err := docker_registry.ParseBaseImageMirrors(specs)
+	if expectedErr {
+		Ω(err).Should(HaveOccurred())
+		return
+	}
+
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(mirrors.Resolve("alpine:3.13")).Should(Equal("alpine:3.13"))
+},
+	Entry("no mirrors", nil, false),
+	Entry("mirror without target", []string{"docker.io"}, true),
+	Entry("mirror with empty source", []string{"=mirror.example.com"}, true),
+)
@@ -44,7 +44,8 @@ func doRequest(ctx context.Context, method, url string, body io.Reader, options
 	}
 
 	logboek.Context(ctx).Debug().LogF("--> %s %s\n", method, url)
-	resp, err := http.DefaultClient.Do(req)
+	client := &http.Client{Transport: registryThrottling.transport(defaultHttpTransport)}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,51 @@
+package container_registry_extensions
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// blobLayer is the layer with the arbitrary not compressed content (e.g. JSON data of the artifact),
+// the digest and the diffID of the layer are the same
+type blobLayer struct {
+	digest    v1.Hash
+	mediaType types.MediaType
+	content   []byte
+}
+
+func NewBlobLayer(content []byte, mediaType types.MediaType) (v1.Layer, error) {
+	digest, _, err := v1.SHA256(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobLayer{digest: digest, mediaType: mediaType, content: content}, nil
+}
+
+func (layer *blobLayer) Digest() (v1.Hash, error) {
+	return layer.digest, nil
+}
+
+func (layer *blobLayer) DiffID() (v1.Hash, error) {
+	return layer.digest, nil
+}
+
+func (layer *blobLayer) Compressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(layer.content)), nil
+}
+
+func (layer *blobLayer) Uncompressed() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(layer.content)), nil
+}
+
+func (layer *blobLayer) Size() (int64, error) {
+	return int64(len(layer.content)), nil
+}
+
+func (layer *blobLayer) MediaType() (types.MediaType, error) {
+	return layer.mediaType, nil
+}
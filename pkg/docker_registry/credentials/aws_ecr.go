@@ -0,0 +1,78 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+var awsEcrRegistryRegexp = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-zA-Z0-9][a-zA-Z0-9-_]*)\.amazonaws\.com(\.cn)?$`)
+
+// awsEcrAuthenticator exchanges the AWS credentials from the environment for the registry token,
+// the token is reused until it expires
+type awsEcrAuthenticator struct {
+	accountId string
+	region    string
+
+	authConfig *authn.AuthConfig
+	expiresAt  time.Time
+	mutex      sync.Mutex
+}
+
+func newAwsEcrAuthenticator(registry string) (*awsEcrAuthenticator, error) {
+	matches := awsEcrRegistryRegexp.FindStringSubmatch(registry)
+	if matches == nil {
+		return nil, fmt.Errorf("%s auth requires AWS ECR registry ACCOUNT_ID.dkr.ecr.REGION.amazonaws.com", AwsEcrAuth)
+	}
+
+	return &awsEcrAuthenticator{accountId: matches[1], region: matches[3]}, nil
+}
+
+func (a *awsEcrAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.authConfig != nil && time.Now().Add(time.Minute).Before(a.expiresAt) {
+		return a.authConfig, nil
+	}
+
+	mySession, err := session.NewSession(&aws.Config{Region: aws.String(a.region)})
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := ecr.New(mySession).GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{
+		RegistryIds: []*string{aws.String(a.accountId)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get authorization token failed: %s", err)
+	}
+
+	if len(output.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("get authorization token failed: no authorization data")
+	}
+
+	authorizationData := output.AuthorizationData[0]
+	token, err := base64.StdEncoding.DecodeString(aws.StringValue(authorizationData.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode authorization token: %s", err)
+	}
+
+	parts := strings.SplitN(string(token), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unexpected authorization token format")
+	}
+
+	a.authConfig = &authn.AuthConfig{Username: parts[0], Password: parts[1]}
+	a.expiresAt = aws.TimeValue(authorizationData.ExpiresAt)
+
+	return a.authConfig, nil
+}
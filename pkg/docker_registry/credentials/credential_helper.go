@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// credentialHelperAuthenticator gets the credentials from the docker credential helper binary docker-credential-HELPER
+type credentialHelperAuthenticator struct {
+	helper   string
+	registry string
+
+	authConfig *authn.AuthConfig
+	mutex      sync.Mutex
+}
+
+type credentialHelperOutput struct {
+	Username string `json:"Username"`
+	Secret   string `json:"Secret"`
+}
+
+func (a *credentialHelperAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.authConfig != nil {
+		return a.authConfig, nil
+	}
+
+	serverURL := a.registry
+	if serverURL == name.DefaultRegistry {
+		// docker stores the Docker Hub credentials by the legacy address
+		serverURL = "https://index.docker.io/v1/"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(fmt.Sprintf("docker-credential-%s", a.helper), "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("docker-credential-%s failed: %s: %s", a.helper, err, strings.TrimSpace(stdout.String()+stderr.String()))
+	}
+
+	output := &credentialHelperOutput{}
+	if err := json.Unmarshal(stdout.Bytes(), output); err != nil {
+		return nil, fmt.Errorf("unable to parse docker-credential-%s output: %s", a.helper, err)
+	}
+
+	if output.Username == "<token>" {
+		a.authConfig = &authn.AuthConfig{IdentityToken: output.Secret}
+	} else {
+		a.authConfig = &authn.AuthConfig{Username: output.Username, Password: output.Secret}
+	}
+
+	return a.authConfig, nil
+}
@@ -0,0 +1,225 @@
+package credentials
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	BasicAuth            = "basic"
+	TokenAuth            = "token"
+	CredentialHelperAuth = "helper"
+	AwsEcrAuth           = "ecr"
+	GcrAuth              = "gcr"
+)
+
+// File maps the registry hostnames to the auth methods
+type File struct {
+	Registries map[string]*RegistryAuth `json:"registries"`
+}
+
+type RegistryAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// Helper is the docker credential helper name, the docker-credential-HELPER binary is used
+	Helper string `json:"helper,omitempty"`
+	// KeyFile is the GCP service account JSON key, the application default credentials are used if not specified
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+var (
+	authenticators      = map[string]authn.Authenticator{}
+	authenticatorsMutex sync.Mutex
+)
+
+// Init loads the registry credentials file, the environment variables in the values are expanded
+func Init(path string) error {
+	authenticatorsMutex.Lock()
+	defer authenticatorsMutex.Unlock()
+
+	authenticators = map[string]authn.Authenticator{}
+	if path == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read registry credentials file %s: %s", path, err)
+	}
+
+	file := &File{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return fmt.Errorf("unable to parse registry credentials file %s: %s", path, err)
+	}
+
+	for address, registryAuth := range file.Registries {
+		registry, err := name.NewRegistry(address, name.WeakValidation)
+		if err != nil {
+			return fmt.Errorf("bad registry %q in registry credentials file %s: %s", address, path, err)
+		}
+
+		if registryAuth == nil {
+			return fmt.Errorf("bad registry %q in registry credentials file %s: auth is not specified", address, path)
+		}
+
+		authenticator, err := newAuthenticator(registry.RegistryStr(), registryAuth)
+		if err != nil {
+			return fmt.Errorf("bad registry %q in registry credentials file %s: %s", address, path, err)
+		}
+
+		authenticators[registry.RegistryStr()] = authenticator
+	}
+
+	return nil
+}
+
+func newAuthenticator(registry string, registryAuth *RegistryAuth) (authn.Authenticator, error) {
+	switch registryAuth.Auth {
+	case BasicAuth:
+		if registryAuth.Username == "" {
+			return nil, fmt.Errorf("username is required for %s auth", BasicAuth)
+		}
+
+		return &authn.Basic{
+			Username: os.ExpandEnv(registryAuth.Username),
+			Password: os.ExpandEnv(registryAuth.Password),
+		}, nil
+	case TokenAuth:
+		if registryAuth.Token == "" {
+			return nil, fmt.Errorf("token is required for %s auth", TokenAuth)
+		}
+
+		return &authn.Bearer{Token: os.ExpandEnv(registryAuth.Token)}, nil
+	case CredentialHelperAuth:
+		if registryAuth.Helper == "" {
+			return nil, fmt.Errorf("helper is required for %s auth", CredentialHelperAuth)
+		}
+
+		return &credentialHelperAuthenticator{helper: registryAuth.Helper, registry: registry}, nil
+	case AwsEcrAuth:
+		return newAwsEcrAuthenticator(registry)
+	case GcrAuth:
+		if registryAuth.KeyFile == "" {
+			return &lazyAuthenticator{newFunc: google.NewEnvAuthenticator}, nil
+		}
+
+		data, err := ioutil.ReadFile(os.ExpandEnv(registryAuth.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key file: %s", err)
+		}
+
+		return google.NewJSONKeyAuthenticator(string(data)), nil
+	default:
+		return nil, fmt.Errorf("unknown auth %q: expected one of %s", registryAuth.Auth, strings.Join([]string{BasicAuth, TokenAuth, CredentialHelperAuth, AwsEcrAuth, GcrAuth}, ", "))
+	}
+}
+
+// Registries returns the registries defined in the registry credentials file
+func Registries() []string {
+	authenticatorsMutex.Lock()
+	defer authenticatorsMutex.Unlock()
+
+	var registries []string
+	for registry := range authenticators {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	return registries
+}
+
+// GetAuthConfig returns the credentials of the registry or the repository address
+// or nil if the registry is not defined in the registry credentials file
+func GetAuthConfig(address string) (*authn.AuthConfig, error) {
+	registry, err := parseRegistry(address)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator := getAuthenticator(registry)
+	if authenticator == nil {
+		return nil, nil
+	}
+
+	authConfig, err := authenticator.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get %s credentials: %s", registry, err)
+	}
+
+	return authConfig, nil
+}
+
+func getAuthenticator(registry string) authn.Authenticator {
+	authenticatorsMutex.Lock()
+	defer authenticatorsMutex.Unlock()
+
+	return authenticators[registry]
+}
+
+func parseRegistry(address string) (string, error) {
+	parts := strings.SplitN(address, "/", 2)
+	if len(parts) == 1 || strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost" {
+		registry, err := name.NewRegistry(parts[0], name.WeakValidation)
+		if err != nil {
+			return "", err
+		}
+
+		return registry.RegistryStr(), nil
+	}
+
+	repository, err := name.NewRepository(address, name.WeakValidation)
+	if err != nil {
+		return "", err
+	}
+
+	return repository.RegistryStr(), nil
+}
+
+// Keychain resolves the credentials from the registry credentials file
+// and falls back to the docker config for the registries which are not defined in the file
+var Keychain authn.Keychain = &keychain{}
+
+type keychain struct{}
+
+func (k *keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if authenticator := getAuthenticator(target.RegistryStr()); authenticator != nil {
+		return authenticator, nil
+	}
+
+	return authn.DefaultKeychain.Resolve(target)
+}
+
+// lazyAuthenticator postpones the initialization to avoid requests to the cloud provider when the registry is not used
+type lazyAuthenticator struct {
+	newFunc func() (authn.Authenticator, error)
+
+	authenticator authn.Authenticator
+	mutex         sync.Mutex
+}
+
+func (a *lazyAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	a.mutex.Lock()
+	if a.authenticator == nil {
+		authenticator, err := a.newFunc()
+		if err != nil {
+			a.mutex.Unlock()
+			return nil, err
+		}
+
+		a.authenticator = authenticator
+	}
+	a.mutex.Unlock()
+
+	return a.authenticator.Authorization()
+}
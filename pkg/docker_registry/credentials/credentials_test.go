@@ -0,0 +1,89 @@
+package credentials_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry/credentials"
+)
+
+const credentialsFile = `
+registries:
+  registry.example.com:
+    auth: basic
+    username: user
+    password: ${WERF_TEST_REGISTRY_PASSWORD}
+  docker.io:
+    auth: token
+    token: hub-token
+`
+
+type getAuthConfigEntry struct {
+	address     string
+	expectation *authn.AuthConfig
+}
+
+var _ = Describe("registry credentials file", func() {
+	var dir string
+
+	writeCredentialsFile := func(content string) string {
+		path := filepath.Join(dir, "credentials.yaml")
+		Ω(ioutil.WriteFile(path, []byte(content), 0644)).Should(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "werf-registry-credentials")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(os.Setenv("WERF_TEST_REGISTRY_PASSWORD", "secret")).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(credentials.Init("")).Should(Succeed())
+		Ω(os.Unsetenv("WERF_TEST_REGISTRY_PASSWORD")).Should(Succeed())
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	DescribeTable("get auth config", func(entry getAuthConfigEntry) {
+		Ω(credentials.Init(writeCredentialsFile(credentialsFile))).Should(Succeed())
+
+		authConfig, err := credentials.GetAuthConfig(entry.address)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(authConfig).Should(Equal(entry.expectation))
+	},
+		Entry("basic auth with expanded environment variable", getAuthConfigEntry{
+			address:     "registry.example.com/group/project",
+			expectation: &authn.AuthConfig{Username: "user", Password: "secret"},
+		}),
+		Entry("registry address", getAuthConfigEntry{
+			address:     "registry.example.com",
+			expectation: &authn.AuthConfig{Username: "user", Password: "secret"},
+		}),
+		Entry("Docker Hub repository", getAuthConfigEntry{
+			address:     "werf/werf",
+			expectation: &authn.AuthConfig{RegistryToken: "hub-token"},
+		}),
+		Entry("registry which is not defined", getAuthConfigEntry{
+			address:     "quay.io/werf/werf",
+			expectation: nil,
+		}),
+	)
+
+	DescribeTable("init with bad file", func(content string) {
+		Ω(credentials.Init(writeCredentialsFile(content))).ShouldNot(Succeed())
+	},
+		Entry("unknown auth", "registries:\n  registry.example.com:\n    auth: unknown\n"),
+		Entry("basic auth without username", "registries:\n  registry.example.com:\n    auth: basic\n"),
+		Entry("token auth without token", "registries:\n  registry.example.com:\n    auth: token\n"),
+		Entry("ecr auth for not ECR registry", "registries:\n  registry.example.com:\n    auth: ecr\n"),
+		Entry("unknown field", "registries:\n  registry.example.com:\n    auth: basic\n    username: user\n    unknown: value\n"),
+	)
+})
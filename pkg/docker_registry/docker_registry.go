@@ -9,6 +9,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 
+	"github.com/werf/werf/pkg/docker_registry/credentials"
 	"github.com/werf/werf/pkg/image"
 )
 
@@ -32,15 +33,29 @@ type PushImageOptions struct {
 type DockerRegistryOptions struct {
 	InsecureRegistry      bool
 	SkipTlsVerifyRegistry bool
+	ArtifactoryUsername   string
+	ArtifactoryPassword   string
 	DockerHubToken        string
 	DockerHubUsername     string
 	DockerHubPassword     string
 	GitHubToken           string
 	HarborUsername        string
 	HarborPassword        string
+	NexusUsername         string
+	NexusPassword         string
 	QuayToken             string
 }
 
+func (o *DockerRegistryOptions) artifactoryOptions() artifactoryOptions {
+	return artifactoryOptions{
+		defaultImplementationOptions: o.defaultOptions(),
+		artifactoryCredentials: artifactoryCredentials{
+			username: o.ArtifactoryUsername,
+			password: o.ArtifactoryPassword,
+		},
+	}
+}
+
 func (o *DockerRegistryOptions) awsEcrOptions() awsEcrOptions {
 	return awsEcrOptions{
 		defaultImplementationOptions: o.defaultOptions(),
@@ -95,6 +110,16 @@ func (o *DockerRegistryOptions) harborOptions() harborOptions {
 	}
 }
 
+func (o *DockerRegistryOptions) nexusOptions() nexusOptions {
+	return nexusOptions{
+		defaultImplementationOptions: o.defaultOptions(),
+		nexusCredentials: nexusCredentials{
+			username: o.NexusUsername,
+			password: o.NexusPassword,
+		},
+	}
+}
+
 func (o *DockerRegistryOptions) quayOptions() quayOptions {
 	return quayOptions{
 		defaultImplementationOptions: o.defaultOptions(),
@@ -104,6 +129,41 @@ func (o *DockerRegistryOptions) quayOptions() quayOptions {
 	}
 }
 
+// setCredentialsFromFile sets the implementation credentials which are not specified explicitly from the registry credentials file
+func (o *DockerRegistryOptions) setCredentialsFromFile(repositoryAddress string) error {
+	authConfig, err := credentials.GetAuthConfig(repositoryAddress)
+	if err != nil {
+		return err
+	} else if authConfig == nil {
+		return nil
+	}
+
+	setCredentials := func(username, password *string) {
+		if *username == "" && *password == "" {
+			*username, *password = authConfig.Username, authConfig.Password
+		}
+	}
+
+	setToken := func(token *string, values ...string) {
+		for _, value := range values {
+			if *token == "" {
+				*token = value
+			}
+		}
+	}
+
+	setCredentials(&o.ArtifactoryUsername, &o.ArtifactoryPassword)
+	setCredentials(&o.DockerHubUsername, &o.DockerHubPassword)
+	setCredentials(&o.HarborUsername, &o.HarborPassword)
+	setCredentials(&o.NexusUsername, &o.NexusPassword)
+	setToken(&o.DockerHubToken, authConfig.RegistryToken)
+	// GitHub personal access token is used as the password
+	setToken(&o.GitHubToken, authConfig.RegistryToken, authConfig.Password)
+	setToken(&o.QuayToken, authConfig.RegistryToken)
+
+	return nil
+}
+
 func (o *DockerRegistryOptions) defaultOptions() defaultImplementationOptions {
 	return defaultImplementationOptions{apiOptions{
 		InsecureRegistry:      o.InsecureRegistry,
@@ -112,7 +172,13 @@ func (o *DockerRegistryOptions) defaultOptions() defaultImplementationOptions {
 }
 
 func NewDockerRegistry(repositoryAddress string, implementation string, options DockerRegistryOptions) (DockerRegistry, error) {
+	if err := options.setCredentialsFromFile(repositoryAddress); err != nil {
+		return nil, err
+	}
+
 	switch implementation {
+	case ArtifactoryImplementationName:
+		return newArtifactory(options.artifactoryOptions())
 	case AwsEcrImplementationName:
 		return newAwsEcr(options.awsEcrOptions())
 	case AzureCrImplementationName:
@@ -127,6 +193,8 @@ func NewDockerRegistry(repositoryAddress string, implementation string, options
 		return newGitLabRegistry(options.gitLabRegistryOptions())
 	case HarborImplementationName:
 		return newHarbor(options.harborOptions())
+	case NexusImplementationName:
+		return newNexus(options.nexusOptions())
 	case QuayImplementationName:
 		return newQuay(options.quayOptions())
 	case DefaultImplementationName:
@@ -176,6 +244,10 @@ func detectImplementation(accountOrRepositoryAddress string) (string, error) {
 		name     string
 		patterns []string
 	}{
+		{
+			name:     ArtifactoryImplementationName,
+			patterns: artifactoryPatterns,
+		},
 		{
 			name:     AwsEcrImplementationName,
 			patterns: awsEcrPatterns,
@@ -204,6 +276,10 @@ func detectImplementation(accountOrRepositoryAddress string) (string, error) {
 			name:     HarborImplementationName,
 			patterns: harborPatterns,
 		},
+		{
+			name:     NexusImplementationName,
+			patterns: nexusPatterns,
+		},
 		{
 			name:     QuayImplementationName,
 			patterns: quayPatterns,
@@ -226,6 +302,7 @@ func detectImplementation(accountOrRepositoryAddress string) (string, error) {
 
 func ImplementationList() []string {
 	return []string{
+		ArtifactoryImplementationName,
 		AwsEcrImplementationName,
 		AzureCrImplementationName,
 		DefaultImplementationName,
@@ -234,6 +311,7 @@ func ImplementationList() []string {
 		GitHubPackagesImplementationName,
 		GitLabRegistryImplementationName,
 		HarborImplementationName,
+		NexusImplementationName,
 		QuayImplementationName,
 	}
 }
@@ -8,12 +8,12 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/docker_registry/credentials"
 	"github.com/werf/werf/pkg/image"
 )
 
@@ -139,7 +139,7 @@ func (r *gitLabRegistry) customDeleteRepoImage(endpointFormat, reference string,
 		return fmt.Errorf("parsing reference %q: %v", reference, err)
 	}
 
-	auth, authErr := authn.DefaultKeychain.Resolve(ref.Context().Registry)
+	auth, authErr := credentials.Keychain.Resolve(ref.Context().Registry)
 	if authErr != nil {
 		return fmt.Errorf("getting creds for %q: %v", ref, authErr)
 	}
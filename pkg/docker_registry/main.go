@@ -12,7 +12,7 @@ import (
 
 var generic *api
 
-func Init(ctx context.Context, insecureRegistry, skipTlsVerifyRegistry bool) error {
+func Init(ctx context.Context, insecureRegistry, skipTlsVerifyRegistry bool, throttlingOptions ThrottlingOptions) error {
 	if logboek.Context(ctx).Debug().IsAccepted() {
 		logs.Progress.SetOutput(logboek.Context(ctx).ProxyOutStream())
 		logs.Warn.SetOutput(logboek.Context(ctx).ProxyErrStream())
@@ -28,6 +28,8 @@ func Init(ctx context.Context, insecureRegistry, skipTlsVerifyRegistry bool) err
 		logs.Debug.SetOutput(ioutil.Discard)
 	}
 
+	registryThrottling = newThrottling(throttlingOptions)
+
 	generic = newAPI(apiOptions{
 		InsecureRegistry:      insecureRegistry,
 		SkipTlsVerifyRegistry: skipTlsVerifyRegistry,
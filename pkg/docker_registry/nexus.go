@@ -0,0 +1,135 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+const NexusImplementationName = "nexus"
+
+type NexusNotFoundError apiError
+
+var nexusPatterns = []string{"^nexus\\..*"}
+
+type nexus struct {
+	*defaultImplementation
+	nexusApi
+	nexusCredentials
+}
+
+type nexusOptions struct {
+	defaultImplementationOptions
+	nexusCredentials
+}
+
+type nexusCredentials struct {
+	username string
+	password string
+}
+
+func newNexus(options nexusOptions) (*nexus, error) {
+	d, err := newDefaultImplementation(options.defaultImplementationOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	nexus := &nexus{
+		defaultImplementation: d,
+		nexusApi:              newNexusApi(),
+		nexusCredentials:      options.nexusCredentials,
+	}
+
+	return nexus, nil
+}
+
+func (r *nexus) Tags(ctx context.Context, reference string) ([]string, error) {
+	tags, err := r.defaultImplementation.Tags(ctx, reference)
+	if err != nil {
+		if IsNameUnknownError(err) || IsNotFoundError(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *nexus) DeleteRepo(ctx context.Context, reference string) error {
+	hostname, repository, imageName, isPathBased, err := r.parseReference(reference)
+	if err != nil {
+		return err
+	} else if !isPathBased {
+		return r.defaultImplementation.DeleteRepo(ctx, reference)
+	}
+
+	return r.deleteComponents(ctx, hostname, repository, imageName, "")
+}
+
+// DeleteRepoImage deletes the component of the tag,
+// the other tags of the same manifest are not affected unlike the deletion by the digest
+func (r *nexus) DeleteRepoImage(ctx context.Context, repoImage *image.Info) error {
+	hostname, repository, imageName, isPathBased, err := r.parseReference(repoImage.Repository)
+	if err != nil {
+		return err
+	} else if !isPathBased {
+		return r.defaultImplementation.DeleteRepoImage(ctx, repoImage)
+	}
+
+	return r.deleteComponents(ctx, hostname, repository, imageName, repoImage.Tag)
+}
+
+func (r *nexus) deleteComponents(ctx context.Context, hostname, repository, imageName, version string) error {
+	componentIDs, err := r.nexusApi.SearchComponents(ctx, hostname, repository, imageName, version, r.nexusCredentials.username, r.nexusCredentials.password)
+	if err != nil {
+		return err
+	}
+
+	if len(componentIDs) == 0 {
+		if version != "" {
+			return NexusNotFoundError{error: fmt.Errorf("component %s:%s not found in %s repository", imageName, version, repository)}
+		}
+
+		return nil
+	}
+
+	for _, componentID := range componentIDs {
+		resp, err := r.nexusApi.DeleteComponent(ctx, hostname, componentID, r.nexusCredentials.username, r.nexusCredentials.password)
+		if resp != nil {
+			if resp.StatusCode == http.StatusNotFound {
+				continue
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *nexus) String() string {
+	return NexusImplementationName
+}
+
+// parseReference splits the reference of the path based routing HOSTNAME/repository/REPOSITORY/IMAGE_NAME,
+// the other references (e.g. the port connector HOSTNAME:PORT/IMAGE_NAME) are not path based and handled by the default implementation
+func (r *nexus) parseReference(reference string) (string, string, string, bool, error) {
+	parsedReference, err := name.NewRepository(reference)
+	if err != nil {
+		return "", "", "", false, err
+	}
+
+	parts := strings.SplitN(parsedReference.RepositoryStr(), "/", 3)
+	if len(parts) != 3 || parts[0] != "repository" {
+		return "", "", "", false, nil
+	}
+
+	return parsedReference.RegistryStr(), parts[1], parts[2], true, nil
+}
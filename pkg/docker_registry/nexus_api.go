@@ -0,0 +1,95 @@
+package docker_registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"path"
+)
+
+type nexusApi struct{}
+
+func newNexusApi() nexusApi {
+	return nexusApi{}
+}
+
+// SearchComponents returns IDs of the docker components with the name and the version (any version if empty)
+func (api *nexusApi) SearchComponents(ctx context.Context, hostname, repository, name, version, username, password string) ([]string, error) {
+	var componentIDs []string
+	var continuationToken string
+	for {
+		u, err := neturl.Parse("https://" + hostname + "/service/rest/v1/search")
+		if err != nil {
+			return nil, err
+		}
+
+		query := u.Query()
+		query.Set("repository", repository)
+		query.Set("format", "docker")
+		query.Set("name", name)
+		if version != "" {
+			query.Set("version", version)
+		}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+		u.RawQuery = query.Encode()
+
+		_, respBody, err := doRequest(ctx, http.MethodGet, u.String(), nil, doRequestOptions{
+			Headers: map[string]string{
+				"Accept": "application/json",
+			},
+			BasicAuth: doRequestBasicAuth{
+				username: username,
+				password: password,
+			},
+			AcceptedCodes: []int{http.StatusOK},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Items []struct {
+				ID string `json:"id"`
+			} `json:"items"`
+			ContinuationToken string `json:"continuationToken"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal search result: %s", err)
+		}
+
+		for _, item := range result.Items {
+			componentIDs = append(componentIDs, item.ID)
+		}
+
+		if result.ContinuationToken == "" {
+			break
+		}
+		continuationToken = result.ContinuationToken
+	}
+
+	return componentIDs, nil
+}
+
+func (api *nexusApi) DeleteComponent(ctx context.Context, hostname, componentID, username, password string) (*http.Response, error) {
+	u, err := neturl.Parse("https://" + hostname + "/service/rest/v1/components")
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = path.Join(u.Path, componentID)
+	url := u.String()
+
+	resp, _, err := doRequest(ctx, http.MethodDelete, url, nil, doRequestOptions{
+		BasicAuth: doRequestBasicAuth{
+			username: username,
+			password: password,
+		},
+		AcceptedCodes: []int{http.StatusOK, http.StatusNoContent},
+	})
+
+	return resp, err
+}
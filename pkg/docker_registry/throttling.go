@@ -0,0 +1,242 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/werf/logboek"
+)
+
+const (
+	DefaultMaxRetries = 5
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+	// the request is not retried if the registry asks to wait longer, e.g. when the pull limit of Docker Hub is exceeded
+	retryAfterMaxDelay = 5 * time.Minute
+)
+
+type ThrottlingOptions struct {
+	// RequestsPerSecond limits the requests to each registry, 0 means no limit
+	RequestsPerSecond float64
+	// RequestsPerSecondByRegistry overrides RequestsPerSecond for the registry hostnames
+	RequestsPerSecondByRegistry map[string]float64
+	// MaxRetries of the requests throttled by the registry (429) or failed due to the registry temporary unavailability (502, 503, 504)
+	MaxRetries int
+}
+
+// defaultHttpTransport is saved before the http.DefaultTransport is replaced to work around go-containerregistry
+var defaultHttpTransport = http.DefaultTransport
+
+// registryThrottling is shared by all registry requests of werf,
+// thus parallel tasks are limited by the same rate and paused together when the registry responds with 429
+var registryThrottling = newThrottling(ThrottlingOptions{MaxRetries: DefaultMaxRetries})
+
+type throttling struct {
+	options ThrottlingOptions
+
+	hostStates      map[string]*throttlingHostState
+	hostStatesMutex sync.Mutex
+}
+
+type throttlingHostState struct {
+	mutex        sync.Mutex
+	interval     time.Duration
+	next         time.Time
+	blockedUntil time.Time
+}
+
+func newThrottling(options ThrottlingOptions) *throttling {
+	return &throttling{
+		options:    options,
+		hostStates: map[string]*throttlingHostState{},
+	}
+}
+
+// NewThrottlingTransport returns the transport with its own throttling state, which is not shared with werf registry requests
+func NewThrottlingTransport(options ThrottlingOptions, base http.RoundTripper) http.RoundTripper {
+	return newThrottling(options).transport(base)
+}
+
+func (t *throttling) transport(base http.RoundTripper) http.RoundTripper {
+	return &throttlingTransport{throttling: t, base: base}
+}
+
+func (t *throttling) hostState(host string) *throttlingHostState {
+	t.hostStatesMutex.Lock()
+	defer t.hostStatesMutex.Unlock()
+
+	if state, hasKey := t.hostStates[host]; hasKey {
+		return state
+	}
+
+	requestsPerSecond := t.options.RequestsPerSecond
+	if value, hasKey := t.options.RequestsPerSecondByRegistry[host]; hasKey {
+		requestsPerSecond = value
+	}
+
+	state := &throttlingHostState{}
+	if requestsPerSecond > 0 {
+		state.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	t.hostStates[host] = state
+
+	return state
+}
+
+// wait reserves the time slot for the request according to the rate limit and the pause requested by the registry
+func (s *throttlingHostState) wait(ctx context.Context) error {
+	s.mutex.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(start) {
+		start = s.next
+	}
+	if s.blockedUntil.After(start) {
+		start = s.blockedUntil
+	}
+	s.next = start.Add(s.interval)
+	s.mutex.Unlock()
+
+	return sleep(ctx, start.Sub(now))
+}
+
+func (s *throttlingHostState) block(until time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if until.After(s.blockedUntil) {
+		s.blockedUntil = until
+	}
+}
+
+type throttlingTransport struct {
+	*throttling
+	base http.RoundTripper
+}
+
+func (t *throttlingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	state := t.hostState(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		if err := state.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if attempt >= t.options.MaxRetries || !isRetryableResponse(req, resp) {
+			return resp, nil
+		}
+
+		delay, ok := retryDelay(resp, attempt)
+		if !ok {
+			return resp, nil
+		}
+
+		retryReq, err := rewindRequest(req)
+		if err != nil {
+			return nil, err
+		} else if retryReq == nil {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		logboek.Context(ctx).Warn().LogF("WARNING: %s %s responded %s: retrying in %s (%d/%d)\n", req.Method, req.URL.Host, resp.Status, delay.Round(time.Millisecond), attempt+1, t.options.MaxRetries)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			state.block(time.Now().Add(delay))
+		} else if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		req = retryReq
+	}
+}
+
+// isRetryableResponse checks whether the request has not been processed by the registry,
+// the temporary unavailability is retried only for the requests without side effects
+func isRetryableResponse(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.Method == http.MethodGet || req.Method == http.MethodHead
+	default:
+		return false
+	}
+}
+
+// retryDelay returns the delay specified by the Retry-After header or the exponential backoff delay with jitter
+func retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		var delay time.Duration
+		if seconds, err := strconv.Atoi(value); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(value); err == nil {
+			delay = time.Until(date)
+		}
+
+		if delay > retryAfterMaxDelay {
+			return 0, false
+		} else if delay > 0 {
+			return delay, true
+		}
+	}
+
+	delay := retryBaseDelay << uint(attempt)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(retryBaseDelay))), true
+}
+
+// rewindRequest returns the copy of the request to resend or nil if the request body cannot be read again
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	newReq := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return newReq, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get request body: %s", err)
+	}
+	newReq.Body = body
+
+	return newReq, nil
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package docker_registry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+type throttlingEntry struct {
+	options           docker_registry.ThrottlingOptions
+	method            string
+	responses         []int
+	requestsNumber    int
+	expectedStatus    int
+	expectedRequests  int32
+	expectedMinDelay  time.Duration
+	retryAfterSeconds string
+}
+
+var _ = DescribeTable("throttling transport", func(entry throttlingEntry) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n <= len(entry.responses) {
+			if entry.retryAfterSeconds != "" {
+				w.Header().Set("Retry-After", entry.retryAfterSeconds)
+			}
+			w.WriteHeader(entry.responses[n-1])
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	options := entry.options
+	if options.RequestsPerSecondByRegistry != nil {
+		options.RequestsPerSecondByRegistry = map[string]float64{strings.TrimPrefix(server.URL, "http://"): options.RequestsPerSecondByRegistry[""]}
+	}
+	client := &http.Client{Transport: docker_registry.NewThrottlingTransport(options, http.DefaultTransport)}
+
+	start := time.Now()
+	for i := 0; i < entry.requestsNumber; i++ {
+		req, err := http.NewRequest(entry.method, server.URL, strings.NewReader("body"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		resp, err := client.Do(req)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.StatusCode).Should(Equal(entry.expectedStatus))
+	}
+
+	Ω(time.Since(start) >= entry.expectedMinDelay).Should(BeTrue())
+	Ω(atomic.LoadInt32(&requests)).Should(Equal(entry.expectedRequests))
+},
+	Entry("429 is retried after Retry-After", throttlingEntry{
+		options:           docker_registry.ThrottlingOptions{MaxRetries: 1},
+		method:            http.MethodPost,
+		responses:         []int{http.StatusTooManyRequests},
+		retryAfterSeconds: "1",
+		requestsNumber:    1,
+		expectedStatus:    http.StatusOK,
+		expectedRequests:  2,
+		expectedMinDelay:  time.Second,
+	}),
+	Entry("429 is not retried when retries are exhausted", throttlingEntry{
+		options:          docker_registry.ThrottlingOptions{MaxRetries: 0},
+		method:           http.MethodGet,
+		responses:        []int{http.StatusTooManyRequests},
+		requestsNumber:   1,
+		expectedStatus:   http.StatusTooManyRequests,
+		expectedRequests: 1,
+	}),
+	Entry("503 is retried for GET", throttlingEntry{
+		options:          docker_registry.ThrottlingOptions{MaxRetries: 1},
+		method:           http.MethodGet,
+		responses:        []int{http.StatusServiceUnavailable},
+		requestsNumber:   1,
+		expectedStatus:   http.StatusOK,
+		expectedRequests: 2,
+	}),
+	Entry("503 is not retried for POST", throttlingEntry{
+		options:          docker_registry.ThrottlingOptions{MaxRetries: 1},
+		method:           http.MethodPost,
+		responses:        []int{http.StatusServiceUnavailable},
+		requestsNumber:   1,
+		expectedStatus:   http.StatusServiceUnavailable,
+		expectedRequests: 1,
+	}),
+	Entry("registry rate limit overrides common rate limit", throttlingEntry{
+		options:          docker_registry.ThrottlingOptions{RequestsPerSecond: 1000, RequestsPerSecondByRegistry: map[string]float64{"": 10}},
+		method:           http.MethodGet,
+		requestsNumber:   3,
+		expectedStatus:   http.StatusOK,
+		expectedRequests: 3,
+		expectedMinDelay: 200 * time.Millisecond,
+	}),
+)
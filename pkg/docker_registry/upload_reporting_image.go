@@ -0,0 +1,78 @@
+package docker_registry
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// uploadReportingImage counts the bytes of the layers blobs read by remote.Write.
+// remote.Write reads the blob only when the blob neither exists in the repo nor could be mounted,
+// so the counted bytes are the bytes actually uploaded.
+type uploadReportingImage struct {
+	v1.Image
+
+	// registry is the destination registry, the layers are mounted only from the repos of this registry
+	registry      string
+	mountFromRef  name.Reference
+	uploadedBytes *int64
+}
+
+func (img *uploadReportingImage) Layers() ([]v1.Layer, error) {
+	layers, err := img.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []v1.Layer
+	for _, l := range layers {
+		mountFromRef := img.mountFromRef
+
+		// remote image layers are already mountable from the source repo of the same registry
+		if ml, ok := l.(*remote.MountableLayer); ok {
+			l = ml.Layer
+			if ml.Reference.Context().RegistryStr() == img.registry {
+				mountFromRef = ml.Reference
+			}
+		}
+
+		var reportingLayer v1.Layer = &uploadReportingLayer{Layer: l, uploadedBytes: img.uploadedBytes}
+		if mountFromRef != nil {
+			reportingLayer = &remote.MountableLayer{Layer: reportingLayer, Reference: mountFromRef}
+		}
+
+		res = append(res, reportingLayer)
+	}
+
+	return res, nil
+}
+
+type uploadReportingLayer struct {
+	v1.Layer
+
+	uploadedBytes *int64
+}
+
+func (l *uploadReportingLayer) Compressed() (io.ReadCloser, error) {
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingReadCloser{ReadCloser: rc, counter: l.uploadedBytes}, nil
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+
+	counter *int64
+}
+
+func (rc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(p)
+	atomic.AddInt64(rc.counter, int64(n))
+	return n, err
+}
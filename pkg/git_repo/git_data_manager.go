@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/werf/werf/pkg/true_git"
 
@@ -21,6 +22,10 @@ import (
 const (
 	GitArchivesCacheVersion = "1"
 	GitPatchesCacheVersion  = "1"
+
+	ArchiveFileExt       = ".tar"
+	PatchFileExt         = ".patch"
+	CacheMetadataFileExt = ".meta.json"
 )
 
 var (
@@ -83,6 +88,10 @@ func (manager *GitDataManager) GetArchiveFile(ctx context.Context, repoID string
 		return nil, nil
 	}
 
+	if err := touchCacheFile(path); err != nil {
+		return nil, err
+	}
+
 	if data, err := ioutil.ReadFile(metadataPath); err != nil {
 		return nil, fmt.Errorf("error reading %s: %s", metadataPath, err)
 	} else {
@@ -107,7 +116,7 @@ func (manager *GitDataManager) CreateArchiveFile(ctx context.Context, repoID str
 		defer werf.ReleaseHostLock(lock)
 	}
 
-	if _, lock, err := werf.AcquireHostLock(ctx, fmt.Sprintf("git_archive.%s_%s", repoID, util.ObjectToHashKey(opts)), lockgate.AcquireOptions{}); err != nil {
+	if _, lock, err := werf.AcquireHostLock(ctx, ArchiveLockName(archiveID(repoID, opts)), lockgate.AcquireOptions{}); err != nil {
 		return nil, err
 	} else {
 		defer werf.ReleaseHostLock(lock)
@@ -166,6 +175,10 @@ func (manager *GitDataManager) GetPatchFile(ctx context.Context, repoID string,
 		return nil, nil
 	}
 
+	if err := touchCacheFile(path); err != nil {
+		return nil, err
+	}
+
 	if data, err := ioutil.ReadFile(metadataPath); err != nil {
 		return nil, fmt.Errorf("error reading %s: %s", metadataPath, err)
 	} else {
@@ -185,7 +198,7 @@ func (manager *GitDataManager) CreatePatchFile(ctx context.Context, repoID strin
 		defer werf.ReleaseHostLock(lock)
 	}
 
-	if _, lock, err := werf.AcquireHostLock(ctx, fmt.Sprintf("git_patch.%s_%s", repoID, util.ObjectToHashKey(opts)), lockgate.AcquireOptions{}); err != nil {
+	if _, lock, err := werf.AcquireHostLock(ctx, PatchLockName(patchID(repoID, opts)), lockgate.AcquireOptions{}); err != nil {
 		return nil, err
 	} else {
 		defer werf.ReleaseHostLock(lock)
@@ -219,18 +232,47 @@ func (manager *GitDataManager) CreatePatchFile(ctx context.Context, repoID strin
 	return &PatchFile{FilePath: path, Descriptor: desc}, nil
 }
 
+// ArchiveLockName returns the host lock name of the cached archive, archiveID is the archive file name without extension
+func ArchiveLockName(archiveID string) string {
+	return fmt.Sprintf("git_archive.%s", archiveID)
+}
+
+// PatchLockName returns the host lock name of the cached patch, patchID is the patch file name without extension
+func PatchLockName(patchID string) string {
+	return fmt.Sprintf("git_patch.%s", patchID)
+}
+
+// touchCacheFile updates the modification time of the cache file on each access,
+// thus host cleanup can remove the least recently used files
+func touchCacheFile(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("unable to update %s modification time: %s", path, err)
+	}
+
+	return nil
+}
+
+func patchID(repoID string, opts PatchOptions) string {
+	return fmt.Sprintf("%s_%s", repoID, util.ObjectToHashKey(opts))
+}
+
 func patchMetadataFileName(repoID string, opts PatchOptions) string {
-	return fmt.Sprintf("%s_%s.meta.json", repoID, util.ObjectToHashKey(opts))
+	return patchID(repoID, opts) + CacheMetadataFileExt
 }
 
 func patchFileName(repoID string, opts PatchOptions) string {
-	return fmt.Sprintf("%s_%s.patch", repoID, util.ObjectToHashKey(opts))
+	return patchID(repoID, opts) + PatchFileExt
+}
+
+func archiveID(repoID string, opts ArchiveOptions) string {
+	return fmt.Sprintf("%s_%s", repoID, util.ObjectToHashKey(opts))
 }
 
 func archiveMetadataFileName(repoID string, opts ArchiveOptions) string {
-	return fmt.Sprintf("%s_%s.meta.json", repoID, util.ObjectToHashKey(opts))
+	return archiveID(repoID, opts) + CacheMetadataFileExt
 }
 
 func archiveFileName(repoID string, opts ArchiveOptions) string {
-	return fmt.Sprintf("%s_%s.tar", repoID, util.ObjectToHashKey(opts))
+	return archiveID(repoID, opts) + ArchiveFileExt
 }
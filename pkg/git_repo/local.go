@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 
 	"github.com/werf/logboek"
 
@@ -209,6 +210,28 @@ func (repo *Local) HeadCommit(_ context.Context) (string, error) {
 	return repo.headCommit, nil
 }
 
+// WithHeadCommit returns a copy of the repo which uses the specified commit as the head commit
+func (repo *Local) WithHeadCommit(commit string) Local {
+	l := *repo
+	l.headCommit = commit
+	return l
+}
+
+// ResolveCommit resolves the git revision (commit, branch or tag) into the commit hash
+func (repo *Local) ResolveCommit(_ context.Context, revision string) (string, error) {
+	repository, err := git.PlainOpenWithOptions(repo.WorkTreeDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return "", fmt.Errorf("cannot open repo %q: %s", repo.WorkTreeDir, err)
+	}
+
+	hash, err := repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve revision %q: %s", revision, err)
+	}
+
+	return hash.String(), nil
+}
+
 func (repo *Local) CreatePatch(ctx context.Context, opts PatchOptions) (Patch, error) {
 	return repo.createPatch(ctx, repo.WorkTreeDir, repo.GitDir, repo.getRepoID(), repo.getRepoWorkTreeCacheDir(repo.getRepoID()), opts)
 }
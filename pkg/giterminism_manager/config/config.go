@@ -85,6 +85,14 @@ func (c Config) IsConfigDockerfileContextAddFileAccepted(relPath string) (bool,
 	return c.Config.Dockerfile.IsContextAddFileAccepted(relPath)
 }
 
+func (c Config) IsConfigDockerfileSecretEnvNameAccepted(envName string) (bool, error) {
+	return c.Config.Dockerfile.IsSecretEnvNameAccepted(envName)
+}
+
+func (c Config) IsConfigDockerfileSecretSrcAccepted(src string) (bool, error) {
+	return c.Config.Dockerfile.IsSecretSrcAccepted(src)
+}
+
 func (c Config) IsUncommittedDockerfileAccepted(relPath string) (bool, error) {
 	return c.Config.Dockerfile.IsUncommittedAccepted(relPath)
 }
@@ -159,6 +167,16 @@ type dockerfile struct {
 	AllowUncommitted                  []string `json:"allowUncommitted"`
 	AllowUncommittedDockerignoreFiles []string `json:"allowUncommittedDockerignoreFiles"`
 	AllowContextAddFiles              []string `json:"allowContextAddFiles"`
+	AllowSecretEnvVariables           []string `json:"allowSecretEnvVariables"`
+	AllowSecretSrcFiles               []string `json:"allowSecretSrcFiles"`
+}
+
+func (d dockerfile) IsSecretEnvNameAccepted(name string) (bool, error) {
+	return isEnvNameMatched(d.AllowSecretEnvVariables, name)
+}
+
+func (d dockerfile) IsSecretSrcAccepted(path string) (bool, error) {
+	return isPathMatched(d.AllowSecretSrcFiles, path)
 }
 
 func (d dockerfile) IsContextAddFileAccepted(path string) (bool, error) {
@@ -181,6 +199,26 @@ func (h helm) IsUncommittedHelmFileAccepted(path string) (bool, error) {
 	return isPathMatched(h.AllowUncommittedFiles, path)
 }
 
+func isEnvNameMatched(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr := fmt.Sprintf("^%s$", pattern[1:len(pattern)-1])
+			r, err := regexp.Compile(expr)
+			if err != nil {
+				return false, err
+			}
+
+			if r.MatchString(name) {
+				return true, nil
+			}
+		} else if pattern == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 func isPathMatched(patterns []string, p string) (bool, error) {
 	p = filepath.ToSlash(p)
 	for _, pattern := range patterns {
@@ -97,6 +97,14 @@ definitions:
         type: array
         items:
           type: string
+      allowSecretEnvVariables:
+        type: array
+        items:
+          type: string
+      allowSecretSrcFiles:
+        type: array
+        items:
+          type: string
   Helm:
     type: object
     additionalProperties: {}
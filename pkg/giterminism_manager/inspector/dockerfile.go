@@ -18,3 +18,31 @@ func (i Inspector) InspectConfigDockerfileContextAddFile(relPath string) error {
 
 	return NewExternalDependencyFoundError(fmt.Sprintf("contextAddFile %q not allowed", filepath.ToSlash(relPath)))
 }
+
+func (i Inspector) InspectConfigDockerfileSecretEnv(envName string) error {
+	if i.sharedOptions.LooseGiterminism() {
+		return nil
+	}
+
+	if isAccepted, err := i.giterminismConfig.IsConfigDockerfileSecretEnvNameAccepted(envName); err != nil {
+		return err
+	} else if isAccepted {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(fmt.Sprintf("secret env name %q not allowed", envName))
+}
+
+func (i Inspector) InspectConfigDockerfileSecretSrc(src string) error {
+	if i.sharedOptions.LooseGiterminism() {
+		return nil
+	}
+
+	if isAccepted, err := i.giterminismConfig.IsConfigDockerfileSecretSrcAccepted(src); err != nil {
+		return err
+	} else if isAccepted {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(fmt.Sprintf("secret src %q not allowed", filepath.ToSlash(src)))
+}
@@ -16,6 +16,8 @@ type giterminismConfig interface {
 	IsConfigStapelMountBuildDirAccepted() bool
 	IsConfigStapelMountFromPathAccepted(fromPath string) (bool, error)
 	IsConfigDockerfileContextAddFileAccepted(relPath string) (bool, error)
+	IsConfigDockerfileSecretEnvNameAccepted(envName string) (bool, error)
+	IsConfigDockerfileSecretSrcAccepted(src string) (bool, error)
 }
 
 type sharedOptions interface {
@@ -43,4 +43,6 @@ type Inspector interface {
 	InspectConfigStapelMountBuildDir() error
 	InspectConfigStapelMountFromPath(fromPath string) error
 	InspectConfigDockerfileContextAddFile(relPath string) error
+	InspectConfigDockerfileSecretEnv(envName string) error
+	InspectConfigDockerfileSecretSrc(src string) error
 }
@@ -21,6 +21,8 @@ import (
 
 type HostCleanupOptions struct {
 	DryRun bool
+	// AllowedDiskUsagePercent enables the removal of the least recently used local stages and werf local cache, 0 to disable
+	AllowedDiskUsagePercent float64
 }
 
 func HostCleanup(ctx context.Context, options HostCleanupOptions) error {
@@ -44,12 +46,22 @@ func HostCleanup(ctx context.Context, options HostCleanupOptions) error {
 			return nil
 		}
 
-		return werf.WithHostLock(ctx, "gc", lockgate.AcquireOptions{}, func() error {
+		if err := werf.WithHostLock(ctx, "gc", lockgate.AcquireOptions{}, func() error {
 			if err := tmp_manager.GC(ctx, commonOptions.DryRun); err != nil {
 				return fmt.Errorf("tmp files gc failed: %s", err)
 			}
 
 			return nil
+		}); err != nil {
+			return err
+		}
+
+		if options.AllowedDiskUsagePercent == 0 {
+			return nil
+		}
+
+		return logboek.Context(ctx).LogProcess("Running cleanup for least recently used local stages and werf local cache").DoError(func() error {
+			return safeLRUCleanup(ctx, options.AllowedDiskUsagePercent, commonOptions)
 		})
 	})
 }
@@ -0,0 +1,422 @@
+package host_cleaning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type volume struct {
+	Name string
+	Path string
+
+	// getCandidates returns the objects which can be removed to free the volume
+	getCandidates func(v *volume) ([]*lruCleanupCandidate, error)
+	// getUsage returns the used and the total bytes of the volume, getVolumeUsage is used by default
+	getUsage func(path string) (uint64, uint64, error)
+
+	// freedBytes are counted to estimate the volume usage in the dry run mode
+	freedBytes uint64
+}
+
+func (v *volume) usagePercent() (float64, error) {
+	getUsage := v.getUsage
+	if getUsage == nil {
+		getUsage = getVolumeUsage
+	}
+
+	used, total, err := getUsage(v.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	if v.freedBytes > used {
+		used = 0
+	} else {
+		used -= v.freedBytes
+	}
+
+	if total == 0 {
+		return 0, nil
+	}
+
+	return float64(used) * 100 / float64(total), nil
+}
+
+type lruCleanupCandidate struct {
+	Description string
+	Volume      *volume
+	LastUsed    time.Time
+	Size        uint64
+	LockNames   []string
+	Remove      func() error
+}
+
+// safeLRUCleanup removes the least recently used local stages and werf local cache until the usage of the docker storage volume
+// and the werf local cache volume is not greater than allowedUsagePercent, the objects locked by other werf processes are skipped
+func safeLRUCleanup(ctx context.Context, allowedUsagePercent float64, options CommonOptions) error {
+	var volumes []*volume
+
+	// the docker storage of the remote daemon cannot be measured on this host
+	if daemonHost := docker.DaemonHost(ctx); !isLocalDockerDaemonHost(daemonHost) {
+		logboek.Context(ctx).Default().LogF("Skip docker storage volume: docker daemon %s is not local\n", daemonHost)
+	} else if info, err := docker.Info(ctx); err != nil {
+		return fmt.Errorf("unable to get docker info: %s", err)
+	} else {
+		volumes = append(volumes, &volume{
+			Name: "docker storage",
+			Path: info.DockerRootDir,
+			getCandidates: func(v *volume) ([]*lruCleanupCandidate, error) {
+				return localStagesLRUCleanupCandidates(ctx, v, options)
+			},
+		})
+	}
+
+	volumes = append(volumes, &volume{
+		Name: "werf local cache",
+		Path: werf.GetLocalCacheDir(),
+		getCandidates: func(v *volume) ([]*lruCleanupCandidate, error) {
+			return localCacheLRUCleanupCandidates(ctx, v)
+		},
+	})
+
+	return lruCleanupVolumes(ctx, volumes, allowedUsagePercent, options)
+}
+
+// lruCleanupVolumes removes the candidates of the volumes with exceeded usage in the order of the last usage
+// until the usage of the candidate volume is not greater than allowedUsagePercent
+func lruCleanupVolumes(ctx context.Context, volumes []*volume, allowedUsagePercent float64, options CommonOptions) error {
+	var candidates []*lruCleanupCandidate
+	for _, v := range volumes {
+		usage, err := v.usagePercent()
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skip %s volume: %s\n", v.Name, err)
+			continue
+		}
+
+		logboek.Context(ctx).Default().LogF("Volume usage of %s (%s): %.2f%% (allowed %.2f%%)\n", v.Name, v.Path, usage, allowedUsagePercent)
+
+		if usage <= allowedUsagePercent {
+			continue
+		}
+
+		volumeCandidates, err := v.getCandidates(v)
+		if err != nil {
+			return err
+		}
+
+		candidates = append(candidates, volumeCandidates...)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].LastUsed.Before(candidates[j].LastUsed)
+	})
+
+	for _, candidate := range candidates {
+		usage, err := candidate.Volume.usagePercent()
+		if err != nil {
+			return err
+		}
+
+		if usage <= allowedUsagePercent {
+			continue
+		}
+
+		if err := lruCleanupCandidateRemove(ctx, candidate, options); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range volumes {
+		if usage, err := v.usagePercent(); err != nil {
+			continue
+		} else if usage > allowedUsagePercent {
+			logboek.Context(ctx).Warn().LogF("WARNING: Volume usage of %s (%s) is %.2f%%, which is still greater than allowed %.2f%%: the rest of data is used or is not managed by werf\n", v.Name, v.Path, usage, allowedUsagePercent)
+		}
+	}
+
+	return nil
+}
+
+func lruCleanupCandidateRemove(ctx context.Context, candidate *lruCleanupCandidate, options CommonOptions) error {
+	var locks []lockgate.LockHandle
+	defer func() {
+		for _, lock := range locks {
+			werf.ReleaseHostLock(lock)
+		}
+	}()
+
+	for _, lockName := range candidate.LockNames {
+		isLocked, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{NonBlocking: true})
+		if err != nil {
+			return fmt.Errorf("failed to lock %s for %s: %s", lockName, candidate.Description, err)
+		}
+
+		if !isLocked {
+			logboek.Context(ctx).Default().LogFDetails("Ignore %s used by another process\n", candidate.Description)
+			return nil
+		}
+
+		locks = append(locks, lock)
+	}
+
+	logboek.Context(ctx).Default().LogFDetails("Removing %s (last used %s)\n", candidate.Description, candidate.LastUsed.Format(time.RFC3339))
+
+	if options.DryRun {
+		candidate.Volume.freedBytes += candidate.Size
+		return nil
+	}
+
+	if err := candidate.Remove(); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Unable to remove %s: %s\n", candidate.Description, err)
+	}
+
+	return nil
+}
+
+func isLocalDockerDaemonHost(daemonHost string) bool {
+	return strings.HasPrefix(daemonHost, "unix://") || strings.HasPrefix(daemonHost, "npipe://")
+}
+
+// localStagesLRUCleanupCandidates returns the tagged stages which are not used by containers,
+// the last usage of the stage is the time when the stage has been built, tagged or used as the cache last time
+func localStagesLRUCleanupCandidates(ctx context.Context, v *volume, options CommonOptions) ([]*lruCleanupCandidate, error) {
+	filterSet := filters.NewArgs()
+	filterSet.Add("label", image.WerfLabel)
+	filterSet.Add("label", image.WerfStageDigestLabel)
+	filterSet.Add("dangling", "false")
+
+	images, err := werfImagesByFilterSet(ctx, filterSet)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get local stages: %s", err)
+	}
+
+	accessRecords, err := image.CommonStagesAccessRecords.GetRecords()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get local stages access records: %s", err)
+	}
+
+	if !options.DryRun {
+		if err := removeStaleStagesAccessRecords(images, accessRecords); err != nil {
+			return nil, err
+		}
+	}
+
+	images, err = processUsedImages(ctx, images, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*lruCleanupCandidate
+	for _, img := range images {
+		img := img
+
+		inspect, err := docker.ImageInspect(ctx, img.ID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to inspect image %s: %s", logImageName(img), err)
+		}
+
+		lastUsed := stageLastUsed(img.Created, inspect.Metadata.LastTagTime, accessRecords[img.ID])
+
+		var lockNames []string
+		for _, repoTag := range img.RepoTags {
+			lockNames = append(lockNames, container_runtime.ImageLockName(repoTag))
+		}
+
+		candidates = append(candidates, &lruCleanupCandidate{
+			Description: fmt.Sprintf("local stage %s", logImageName(img)),
+			Volume:      v,
+			LastUsed:    lastUsed,
+			Size:        uint64(img.Size),
+			LockNames:   lockNames,
+			Remove: func() error {
+				if err := imagesRemove(ctx, []types.ImageSummary{img}, options); err != nil {
+					return err
+				}
+
+				return image.CommonStagesAccessRecords.RemoveRecord(img.ID)
+			},
+		})
+	}
+
+	return candidates, nil
+}
+
+func stageLastUsed(created int64, lastTagTime time.Time, accessRecord *image.StageAccessRecord) time.Time {
+	lastUsed := time.Unix(created, 0)
+	if lastTagTime.After(lastUsed) {
+		lastUsed = lastTagTime
+	}
+
+	if accessRecord != nil {
+		if accessTime := time.Unix(accessRecord.AccessTimestamp, 0); accessTime.After(lastUsed) {
+			lastUsed = accessTime
+		}
+	}
+
+	return lastUsed
+}
+
+// removeStaleStagesAccessRecords removes the access records of the stages which have been removed not by the lru cleanup
+func removeStaleStagesAccessRecords(images []types.ImageSummary, accessRecords map[string]*image.StageAccessRecord) error {
+	imageIDs := map[string]bool{}
+	for _, img := range images {
+		imageIDs[img.ID] = true
+	}
+
+	for imageID := range accessRecords {
+		if imageIDs[imageID] {
+			continue
+		}
+
+		if err := image.CommonStagesAccessRecords.RemoveRecord(imageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// localCacheLRUCleanupCandidates returns the git archives, the git patches and the manifest cache records,
+// the last usage of the git data is the modification time of the file, which is updated on each access
+func localCacheLRUCleanupCandidates(ctx context.Context, v *volume) ([]*lruCleanupCandidate, error) {
+	var candidates []*lruCleanupCandidate
+
+	if archivesCandidates, err := gitDataLRUCleanupCandidates(v, "git archive", git_repo.CommonGitDataManager.ArchivesCacheDir, git_repo.ArchiveFileExt, git_repo.ArchiveLockName); err != nil {
+		return nil, err
+	} else {
+		candidates = append(candidates, archivesCandidates...)
+	}
+
+	if patchesCandidates, err := gitDataLRUCleanupCandidates(v, "git patch", git_repo.CommonGitDataManager.PatchesCacheDir, git_repo.PatchFileExt, git_repo.PatchLockName); err != nil {
+		return nil, err
+	} else {
+		candidates = append(candidates, patchesCandidates...)
+	}
+
+	if manifestCacheCandidates, err := manifestCacheLRUCleanupCandidates(ctx, v, image.CommonManifestCache.CacheDir); err != nil {
+		return nil, err
+	} else {
+		candidates = append(candidates, manifestCacheCandidates...)
+	}
+
+	return candidates, nil
+}
+
+func gitDataLRUCleanupCandidates(v *volume, kind, cacheDir, fileExt string, lockNameFunc func(id string) string) ([]*lruCleanupCandidate, error) {
+	files, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %s", cacheDir, err)
+	}
+
+	var candidates []*lruCleanupCandidate
+	for _, info := range files {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), fileExt) {
+			continue
+		}
+
+		id := strings.TrimSuffix(info.Name(), fileExt)
+		// the metadata is removed first, thus the data file without metadata is not considered as cached by other processes
+		paths := []string{
+			filepath.Join(cacheDir, id+git_repo.CacheMetadataFileExt),
+			filepath.Join(cacheDir, info.Name()),
+		}
+
+		candidates = append(candidates, &lruCleanupCandidate{
+			Description: fmt.Sprintf("%s %s", kind, id),
+			Volume:      v,
+			LastUsed:    info.ModTime(),
+			Size:        uint64(info.Size()),
+			LockNames:   []string{lockNameFunc(id)},
+			Remove: func() error {
+				return removeFiles(paths)
+			},
+		})
+	}
+
+	return candidates, nil
+}
+
+func manifestCacheLRUCleanupCandidates(ctx context.Context, v *volume, cacheDir string) ([]*lruCleanupCandidate, error) {
+	storageDirs, err := ioutil.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %s", cacheDir, err)
+	}
+
+	var candidates []*lruCleanupCandidate
+	for _, storageDir := range storageDirs {
+		if !storageDir.IsDir() {
+			continue
+		}
+
+		storageDirPath := filepath.Join(cacheDir, storageDir.Name())
+		files, err := ioutil.ReadDir(storageDirPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list %s: %s", storageDirPath, err)
+		}
+
+		for _, info := range files {
+			path := filepath.Join(storageDirPath, info.Name())
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %s", path, err)
+			}
+
+			record := &image.ManifestCacheRecord{}
+			if err := json.Unmarshal(data, record); err != nil || record.Info == nil {
+				logboek.Context(ctx).Debug().LogF("Ignore invalid manifests cache record %s\n", path)
+				continue
+			}
+
+			candidates = append(candidates, &lruCleanupCandidate{
+				Description: fmt.Sprintf("manifest cache record %s", record.Info.Name),
+				Volume:      v,
+				LastUsed:    time.Unix(record.AccessTimestamp, 0),
+				Size:        uint64(info.Size()),
+				LockNames:   []string{image.ManifestCacheLockName(storageDir.Name(), record.Info.Name)},
+				Remove: func() error {
+					return removeFiles([]string{path})
+				},
+			})
+		}
+	}
+
+	return candidates, nil
+}
+
+func removeFiles(paths []string) error {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package host_cleaning
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestLRUCleanupVolumes(t *testing.T) {
+	type testCandidate struct {
+		name     string
+		volume   string
+		lastUsed int64
+		size     uint64
+	}
+
+	tests := []struct {
+		name                string
+		dryRun              bool
+		usedBytes           map[string]uint64
+		candidates          []testCandidate
+		expectedRemoved     []string
+		expectedUsedBytes   map[string]uint64
+		expectedFreedBytes  map[string]uint64
+		allowedUsagePercent float64
+	}{
+		{
+			name:                "least recently used candidates are removed until the usage is allowed",
+			allowedUsagePercent: 50,
+			usedBytes:           map[string]uint64{"docker": 80},
+			candidates: []testCandidate{
+				{name: "hot", volume: "docker", lastUsed: 30, size: 20},
+				{name: "cold", volume: "docker", lastUsed: 10, size: 20},
+				{name: "warm", volume: "docker", lastUsed: 20, size: 20},
+			},
+			expectedRemoved:   []string{"cold", "warm"},
+			expectedUsedBytes: map[string]uint64{"docker": 40},
+		},
+		{
+			name:                "candidates of the volume with allowed usage are kept",
+			allowedUsagePercent: 50,
+			usedBytes:           map[string]uint64{"docker": 60, "cache": 40},
+			candidates: []testCandidate{
+				{name: "cache-cold", volume: "cache", lastUsed: 10, size: 10},
+				{name: "docker-warm", volume: "docker", lastUsed: 20, size: 10},
+			},
+			expectedRemoved:   []string{"docker-warm"},
+			expectedUsedBytes: map[string]uint64{"docker": 50, "cache": 40},
+		},
+		{
+			name:                "candidates of the volumes are removed in the order of the last usage",
+			allowedUsagePercent: 50,
+			usedBytes:           map[string]uint64{"docker": 70, "cache": 60},
+			candidates: []testCandidate{
+				{name: "docker-hot", volume: "docker", lastUsed: 40, size: 10},
+				{name: "cache-warm", volume: "cache", lastUsed: 20, size: 10},
+				{name: "docker-cold", volume: "docker", lastUsed: 10, size: 10},
+				{name: "docker-warm", volume: "docker", lastUsed: 30, size: 10},
+				{name: "cache-hot", volume: "cache", lastUsed: 50, size: 10},
+			},
+			expectedRemoved:   []string{"docker-cold", "cache-warm", "docker-warm"},
+			expectedUsedBytes: map[string]uint64{"docker": 50, "cache": 50},
+		},
+		{
+			name:                "dry run counts freed bytes",
+			dryRun:              true,
+			allowedUsagePercent: 50,
+			usedBytes:           map[string]uint64{"docker": 80},
+			candidates: []testCandidate{
+				{name: "hot", volume: "docker", lastUsed: 30, size: 20},
+				{name: "cold", volume: "docker", lastUsed: 10, size: 20},
+				{name: "warm", volume: "docker", lastUsed: 20, size: 20},
+			},
+			expectedUsedBytes:  map[string]uint64{"docker": 80},
+			expectedFreedBytes: map[string]uint64{"docker": 40},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var removed []string
+			usedBytes := map[string]uint64{}
+			for name, used := range tt.usedBytes {
+				usedBytes[name] = used
+			}
+
+			var volumes []*volume
+			for _, name := range []string{"docker", "cache"} {
+				if _, ok := usedBytes[name]; !ok {
+					continue
+				}
+
+				volumes = append(volumes, &volume{
+					Name: name,
+					Path: name,
+					getCandidates: func(v *volume) ([]*lruCleanupCandidate, error) {
+						var candidates []*lruCleanupCandidate
+						for _, c := range tt.candidates {
+							if c.volume != v.Name {
+								continue
+							}
+
+							c := c
+							candidates = append(candidates, &lruCleanupCandidate{
+								Description: c.name,
+								Volume:      v,
+								LastUsed:    time.Unix(c.lastUsed, 0),
+								Size:        c.size,
+								Remove: func() error {
+									removed = append(removed, c.name)
+									usedBytes[c.volume] -= c.size
+									return nil
+								},
+							})
+						}
+
+						return candidates, nil
+					},
+					getUsage: func(path string) (uint64, uint64, error) {
+						return usedBytes[path], 100, nil
+					},
+				})
+			}
+
+			if err := lruCleanupVolumes(context.Background(), volumes, tt.allowedUsagePercent, CommonOptions{DryRun: tt.dryRun}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(removed, tt.expectedRemoved) {
+				t.Errorf("expected removed %v, got %v", tt.expectedRemoved, removed)
+			}
+
+			if !reflect.DeepEqual(usedBytes, tt.expectedUsedBytes) {
+				t.Errorf("expected used bytes %v, got %v", tt.expectedUsedBytes, usedBytes)
+			}
+
+			for _, v := range volumes {
+				if v.freedBytes != tt.expectedFreedBytes[v.Name] {
+					t.Errorf("expected %s freed bytes %d, got %d", v.Name, tt.expectedFreedBytes[v.Name], v.freedBytes)
+				}
+			}
+		})
+	}
+}
+
+func TestStageLastUsed(t *testing.T) {
+	tests := []struct {
+		name         string
+		created      int64
+		lastTagTime  time.Time
+		accessRecord *image.StageAccessRecord
+		expected     int64
+	}{
+		{
+			name:     "created",
+			created:  10,
+			expected: 10,
+		},
+		{
+			name:        "tagged after creation",
+			created:     10,
+			lastTagTime: time.Unix(20, 0),
+			expected:    20,
+		},
+		{
+			name:         "used as the cache after tagging",
+			created:      10,
+			lastTagTime:  time.Unix(20, 0),
+			accessRecord: &image.StageAccessRecord{AccessTimestamp: 30},
+			expected:     30,
+		},
+		{
+			name:         "tagged after the last usage",
+			created:      10,
+			lastTagTime:  time.Unix(40, 0),
+			accessRecord: &image.StageAccessRecord{AccessTimestamp: 30},
+			expected:     40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if lastUsed := stageLastUsed(tt.created, tt.lastTagTime, tt.accessRecord); lastUsed.Unix() != tt.expected {
+				t.Errorf("expected last used %d, got %d", tt.expected, lastUsed.Unix())
+			}
+		})
+	}
+}
+
+func TestIsLocalDockerDaemonHost(t *testing.T) {
+	tests := []struct {
+		daemonHost string
+		expected   bool
+	}{
+		{daemonHost: "unix:///var/run/docker.sock", expected: true},
+		{daemonHost: "npipe:////./pipe/docker_engine", expected: true},
+		{daemonHost: "tcp://docker:2375", expected: false},
+		{daemonHost: "ssh://user@host", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.daemonHost, func(t *testing.T) {
+			if isLocal := isLocalDockerDaemonHost(tt.daemonHost); isLocal != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, isLocal)
+			}
+		})
+	}
+}
+
+func TestRemoveStaleStagesAccessRecords(t *testing.T) {
+	recordsDir, err := ioutil.TempDir("", "werf-stages-access-records-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(recordsDir)
+
+	oldStagesAccessRecords := image.CommonStagesAccessRecords
+	image.CommonStagesAccessRecords = image.NewStagesAccessRecords(recordsDir)
+	defer func() { image.CommonStagesAccessRecords = oldStagesAccessRecords }()
+
+	for _, imageID := range []string{"sha256:existing", "sha256:removed"} {
+		if err := image.CommonStagesAccessRecords.TouchStage(imageID, "project:"+imageID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := image.CommonStagesAccessRecords.GetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeStaleStagesAccessRecords([]types.ImageSummary{{ID: "sha256:existing"}}, records); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err = image.CommonStagesAccessRecords.GetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records["sha256:existing"] == nil || records["sha256:existing"].ImageName != "project:sha256:existing" {
+		t.Errorf("expected only the record of the existing stage, got %v", records)
+	}
+}
@@ -0,0 +1,22 @@
+// +build linux darwin
+
+package host_cleaning
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// getVolumeUsage returns the used and the total bytes of the filesystem containing the path the same way as df does,
+// the space reserved for the root user is not taken into account
+func getVolumeUsage(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, fmt.Errorf("unable to get filesystem stats of %s: %s", path, err)
+	}
+
+	used := (stat.Blocks - stat.Bfree) * uint64(stat.Bsize)
+	available := stat.Bavail * uint64(stat.Bsize)
+
+	return used, used + available, nil
+}
@@ -0,0 +1,31 @@
+// +build windows
+
+package host_cleaning
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceExProc = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// getVolumeUsage returns the used and the total bytes of the volume containing the path
+func getVolumeUsage(path string) (uint64, uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad path %s: %s", path, err)
+	}
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	if res, _, err := getDiskFreeSpaceExProc.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	); res == 0 {
+		return 0, 0, fmt.Errorf("unable to get volume stats of %s: %s", path, err)
+	}
+
+	return totalBytes - totalFreeBytes, totalBytes, nil
+}
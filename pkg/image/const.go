@@ -16,6 +16,10 @@ const (
 	WerfImportMetadataSourceImageIDLabel  = "source-image-id"
 	WerfImportMetadataImportSourceIDLabel = "import-source-id"
 
+	WerfImageIndexStageIDAnnotation = "werf-stage-id"
+
+	WerfMetadataLegacyTagsRemovedLabel = "werf-metadata-legacy-tags-removed"
+
 	WerfMountTmpDirLabel          = "werf-mount-type-tmp-dir"
 	WerfMountBuildDirLabel        = "werf-mount-type-build-dir"
 	WerfMountCustomDirLabelPrefix = "werf-mount-type-custom-dir-"
@@ -15,6 +15,7 @@ type Info struct {
 	Repository string `json:"repository"`
 	Tag        string `json:"tag"`
 	RepoDigest string `json:"repoDigest"`
+	Platform   string `json:"platform"`
 
 	ID                string            `json:"ID"`
 	ParentID          string            `json:"parentID"`
@@ -54,9 +55,23 @@ func NewInfoFromInspect(ref string, inspect *types.ImageInspect) *Info {
 		ID:                inspect.ID,
 		ParentID:          inspect.Config.Image,
 		Size:              inspect.Size,
+		Platform:          PlatformName(inspect.Os, inspect.Architecture, inspect.Variant),
 	}
 }
 
+// PlatformName returns the platform in the OS/ARCH[/VARIANT] format.
+func PlatformName(os, architecture, variant string) string {
+	if os == "" || architecture == "" {
+		return ""
+	}
+
+	if variant == "" {
+		return fmt.Sprintf("%s/%s", os, architecture)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", os, architecture, variant)
+}
+
 func MustParseTimestampString(timestampString string) time.Time {
 	t, err := time.Parse(time.RFC3339, timestampString)
 	if err != nil {
@@ -6,9 +6,13 @@ import (
 	"github.com/werf/werf/pkg/werf"
 )
 
-var CommonManifestCache *ManifestCache
+var (
+	CommonManifestCache       *ManifestCache
+	CommonStagesAccessRecords *StagesAccessRecords
+)
 
 func Init() error {
 	CommonManifestCache = NewManifestCache(filepath.Join(werf.GetLocalCacheDir(), "manifests", ManifestCacheVersion))
+	CommonStagesAccessRecords = NewStagesAccessRecords(filepath.Join(werf.GetLocalCacheDir(), "stages_access", StagesAccessRecordsVersion))
 	return nil
 }
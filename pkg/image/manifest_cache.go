@@ -122,7 +122,7 @@ func (cache *ManifestCache) constructFilePathForImage(storageName, imageName str
 }
 
 func (cache *ManifestCache) lock(ctx context.Context, storageName, imageName string) (lockgate.LockHandle, error) {
-	lockName := fmt.Sprintf("manifest_cache.%s.%s", slug.Slug(storageName), imageName)
+	lockName := ManifestCacheLockName(slug.Slug(storageName), imageName)
 	if _, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{}); err != nil {
 		return lockgate.LockHandle{}, fmt.Errorf("cannot acquire %s host lock: %s", lockName, err)
 	} else {
@@ -130,6 +130,11 @@ func (cache *ManifestCache) lock(ctx context.Context, storageName, imageName str
 	}
 }
 
+// ManifestCacheLockName returns the host lock name of the image record, storageDirName is the slugified storage name
+func ManifestCacheLockName(storageDirName, imageName string) string {
+	return fmt.Sprintf("manifest_cache.%s.%s", storageDirName, imageName)
+}
+
 func (cache *ManifestCache) unlock(lock lockgate.LockHandle) error {
 	if err := werf.ReleaseHostLock(lock); err != nil {
 		return fmt.Errorf("cannot release %s host lock: %s", lock.LockName, err)
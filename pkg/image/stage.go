@@ -24,6 +24,12 @@ type StageDescription struct {
 	Info    *Info    `json:"info"`
 }
 
+// ImageIndexDescription is the multi-platform image index and the IDs of the platform stages referenced by the index
+type ImageIndexDescription struct {
+	StageIDs []string `json:"stageIDs"`
+	Info     *Info    `json:"info"`
+}
+
 func ParseUniqueIDAsTimestamp(uniqueID string) (int64, error) {
 	if timestamp, err := strconv.ParseInt(uniqueID, 10, 64); err != nil {
 		return 0, err
@@ -0,0 +1,115 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+const (
+	StagesAccessRecordsVersion = "1"
+)
+
+// StagesAccessRecords keeps the last time the local stages have been used as the cache,
+// the host cleanup removes the least recently used stages first
+type StagesAccessRecords struct {
+	RecordsDir string
+}
+
+type StageAccessRecord struct {
+	AccessTimestamp int64
+	ImageID         string
+	ImageName       string
+}
+
+func NewStagesAccessRecords(recordsDir string) *StagesAccessRecords {
+	return &StagesAccessRecords{RecordsDir: recordsDir}
+}
+
+// TouchStage updates the access time of the stage image, the record is replaced atomically thus no lock is required
+func (records *StagesAccessRecords) TouchStage(imageID, imageName string) error {
+	if err := os.MkdirAll(records.RecordsDir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating dir %s: %s", records.RecordsDir, err)
+	}
+
+	dataBytes, err := json.Marshal(&StageAccessRecord{AccessTimestamp: time.Now().Unix(), ImageID: imageID, ImageName: imageName})
+	if err != nil {
+		return fmt.Errorf("error marshalling json: %s", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(records.RecordsDir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file in %s: %s", records.RecordsDir, err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(append(dataBytes, []byte("\n")...)); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing %s: %s", tmpFile.Name(), err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("error closing %s: %s", tmpFile.Name(), err)
+	}
+
+	filePath := records.constructFilePath(imageID)
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %s", tmpFile.Name(), filePath, err)
+	}
+
+	return nil
+}
+
+// GetRecords returns the access records by the image ID, the invalid records are ignored
+func (records *StagesAccessRecords) GetRecords() (map[string]*StageAccessRecord, error) {
+	files, err := ioutil.ReadDir(records.RecordsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %s", records.RecordsDir, err)
+	}
+
+	res := map[string]*StageAccessRecord{}
+	for _, info := range files {
+		// the temporary files of the records being written are skipped
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+
+		filePath := filepath.Join(records.RecordsDir, info.Name())
+		dataBytes, err := ioutil.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", filePath, err)
+		}
+
+		record := &StageAccessRecord{}
+		if err := json.Unmarshal(dataBytes, record); err != nil || record.ImageID == "" {
+			continue
+		}
+
+		res[record.ImageID] = record
+	}
+
+	return res, nil
+}
+
+func (records *StagesAccessRecords) RemoveRecord(imageID string) error {
+	filePath := records.constructFilePath(imageID)
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove %s: %s", filePath, err)
+	}
+
+	return nil
+}
+
+func (records *StagesAccessRecords) constructFilePath(imageID string) string {
+	return filepath.Join(records.RecordsDir, util.Sha256Hash(imageID))
+}
@@ -34,6 +34,14 @@ func getContainer() container {
 	}
 }
 
+func ContainerName() string {
+	return getContainer().Name
+}
+
+func ContainerVolume() string {
+	return getContainer().Volume
+}
+
 func GetOrCreateContainer(ctx context.Context) (string, error) {
 	container := getContainer()
 
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	CleanupAuditRecordLayerMediaType types.MediaType = "application/vnd.werf.cleanup-audit-record.v1+json"
+	CleanupAuditRecordFileName                       = "cleanup-audit-record.json"
+)
+
+// CleanupAuditRecord describes the objects deleted from the stages storage by a single werf command run
+type CleanupAuditRecord struct {
+	ID          string
+	Command     string
+	User        string
+	Host        string
+	WerfVersion string
+	CreatedAt   time.Time
+	Items       []*CleanupAuditRecordItem
+}
+
+type CleanupAuditRecordItem struct {
+	Kind    string
+	Object  string
+	Reason  string `json:",omitempty"`
+	Details string `json:",omitempty"`
+}
+
+func NewCleanupAuditRecord(command string) *CleanupAuditRecord {
+	createdAt := time.Now().UTC()
+
+	rec := &CleanupAuditRecord{
+		ID:          fmt.Sprintf("%d-%s", createdAt.UnixNano()/int64(time.Millisecond), util.GenerateConsistentRandomString(6)),
+		Command:     command,
+		WerfVersion: werf.Version,
+		CreatedAt:   createdAt,
+	}
+
+	if usr, err := user.Current(); err == nil {
+		rec.User = usr.Username
+	} else {
+		rec.User = os.Getenv("USER")
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		rec.Host = host
+	}
+
+	return rec
+}
+
+// GetCleanupAuditRecordIDTime parses the creation time encoded into the record ID,
+// thus the records can be filtered without fetching
+func GetCleanupAuditRecordIDTime(id string) (time.Time, error) {
+	parts := strings.SplitN(id, "-", 2)
+
+	timestampMillisec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected cleanup audit record id %q: %s", id, err)
+	}
+
+	return time.Unix(0, timestampMillisec*int64(time.Millisecond)).UTC(), nil
+}
+
+func (rec *CleanupAuditRecord) AddItem(item *CleanupAuditRecordItem) {
+	rec.Items = append(rec.Items, item)
+}
+
+// ToArtifact returns the image with the record JSON as the single blob layer, the record is not limited by the size of the image config
+func (rec *CleanupAuditRecord) ToArtifact() (v1.Image, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal cleanup audit record: %s", err)
+	}
+
+	layer, err := container_registry_extensions.NewBlobLayer(data, CleanupAuditRecordLayerMediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{Layer: layer, MediaType: CleanupAuditRecordLayerMediaType})
+	if err != nil {
+		return nil, err
+	}
+
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{image.WerfLabel: "cleanup-audit"}})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutate.MediaType(img, types.OCIManifestSchema1), nil
+}
+
+// newCleanupAuditRecordFromArtifact reads the record blob of the artifact manifest by getBlob
+func newCleanupAuditRecordFromArtifact(manifest *v1.Manifest, getBlob func(digest v1.Hash) (io.ReadCloser, error)) (*CleanupAuditRecord, error) {
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != CleanupAuditRecordLayerMediaType {
+			continue
+		}
+
+		rc, err := getBlob(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read cleanup audit record layer: %s", err)
+		}
+		defer rc.Close()
+
+		return newCleanupAuditRecordFromReader(rc)
+	}
+
+	return nil, fmt.Errorf("layer %q not found", CleanupAuditRecordLayerMediaType)
+}
+
+func newCleanupAuditRecordFromArtifactImage(img v1.Image) (*CleanupAuditRecord, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest: %s", err)
+	}
+
+	return newCleanupAuditRecordFromArtifact(manifest, func(digest v1.Hash) (io.ReadCloser, error) {
+		layer, err := img.LayerByDigest(digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return layer.Compressed()
+	})
+}
+
+// ToArchive returns the filesystem archive with the record JSON file, the archive is the layer of the local docker image
+func (rec *CleanupAuditRecord) ToArchive() (io.Reader, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal cleanup audit record: %s", err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: CleanupAuditRecordFileName, Mode: 0644, Size: int64(len(data)), ModTime: rec.CreatedAt}); err != nil {
+		return nil, fmt.Errorf("unable to write archive header: %s", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, fmt.Errorf("unable to write archive: %s", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write archive: %s", err)
+	}
+
+	return buf, nil
+}
+
+// newCleanupAuditRecordFromImageLayers reads the record JSON file from the filesystem layers of the local docker image
+func newCleanupAuditRecordFromImageLayers(img v1.Image) (*CleanupAuditRecord, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get layers: %s", err)
+	}
+
+	for _, layer := range layers {
+		rec, err := func() (*CleanupAuditRecord, error) {
+			rc, err := layer.Uncompressed()
+			if err != nil {
+				return nil, fmt.Errorf("unable to read layer: %s", err)
+			}
+			defer rc.Close()
+
+			tr := tar.NewReader(rc)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					return nil, nil
+				} else if err != nil {
+					return nil, fmt.Errorf("unable to read layer: %s", err)
+				}
+
+				if path.Clean(hdr.Name) == CleanupAuditRecordFileName {
+					return newCleanupAuditRecordFromReader(tr)
+				}
+			}
+		}()
+		if err != nil || rec != nil {
+			return rec, err
+		}
+	}
+
+	return nil, fmt.Errorf("file %q not found", CleanupAuditRecordFileName)
+}
+
+func newCleanupAuditRecordFromReader(r io.Reader) (*CleanupAuditRecord, error) {
+	rec := &CleanupAuditRecord{}
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, fmt.Errorf("unable to parse cleanup audit record: %s", err)
+	}
+
+	return rec, nil
+}
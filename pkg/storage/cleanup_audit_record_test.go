@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+// newLargeCleanupAuditRecord returns the record which does not fit into the image label of the registries
+func newLargeCleanupAuditRecord() *CleanupAuditRecord {
+	rec := NewCleanupAuditRecord("werf cleanup")
+	for i := 0; i < 20000; i++ {
+		rec.AddItem(&CleanupAuditRecordItem{
+			Kind:    "stage",
+			Object:  fmt.Sprintf("stage digest-%d", i),
+			Reason:  "not-used",
+			Details: "the stage is not used in Kubernetes and is not related to the kept image metadata",
+		})
+	}
+
+	return rec
+}
+
+func TestCleanupAuditRecordFormats(t *testing.T) {
+	tests := []struct {
+		name      string
+		roundTrip func(rec *CleanupAuditRecord) (*CleanupAuditRecord, error)
+	}{
+		{
+			name: "artifact of the repo and the oci layout",
+			roundTrip: func(rec *CleanupAuditRecord) (*CleanupAuditRecord, error) {
+				img, err := rec.ToArtifact()
+				if err != nil {
+					return nil, err
+				}
+
+				return newCleanupAuditRecordFromArtifactImage(img)
+			},
+		},
+		{
+			name: "filesystem layer of the local docker image",
+			roundTrip: func(rec *CleanupAuditRecord) (*CleanupAuditRecord, error) {
+				archive, err := rec.ToArchive()
+				if err != nil {
+					return nil, err
+				}
+
+				data, err := ioutil.ReadAll(archive)
+				if err != nil {
+					return nil, err
+				}
+
+				layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(data)), nil
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				img, err := mutate.AppendLayers(empty.Image, layer)
+				if err != nil {
+					return nil, err
+				}
+
+				return newCleanupAuditRecordFromImageLayers(img)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newLargeCleanupAuditRecord()
+
+			res, err := tt.roundTrip(rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.ID != rec.ID || res.Command != rec.Command || !res.CreatedAt.Equal(rec.CreatedAt) || !reflect.DeepEqual(res.Items, rec.Items) {
+				t.Errorf("unexpected cleanup audit record %s with %d items", res.ID, len(res.Items))
+			}
+		})
+	}
+}
+
+func TestCleanupAuditRecordWithoutRecordLayer(t *testing.T) {
+	if _, err := newCleanupAuditRecordFromArtifactImage(empty.Image); err == nil {
+		t.Error("expected error of the image without the record layer")
+	}
+
+	if _, err := newCleanupAuditRecordFromImageLayers(empty.Image); err == nil {
+		t.Error("expected error of the image without the record file")
+	}
+}
+
+func TestRepoStagesStorageCleanupAuditRecord(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	if err := docker_registry.Init(ctx, true, false, docker_registry.ThrottlingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repoAddress := strings.TrimPrefix(server.URL, "http://") + "/project"
+	storage, err := NewRepoStagesStorage(repoAddress, nil, RepoStagesStorageOptions{
+		DockerRegistryOptions: docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+		Implementation:        docker_registry.DefaultImplementationName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err := storage.GetCleanupAuditRecord(ctx, "project", "1611573400000-absent"); err != nil {
+		t.Fatal(err)
+	} else if rec != nil {
+		t.Fatalf("expected no record, got %#v", rec)
+	}
+
+	rec := newLargeCleanupAuditRecord()
+	if err := storage.PutCleanupAuditRecord(ctx, "project", rec); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := storage.GetCleanupAuditRecord(ctx, "project", rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if res == nil || res.ID != rec.ID || !reflect.DeepEqual(res.Items, rec.Items) {
+		t.Fatalf("unexpected cleanup audit record %#v", res)
+	}
+
+	configFile, err := docker_registry.API().GetRepoImageConfigFile(ctx, fmt.Sprintf(RepoCleanupAuditRecord_ImageNameFormat, repoAddress, rec.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configSize := len(fmt.Sprint(configFile.Config.Labels)); configSize > 1024 {
+		t.Errorf("expected the record not to be stored in the image config, got labels of %d bytes", configSize)
+	}
+
+	// the registry does not support the manifests deletion, thus the deletion is checked by the oci layout storage test
+}
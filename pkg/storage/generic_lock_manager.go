@@ -6,6 +6,7 @@ import (
 
 	"github.com/werf/lockgate"
 	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
 
@@ -28,6 +29,11 @@ func (manager *GenericLockManager) LockStageCache(ctx context.Context, projectNa
 	return LockHandle{LockgateHandle: lock, ProjectName: projectName}, err
 }
 
+func (manager *GenericLockManager) LockMetadata(ctx context.Context, projectName, repoAddress string) (LockHandle, error) {
+	_, lock, err := manager.Locker.Acquire(genericMetadataLockName(projectName, repoAddress), werf.SetupLockerDefaultOptions(ctx, lockgate.AcquireOptions{}))
+	return LockHandle{LockgateHandle: lock, ProjectName: projectName}, err
+}
+
 func (manager *GenericLockManager) Unlock(ctx context.Context, lock LockHandle) error {
 	err := manager.Locker.Release(lock.LockgateHandle)
 	if err != nil {
@@ -43,3 +49,7 @@ func genericStageLockName(projectName, digest string) string {
 func genericStageCacheLockName(projectName, digest string) string {
 	return fmt.Sprintf("%s.%s.cache", projectName, digest)
 }
+
+func genericMetadataLockName(projectName, repoAddress string) string {
+	return fmt.Sprintf("%s.metadata.%s", projectName, util.Sha256Hash(repoAddress))
+}
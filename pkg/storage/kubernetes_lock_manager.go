@@ -10,6 +10,7 @@ import (
 
 	"github.com/werf/lockgate/pkg/distributed_locker"
 	"github.com/werf/werf/pkg/kubeutils"
+	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf/locker_with_retry"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -84,6 +85,15 @@ func (manager *KuberntesLockManager) LockStageCache(ctx context.Context, project
 	}
 }
 
+func (manager *KuberntesLockManager) LockMetadata(ctx context.Context, projectName, repoAddress string) (LockHandle, error) {
+	if locker, err := manager.getLockerForProject(ctx, projectName); err != nil {
+		return LockHandle{}, err
+	} else {
+		_, lock, err := locker.Acquire(kubernetesMetadataLockName(projectName, repoAddress), werf.SetupLockerDefaultOptions(ctx, lockgate.AcquireOptions{}))
+		return LockHandle{LockgateHandle: lock, ProjectName: projectName}, err
+	}
+}
+
 func (manager *KuberntesLockManager) Unlock(ctx context.Context, lock LockHandle) error {
 	if locker, err := manager.getLockerForProject(ctx, lock.ProjectName); err != nil {
 		return err
@@ -103,3 +113,7 @@ func kubernetesStageLockName(projectName, digest string) string {
 func kubernetesStageCacheLockName(projectName, digest string) string {
 	return fmt.Sprintf("%s/stage-cache/%s", projectName, digest)
 }
+
+func kubernetesMetadataLockName(projectName, repoAddress string) string {
+	return fmt.Sprintf("%s/metadata/%s", projectName, util.Sha256Hash(repoAddress))
+}
@@ -1,13 +1,17 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 
 	"github.com/werf/logboek"
 
@@ -32,6 +36,9 @@ const (
 
 	LocalClientIDRecord_ImageNameFormat = "werf-client-id/%s"
 	LocalClientIDRecord_ImageFormat     = "werf-client-id/%s:%s-%d"
+
+	LocalCleanupAuditRecord_ImageNameFormat = "werf-cleanup-audit/%s"
+	LocalCleanupAuditRecord_ImageFormat     = "werf-cleanup-audit/%s:%s"
 )
 
 const ImageDeletionFailedDueToUsedByContainerErrorTip = "Use --force option to remove all containers that are based on deleting werf docker images"
@@ -208,8 +215,12 @@ func (storage *LocalDockerServerStagesStorage) FetchImage(_ context.Context, _ c
 	return nil
 }
 
-func (storage *LocalDockerServerStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
-	return storage.LocalDockerServerRuntime.TagImageByName(ctx, img)
+func (storage *LocalDockerServerStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image, _ StoreImageOptions) (*StoreImageReport, error) {
+	if err := storage.LocalDockerServerRuntime.TagImageByName(ctx, img); err != nil {
+		return nil, err
+	}
+
+	return &StoreImageReport{}, nil
 }
 
 func (storage *LocalDockerServerStagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
@@ -464,6 +475,110 @@ func (storage *LocalDockerServerStagesStorage) PostClientIDRecord(ctx context.Co
 	return nil
 }
 
+func (storage *LocalDockerServerStagesStorage) GetForeignTags(_ context.Context, _ string) ([]string, error) {
+	return nil, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) GetForeignTagInfo(_ context.Context, _, tag string) (*image.Info, error) {
+	return nil, fmt.Errorf("foreign tag %q is not supported by %s stages storage", tag, storage.String())
+}
+
+func (storage *LocalDockerServerStagesStorage) DeleteForeignTag(_ context.Context, _ string, info *image.Info) error {
+	return fmt.Errorf("foreign tag %q is not supported by %s stages storage", info.Name, storage.String())
+}
+
+func (storage *LocalDockerServerStagesStorage) GetCleanupAuditRecordIDs(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetCleanupAuditRecordIDs %s\n", projectName)
+
+	filterSet := filters.NewArgs()
+	filterSet.Add("reference", fmt.Sprintf(LocalCleanupAuditRecord_ImageNameFormat, projectName))
+
+	images, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get docker images: %s", err)
+	}
+
+	var ids []string
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			_, tag := image.ParseRepositoryAndTag(repoTag)
+			ids = append(ids, tag)
+		}
+	}
+
+	return ids, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) GetCleanupAuditRecord(ctx context.Context, projectName, id string) (*CleanupAuditRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.GetCleanupAuditRecord %s %s\n", projectName, id)
+
+	fullImageName := fmt.Sprintf(LocalCleanupAuditRecord_ImageFormat, projectName, id)
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return nil, fmt.Errorf("unable to check existence of image %s: %s", fullImageName, err)
+	} else if !exists {
+		return nil, nil
+	}
+
+	rc, err := docker.ImageSave(ctx, fullImageName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to save image %s: %s", fullImageName, err)
+	}
+	defer rc.Close()
+
+	// the archive of the record image is small, thus it is kept in memory to be read by the tarball image
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to save image %s: %s", fullImageName, err)
+	}
+
+	img, err := tarball.Image(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image %s archive: %s", fullImageName, err)
+	}
+
+	rec, err := newCleanupAuditRecordFromImageLayers(img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %s: %s", fullImageName, err)
+	}
+
+	return rec, nil
+}
+
+func (storage *LocalDockerServerStagesStorage) PutCleanupAuditRecord(ctx context.Context, projectName string, rec *CleanupAuditRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.PutCleanupAuditRecord %s %s\n", projectName, rec.ID)
+
+	archive, err := rec.ToArchive()
+	if err != nil {
+		return err
+	}
+
+	fullImageName := fmt.Sprintf(LocalCleanupAuditRecord_ImageFormat, projectName, rec.ID)
+	if err := docker.ImportImage(ctx, fullImageName, archive, nil); err != nil {
+		return fmt.Errorf("unable to create image %q: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *LocalDockerServerStagesStorage) DeleteCleanupAuditRecord(ctx context.Context, projectName, id string) error {
+	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.DeleteCleanupAuditRecord %s %s\n", projectName, id)
+
+	fullImageName := fmt.Sprintf(LocalCleanupAuditRecord_ImageFormat, projectName, id)
+	if exists, err := docker.ImageExist(ctx, fullImageName); err != nil {
+		return fmt.Errorf("unable to check existence of image %s: %s", fullImageName, err)
+	} else if !exists {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", fullImageName); err != nil {
+		return fmt.Errorf("unable to remove image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
 type processRelatedContainersOptions struct {
 	skipUsedImages           bool
 	rmContainersThatUseImage bool
@@ -9,6 +9,7 @@ import (
 type LockManager interface {
 	LockStage(ctx context.Context, projectName, digest string) (LockHandle, error)
 	LockStageCache(ctx context.Context, projectName, digest string) (LockHandle, error)
+	LockMetadata(ctx context.Context, projectName, repoAddress string) (LockHandle, error)
 	Unlock(ctx context.Context, lockHandle LockHandle) error
 }
 
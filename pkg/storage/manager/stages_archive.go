@@ -0,0 +1,321 @@
+package manager
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type ExportStagesOptions struct {
+	// ImagesNames selects stages of the specified images, all managed images are used when empty
+	ImagesNames []string
+	// Commits selects stages of the images built for the specified commits, all commits are used when empty
+	Commits []string
+}
+
+func (opts ExportStagesOptions) isAllStages() bool {
+	return len(opts.ImagesNames) == 0 && len(opts.Commits) == 0
+}
+
+// ExportStages writes selected stages of the project with the related image metadata, managed images
+// and import metadata into the archive. The archive is an OCI image layout packed into tar.
+func (m *StagesStorageManager) ExportStages(ctx context.Context, archivePath string, containerRuntime container_runtime.ContainerRuntime, opts ExportStagesOptions) error {
+	layoutDir, err := ioutil.TempDir(werf.GetServiceDir(), "stages-export-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(layoutDir)
+
+	layoutStorage, err := newArchiveLayoutStagesStorage(layoutDir, containerRuntime)
+	if err != nil {
+		return err
+	}
+
+	if err := layoutStorage.CreateRepo(ctx); err != nil {
+		return err
+	}
+
+	managedImages, err := m.StagesStorage.GetManagedImages(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get managed images: %s", err)
+	}
+
+	imagesNames := opts.ImagesNames
+	if len(imagesNames) == 0 {
+		imagesNames = managedImages
+	}
+
+	imageMetadataByImageName, _, err := m.StagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, m.ProjectName, imagesNames)
+	if err != nil {
+		return fmt.Errorf("unable to get image metadata: %s", err)
+	}
+	imageMetadataByImageName = filterImageMetadataByCommits(imageMetadataByImageName, opts.Commits)
+
+	stagesDescriptions, err := m.GetStageDescriptionList(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get stages: %s", err)
+	}
+
+	if !opts.isAllStages() {
+		stagesDescriptions = selectImageMetadataStagesWithAncestors(stagesDescriptions, imageMetadataByImageName)
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Exporting %d stages", len(stagesDescriptions)).DoError(func() error {
+		for _, stageDesc := range stagesDescriptions {
+			if _, err := m.CopySuitableByDigestStage(ctx, stageDesc, m.StagesStorage, layoutStorage, containerRuntime); err != nil {
+				return err
+			}
+
+			logboek.Context(ctx).Default().LogFDetails("  stage: %s\n", stageDesc.StageID.String())
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Exporting metadata").DoError(func() error {
+		for imageName, stageIDCommitList := range imageMetadataByImageName {
+			if util.IsStringsContainValue(managedImages, imageName) {
+				if err := layoutStorage.AddManagedImage(ctx, m.ProjectName, imageName); err != nil {
+					return fmt.Errorf("unable to add managed image %q: %s", imageName, err)
+				}
+			}
+
+			for stageID, commits := range stageIDCommitList {
+				for _, commit := range commits {
+					if err := layoutStorage.PutImageMetadata(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to put image %q metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
+					}
+				}
+			}
+		}
+
+		return copyImportMetadata(ctx, m.ProjectName, m.StagesStorage, layoutStorage, stagesDescriptions)
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Writing archive %s", archivePath).DoError(func() error {
+		return createArchiveFromDir(archivePath, layoutDir)
+	})
+}
+
+// ImportStages copies stages with the related metadata from the archive created by ExportStages
+// into the stages storage, existing stages are skipped.
+func (m *StagesStorageManager) ImportStages(ctx context.Context, archivePath string, containerRuntime container_runtime.ContainerRuntime) error {
+	layoutDir, err := ioutil.TempDir(werf.GetServiceDir(), "stages-import-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(layoutDir)
+
+	if err := logboek.Context(ctx).Default().LogProcess("Extracting archive %s", archivePath).DoError(func() error {
+		return util.ExtractArchive(archivePath, layoutDir)
+	}); err != nil {
+		return err
+	}
+
+	layoutStorage, err := newArchiveLayoutStagesStorage(layoutDir, containerRuntime)
+	if err != nil {
+		return err
+	}
+
+	stagesIDs, err := layoutStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get archive stages: %s", err)
+	}
+
+	var stagesDescriptions []*image.StageDescription
+	if err := logboek.Context(ctx).Default().LogProcess("Importing %d stages", len(stagesIDs)).DoError(func() error {
+		for _, stageID := range stagesIDs {
+			if stageDesc, err := m.StagesStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID); err != nil {
+				return fmt.Errorf("unable to get stage %s description: %s", stageID.String(), err)
+			} else if stageDesc != nil {
+				logboek.Context(ctx).Info().LogF("Stage %s already exists in %s\n", stageID.String(), m.StagesStorage.String())
+				continue
+			}
+
+			stageDesc, err := layoutStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID)
+			if err != nil {
+				return fmt.Errorf("unable to get archive stage %s description: %s", stageID.String(), err)
+			} else if stageDesc == nil {
+				return fmt.Errorf("invalid archive: stage %s description not found", stageID.String())
+			}
+
+			if _, err := m.CopySuitableByDigestStage(ctx, stageDesc, layoutStorage, m.StagesStorage, containerRuntime); err != nil {
+				return err
+			}
+
+			if err := m.StagesStorageCache.DeleteStagesByDigest(ctx, m.ProjectName, stageID.Digest); err != nil {
+				return fmt.Errorf("unable to delete storage cache record (%s): %s", stageID.Digest, err)
+			}
+
+			stagesDescriptions = append(stagesDescriptions, stageDesc)
+
+			logboek.Context(ctx).Default().LogFDetails("  stage: %s\n", stageID.String())
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Importing metadata").DoError(func() error {
+		managedImages, err := layoutStorage.GetManagedImages(ctx, m.ProjectName)
+		if err != nil {
+			return fmt.Errorf("unable to get archive managed images: %s", err)
+		}
+
+		for _, imageName := range managedImages {
+			if err := m.StagesStorage.AddManagedImage(ctx, m.ProjectName, imageName); err != nil {
+				return fmt.Errorf("unable to add managed image %q: %s", imageName, err)
+			}
+		}
+
+		imageMetadataByImageName, _, err := layoutStorage.GetAllAndGroupImageMetadataByImageName(ctx, m.ProjectName, managedImages)
+		if err != nil {
+			return fmt.Errorf("unable to get archive image metadata: %s", err)
+		}
+
+		for imageName, stageIDCommitList := range imageMetadataByImageName {
+			for stageID, commits := range stageIDCommitList {
+				for _, commit := range commits {
+					if exists, err := m.StagesStorage.IsImageMetadataExist(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to get image %q metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
+					} else if exists {
+						continue
+					}
+
+					if err := m.StagesStorage.PutImageMetadata(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to put image %q metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
+					}
+				}
+			}
+		}
+
+		return copyImportMetadata(ctx, m.ProjectName, layoutStorage, m.StagesStorage, nil)
+	})
+}
+
+func newArchiveLayoutStagesStorage(layoutDir string, containerRuntime container_runtime.ContainerRuntime) (storage.StagesStorage, error) {
+	return storage.NewStagesStorage(storage.OCILayoutStorageAddressPrefix+layoutDir, containerRuntime, storage.StagesStorageOptions{})
+}
+
+// copyImportMetadata copies all import metadata or only metadata related to the specified stages.
+func copyImportMetadata(ctx context.Context, projectName string, fromStagesStorage, toStagesStorage storage.StagesStorage, stagesDescriptions []*image.StageDescription) error {
+	ids, err := fromStagesStorage.GetImportMetadataIDs(ctx, projectName)
+	if err != nil {
+		return fmt.Errorf("unable to get import metadata ids: %s", err)
+	}
+
+	for _, id := range ids {
+		metadata, err := fromStagesStorage.GetImportMetadata(ctx, projectName, id)
+		if err != nil {
+			return fmt.Errorf("unable to get import metadata %s: %s", id, err)
+		} else if metadata == nil {
+			continue
+		}
+
+		if stagesDescriptions != nil && !isImportMetadataRelatedToStages(metadata, stagesDescriptions) {
+			continue
+		}
+
+		if err := toStagesStorage.PutImportMetadata(ctx, projectName, metadata); err != nil {
+			return fmt.Errorf("unable to put import metadata %s: %s", id, err)
+		}
+	}
+
+	return nil
+}
+
+func isImportMetadataRelatedToStages(metadata *storage.ImportMetadata, stagesDescriptions []*image.StageDescription) bool {
+	for _, stageDesc := range stagesDescriptions {
+		if stageDesc.Info.ID == metadata.SourceImageID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func filterImageMetadataByCommits(imageMetadataByImageName map[string]map[string][]string, commits []string) map[string]map[string][]string {
+	if len(commits) == 0 {
+		return imageMetadataByImageName
+	}
+
+	res := map[string]map[string][]string{}
+	for imageName, stageIDCommitList := range imageMetadataByImageName {
+		for stageID, stageCommits := range stageIDCommitList {
+			for _, commit := range stageCommits {
+				if !util.IsStringsContainValue(commits, commit) {
+					continue
+				}
+
+				if _, hasKey := res[imageName]; !hasKey {
+					res[imageName] = map[string][]string{}
+				}
+				res[imageName][stageID] = append(res[imageName][stageID], commit)
+			}
+		}
+	}
+
+	return res
+}
+
+// selectImageMetadataStagesWithAncestors selects stages referenced by image metadata
+// with all parent stages, so that the whole stages chain of each image is available.
+func selectImageMetadataStagesWithAncestors(stagesDescriptions []*image.StageDescription, imageMetadataByImageName map[string]map[string][]string) []*image.StageDescription {
+	stageDescByID := map[string]*image.StageDescription{}
+	stageDescByStageID := map[string]*image.StageDescription{}
+	for _, stageDesc := range stagesDescriptions {
+		stageDescByID[stageDesc.Info.ID] = stageDesc
+		stageDescByStageID[stageDesc.StageID.String()] = stageDesc
+	}
+
+	// the empty selection is not nil, otherwise the import metadata of all stages is exported
+	res := []*image.StageDescription{}
+	selected := map[*image.StageDescription]bool{}
+	for _, stageIDCommitList := range imageMetadataByImageName {
+		for stageID := range stageIDCommitList {
+			for stageDesc := stageDescByStageID[stageID]; stageDesc != nil && !selected[stageDesc]; stageDesc = stageDescByID[stageDesc.Info.ParentID] {
+				selected[stageDesc] = true
+				res = append(res, stageDesc)
+			}
+		}
+	}
+
+	return res
+}
+
+func createArchiveFromDir(archivePath, dir string) error {
+	return util.CreateArchive(archivePath, func(tw *tar.Writer) error {
+		return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+
+			return util.CopyFileIntoTar(tw, filepath.ToSlash(relPath), path)
+		})
+	})
+}
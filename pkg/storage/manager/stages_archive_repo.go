@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+)
+
+// ArchiveStage copies the stage into the archive stages storage before the stage deletion,
+// so that the stage can be restored by RestoreStages later. The stage which is already archived is skipped.
+func (m *StagesStorageManager) ArchiveStage(ctx context.Context, stageDesc *image.StageDescription, archiveStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) error {
+	if archivedStageDesc, err := archiveStagesStorage.GetStageDescription(ctx, m.ProjectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID); err != nil {
+		return fmt.Errorf("unable to get stage %s description from %s: %s", stageDesc.StageID.String(), archiveStagesStorage.String(), err)
+	} else if archivedStageDesc != nil {
+		return nil
+	}
+
+	if _, err := m.CopySuitableByDigestStage(ctx, stageDesc, m.StagesStorage, archiveStagesStorage, containerRuntime); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ArchiveImageMetadata copies the image metadata into the archive stages storage before the metadata deletion.
+// The image is recorded as managed in the archive, thus the image name can be resolved by the metadata on restore.
+func (m *StagesStorageManager) ArchiveImageMetadata(ctx context.Context, imageName string, stageIDCommitList map[string][]string, archiveStagesStorage storage.StagesStorage) error {
+	if len(stageIDCommitList) == 0 {
+		return nil
+	}
+
+	if err := archiveStagesStorage.AddManagedImage(ctx, m.ProjectName, imageName); err != nil {
+		return fmt.Errorf("unable to add managed image %q into %s: %s", imageName, archiveStagesStorage.String(), err)
+	}
+
+	for stageID, commits := range stageIDCommitList {
+		for _, commit := range commits {
+			if err := archiveStagesStorage.PutImageMetadata(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+				return fmt.Errorf("unable to put image %q metadata by commit %s and stage ID %s into %s: %s", imageName, commit, stageID, archiveStagesStorage.String(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RestoreStages copies the stages from the archive stages storage back into the stages storage together with
+// the archived ancestors missing in the stages storage and the archived image metadata of the stages.
+func (m *StagesStorageManager) RestoreStages(ctx context.Context, stageIDs []string, archiveStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) error {
+	archivedStagesIDs, err := archiveStagesStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get stages of %s: %s", archiveStagesStorage.String(), err)
+	}
+
+	archivedStagesDescriptions, err := m.getStagesDescriptions(ctx, archivedStagesIDs, archiveStagesStorage)
+	if err != nil {
+		return fmt.Errorf("unable to get stage descriptions from %s: %s", archiveStagesStorage.String(), err)
+	}
+
+	var notFoundStageIDs []string
+	stageIDCommitList := map[string][]string{}
+	for _, stageID := range stageIDs {
+		if findStageDescription(archivedStagesDescriptions, stageID) == nil {
+			notFoundStageIDs = append(notFoundStageIDs, stageID)
+			continue
+		}
+
+		stageIDCommitList[stageID] = nil
+	}
+
+	if len(notFoundStageIDs) != 0 {
+		return fmt.Errorf("stages %s not found in %s", strings.Join(notFoundStageIDs, ", "), archiveStagesStorage.String())
+	}
+
+	stagesDescriptions := selectImageMetadataStagesWithAncestors(archivedStagesDescriptions, map[string]map[string][]string{"": stageIDCommitList})
+
+	if err := logboek.Context(ctx).Default().LogProcess("Restoring %d stages", len(stagesDescriptions)).DoError(func() error {
+		for _, stageDesc := range stagesDescriptions {
+			stageID := stageDesc.StageID
+			if existingStageDesc, err := m.StagesStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID); err != nil {
+				return fmt.Errorf("unable to get stage %s description: %s", stageID.String(), err)
+			} else if existingStageDesc != nil {
+				logboek.Context(ctx).Info().LogF("Stage %s already exists in %s\n", stageID.String(), m.StagesStorage.String())
+				continue
+			}
+
+			if _, err := m.CopySuitableByDigestStage(ctx, stageDesc, archiveStagesStorage, m.StagesStorage, containerRuntime); err != nil {
+				return err
+			}
+
+			if err := m.StagesStorageCache.DeleteStagesByDigest(ctx, m.ProjectName, stageID.Digest); err != nil {
+				return fmt.Errorf("unable to delete storage cache record (%s): %s", stageID.Digest, err)
+			}
+
+			logboek.Context(ctx).Default().LogFDetails("  stage: %s\n", stageID.String())
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Restoring metadata").DoError(func() error {
+		archivedManagedImages, err := archiveStagesStorage.GetManagedImages(ctx, m.ProjectName)
+		if err != nil {
+			return fmt.Errorf("unable to get managed images of %s: %s", archiveStagesStorage.String(), err)
+		}
+
+		imageMetadataByImageName, _, err := archiveStagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, m.ProjectName, archivedManagedImages)
+		if err != nil {
+			return fmt.Errorf("unable to get image metadata of %s: %s", archiveStagesStorage.String(), err)
+		}
+
+		for imageName, imageStageIDCommitList := range imageMetadataByImageName {
+			for stageID, commits := range imageStageIDCommitList {
+				if !util.IsStringsContainValue(stageIDs, stageID) {
+					continue
+				}
+
+				if err := m.StagesStorage.AddManagedImage(ctx, m.ProjectName, imageName); err != nil {
+					return fmt.Errorf("unable to add managed image %q: %s", imageName, err)
+				}
+
+				for _, commit := range commits {
+					if exists, err := m.StagesStorage.IsImageMetadataExist(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to get image %q metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
+					} else if exists {
+						continue
+					}
+
+					if err := m.StagesStorage.PutImageMetadata(ctx, m.ProjectName, imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to put image %q metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
+					}
+
+					logboek.Context(ctx).Default().LogFDetails("  image: %s, commit: %s, stage: %s\n", imageName, commit, stageID)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func findStageDescription(stagesDescriptions []*image.StageDescription, stageID string) *image.StageDescription {
+	for _, stageDesc := range stagesDescriptions {
+		if stageDesc.StageID.String() == stageID {
+			return stageDesc
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package manager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// newArchiveRepoTestStagesStorage creates the oci layout stages storage with the stages written directly into the layout,
+// the stages copying requires the local docker server, thus the copied stages are expected to exist in both storages
+func newArchiveRepoTestStagesStorage(t *testing.T, dir string, stageIDs ...image.StageID) *storage.OCILayoutStagesStorage {
+	stagesStorage, err := storage.NewOCILayoutStagesStorage(storage.OCILayoutStorageAddressPrefix+dir, &container_runtime.LocalDockerServerRuntime{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the layout is created with the first record
+	if err := stagesStorage.AddManagedImage(context.Background(), "project", "layout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stagesStorage.RmManagedImage(context.Background(), "project", "layout"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stageID := range stageIDs {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refName := stagesStorage.ConstructStageImageName("project", stageID.Digest, stageID.UniqueID)
+		if err := layout.Path(stagesStorage.LayoutPath).AppendImage(img, layout.WithAnnotations(map[string]string{"org.opencontainers.image.ref.name": refName})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return stagesStorage
+}
+
+func TestArchiveAndRestoreStages(t *testing.T) {
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "werf-stages-archive-repo-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+
+	stageID1 := image.StageID{Digest: "digest1", UniqueID: 1}
+	stageID2 := image.StageID{Digest: "digest2", UniqueID: 2}
+
+	tests := []struct {
+		name                          string
+		archivedMetadata              map[string]map[string][]string
+		restoreStageIDs               []string
+		expectedErr                   string
+		expectedArchivedManagedImages []string
+		expectedManagedImages         []string
+		expectedImageMetadata         map[string]map[string][]string
+	}{
+		{
+			name: "metadata of the restored stages",
+			archivedMetadata: map[string]map[string][]string{
+				"backend":  {stageID1.String(): {"commit-1", "commit-2"}, stageID2.String(): {"commit-3"}},
+				"frontend": {stageID2.String(): {"commit-3"}},
+			},
+			restoreStageIDs:               []string{stageID1.String()},
+			expectedArchivedManagedImages: []string{"backend", "frontend"},
+			expectedManagedImages:         []string{"backend"},
+			expectedImageMetadata: map[string]map[string][]string{
+				"backend": {stageID1.String(): {"commit-1", "commit-2"}},
+			},
+		},
+		{
+			name: "metadata of all the stages",
+			archivedMetadata: map[string]map[string][]string{
+				"backend":  {stageID1.String(): {"commit-1"}},
+				"frontend": {stageID2.String(): {"commit-3"}},
+			},
+			restoreStageIDs:               []string{stageID1.String(), stageID2.String()},
+			expectedArchivedManagedImages: []string{"backend", "frontend"},
+			expectedManagedImages:         []string{"backend", "frontend"},
+			expectedImageMetadata: map[string]map[string][]string{
+				"backend":  {stageID1.String(): {"commit-1"}},
+				"frontend": {stageID2.String(): {"commit-3"}},
+			},
+		},
+		{
+			name:             "image without the archived metadata",
+			archivedMetadata: map[string]map[string][]string{"backend": {}},
+			restoreStageIDs:  []string{stageID1.String()},
+		},
+		{
+			name:                          "stage not found in the archive",
+			archivedMetadata:              map[string]map[string][]string{"backend": {stageID1.String(): {"commit-1"}}},
+			restoreStageIDs:               []string{stageID1.String(), "digest3-3"},
+			expectedErr:                   "stages digest3-3 not found",
+			expectedArchivedManagedImages: []string{"backend"},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := filepath.Join(tmpDir, strconv.Itoa(i))
+
+			stagesStorage := newArchiveRepoTestStagesStorage(t, filepath.Join(testDir, "repo"), stageID1, stageID2)
+			archiveStagesStorage := newArchiveRepoTestStagesStorage(t, filepath.Join(testDir, "archive"), stageID1, stageID2)
+			m := NewStorageManager("project", stagesStorage, nil, nil, nil)
+
+			// the already archived stage is neither fetched nor copied without the container runtime
+			stageDesc, err := stagesStorage.GetStageDescription(ctx, "project", stageID1.Digest, stageID1.UniqueID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := m.ArchiveStage(ctx, stageDesc, archiveStagesStorage, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			for imageName, stageIDCommitList := range tt.archivedMetadata {
+				if err := m.ArchiveImageMetadata(ctx, imageName, stageIDCommitList, archiveStagesStorage); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			archivedManagedImages, err := archiveStagesStorage.GetManagedImages(ctx, "project")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(archivedManagedImages)
+			if !reflect.DeepEqual(archivedManagedImages, tt.expectedArchivedManagedImages) {
+				t.Errorf("expected archived managed images %v, got %v", tt.expectedArchivedManagedImages, archivedManagedImages)
+			}
+
+			err = m.RestoreStages(ctx, tt.restoreStageIDs, archiveStagesStorage, &container_runtime.LocalDockerServerRuntime{})
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			managedImages, err := stagesStorage.GetManagedImages(ctx, "project")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(managedImages)
+			if !reflect.DeepEqual(managedImages, tt.expectedManagedImages) {
+				t.Errorf("expected managed images %v, got %v", tt.expectedManagedImages, managedImages)
+			}
+
+			imageMetadata, _, err := stagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, "project", managedImages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, stageIDCommitList := range imageMetadata {
+				for _, commits := range stageIDCommitList {
+					sort.Strings(commits)
+				}
+			}
+			if len(imageMetadata) == 0 {
+				imageMetadata = nil
+			}
+			if !reflect.DeepEqual(imageMetadata, tt.expectedImageMetadata) {
+				t.Errorf("expected image metadata %v, got %v", tt.expectedImageMetadata, imageMetadata)
+			}
+		})
+	}
+}
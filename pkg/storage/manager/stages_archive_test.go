@@ -0,0 +1,188 @@
+package manager
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestStagesArchiveRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "werf-stages-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+
+	containerRuntime := &container_runtime.LocalDockerServerRuntime{}
+
+	tests := []struct {
+		name                     string
+		opts                     ExportStagesOptions
+		expectedManagedImages    []string
+		expectedImageMetadata    map[string]map[string][]string
+		expectedImportMetadataID []string
+	}{
+		{
+			name:                  "all stages",
+			opts:                  ExportStagesOptions{},
+			expectedManagedImages: []string{"backend", "frontend"},
+			expectedImageMetadata: map[string]map[string][]string{
+				"backend":  {"digest-1": {"commit-1"}},
+				"frontend": {"digest-2": {"commit-2"}},
+			},
+			expectedImportMetadataID: []string{"import-source-1"},
+		},
+		{
+			name:                  "stages of the commit",
+			opts:                  ExportStagesOptions{Commits: []string{"commit-2"}},
+			expectedManagedImages: []string{"frontend"},
+			expectedImageMetadata: map[string]map[string][]string{
+				"frontend": {"digest-2": {"commit-2"}},
+			},
+		},
+		{
+			name:                  "stages of the image",
+			opts:                  ExportStagesOptions{ImagesNames: []string{"backend"}},
+			expectedManagedImages: []string{"backend"},
+			expectedImageMetadata: map[string]map[string][]string{
+				"backend": {"digest-1": {"commit-1"}},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := filepath.Join(tmpDir, strconv.Itoa(i))
+
+			sourceStagesStorage, err := storage.NewOCILayoutStagesStorage(storage.OCILayoutStorageAddressPrefix+filepath.Join(testDir, "source"), containerRuntime)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, imageName := range []string{"backend", "frontend"} {
+				if err := sourceStagesStorage.AddManagedImage(ctx, "project", imageName); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sourceStagesStorage.PutImageMetadata(ctx, "project", "backend", "commit-1", "digest-1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := sourceStagesStorage.PutImageMetadata(ctx, "project", "frontend", "commit-2", "digest-2"); err != nil {
+				t.Fatal(err)
+			}
+			if err := sourceStagesStorage.PutImportMetadata(ctx, "project", &storage.ImportMetadata{ImportSourceID: "import-source-1", SourceImageID: "sha256:source", Checksum: "checksum"}); err != nil {
+				t.Fatal(err)
+			}
+
+			archivePath := filepath.Join(testDir, "stages.tar")
+			if err := NewStorageManager("project", sourceStagesStorage, nil, nil, nil).ExportStages(ctx, archivePath, containerRuntime, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+
+			destinationStagesStorage, err := storage.NewOCILayoutStagesStorage(storage.OCILayoutStorageAddressPrefix+filepath.Join(testDir, "destination"), containerRuntime)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewStorageManager("project", destinationStagesStorage, nil, nil, nil).ImportStages(ctx, archivePath, containerRuntime); err != nil {
+				t.Fatal(err)
+			}
+
+			managedImages, err := destinationStagesStorage.GetManagedImages(ctx, "project")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(managedImages)
+			if !reflect.DeepEqual(managedImages, tt.expectedManagedImages) {
+				t.Errorf("expected managed images %v, got %v", tt.expectedManagedImages, managedImages)
+			}
+
+			imageMetadata, _, err := destinationStagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, "project", managedImages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(imageMetadata, tt.expectedImageMetadata) {
+				t.Errorf("expected image metadata %v, got %v", tt.expectedImageMetadata, imageMetadata)
+			}
+
+			importMetadataIDs, err := destinationStagesStorage.GetImportMetadataIDs(ctx, "project")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(importMetadataIDs, tt.expectedImportMetadataID) {
+				t.Errorf("expected import metadata %v, got %v", tt.expectedImportMetadataID, importMetadataIDs)
+			}
+		})
+	}
+}
+
+func TestSelectImageMetadataStagesWithAncestors(t *testing.T) {
+	newStage := func(digest, id, parentID string) *image.StageDescription {
+		return &image.StageDescription{
+			StageID: &image.StageID{Digest: digest, UniqueID: 1},
+			Info:    &image.Info{ID: id, ParentID: parentID},
+		}
+	}
+
+	stages := []*image.StageDescription{
+		newStage("from", "id-from", "id-base"),
+		newStage("install", "id-install", "id-from"),
+		newStage("setup", "id-setup", "id-install"),
+		newStage("other", "id-other", "id-base"),
+	}
+
+	tests := []struct {
+		name                  string
+		imageMetadata         map[string]map[string][]string
+		expectedStagesDigests []string
+	}{
+		{
+			name:                  "stage with the ancestors",
+			imageMetadata:         map[string]map[string][]string{"app": {"install-1": {"commit-1"}}},
+			expectedStagesDigests: []string{"from", "install"},
+		},
+		{
+			name: "shared ancestors are selected once",
+			imageMetadata: map[string]map[string][]string{
+				"app":    {"setup-1": {"commit-1"}},
+				"worker": {"install-1": {"commit-1"}},
+			},
+			expectedStagesDigests: []string{"from", "install", "setup"},
+		},
+		{
+			name:                  "nonexistent stage",
+			imageMetadata:         map[string]map[string][]string{"app": {"absent-1": {"commit-1"}}},
+			expectedStagesDigests: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digests := []string{}
+			for _, stageDesc := range selectImageMetadataStagesWithAncestors(stages, tt.imageMetadata) {
+				digests = append(digests, stageDesc.StageID.Digest)
+			}
+			sort.Strings(digests)
+
+			if !reflect.DeepEqual(digests, tt.expectedStagesDigests) {
+				t.Errorf("expected stages %v, got %v", tt.expectedStagesDigests, digests)
+			}
+		})
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	ggcrTypes "github.com/google/go-containerregistry/pkg/v1/types"
 	"gopkg.in/yaml.v2"
 
 	"github.com/werf/logboek"
@@ -16,8 +21,10 @@ import (
 
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/util/parallel"
 )
 
@@ -43,6 +50,12 @@ type StagesStorageManager struct {
 }
 
 func newStagesStorageManager(projectName string, stagesStorage storage.StagesStorage, secondaryStagesStorageList []storage.StagesStorage, storageLockManager storage.LockManager, stagesStorageCache storage.StagesStorageCache) *StagesStorageManager {
+	for _, s := range append([]storage.StagesStorage{stagesStorage}, secondaryStagesStorageList...) {
+		if repoStagesStorage, ok := s.(*storage.RepoStagesStorage); ok {
+			repoStagesStorage.LockManager = storageLockManager
+		}
+	}
+
 	return &StagesStorageManager{
 		ProjectName:        projectName,
 		StorageLockManager: storageLockManager,
@@ -53,6 +66,19 @@ func newStagesStorageManager(projectName string, stagesStorage storage.StagesSto
 	}
 }
 
+// GetStoreImageOptions returns options to store the stage into the stages storage
+// reusing layers of the same registry repo stages storages (the source and secondary ones).
+func (m *StagesStorageManager) GetStoreImageOptions(sourceStagesStorageList ...storage.StagesStorage) storage.StoreImageOptions {
+	var opts storage.StoreImageOptions
+	for _, stagesStorage := range append(sourceStagesStorageList, m.SecondaryStagesStorageList...) {
+		if repoStagesStorage, ok := stagesStorage.(*storage.RepoStagesStorage); ok && !util.IsStringsContainValue(opts.MountFromRepos, repoStagesStorage.RepoAddress) {
+			opts.MountFromRepos = append(opts.MountFromRepos, repoStagesStorage.RepoAddress)
+		}
+	}
+
+	return opts
+}
+
 func (m *StagesStorageManager) ResetStagesStorageCache(ctx context.Context) error {
 	msg := fmt.Sprintf("Reset storage cache %s for project %q", m.StagesStorageCache.String(), m.ProjectName)
 	return logboek.Context(ctx).Default().LogProcess(msg).DoError(func() error {
@@ -122,22 +148,32 @@ func (m *StagesStorageManager) ForEachDeleteStage(ctx context.Context, options F
 
 func (m *StagesStorageManager) FetchStage(ctx context.Context, stg stage.Interface) error {
 	logboek.Context(ctx).Debug().LogF("-- StagesManager.FetchStage %s\n", stg.LogDetailedName())
-	if freshStageDescription, err := m.StagesStorage.GetStageDescription(ctx, m.ProjectName, stg.GetImage().GetStageDescription().StageID.Digest, stg.GetImage().GetStageDescription().StageID.UniqueID); err != nil {
+
+	stagesStorage := m.StagesStorage
+	if secondaryStagesStorage := m.GetSecondaryStagesStorageOfStage(stg.GetImage().GetStageDescription()); secondaryStagesStorage != nil {
+		stagesStorage = secondaryStagesStorage
+	}
+
+	if freshStageDescription, err := stagesStorage.GetStageDescription(ctx, m.ProjectName, stg.GetImage().GetStageDescription().StageID.Digest, stg.GetImage().GetStageDescription().StageID.UniqueID); err != nil {
 		return err
 	} else if freshStageDescription == nil {
-		logboek.Context(ctx).Error().LogF("Invalid stage %s image %q! Stage is no longer available in the %s. Stages storage cache for project %q should be reset!\n", stg.LogDetailedName(), stg.GetImage().Name(), m.StagesStorage.String(), m.ProjectName)
+		if stagesStorage != m.StagesStorage {
+			return fmt.Errorf("stage %s image %q is no longer available in the %s", stg.LogDetailedName(), stg.GetImage().Name(), stagesStorage.String())
+		}
+
+		logboek.Context(ctx).Error().LogF("Invalid stage %s image %q! Stage is no longer available in the %s. Stages storage cache for project %q should be reset!\n", stg.LogDetailedName(), stg.GetImage().Name(), stagesStorage.String(), m.ProjectName)
 		return ErrShouldResetStagesStorageCache
 	}
 
-	if shouldFetch, err := m.StagesStorage.ShouldFetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()}); err == nil && shouldFetch {
+	if shouldFetch, err := stagesStorage.ShouldFetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()}); err == nil && shouldFetch {
 		if err := logboek.Context(ctx).Default().LogProcess("Fetching stage %s from storage", stg.LogDetailedName()).
 			Options(func(options types.LogProcessOptionsInterface) {
 				options.Style(style.Highlight())
 			}).
 			DoError(func() error {
 				logboek.Context(ctx).Info().LogF("Image name: %s\n", stg.GetImage().Name())
-				if err := m.StagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()}); err != nil {
-					return fmt.Errorf("unable to fetch stage %s image %s from storage %s: %s", stg.LogDetailedName(), stg.GetImage().Name(), m.StagesStorage.String(), err)
+				if err := stagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()}); err != nil {
+					return fmt.Errorf("unable to fetch stage %s image %s from storage %s: %s", stg.LogDetailedName(), stg.GetImage().Name(), stagesStorage.String(), err)
 				}
 				return nil
 			}); err != nil {
@@ -231,7 +267,7 @@ func (m *StagesStorageManager) GetStagesByDigestFromStagesStorage(ctx context.Co
 }
 
 func (m *StagesStorageManager) CopySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) (*image.StageDescription, error) {
-	img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime.(*container_runtime.LocalDockerServerRuntime))
+	img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime)
 
 	logboek.Context(ctx).Info().LogF("Fetching %s\n", img.Name())
 	if err := sourceStagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: img}); err != nil {
@@ -245,7 +281,7 @@ func (m *StagesStorageManager) CopySuitableByDigestStage(ctx context.Context, st
 	}
 
 	logboek.Context(ctx).Info().LogF("Storing %s\n", newImageName)
-	if err := destinationStagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: img}); err != nil {
+	if _, err := destinationStagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: img}, m.GetStoreImageOptions(sourceStagesStorage)); err != nil {
 		return nil, fmt.Errorf("unable to store %s to %s: %s", stageDesc.Info.Name, destinationStagesStorage.String(), err)
 	}
 
@@ -256,8 +292,135 @@ func (m *StagesStorageManager) CopySuitableByDigestStage(ctx context.Context, st
 	}
 }
 
+// CopySuitableByDigestStageInRegistry copies the stage between the repo stages storages by the registry API
+// without the local docker server, the missing layers are mounted from the repos of the same registry if possible.
+func (m *StagesStorageManager) CopySuitableByDigestStageInRegistry(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage) (*image.StageDescription, *storage.StoreImageReport, error) {
+	for _, stagesStorage := range []storage.StagesStorage{sourceStagesStorage, destinationStagesStorage} {
+		if _, ok := stagesStorage.(*storage.RepoStagesStorage); !ok {
+			return nil, nil, fmt.Errorf("unable to copy stage %s in the registry: %s is not a repo stages storage", stageDesc.StageID.String(), stagesStorage.String())
+		}
+	}
+
+	newImageName := destinationStagesStorage.ConstructStageImageName(m.ProjectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID)
+	logboek.Context(ctx).Info().LogF("Copying %s to %s\n", stageDesc.Info.Name, newImageName)
+	writeReport, err := docker_registry.API().CopyRepoImage(ctx, stageDesc.Info.Name, newImageName, docker_registry.WriteRepoImageOptions{MountFromRepos: m.GetStoreImageOptions(sourceStagesStorage).MountFromRepos})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to copy %s from %s to %s: %s", stageDesc.Info.Name, sourceStagesStorage.String(), destinationStagesStorage.String(), err)
+	}
+
+	if destinationStageDesc, err := getStageDescription(ctx, m.ProjectName, *stageDesc.StageID, destinationStagesStorage, getStageDescriptionOptions{StageShouldExist: true, WithManifestCache: m.getWithManifestCacheOption()}); err != nil {
+		return nil, nil, fmt.Errorf("unable to get stage %s description from %s: %s", stageDesc.StageID.String(), destinationStagesStorage.String(), err)
+	} else {
+		return destinationStageDesc, &storage.StoreImageReport{UploadedBytes: writeReport.UploadedBytes}, nil
+	}
+}
+
+// GetSecondaryStagesStorageOfStage returns the secondary stages storage the stage belongs to or nil if the stage is in the primary stages storage.
+// In the registry-only mode the stages of the secondary repos are used as is until the stage is needed in the primary repo.
+func (m *StagesStorageManager) GetSecondaryStagesStorageOfStage(stageDesc *image.StageDescription) storage.StagesStorage {
+	if stageDesc == nil || stageDesc.StageID == nil {
+		return nil
+	}
+
+	for _, stagesStorage := range m.SecondaryStagesStorageList {
+		if stagesStorage.ConstructStageImageName(m.ProjectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID) == stageDesc.Info.Name {
+			return stagesStorage
+		}
+	}
+
+	return nil
+}
+
+// PublishImageIndex assembles an image index from the image last stages built for the different platforms
+// and stores it in the repo. The index is tagged by the digest of these stages, so existing index is reused.
+func (m *StagesStorageManager) PublishImageIndex(ctx context.Context, stageDescByPlatform map[string]*image.StageDescription) (*image.Info, error) {
+	indexName, err := m.constructImageIndexName(stageDescByPlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexInfo, err := docker_registry.API().TryGetRepoImageIndex(ctx, indexName); err != nil {
+		return nil, fmt.Errorf("unable to get image index %s: %s", indexName, err)
+	} else if indexInfo != nil {
+		return indexInfo, nil
+	}
+
+	index := mutate.IndexMediaType(empty.Index, ggcrTypes.DockerManifestList)
+	for _, platform := range getSortedPlatforms(stageDescByPlatform) {
+		stageDesc := stageDescByPlatform[platform]
+
+		img, err := docker_registry.API().GetRepoImageObject(ctx, stageDesc.Info.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get stage %s image: %s", stageDesc.Info.Name, err)
+		}
+
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform:    parsePlatform(platform),
+				Annotations: map[string]string{image.WerfImageIndexStageIDAnnotation: stageDesc.StageID.String()},
+			},
+		})
+	}
+
+	logboek.Context(ctx).Info().LogF("Storing image index %s\n", indexName)
+	if err := docker_registry.API().WriteRepoImageIndex(ctx, indexName, index); err != nil {
+		return nil, fmt.Errorf("unable to store image index %s: %s", indexName, err)
+	}
+
+	return docker_registry.API().GetRepoImageIndex(ctx, indexName)
+}
+
+// GetImageIndex returns the image index published for the specified stages or nil if the index does not exist.
+func (m *StagesStorageManager) GetImageIndex(ctx context.Context, stageDescByPlatform map[string]*image.StageDescription) (*image.Info, error) {
+	indexName, err := m.constructImageIndexName(stageDescByPlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	return docker_registry.API().TryGetRepoImageIndex(ctx, indexName)
+}
+
+func (m *StagesStorageManager) constructImageIndexName(stageDescByPlatform map[string]*image.StageDescription) (string, error) {
+	repoStagesStorage, ok := m.StagesStorage.(*storage.RepoStagesStorage)
+	if !ok {
+		return "", fmt.Errorf("image index cannot be stored in %s stages storage: repo should be specified", m.StagesStorage.String())
+	}
+
+	var checksumArgs []string
+	for _, platform := range getSortedPlatforms(stageDescByPlatform) {
+		checksumArgs = append(checksumArgs, platform, stageDescByPlatform[platform].StageID.String())
+	}
+
+	return repoStagesStorage.ConstructImageIndexName(util.Sha3_224Hash(checksumArgs...)), nil
+}
+
+func getSortedPlatforms(stageDescByPlatform map[string]*image.StageDescription) []string {
+	var platforms []string
+	for platform := range stageDescByPlatform {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	return platforms
+}
+
+func parsePlatform(platform string) *v1.Platform {
+	parts := strings.SplitN(platform, "/", 3)
+
+	res := &v1.Platform{OS: parts[0]}
+	if len(parts) > 1 {
+		res.Architecture = parts[1]
+	}
+	if len(parts) > 2 {
+		res.Variant = parts[2]
+	}
+
+	return res
+}
+
 func (m *StagesStorageManager) getWithManifestCacheOption() bool {
-	return m.StagesStorage.Address() != storage.LocalStorageAddress
+	return m.StagesStorage.Address() != storage.LocalStorageAddress && !storage.IsOCILayoutStorageAddress(m.StagesStorage.Address())
 }
 
 func (m *StagesStorageManager) getStagesByDigestFromCache(ctx context.Context, stageName, stageDigest string) (bool, []*image.StageDescription, error) {
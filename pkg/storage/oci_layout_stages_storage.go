@@ -0,0 +1,777 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	OCILayoutStorageAddressPrefix = "oci:"
+
+	OCILayoutStage_ImageFormat = "%s:%s-%d"
+
+	OCILayoutImageMetadataByCommitRecord_TagFormat = RepoImageMetadataByCommitRecord_TagFormat
+	OCILayoutClientIDRecord_TagFormat              = "client-id-%s-%d"
+
+	ociLayoutRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+func IsOCILayoutStorageAddress(address string) bool {
+	return strings.HasPrefix(address, OCILayoutStorageAddressPrefix)
+}
+
+// OCILayoutStagesStorage keeps stages and all service records in the OCI image layout directory,
+// every record is an image manifest referenced from the index by the PROJECT:TAG annotation,
+// so several projects can share one layout directory.
+type OCILayoutStagesStorage struct {
+	LayoutPath string
+
+	// Stages are fetched from the layout into the local docker server and stored back from it
+	LocalDockerServerRuntime *container_runtime.LocalDockerServerRuntime
+}
+
+func NewOCILayoutStagesStorage(address string, localDockerServerRuntime *container_runtime.LocalDockerServerRuntime) (*OCILayoutStagesStorage, error) {
+	layoutPath := strings.TrimPrefix(address, OCILayoutStorageAddressPrefix)
+	if layoutPath == "" {
+		return nil, fmt.Errorf("bad oci layout stages storage address %q: %sPATH expected", address, OCILayoutStorageAddressPrefix)
+	}
+
+	absLayoutPath, err := filepath.Abs(layoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for %q: %s", layoutPath, err)
+	}
+
+	return &OCILayoutStagesStorage{
+		LayoutPath:               absLayoutPath,
+		LocalDockerServerRuntime: localDockerServerRuntime,
+	}, nil
+}
+
+func (storage *OCILayoutStagesStorage) ConstructStageImageName(projectName, digest string, uniqueID int64) string {
+	return fmt.Sprintf(OCILayoutStage_ImageFormat, projectName, digest, uniqueID)
+}
+
+func (storage *OCILayoutStagesStorage) GetStagesIDs(ctx context.Context, projectName string) ([]image.StageID, error) {
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []image.StageID
+	for _, tag := range tags {
+		if digest, uniqueID, err := getDigestAndUniqueIDFromRepoStageImageTag(tag); err != nil {
+			if isUnexpectedTagFormatError(err) {
+				logboek.Context(ctx).Debug().LogLn(err.Error())
+				continue
+			}
+			return nil, err
+		} else {
+			res = append(res, image.StageID{Digest: digest, UniqueID: uniqueID})
+		}
+	}
+
+	return res, nil
+}
+
+func (storage *OCILayoutStagesStorage) GetStagesIDsByDigest(ctx context.Context, projectName, digest string) ([]image.StageID, error) {
+	stageIDs, err := storage.GetStagesIDs(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []image.StageID
+	for _, stageID := range stageIDs {
+		if stageID.Digest == digest {
+			res = append(res, stageID)
+		}
+	}
+
+	return res, nil
+}
+
+func (storage *OCILayoutStagesStorage) GetStageDescription(ctx context.Context, projectName, digest string, uniqueID int64) (*image.StageDescription, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage GetStageDescription %s %s %d\n", projectName, digest, uniqueID)
+
+	stageImageName := storage.ConstructStageImageName(projectName, digest, uniqueID)
+	if imgInfo, err := storage.getImageInfo(stageImageName); err != nil {
+		return nil, err
+	} else if imgInfo != nil {
+		return &image.StageDescription{
+			StageID: &image.StageID{Digest: digest, UniqueID: uniqueID},
+			Info:    imgInfo,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (storage *OCILayoutStagesStorage) DeleteStage(ctx context.Context, stageDescription *image.StageDescription, _ DeleteImageOptions) error {
+	return storage.withLock(ctx, func() error {
+		if err := storage.removeRecords(stageDescription.Info.Name); err != nil {
+			return err
+		}
+
+		return storage.removeUnreferencedBlobs()
+	})
+}
+
+func (storage *OCILayoutStagesStorage) FilterStagesAndProcessRelatedData(_ context.Context, stageDescriptions []*image.StageDescription, _ FilterStagesAndProcessRelatedDataOptions) ([]*image.StageDescription, error) {
+	return stageDescriptions, nil
+}
+
+func (storage *OCILayoutStagesStorage) FetchImage(ctx context.Context, img container_runtime.Image) error {
+	dockerImage := img.(*container_runtime.DockerImage)
+
+	imgObj, err := storage.getImageObject(dockerImage.Image.Name())
+	if err != nil {
+		return err
+	} else if imgObj == nil {
+		return fmt.Errorf("image %s not found in %s", dockerImage.Image.Name(), storage.String())
+	}
+
+	if err := docker.LoadImageObject(ctx, dockerImage.Image.Name(), imgObj); err != nil {
+		return err
+	}
+
+	return storage.LocalDockerServerRuntime.RefreshImageObject(ctx, img)
+}
+
+func (storage *OCILayoutStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image, _ StoreImageOptions) (*StoreImageReport, error) {
+	dockerImage := img.(*container_runtime.DockerImage)
+
+	if err := storage.LocalDockerServerRuntime.TagImageByName(ctx, img); err != nil {
+		return nil, err
+	}
+
+	imgObj, err := docker.ImageObject(ctx, dockerImage.Image.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image %s from the local docker server: %s", dockerImage.Image.Name(), err)
+	}
+
+	if err := storage.withLock(ctx, func() error {
+		return storage.putRecord(dockerImage.Image.Name(), imgObj)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &StoreImageReport{}, nil
+}
+
+func (storage *OCILayoutStagesStorage) ShouldFetchImage(_ context.Context, img container_runtime.Image) (bool, error) {
+	dockerImage := img.(*container_runtime.DockerImage)
+	return !dockerImage.Image.IsExistsLocally(), nil
+}
+
+func (storage *OCILayoutStagesStorage) CreateRepo(ctx context.Context) error {
+	return storage.withLock(ctx, storage.createLayoutIfNotExists)
+}
+
+func (storage *OCILayoutStagesStorage) DeleteRepo(ctx context.Context) error {
+	return storage.withLock(ctx, func() error {
+		return os.RemoveAll(storage.LayoutPath)
+	})
+}
+
+func (storage *OCILayoutStagesStorage) AddManagedImage(ctx context.Context, projectName, imageName string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.AddManagedImage %s %s\n", projectName, imageName)
+
+	if validateImageName(imageName) != nil {
+		return nil
+	}
+
+	return storage.putRecordIfNotExists(ctx, storage.refName(projectName, RepoManagedImageRecord_ImageTagPrefix+slugImageNameAsDockerImageTag(imageName)), nil)
+}
+
+func (storage *OCILayoutStagesStorage) RmManagedImage(ctx context.Context, projectName, imageName string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.RmManagedImage %s %s\n", projectName, imageName)
+
+	return storage.withLock(ctx, func() error {
+		return storage.removeRecords(storage.refName(projectName, RepoManagedImageRecord_ImageTagPrefix+slugImageNameAsDockerImageTag(imageName)))
+	})
+}
+
+func (storage *OCILayoutStagesStorage) GetManagedImages(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetManagedImages %s\n", projectName)
+
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) {
+			continue
+		}
+
+		managedImageName := unslugDockerImageTagAsImageName(strings.TrimPrefix(tag, RepoManagedImageRecord_ImageTagPrefix))
+		if validateImageName(managedImageName) != nil {
+			continue
+		}
+
+		res = append(res, managedImageName)
+	}
+
+	return res, nil
+}
+
+func (storage *OCILayoutStagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.PutImageMetadata %s %s %s %s\n", projectName, imageName, commit, stageID)
+
+	if err := storage.putRecordIfNotExists(ctx, storage.refName(projectName, fmt.Sprintf(OCILayoutImageMetadataByCommitRecord_TagFormat, imageNameID(imageName), commit, stageID)), nil); err != nil {
+		return err
+	}
+	logboek.Context(ctx).Info().LogF("Put image %s commit %s stage ID %s\n", imageName, commit, stageID)
+
+	return nil
+}
+
+func (storage *OCILayoutStagesStorage) RmImageMetadata(ctx context.Context, projectName, imageNameOrID, commit, stageID string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.RmImageMetadata %s %s %s %s\n", projectName, imageNameOrID, commit, stageID)
+
+	if err := storage.withLock(ctx, func() error {
+		return storage.removeRecords(
+			storage.refName(projectName, fmt.Sprintf(OCILayoutImageMetadataByCommitRecord_TagFormat, imageNameID(imageNameOrID), commit, stageID)),
+			storage.refName(projectName, fmt.Sprintf(OCILayoutImageMetadataByCommitRecord_TagFormat, imageNameOrID, commit, stageID)),
+		)
+	}); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Info().LogF("Removed image %s commit %s stage ID %s\n", imageNameOrID, commit, stageID)
+
+	return nil
+}
+
+func (storage *OCILayoutStagesStorage) IsImageMetadataExist(ctx context.Context, projectName, imageName, commit, stageID string) (bool, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.IsImageMetadataExist %s %s %s %s\n", projectName, imageName, commit, stageID)
+
+	desc, err := storage.getDescriptor(storage.refName(projectName, fmt.Sprintf(OCILayoutImageMetadataByCommitRecord_TagFormat, imageNameID(imageName), commit, stageID)))
+	return desc != nil, err
+}
+
+func (storage *OCILayoutStagesStorage) GetAllAndGroupImageMetadataByImageName(ctx context.Context, projectName string, imageNameList []string) (map[string]map[string][]string, map[string]map[string][]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetAllAndGroupImageMetadataByImageName %s %v\n", projectName, imageNameList)
+
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groupImageMetadataTagsByImageName(ctx, imageNameList, tags, RepoImageMetadataByCommitRecord_ImageTagPrefix)
+}
+
+func (storage *OCILayoutStagesStorage) GetImportMetadata(ctx context.Context, projectName, id string) (*ImportMetadata, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetImportMetadata %s %s\n", projectName, id)
+
+	imgObj, err := storage.getImageObject(storage.refName(projectName, RepoImportMetadata_ImageTagPrefix+id))
+	if err != nil {
+		return nil, err
+	} else if imgObj == nil {
+		return nil, nil
+	}
+
+	configFile, err := imgObj.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get import metadata %s config: %s", id, err)
+	}
+
+	return newImportMetadataFromLabels(configFile.Config.Labels), nil
+}
+
+func (storage *OCILayoutStagesStorage) PutImportMetadata(ctx context.Context, projectName string, metadata *ImportMetadata) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.PutImportMetadata %v\n", metadata)
+
+	return storage.withLock(ctx, func() error {
+		return storage.putRecord(storage.refName(projectName, RepoImportMetadata_ImageTagPrefix+metadata.ImportSourceID), container_registry_extensions.NewManifestOnlyImage(metadata.ToLabels()))
+	})
+}
+
+func (storage *OCILayoutStagesStorage) RmImportMetadata(ctx context.Context, projectName, id string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.RmImportMetadata %s\n", id)
+
+	return storage.withLock(ctx, func() error {
+		return storage.removeRecords(storage.refName(projectName, RepoImportMetadata_ImageTagPrefix+id))
+	})
+}
+
+func (storage *OCILayoutStagesStorage) GetImportMetadataIDs(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetImportMetadataIDs %s\n", projectName)
+
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoImportMetadata_ImageTagPrefix) {
+			continue
+		}
+
+		ids = append(ids, getImportMetadataIDFromRepoTag(tag))
+	}
+
+	return ids, nil
+}
+
+func (storage *OCILayoutStagesStorage) GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetClientIDRecords for project %s\n", projectName)
+
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*ClientIDRecord
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoClientIDRecrod_ImageTagPrefix) {
+			continue
+		}
+
+		dataParts := strings.SplitN(util.Reverse(strings.TrimPrefix(tag, RepoClientIDRecrod_ImageTagPrefix)), "-", 2)
+		if len(dataParts) != 2 {
+			continue
+		}
+
+		clientID, timestampMillisecStr := util.Reverse(dataParts[1]), util.Reverse(dataParts[0])
+
+		timestampMillisec, err := strconv.ParseInt(timestampMillisecStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, &ClientIDRecord{ClientID: clientID, TimestampMillisec: timestampMillisec})
+	}
+
+	return res, nil
+}
+
+func (storage *OCILayoutStagesStorage) PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.PostClientID %s for project %s\n", rec.ClientID, projectName)
+
+	if err := storage.putRecordIfNotExists(ctx, storage.refName(projectName, fmt.Sprintf(OCILayoutClientIDRecord_TagFormat, rec.ClientID, rec.TimestampMillisec)), nil); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Info().LogF("Posted new clientID %q for project %s\n", rec.ClientID, projectName)
+
+	return nil
+}
+
+func (storage *OCILayoutStagesStorage) GetForeignTags(_ context.Context, _ string) ([]string, error) {
+	return nil, nil
+}
+
+func (storage *OCILayoutStagesStorage) GetForeignTagInfo(_ context.Context, _, tag string) (*image.Info, error) {
+	return nil, fmt.Errorf("foreign tag %q is not supported by %s stages storage", tag, storage.String())
+}
+
+func (storage *OCILayoutStagesStorage) DeleteForeignTag(_ context.Context, _ string, info *image.Info) error {
+	return fmt.Errorf("foreign tag %q is not supported by %s stages storage", info.Name, storage.String())
+}
+
+func (storage *OCILayoutStagesStorage) GetCleanupAuditRecordIDs(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetCleanupAuditRecordIDs %s\n", projectName)
+
+	tags, err := storage.tags(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoCleanupAuditRecord_ImageTagPrefix) {
+			continue
+		}
+
+		ids = append(ids, strings.TrimPrefix(tag, RepoCleanupAuditRecord_ImageTagPrefix))
+	}
+
+	return ids, nil
+}
+
+func (storage *OCILayoutStagesStorage) GetCleanupAuditRecord(ctx context.Context, projectName, id string) (*CleanupAuditRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.GetCleanupAuditRecord %s %s\n", projectName, id)
+
+	imgObj, err := storage.getImageObject(storage.refName(projectName, RepoCleanupAuditRecord_ImageTagPrefix+id))
+	if err != nil {
+		return nil, err
+	} else if imgObj == nil {
+		return nil, nil
+	}
+
+	manifest, err := imgObj.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get cleanup audit record %s manifest: %s", id, err)
+	}
+
+	// the layout image provides only the filesystem layers, thus the record blob is read from the layout directly
+	rec, err := newCleanupAuditRecordFromArtifact(manifest, layout.Path(storage.LayoutPath).Blob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cleanup audit record %s: %s", id, err)
+	}
+
+	return rec, nil
+}
+
+func (storage *OCILayoutStagesStorage) PutCleanupAuditRecord(ctx context.Context, projectName string, rec *CleanupAuditRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.PutCleanupAuditRecord %s %s\n", projectName, rec.ID)
+
+	imgObj, err := rec.ToArtifact()
+	if err != nil {
+		return err
+	}
+
+	return storage.withLock(ctx, func() error {
+		return storage.putRecord(storage.refName(projectName, RepoCleanupAuditRecord_ImageTagPrefix+rec.ID), imgObj)
+	})
+}
+
+func (storage *OCILayoutStagesStorage) DeleteCleanupAuditRecord(ctx context.Context, projectName, id string) error {
+	logboek.Context(ctx).Debug().LogF("-- OCILayoutStagesStorage.DeleteCleanupAuditRecord %s %s\n", projectName, id)
+
+	return storage.withLock(ctx, func() error {
+		if err := storage.removeRecords(storage.refName(projectName, RepoCleanupAuditRecord_ImageTagPrefix+id)); err != nil {
+			return err
+		}
+
+		return storage.removeUnreferencedBlobs()
+	})
+}
+
+func (storage *OCILayoutStagesStorage) String() string {
+	return storage.Address()
+}
+
+func (storage *OCILayoutStagesStorage) Address() string {
+	return OCILayoutStorageAddressPrefix + storage.LayoutPath
+}
+
+func (storage *OCILayoutStagesStorage) withLock(ctx context.Context, f func() error) error {
+	lockName := fmt.Sprintf("oci_layout_stages_storage.%s", util.MurmurHash(storage.LayoutPath))
+	return werf.WithHostLock(ctx, lockName, lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
+}
+
+func (storage *OCILayoutStagesStorage) isLayoutExist() (bool, error) {
+	return util.RegularFileExists(filepath.Join(storage.LayoutPath, "index.json"))
+}
+
+// createLayoutIfNotExists should be called under the storage lock
+func (storage *OCILayoutStagesStorage) createLayoutIfNotExists() error {
+	if exist, err := storage.isLayoutExist(); err != nil {
+		return err
+	} else if exist {
+		return nil
+	}
+
+	if _, err := layout.Write(storage.LayoutPath, empty.Index); err != nil {
+		return fmt.Errorf("unable to create oci layout %s: %s", storage.LayoutPath, err)
+	}
+
+	return nil
+}
+
+// indexManifest returns an empty index if the layout has not been created yet
+func (storage *OCILayoutStagesStorage) indexManifest() (layout.Path, *v1.IndexManifest, error) {
+	if exist, err := storage.isLayoutExist(); err != nil {
+		return "", nil, err
+	} else if !exist {
+		return layout.Path(storage.LayoutPath), &v1.IndexManifest{SchemaVersion: 2}, nil
+	}
+
+	p, err := layout.FromPath(storage.LayoutPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to open oci layout %s: %s", storage.LayoutPath, err)
+	}
+
+	ii, err := p.ImageIndex()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to read oci layout %s index: %s", storage.LayoutPath, err)
+	}
+
+	indexManifest, err := ii.IndexManifest()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to read oci layout %s index: %s", storage.LayoutPath, err)
+	}
+
+	return p, indexManifest, nil
+}
+
+func (storage *OCILayoutStagesStorage) writeIndexManifest(p layout.Path, indexManifest *v1.IndexManifest) error {
+	data, err := json.MarshalIndent(indexManifest, "", "   ")
+	if err != nil {
+		return err
+	}
+
+	if err := p.WriteFile("index.json", data, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write oci layout %s index: %s", storage.LayoutPath, err)
+	}
+
+	return nil
+}
+
+func (storage *OCILayoutStagesStorage) refName(projectName, tag string) string {
+	return fmt.Sprintf("%s:%s", projectName, tag)
+}
+
+// tags returns the tags of the project records only
+func (storage *OCILayoutStagesStorage) tags(projectName string) ([]string, error) {
+	_, indexManifest, err := storage.indexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	refNamePrefix := storage.refName(projectName, "")
+
+	var tags []string
+	for _, desc := range indexManifest.Manifests {
+		refName := desc.Annotations[ociLayoutRefNameAnnotation]
+		if !strings.HasPrefix(refName, refNamePrefix) {
+			continue
+		}
+
+		if tag := strings.TrimPrefix(refName, refNamePrefix); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+func (storage *OCILayoutStagesStorage) getDescriptor(refName string) (*v1.Descriptor, error) {
+	_, indexManifest, err := storage.indexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		if desc.Annotations[ociLayoutRefNameAnnotation] == refName {
+			return &desc, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (storage *OCILayoutStagesStorage) getImageObject(refName string) (v1.Image, error) {
+	desc, err := storage.getDescriptor(refName)
+	if err != nil || desc == nil {
+		return nil, err
+	}
+
+	p := layout.Path(storage.LayoutPath)
+
+	imgObj, err := p.Image(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image %s from oci layout %s: %s", refName, storage.LayoutPath, err)
+	}
+
+	return imgObj, nil
+}
+
+func (storage *OCILayoutStagesStorage) getImageInfo(imageName string) (*image.Info, error) {
+	imgObj, err := storage.getImageObject(imageName)
+	if err != nil || imgObj == nil {
+		return nil, err
+	}
+
+	digest, err := imgObj.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := imgObj.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := imgObj.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var totalSize int64
+	for _, layer := range manifest.Layers {
+		totalSize += layer.Size
+	}
+
+	repository, tag := image.ParseRepositoryAndTag(imageName)
+	info := &image.Info{
+		Name:       imageName,
+		Repository: repository,
+		Tag:        tag,
+		ID:         manifest.Config.Digest.String(),
+		RepoDigest: digest.String(),
+		ParentID:   configFile.Config.Image,
+		Labels:     configFile.Config.Labels,
+		Size:       totalSize,
+	}
+	info.SetCreatedAtUnix(configFile.Created.Unix())
+
+	return info, nil
+}
+
+func (storage *OCILayoutStagesStorage) putRecordIfNotExists(ctx context.Context, refName string, labels map[string]string) error {
+	return storage.withLock(ctx, func() error {
+		if desc, err := storage.getDescriptor(refName); err != nil {
+			return err
+		} else if desc != nil {
+			return nil
+		}
+
+		return storage.putRecord(refName, container_registry_extensions.NewManifestOnlyImage(labels))
+	})
+}
+
+// putRecord should be called under the storage lock
+func (storage *OCILayoutStagesStorage) putRecord(refName string, imgObj v1.Image) error {
+	if err := storage.createLayoutIfNotExists(); err != nil {
+		return err
+	}
+
+	p, indexManifest, err := storage.indexManifest()
+	if err != nil {
+		return err
+	}
+
+	if err := p.WriteImage(imgObj); err != nil {
+		return fmt.Errorf("unable to write image %s into oci layout %s: %s", refName, storage.LayoutPath, err)
+	}
+
+	mediaType, err := imgObj.MediaType()
+	if err != nil {
+		return err
+	}
+
+	digest, err := imgObj.Digest()
+	if err != nil {
+		return err
+	}
+
+	size, err := imgObj.Size()
+	if err != nil {
+		return err
+	}
+
+	var manifests []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if desc.Annotations[ociLayoutRefNameAnnotation] != refName {
+			manifests = append(manifests, desc)
+		}
+	}
+
+	indexManifest.Manifests = append(manifests, v1.Descriptor{
+		MediaType:   mediaType,
+		Size:        size,
+		Digest:      digest,
+		Annotations: map[string]string{ociLayoutRefNameAnnotation: refName},
+	})
+
+	return storage.writeIndexManifest(p, indexManifest)
+}
+
+// removeRecords should be called under the storage lock
+func (storage *OCILayoutStagesStorage) removeRecords(refNames ...string) error {
+	p, indexManifest, err := storage.indexManifest()
+	if err != nil {
+		return err
+	}
+
+	var manifests []v1.Descriptor
+ManifestsLoop:
+	for _, desc := range indexManifest.Manifests {
+		for _, refName := range refNames {
+			if desc.Annotations[ociLayoutRefNameAnnotation] == refName {
+				continue ManifestsLoop
+			}
+		}
+
+		manifests = append(manifests, desc)
+	}
+
+	if len(manifests) == len(indexManifest.Manifests) {
+		return nil
+	}
+
+	indexManifest.Manifests = manifests
+
+	return storage.writeIndexManifest(p, indexManifest)
+}
+
+// removeUnreferencedBlobs should be called under the storage lock
+func (storage *OCILayoutStagesStorage) removeUnreferencedBlobs() error {
+	p, indexManifest, err := storage.indexManifest()
+	if err != nil {
+		return err
+	}
+
+	referencedBlobs := map[string]bool{}
+	for _, desc := range indexManifest.Manifests {
+		referencedBlobs[desc.Digest.String()] = true
+
+		imgObj, err := p.Image(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("unable to read image %s from oci layout %s: %s", desc.Digest, storage.LayoutPath, err)
+		}
+
+		manifest, err := imgObj.Manifest()
+		if err != nil {
+			return err
+		}
+
+		referencedBlobs[manifest.Config.Digest.String()] = true
+		for _, layer := range manifest.Layers {
+			referencedBlobs[layer.Digest.String()] = true
+		}
+	}
+
+	blobsDir := filepath.Join(storage.LayoutPath, "blobs")
+	algorithmDirs, err := ioutil.ReadDir(blobsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to read dir %s: %s", blobsDir, err)
+	}
+
+	for _, algorithmDir := range algorithmDirs {
+		blobs, err := ioutil.ReadDir(filepath.Join(blobsDir, algorithmDir.Name()))
+		if err != nil {
+			return fmt.Errorf("unable to read dir %s: %s", filepath.Join(blobsDir, algorithmDir.Name()), err)
+		}
+
+		for _, blob := range blobs {
+			if referencedBlobs[fmt.Sprintf("%s:%s", algorithmDir.Name(), blob.Name())] {
+				continue
+			}
+
+			blobPath := filepath.Join(blobsDir, algorithmDir.Name(), blob.Name())
+			if err := os.Remove(blobPath); err != nil {
+				return fmt.Errorf("unable to remove %s: %s", blobPath, err)
+			}
+		}
+	}
+
+	return nil
+}
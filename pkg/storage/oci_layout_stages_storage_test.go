@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestOCILayoutStagesStorage(t *testing.T) {
+	ctx := context.Background()
+
+	tmpDir, err := ioutil.TempDir("", "werf-oci-layout-stages-storage-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := NewOCILayoutStagesStorage(OCILayoutStorageAddressPrefix+filepath.Join(tmpDir, "layout"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if managedImages, err := storage.GetManagedImages(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(managedImages) != 0 {
+		t.Errorf("expected no managed images in the not created layout, got %v", managedImages)
+	}
+
+	if err := storage.CreateRepo(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imageName := range []string{"backend", "frontend/app", "backend"} {
+		if err := storage.AddManagedImage(ctx, "project", imageName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.RmManagedImage(ctx, "project", "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if managedImages, err := storage.GetManagedImages(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(managedImages, []string{"frontend/app"}) {
+		t.Errorf("unexpected managed images %v", managedImages)
+	}
+
+	if err := storage.PutImageMetadata(ctx, "project", "frontend/app", "commit", "stage-id"); err != nil {
+		t.Fatal(err)
+	}
+	if exist, err := storage.IsImageMetadataExist(ctx, "project", "frontend/app", "commit", "stage-id"); err != nil {
+		t.Fatal(err)
+	} else if !exist {
+		t.Errorf("expected image metadata to exist")
+	}
+	if metadata, _, err := storage.GetAllAndGroupImageMetadataByImageName(ctx, "project", []string{"frontend/app"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(metadata, map[string]map[string][]string{"frontend/app": {"stage-id": {"commit"}}}) {
+		t.Errorf("unexpected image metadata %v", metadata)
+	}
+	if err := storage.RmImageMetadata(ctx, "project", "frontend/app", "commit", "stage-id"); err != nil {
+		t.Fatal(err)
+	}
+	if exist, err := storage.IsImageMetadataExist(ctx, "project", "frontend/app", "commit", "stage-id"); err != nil {
+		t.Fatal(err)
+	} else if exist {
+		t.Errorf("expected image metadata to be removed")
+	}
+
+	importMetadata := &ImportMetadata{ImportSourceID: "source-id", SourceImageID: "image-id", Checksum: "checksum"}
+	if err := storage.PutImportMetadata(ctx, "project", importMetadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata, err := storage.GetImportMetadata(ctx, "project", "source-id"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(metadata, importMetadata) {
+		t.Errorf("unexpected import metadata %#v", metadata)
+	}
+	if ids, err := storage.GetImportMetadataIDs(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []string{"source-id"}) {
+		t.Errorf("unexpected import metadata ids %v", ids)
+	}
+
+	if err := storage.PostClientIDRecord(ctx, "project", &ClientIDRecord{ClientID: "client-id", TimestampMillisec: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := storage.GetClientIDRecords(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 1 || *records[0] != (ClientIDRecord{ClientID: "client-id", TimestampMillisec: 42}) {
+		t.Errorf("unexpected client id records %v", records)
+	}
+
+	auditRecord := NewCleanupAuditRecord("werf cleanup")
+	auditRecord.AddItem(&CleanupAuditRecordItem{Kind: "stage", Object: "stage digest-1611573400000", Reason: "not-used"})
+	if err := storage.PutCleanupAuditRecord(ctx, "project", auditRecord); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := storage.GetCleanupAuditRecordIDs(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, []string{auditRecord.ID}) {
+		t.Errorf("unexpected cleanup audit record ids %v", ids)
+	}
+	if rec, err := storage.GetCleanupAuditRecord(ctx, "project", auditRecord.ID); err != nil {
+		t.Fatal(err)
+	} else if rec == nil || rec.Command != auditRecord.Command || !reflect.DeepEqual(rec.Items, auditRecord.Items) {
+		t.Errorf("unexpected cleanup audit record %#v", rec)
+	}
+
+	stageImage, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stageID := image.StageID{Digest: "digest", UniqueID: 1611573400000}
+	if err := storage.putRecord(storage.ConstructStageImageName("project", stageID.Digest, stageID.UniqueID), stageImage); err != nil {
+		t.Fatal(err)
+	}
+
+	if stageIDs, err := storage.GetStagesIDs(ctx, "other-project"); err != nil {
+		t.Fatal(err)
+	} else if len(stageIDs) != 0 {
+		t.Errorf("expected no stages of the other project, got %v", stageIDs)
+	}
+	if managedImages, err := storage.GetManagedImages(ctx, "other-project"); err != nil {
+		t.Fatal(err)
+	} else if len(managedImages) != 0 {
+		t.Errorf("expected no managed images of the other project, got %v", managedImages)
+	}
+	if ids, err := storage.GetImportMetadataIDs(ctx, "other-project"); err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 0 {
+		t.Errorf("expected no import metadata of the other project, got %v", ids)
+	}
+	if records, err := storage.GetClientIDRecords(ctx, "other-project"); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 0 {
+		t.Errorf("expected no client id records of the other project, got %v", records)
+	}
+	if ids, err := storage.GetCleanupAuditRecordIDs(ctx, "other-project"); err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 0 {
+		t.Errorf("expected no cleanup audit records of the other project, got %v", ids)
+	}
+
+	if err := storage.DeleteCleanupAuditRecord(ctx, "project", auditRecord.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := storage.GetCleanupAuditRecordIDs(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 0 {
+		t.Errorf("expected no cleanup audit records after deletion, got %v", ids)
+	}
+	if rec, err := storage.GetCleanupAuditRecord(ctx, "project", auditRecord.ID); err != nil {
+		t.Fatal(err)
+	} else if rec != nil {
+		t.Errorf("expected deleted cleanup audit record, got %#v", rec)
+	}
+
+	if stageIDs, err := storage.GetStagesIDsByDigest(ctx, "project", "digest"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(stageIDs, []image.StageID{stageID}) {
+		t.Errorf("unexpected stages %v", stageIDs)
+	}
+
+	stageDesc, err := storage.GetStageDescription(ctx, "project", stageID.Digest, stageID.UniqueID)
+	if err != nil {
+		t.Fatal(err)
+	} else if stageDesc == nil {
+		t.Fatal("expected stage description")
+	} else if stageDesc.Info.Name != "project:digest-1611573400000" {
+		t.Errorf("unexpected stage image name %q", stageDesc.Info.Name)
+	}
+
+	if err := storage.DeleteStage(ctx, stageDesc, DeleteImageOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if stageIDs, err := storage.GetStagesIDs(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(stageIDs) != 0 {
+		t.Errorf("expected no stages, got %v", stageIDs)
+	}
+
+	layers, err := stageImage.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	layerDigest, err := layers[0].Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(storage.LayoutPath, "blobs", layerDigest.Algorithm, layerDigest.Hex)); !os.IsNotExist(err) {
+		t.Errorf("expected deleted stage layer blob to be removed (err: %v)", err)
+	}
+}
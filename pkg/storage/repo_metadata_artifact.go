@@ -0,0 +1,748 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+)
+
+const (
+	RepoMetadataArtifact_ImageTag        = "werf-metadata"
+	RepoMetadataArtifact_ImageNameFormat = "%s:werf-metadata"
+
+	RepoMetadataArtifactLayerMediaType    types.MediaType = "application/vnd.werf.metadata.v1+json"
+	RepoMetadataArtifactSectionAnnotation                 = "io.werf.metadata.section"
+
+	repoMetadataArtifactManagedImagesSection       = "managed-images"
+	repoMetadataArtifactImportMetadataSection      = "import-metadata"
+	repoMetadataArtifactClientIDRecordsSection     = "client-ids"
+	repoMetadataArtifactImageMetadataSectionPrefix = "image-metadata-"
+)
+
+// repoMetadataArtifact is the single tag of the repo, which stores werf metadata records instead of the tag per record.
+// Each section of the records is the JSON blob layer of the artifact, the section name is in the layer annotation,
+// thus the reader fetches only the required sections.
+type repoMetadataArtifact struct {
+	img      v1.Image
+	manifest *v1.Manifest
+	// legacyTagsRemoved means that the records of the tag per record format have been migrated and removed,
+	// otherwise the legacy records are read along with the artifact
+	legacyTagsRemoved bool
+}
+
+type repoImageMetadataSection struct {
+	// StageIDCommits are the commits by the stage ID
+	StageIDCommits map[string][]string
+}
+
+func repoMetadataArtifactImageMetadataSection(imageNameOrID string) string {
+	return repoMetadataArtifactImageMetadataSectionPrefix + imageNameOrID
+}
+
+func (storage *RepoStagesStorage) metadataArtifactName() string {
+	return fmt.Sprintf(RepoMetadataArtifact_ImageNameFormat, storage.RepoAddress)
+}
+
+// getMetadataArtifact returns nil if the repo stores the metadata in the tag per record format only
+func (storage *RepoStagesStorage) getMetadataArtifact(ctx context.Context) (*repoMetadataArtifact, error) {
+	if storage.isMetadataArtifactAbsent() {
+		return nil, nil
+	}
+
+	img, err := docker_registry.API().GetRepoImageObject(ctx, storage.metadataArtifactName())
+	if err != nil {
+		if docker_registry.IsManifestUnknownError(err) || docker_registry.IsNameUnknownError(err) {
+			storage.setMetadataArtifactAbsent(true)
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("unable to get metadata artifact %s: %s", storage.metadataArtifactName(), err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata artifact %s manifest: %s", storage.metadataArtifactName(), err)
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get metadata artifact %s config: %s", storage.metadataArtifactName(), err)
+	}
+
+	return &repoMetadataArtifact{
+		img:               img,
+		manifest:          manifest,
+		legacyTagsRemoved: configFile.Config.Labels[image.WerfMetadataLegacyTagsRemovedLabel] == "true",
+	}, nil
+}
+
+// isMetadataArtifactAbsent is used to avoid checking of the artifact on each operation with the legacy records,
+// the artifact is created only by the migration
+func (storage *RepoStagesStorage) isMetadataArtifactAbsent() bool {
+	storage.metadataArtifactAbsentMutex.Lock()
+	defer storage.metadataArtifactAbsentMutex.Unlock()
+
+	return storage.metadataArtifactAbsent
+}
+
+func (storage *RepoStagesStorage) setMetadataArtifactAbsent(absent bool) {
+	storage.metadataArtifactAbsentMutex.Lock()
+	defer storage.metadataArtifactAbsentMutex.Unlock()
+
+	storage.metadataArtifactAbsent = absent
+}
+
+func (artifact *repoMetadataArtifact) sections() []string {
+	var res []string
+	for _, desc := range artifact.manifest.Layers {
+		res = append(res, desc.Annotations[RepoMetadataArtifactSectionAnnotation])
+	}
+
+	return res
+}
+
+// readSection unmarshals the section into data, data is not changed if there is no such section
+func (artifact *repoMetadataArtifact) readSection(section string, data interface{}) error {
+	for _, desc := range artifact.manifest.Layers {
+		if desc.Annotations[RepoMetadataArtifactSectionAnnotation] != section {
+			continue
+		}
+
+		layer, err := artifact.img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("unable to get metadata section %q: %s", section, err)
+		}
+
+		rc, err := layer.Compressed()
+		if err != nil {
+			return fmt.Errorf("unable to read metadata section %q: %s", section, err)
+		}
+		defer rc.Close()
+
+		content, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return fmt.Errorf("unable to read metadata section %q: %s", section, err)
+		}
+
+		if err := json.Unmarshal(content, data); err != nil {
+			return fmt.Errorf("unable to parse metadata section %q: %s", section, err)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// withSections returns the new artifact image with the replaced sections, the rest sections are referenced as is
+func (artifact *repoMetadataArtifact) withSections(dataBySection map[string]interface{}, legacyTagsRemoved bool) (v1.Image, error) {
+	var adds []mutate.Addendum
+
+	if artifact != nil {
+		for _, desc := range artifact.manifest.Layers {
+			if _, ok := dataBySection[desc.Annotations[RepoMetadataArtifactSectionAnnotation]]; ok {
+				continue
+			}
+
+			layer, err := artifact.img.LayerByDigest(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			adds = append(adds, mutate.Addendum{Layer: layer, Annotations: desc.Annotations, MediaType: desc.MediaType})
+		}
+	}
+
+	var sections []string
+	for section := range dataBySection {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		content, err := json.Marshal(dataBySection[section])
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal metadata section %q: %s", section, err)
+		}
+
+		layer, err := container_registry_extensions.NewBlobLayer(content, RepoMetadataArtifactLayerMediaType)
+		if err != nil {
+			return nil, err
+		}
+
+		adds = append(adds, mutate.Addendum{
+			Layer:       layer,
+			Annotations: map[string]string{RepoMetadataArtifactSectionAnnotation: section},
+			MediaType:   RepoMetadataArtifactLayerMediaType,
+		})
+	}
+
+	img, err := mutate.Append(empty.Image, adds...)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{image.WerfLabel: "metadata"}
+	if legacyTagsRemoved {
+		labels[image.WerfMetadataLegacyTagsRemovedLabel] = "true"
+	}
+
+	img, err = mutate.Config(img, v1.Config{Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutate.MediaType(img, types.OCIManifestSchema1), nil
+}
+
+// withMetadataArtifactLock runs f holding the metadata artifact lock of the repo. The artifact is changed by
+// the read-modify-write of the whole manifest, thus without the lock the records written concurrently by another process are lost.
+func (storage *RepoStagesStorage) withMetadataArtifactLock(ctx context.Context, projectName string, f func() error) error {
+	storage.metadataArtifactMutex.Lock()
+	defer storage.metadataArtifactMutex.Unlock()
+
+	if storage.LockManager != nil {
+		if lock, err := storage.LockManager.LockMetadata(ctx, projectName, storage.RepoAddress); err != nil {
+			return fmt.Errorf("unable to lock metadata artifact %s: %s", storage.metadataArtifactName(), err)
+		} else {
+			defer storage.LockManager.Unlock(ctx, lock)
+		}
+	}
+
+	return f()
+}
+
+// updateMetadataArtifactSection applies the update to the section and writes the artifact holding the metadata artifact lock.
+// The update function receives the data created by newData and returns false if the data already contains the changes.
+func (storage *RepoStagesStorage) updateMetadataArtifactSection(ctx context.Context, projectName, section string, newData func() interface{}, update func(data interface{}) bool) error {
+	return storage.withMetadataArtifactLock(ctx, projectName, func() error {
+		artifact, err := storage.getMetadataArtifact(ctx)
+		if err != nil {
+			return err
+		} else if artifact == nil {
+			return fmt.Errorf("metadata artifact %s not found", storage.metadataArtifactName())
+		}
+
+		data := newData()
+		if err := artifact.readSection(section, data); err != nil {
+			return err
+		}
+
+		if !update(data) {
+			return nil
+		}
+
+		img, err := artifact.withSections(map[string]interface{}{section: data}, artifact.legacyTagsRemoved)
+		if err != nil {
+			return fmt.Errorf("unable to construct metadata artifact: %s", err)
+		}
+
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.updateMetadataArtifactSection %s\n", section)
+
+		if err := docker_registry.API().WriteRepoImage(ctx, storage.metadataArtifactName(), img); err != nil {
+			return fmt.Errorf("unable to write metadata artifact %s: %s", storage.metadataArtifactName(), err)
+		}
+
+		return nil
+	})
+}
+
+func (storage *RepoStagesStorage) getMetadataArtifactManagedImages(artifact *repoMetadataArtifact) ([]string, error) {
+	var managedImages []string
+	if err := artifact.readSection(repoMetadataArtifactManagedImagesSection, &managedImages); err != nil {
+		return nil, err
+	}
+
+	return managedImages, nil
+}
+
+func (storage *RepoStagesStorage) addMetadataArtifactManagedImage(ctx context.Context, projectName string, imageName string) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactManagedImagesSection, func() interface{} {
+		return &[]string{}
+	}, func(data interface{}) bool {
+		managedImages := data.(*[]string)
+		for _, managedImage := range *managedImages {
+			if managedImage == imageName {
+				return false
+			}
+		}
+
+		*managedImages = append(*managedImages, imageName)
+		sort.Strings(*managedImages)
+
+		return true
+	})
+}
+
+func (storage *RepoStagesStorage) rmMetadataArtifactManagedImage(ctx context.Context, projectName string, imageName string) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactManagedImagesSection, func() interface{} {
+		return &[]string{}
+	}, func(data interface{}) bool {
+		managedImages := data.(*[]string)
+
+		var res []string
+		for _, managedImage := range *managedImages {
+			if managedImage != imageName {
+				res = append(res, managedImage)
+			}
+		}
+
+		if len(res) == len(*managedImages) {
+			return false
+		}
+
+		*managedImages = res
+
+		return true
+	})
+}
+
+// getMetadataArtifactImageMetadataTags returns the image metadata records in the legacy tag format,
+// thus the records are grouped by the image name the same way as the legacy records
+func (storage *RepoStagesStorage) getMetadataArtifactImageMetadataTags(artifact *repoMetadataArtifact) ([]string, error) {
+	var tags []string
+	for _, section := range artifact.sections() {
+		if !strings.HasPrefix(section, repoMetadataArtifactImageMetadataSectionPrefix) {
+			continue
+		}
+
+		imageID := strings.TrimPrefix(section, repoMetadataArtifactImageMetadataSectionPrefix)
+
+		data := &repoImageMetadataSection{}
+		if err := artifact.readSection(section, data); err != nil {
+			return nil, err
+		}
+
+		for stageID, commits := range data.StageIDCommits {
+			for _, commit := range commits {
+				tags = append(tags, fmt.Sprintf(RepoImageMetadataByCommitRecord_TagFormat, imageID, commit, stageID))
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func (storage *RepoStagesStorage) isMetadataArtifactImageMetadataExist(artifact *repoMetadataArtifact, imageName, commit, stageID string) (bool, error) {
+	data := &repoImageMetadataSection{}
+	if err := artifact.readSection(repoMetadataArtifactImageMetadataSection(imageNameID(imageName)), data); err != nil {
+		return false, err
+	}
+
+	for _, c := range data.StageIDCommits[stageID] {
+		if c == commit {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (storage *RepoStagesStorage) putMetadataArtifactImageMetadata(ctx context.Context, projectName string, imageName, commit, stageID string) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactImageMetadataSection(imageNameID(imageName)), func() interface{} {
+		return &repoImageMetadataSection{}
+	}, func(data interface{}) bool {
+		section := data.(*repoImageMetadataSection)
+		if section.StageIDCommits == nil {
+			section.StageIDCommits = map[string][]string{}
+		}
+
+		for _, c := range section.StageIDCommits[stageID] {
+			if c == commit {
+				return false
+			}
+		}
+
+		section.StageIDCommits[stageID] = append(section.StageIDCommits[stageID], commit)
+
+		return true
+	})
+}
+
+// rmMetadataArtifactImageMetadata removes the record either by the image name or by the image name ID
+func (storage *RepoStagesStorage) rmMetadataArtifactImageMetadata(ctx context.Context, projectName string, artifact *repoMetadataArtifact, imageNameOrID, commit, stageID string) error {
+	for _, section := range []string{
+		repoMetadataArtifactImageMetadataSection(imageNameID(imageNameOrID)),
+		repoMetadataArtifactImageMetadataSection(imageNameOrID),
+	} {
+		if !util.IsStringsContainValue(artifact.sections(), section) {
+			continue
+		}
+
+		if err := storage.updateMetadataArtifactSection(ctx, projectName, section, func() interface{} {
+			return &repoImageMetadataSection{}
+		}, func(data interface{}) bool {
+			section := data.(*repoImageMetadataSection)
+
+			var commits []string
+			for _, c := range section.StageIDCommits[stageID] {
+				if c != commit {
+					commits = append(commits, c)
+				}
+			}
+
+			if len(commits) == len(section.StageIDCommits[stageID]) {
+				return false
+			}
+
+			if len(commits) == 0 {
+				delete(section.StageIDCommits, stageID)
+			} else {
+				section.StageIDCommits[stageID] = commits
+			}
+
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) getMetadataArtifactImportMetadata(artifact *repoMetadataArtifact) (map[string]*ImportMetadata, error) {
+	importMetadataByID := map[string]*ImportMetadata{}
+	if err := artifact.readSection(repoMetadataArtifactImportMetadataSection, &importMetadataByID); err != nil {
+		return nil, err
+	}
+
+	return importMetadataByID, nil
+}
+
+func (storage *RepoStagesStorage) putMetadataArtifactImportMetadata(ctx context.Context, projectName string, metadata *ImportMetadata) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactImportMetadataSection, func() interface{} {
+		return &map[string]*ImportMetadata{}
+	}, func(data interface{}) bool {
+		importMetadataByID := *data.(*map[string]*ImportMetadata)
+		if existing, ok := importMetadataByID[metadata.ImportSourceID]; ok && *existing == *metadata {
+			return false
+		}
+
+		importMetadataByID[metadata.ImportSourceID] = metadata
+
+		return true
+	})
+}
+
+func (storage *RepoStagesStorage) rmMetadataArtifactImportMetadata(ctx context.Context, projectName string, id string) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactImportMetadataSection, func() interface{} {
+		return &map[string]*ImportMetadata{}
+	}, func(data interface{}) bool {
+		importMetadataByID := *data.(*map[string]*ImportMetadata)
+		if _, ok := importMetadataByID[id]; !ok {
+			return false
+		}
+
+		delete(importMetadataByID, id)
+
+		return true
+	})
+}
+
+func (storage *RepoStagesStorage) getMetadataArtifactClientIDRecords(artifact *repoMetadataArtifact) ([]*ClientIDRecord, error) {
+	var records []*ClientIDRecord
+	if err := artifact.readSection(repoMetadataArtifactClientIDRecordsSection, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (storage *RepoStagesStorage) postMetadataArtifactClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
+	return storage.updateMetadataArtifactSection(ctx, projectName, repoMetadataArtifactClientIDRecordsSection, func() interface{} {
+		return &[]*ClientIDRecord{}
+	}, func(data interface{}) bool {
+		records := data.(*[]*ClientIDRecord)
+		for _, r := range *records {
+			if *r == *rec {
+				return false
+			}
+		}
+
+		*records = append(*records, rec)
+
+		return true
+	})
+}
+
+// MigrateMetadataToArtifact moves the metadata records of the tag per record format into the metadata artifact.
+// After the migration werf reads and writes the records of the artifact, the legacy records are still read until
+// they are removed by rmLegacyTags option. The legacy records should be removed only when all werf processes
+// working with the repo support the artifact, because the records added by the old werf versions are not read anymore.
+func (storage *RepoStagesStorage) MigrateMetadataToArtifact(ctx context.Context, projectName string, rmLegacyTags bool) error {
+	return storage.withMetadataArtifactLock(ctx, projectName, func() error {
+		storage.setMetadataArtifactAbsent(false)
+		artifact, err := storage.getMetadataArtifact(ctx)
+		if err != nil {
+			return err
+		}
+
+		// the legacy tags are listed with rmLegacyTags option even if they have been removed,
+		// because the tags sharing the digest with the not migrated records are kept by the previous removal
+		var legacyTags []string
+		if artifact == nil || !artifact.legacyTagsRemoved || rmLegacyTags {
+			legacyTags, err = storage.getLegacyMetadataTags(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := storage.writeMigratedMetadataArtifact(ctx, artifact, legacyTags, rmLegacyTags); err != nil {
+			return err
+		}
+
+		if !rmLegacyTags || len(legacyTags) == 0 {
+			return nil
+		}
+
+		// the tags are listed again right before the removal, because the old werf versions might have added
+		// the legacy records since the first listing, such records are moved into the artifact as well
+		for {
+			currentLegacyTags, err := storage.getLegacyMetadataTags(ctx)
+			if err != nil {
+				return err
+			}
+
+			var newLegacyTags []string
+			for _, tag := range currentLegacyTags {
+				if !util.IsStringsContainValue(legacyTags, tag) {
+					newLegacyTags = append(newLegacyTags, tag)
+				}
+			}
+
+			if len(newLegacyTags) == 0 {
+				break
+			}
+
+			artifact, err := storage.getMetadataArtifact(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := storage.writeMigratedMetadataArtifact(ctx, artifact, newLegacyTags, true); err != nil {
+				return err
+			}
+
+			legacyTags = append(legacyTags, newLegacyTags...)
+		}
+
+		return storage.rmLegacyMetadataTags(ctx, legacyTags)
+	})
+}
+
+// writeMigratedMetadataArtifact writes the artifact with the records of the legacy tags merged into the records of the artifact
+func (storage *RepoStagesStorage) writeMigratedMetadataArtifact(ctx context.Context, artifact *repoMetadataArtifact, legacyTags []string, rmLegacyTags bool) error {
+	managedImages := getManagedImagesFromRepoTags(legacyTags)
+	importMetadataByID := map[string]*ImportMetadata{}
+	clientIDRecords := getClientIDRecordsFromRepoTags(ctx, legacyTags)
+	imageMetadataByImageID := map[string]*repoImageMetadataSection{}
+
+	for _, id := range getImportMetadataIDsFromRepoTags(legacyTags) {
+		metadata, err := storage.getLegacyImportMetadata(ctx, id)
+		if err != nil {
+			return err
+		} else if metadata != nil {
+			importMetadataByID[id] = metadata
+		}
+	}
+
+	_, legacyImageMetadataByImageID, err := groupImageMetadataTagsByImageName(ctx, nil, legacyTags, RepoImageMetadataByCommitRecord_ImageTagPrefix)
+	if err != nil {
+		return err
+	}
+
+	for imageID, stageIDCommits := range legacyImageMetadataByImageID {
+		imageMetadataByImageID[imageID] = &repoImageMetadataSection{StageIDCommits: stageIDCommits}
+	}
+
+	if artifact != nil {
+		if artifactManagedImages, err := storage.getMetadataArtifactManagedImages(artifact); err != nil {
+			return err
+		} else {
+			managedImages = append(managedImages, artifactManagedImages...)
+		}
+
+		if artifactImportMetadata, err := storage.getMetadataArtifactImportMetadata(artifact); err != nil {
+			return err
+		} else {
+			for id, metadata := range artifactImportMetadata {
+				importMetadataByID[id] = metadata
+			}
+		}
+
+		if artifactClientIDRecords, err := storage.getMetadataArtifactClientIDRecords(artifact); err != nil {
+			return err
+		} else {
+			clientIDRecords = append(clientIDRecords, artifactClientIDRecords...)
+		}
+
+		for _, section := range artifact.sections() {
+			if !strings.HasPrefix(section, repoMetadataArtifactImageMetadataSectionPrefix) {
+				continue
+			}
+
+			imageID := strings.TrimPrefix(section, repoMetadataArtifactImageMetadataSectionPrefix)
+			data := &repoImageMetadataSection{}
+			if err := artifact.readSection(section, data); err != nil {
+				return err
+			}
+
+			if legacyData, ok := imageMetadataByImageID[imageID]; ok {
+				for stageID, commits := range data.StageIDCommits {
+					legacyData.StageIDCommits[stageID] = append(legacyData.StageIDCommits[stageID], commits...)
+				}
+			} else {
+				imageMetadataByImageID[imageID] = data
+			}
+		}
+	}
+
+	dataBySection := map[string]interface{}{
+		repoMetadataArtifactManagedImagesSection:   uniqSortedStrings(managedImages),
+		repoMetadataArtifactImportMetadataSection:  importMetadataByID,
+		repoMetadataArtifactClientIDRecordsSection: uniqClientIDRecords(clientIDRecords),
+	}
+
+	for imageID, data := range imageMetadataByImageID {
+		for stageID, commits := range data.StageIDCommits {
+			data.StageIDCommits[stageID] = uniqSortedStrings(commits)
+		}
+
+		dataBySection[repoMetadataArtifactImageMetadataSection(imageID)] = data
+	}
+
+	legacyTagsRemoved := rmLegacyTags || (artifact != nil && artifact.legacyTagsRemoved)
+	img, err := artifact.withSections(dataBySection, legacyTagsRemoved)
+	if err != nil {
+		return fmt.Errorf("unable to construct metadata artifact: %s", err)
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Writing metadata artifact %s", storage.metadataArtifactName()).DoError(func() error {
+		logboek.Context(ctx).Default().LogFDetails("managed images: %d\n", len(managedImages))
+		logboek.Context(ctx).Default().LogFDetails("images metadata: %d\n", len(imageMetadataByImageID))
+		logboek.Context(ctx).Default().LogFDetails("imports metadata: %d\n", len(importMetadataByID))
+		logboek.Context(ctx).Default().LogFDetails("client ID records: %d\n", len(clientIDRecords))
+
+		return docker_registry.API().WriteRepoImage(ctx, storage.metadataArtifactName(), img)
+	}); err != nil {
+		return fmt.Errorf("unable to write metadata artifact %s: %s", storage.metadataArtifactName(), err)
+	}
+	storage.setMetadataArtifactAbsent(false)
+
+	return nil
+}
+
+// rmLegacyMetadataTags removes the migrated legacy tags. The tag is removed by the manifest digest, and most of the legacy records
+// are the same empty manifest, thus the removal of the digest removes all the tags sharing the digest. The digest shared with
+// the legacy tag which has not been migrated is kept, the tags are listed right before the removal to find such tags.
+func (storage *RepoStagesStorage) rmLegacyMetadataTags(ctx context.Context, legacyTags []string) error {
+	currentLegacyTags, err := storage.getLegacyMetadataTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	var imagesToRemove []*image.Info
+	keptTagByDigest := map[string]string{}
+	for _, tag := range currentLegacyTags {
+		fullImageName := strings.Join([]string{storage.RepoAddress, tag}, ":")
+		img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+		if err != nil {
+			return fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+		} else if img == nil {
+			continue
+		}
+
+		if util.IsStringsContainValue(legacyTags, tag) {
+			imagesToRemove = append(imagesToRemove, img)
+		} else {
+			keptTagByDigest[img.RepoDigest] = tag
+		}
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Removing %d legacy metadata tags", len(imagesToRemove)).DoError(func() error {
+		removedDigests := map[string]bool{}
+		for _, img := range imagesToRemove {
+			fullImageName := strings.Join([]string{storage.RepoAddress, img.Tag}, ":")
+
+			if keptTag, ok := keptTagByDigest[img.RepoDigest]; ok {
+				logboek.Context(ctx).Warn().LogF("WARNING: %s is not removed: digest %s is shared with the not migrated tag %s, run the migration again\n", fullImageName, img.RepoDigest, keptTag)
+				continue
+			}
+
+			if !removedDigests[img.RepoDigest] {
+				if err := storage.DockerRegistry.DeleteRepoImage(ctx, img); err != nil {
+					return fmt.Errorf("unable to remove repo image %s: %s", fullImageName, err)
+				}
+				removedDigests[img.RepoDigest] = true
+			}
+
+			logboek.Context(ctx).Info().LogF("Removed %s\n", fullImageName)
+		}
+
+		return nil
+	})
+}
+
+// getLegacyMetadataTags returns the tags of the records, which are stored in the metadata artifact in the new format
+func (storage *RepoStagesStorage) getLegacyMetadataTags(ctx context.Context) ([]string, error) {
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []string
+	for _, tag := range tags {
+		for _, prefix := range []string{
+			RepoManagedImageRecord_ImageTagPrefix,
+			RepoImageMetadataByCommitRecord_ImageTagPrefix,
+			RepoImportMetadata_ImageTagPrefix,
+			RepoClientIDRecrod_ImageTagPrefix,
+		} {
+			if strings.HasPrefix(tag, prefix) {
+				res = append(res, tag)
+				break
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func uniqSortedStrings(arr []string) []string {
+	res := util.UniqStrings(arr)
+	sort.Strings(res)
+
+	return res
+}
+
+func uniqClientIDRecords(records []*ClientIDRecord) []*ClientIDRecord {
+	res := []*ClientIDRecord{}
+
+recordsLoop:
+	for _, rec := range records {
+		for _, r := range res {
+			if *r == *rec {
+				continue recordsLoop
+			}
+		}
+
+		res = append(res, rec)
+	}
+
+	return res
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/werf/lockgate/pkg/file_locker"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestRepoStagesStorageMetadataArtifact(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	if err := docker_registry.Init(ctx, true, false, docker_registry.ThrottlingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repoAddress := strings.TrimPrefix(server.URL, "http://") + "/project"
+	storage, err := NewRepoStagesStorage(repoAddress, nil, RepoStagesStorageOptions{
+		DockerRegistryOptions: docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+		Implementation:        docker_registry.DefaultImplementationName,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the registry does not support tags listing, thus the artifact is created without the legacy records
+	img, err := (*repoMetadataArtifact)(nil).withSections(map[string]interface{}{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := docker_registry.API().WriteRepoImage(ctx, storage.metadataArtifactName(), img); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, imageName := range []string{"backend", "frontend/app", "backend"} {
+		if err := storage.AddManagedImage(ctx, "project", imageName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.RmManagedImage(ctx, "project", "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if managedImages, err := storage.GetManagedImages(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(managedImages, []string{"frontend/app"}) {
+		t.Errorf("unexpected managed images %v", managedImages)
+	}
+
+	for _, commit := range []string{"commit-1", "commit-2"} {
+		if err := storage.PutImageMetadata(ctx, "project", "frontend/app", commit, "stage-id"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.RmImageMetadata(ctx, "project", "frontend/app", "commit-1", "stage-id"); err != nil {
+		t.Fatal(err)
+	}
+	if exist, err := storage.IsImageMetadataExist(ctx, "project", "frontend/app", "commit-2", "stage-id"); err != nil {
+		t.Fatal(err)
+	} else if !exist {
+		t.Errorf("expected image metadata to exist")
+	}
+	if metadata, _, err := storage.GetAllAndGroupImageMetadataByImageName(ctx, "project", []string{"frontend/app"}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(metadata, map[string]map[string][]string{"frontend/app": {"stage-id": {"commit-2"}}}) {
+		t.Errorf("unexpected image metadata %v", metadata)
+	}
+
+	importMetadata := &ImportMetadata{ImportSourceID: "source-id", SourceImageID: "image-id", Checksum: "checksum"}
+	if err := storage.PutImportMetadata(ctx, "project", importMetadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata, err := storage.GetImportMetadata(ctx, "project", "source-id"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(metadata, importMetadata) {
+		t.Errorf("unexpected import metadata %#v", metadata)
+	}
+	if err := storage.RmImportMetadata(ctx, "project", "source-id"); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := storage.GetImportMetadataIDs(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 0 {
+		t.Errorf("unexpected import metadata ids %v", ids)
+	}
+
+	if err := storage.PostClientIDRecord(ctx, "project", &ClientIDRecord{ClientID: "client-id", TimestampMillisec: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := storage.GetClientIDRecords(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(records) != 1 || *records[0] != (ClientIDRecord{ClientID: "client-id", TimestampMillisec: 42}) {
+		t.Errorf("unexpected client id records %v", records)
+	}
+}
+
+func TestRepoStagesStorageMetadataArtifactConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer server.Close()
+
+	if err := docker_registry.Init(ctx, true, false, docker_registry.ThrottlingOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	locksDir, err := ioutil.TempDir("", "werf-metadata-artifact-locks-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(locksDir)
+
+	locker, err := file_locker.NewFileLocker(locksDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockManager := NewGenericLockManager(locker)
+
+	repoAddress := strings.TrimPrefix(server.URL, "http://") + "/project"
+
+	// each writer has its own storage as a separate werf process would have
+	newStorage := func() *RepoStagesStorage {
+		storage, err := NewRepoStagesStorage(repoAddress, nil, RepoStagesStorageOptions{
+			DockerRegistryOptions: docker_registry.DockerRegistryOptions{InsecureRegistry: true},
+			Implementation:        docker_registry.DefaultImplementationName,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		storage.LockManager = lockManager
+
+		return storage
+	}
+
+	img, err := (*repoMetadataArtifact)(nil).withSections(map[string]interface{}{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := docker_registry.API().WriteRepoImage(ctx, newStorage().metadataArtifactName(), img); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 10
+	var expectedCommits []string
+	for i := 0; i < writers; i++ {
+		expectedCommits = append(expectedCommits, fmt.Sprintf("commit-%d", i))
+	}
+	sort.Strings(expectedCommits)
+
+	var storages []*RepoStagesStorage
+	for i := 0; i < writers; i++ {
+		storages = append(storages, newStorage())
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, writers*2)
+	for i, storage := range storages {
+		wg.Add(1)
+		go func(storage *RepoStagesStorage, commit string) {
+			defer wg.Done()
+
+			if err := storage.PutImageMetadata(ctx, "project", "app", commit, "stage-id"); err != nil {
+				errCh <- err
+			}
+			if err := storage.AddManagedImage(ctx, "project", "image-"+commit); err != nil {
+				errCh <- err
+			}
+		}(storage, expectedCommits[i])
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Fatal(err)
+	}
+
+	storage := newStorage()
+	if metadata, _, err := storage.GetAllAndGroupImageMetadataByImageName(ctx, "project", []string{"app"}); err != nil {
+		t.Fatal(err)
+	} else {
+		commits := metadata["app"]["stage-id"]
+		sort.Strings(commits)
+		if !reflect.DeepEqual(commits, expectedCommits) {
+			t.Errorf("unexpected image metadata commits %v", commits)
+		}
+	}
+
+	if managedImages, err := storage.GetManagedImages(ctx, "project"); err != nil {
+		t.Fatal(err)
+	} else if len(managedImages) != writers {
+		t.Errorf("unexpected managed images %v", managedImages)
+	}
+}
+
+// legacyTagsTestRegistry removes the manifest digest with all the tags sharing it as the registries do
+type legacyTagsTestRegistry struct {
+	docker_registry.DockerRegistry
+	repoAddress string
+	digestByTag map[string]string
+}
+
+func (r *legacyTagsTestRegistry) Tags(_ context.Context, _ string) ([]string, error) {
+	var tags []string
+	for tag := range r.digestByTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags, nil
+}
+
+func (r *legacyTagsTestRegistry) TryGetRepoImage(_ context.Context, reference string) (*image.Info, error) {
+	tag := strings.TrimPrefix(reference, r.repoAddress+":")
+	digest, ok := r.digestByTag[tag]
+	if !ok {
+		return nil, nil
+	}
+
+	return &image.Info{Repository: r.repoAddress, Tag: tag, RepoDigest: digest}, nil
+}
+
+func (r *legacyTagsTestRegistry) DeleteRepoImage(_ context.Context, repoImage *image.Info) error {
+	for tag, digest := range r.digestByTag {
+		if digest == repoImage.RepoDigest {
+			delete(r.digestByTag, tag)
+		}
+	}
+
+	return nil
+}
+
+func TestRepoStagesStorageRmLegacyMetadataTags(t *testing.T) {
+	managedImageTag := func(imageName string) string {
+		return RepoManagedImageRecord_ImageTagPrefix + imageName
+	}
+
+	tests := []struct {
+		name                string
+		digestByTag         map[string]string
+		migratedTags        []string
+		expectedDigestByTag map[string]string
+	}{
+		{
+			name:                "migrated tags sharing the digest are removed",
+			digestByTag:         map[string]string{managedImageTag("a"): "sha256:empty", managedImageTag("b"): "sha256:empty", "stage-tag": "sha256:stage"},
+			migratedTags:        []string{managedImageTag("a"), managedImageTag("b")},
+			expectedDigestByTag: map[string]string{"stage-tag": "sha256:stage"},
+		},
+		{
+			name:                "digest shared with the not migrated tag is kept",
+			digestByTag:         map[string]string{managedImageTag("a"): "sha256:empty", managedImageTag("b"): "sha256:empty", managedImageTag("c"): "sha256:labels"},
+			migratedTags:        []string{managedImageTag("a"), managedImageTag("c")},
+			expectedDigestByTag: map[string]string{managedImageTag("a"): "sha256:empty", managedImageTag("b"): "sha256:empty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dockerRegistry := &legacyTagsTestRegistry{repoAddress: "registry.example.com/project", digestByTag: tt.digestByTag}
+			storage := &RepoStagesStorage{RepoAddress: dockerRegistry.repoAddress, DockerRegistry: dockerRegistry}
+
+			if err := storage.rmLegacyMetadataTags(context.Background(), tt.migratedTags); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(dockerRegistry.digestByTag, tt.expectedDigestByTag) {
+				t.Errorf("unexpected repo tags %v, expected %v", dockerRegistry.digestByTag, tt.expectedDigestByTag)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/werf/logboek"
 
@@ -29,6 +30,12 @@ const (
 	RepoClientIDRecrod_ImageTagPrefix  = "client-id-"
 	RepoClientIDRecrod_ImageNameFormat = "%s:client-id-%s-%d"
 
+	RepoImageIndex_ImageTagPrefix  = "image-index-"
+	RepoImageIndex_ImageNameFormat = "%s:image-index-%s"
+
+	RepoCleanupAuditRecord_ImageTagPrefix  = "cleanup-audit-"
+	RepoCleanupAuditRecord_ImageNameFormat = "%s:cleanup-audit-%s"
+
 	UnexpectedTagFormatErrorPrefix = "unexpected tag format"
 )
 
@@ -54,6 +61,12 @@ type RepoStagesStorage struct {
 	RepoAddress      string
 	DockerRegistry   docker_registry.DockerRegistry
 	ContainerRuntime container_runtime.ContainerRuntime
+	// LockManager is used to lock the metadata artifact changes of the concurrent werf processes
+	LockManager LockManager
+
+	metadataArtifactMutex       sync.Mutex
+	metadataArtifactAbsent      bool
+	metadataArtifactAbsentMutex sync.Mutex
 }
 
 type RepoStagesStorageOptions struct {
@@ -80,6 +93,49 @@ func (storage *RepoStagesStorage) ConstructStageImageName(_, digest string, uniq
 	return fmt.Sprintf(RepoStage_ImageFormat, storage.RepoAddress, digest, uniqueID)
 }
 
+func (storage *RepoStagesStorage) ConstructImageIndexName(digest string) string {
+	return fmt.Sprintf(RepoImageIndex_ImageNameFormat, storage.RepoAddress, digest)
+}
+
+// GetImageIndexDescriptionList returns the multi-platform images indexes stored in the repo
+func (storage *RepoStagesStorage) GetImageIndexDescriptionList(ctx context.Context) ([]*image.ImageIndexDescription, error) {
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch tags for repo %q: %s", storage.RepoAddress, err)
+	}
+
+	var res []*image.ImageIndexDescription
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoImageIndex_ImageTagPrefix) {
+			continue
+		}
+
+		indexName := fmt.Sprintf("%s:%s", storage.RepoAddress, tag)
+		indexInfo, indexManifest, err := docker_registry.API().GetRepoImageIndexManifest(ctx, indexName)
+		if err != nil {
+			if docker_registry.IsManifestUnknownError(err) || docker_registry.IsNameUnknownError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("unable to get image index %s: %s", indexName, err)
+		}
+
+		indexDesc := &image.ImageIndexDescription{Info: indexInfo}
+		for _, desc := range indexManifest.Manifests {
+			if stageID, hasKey := desc.Annotations[image.WerfImageIndexStageIDAnnotation]; hasKey {
+				indexDesc.StageIDs = append(indexDesc.StageIDs, stageID)
+			}
+		}
+
+		res = append(res, indexDesc)
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) DeleteImageIndex(ctx context.Context, indexDesc *image.ImageIndexDescription) error {
+	return storage.DockerRegistry.DeleteRepoImage(ctx, indexDesc.Info)
+}
+
 func (storage *RepoStagesStorage) GetStagesIDs(ctx context.Context, projectName string) ([]image.StageID, error) {
 	var res []image.StageID
 
@@ -89,7 +145,7 @@ func (storage *RepoStagesStorage) GetStagesIDs(ctx context.Context, projectName
 		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetRepoImagesByDigest fetched tags for %q: %#v\n", storage.RepoAddress, tags)
 
 		for _, tag := range tags {
-			if strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoImageMetadataByCommitRecord_ImageTagPrefix) {
+			if strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoImageMetadataByCommitRecord_ImageTagPrefix) || strings.HasPrefix(tag, RepoImageIndex_ImageTagPrefix) {
 				continue
 			}
 
@@ -180,6 +236,12 @@ func (storage *RepoStagesStorage) AddManagedImage(ctx context.Context, projectNa
 		return nil
 	}
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		return storage.addMetadataArtifactManagedImage(ctx, projectName, imageName)
+	}
+
 	fullImageName := makeRepoManagedImageRecord(storage.RepoAddress, imageName)
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.AddManagedImage full image name: %s\n", fullImageName)
 
@@ -202,6 +264,18 @@ func (storage *RepoStagesStorage) AddManagedImage(ctx context.Context, projectNa
 func (storage *RepoStagesStorage) RmManagedImage(ctx context.Context, projectName, imageName string) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmManagedImage %s %s\n", projectName, imageName)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		if err := storage.rmMetadataArtifactManagedImage(ctx, projectName, imageName); err != nil {
+			return err
+		}
+
+		if artifact.legacyTagsRemoved {
+			return nil
+		}
+	}
+
 	fullImageName := makeRepoManagedImageRecord(storage.RepoAddress, imageName)
 
 	if imgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName); err != nil {
@@ -223,72 +297,95 @@ func (storage *RepoStagesStorage) GetManagedImages(ctx context.Context, projectN
 
 	var res []string
 
+	artifact, err := storage.getMetadataArtifact(ctx)
+	if err != nil {
+		return nil, err
+	} else if artifact != nil {
+		if res, err = storage.getMetadataArtifactManagedImages(artifact); err != nil {
+			return nil, err
+		}
+
+		if artifact.legacyTagsRemoved {
+			return res, nil
+		}
+	}
+
 	if tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress); err != nil {
 		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
 	} else {
-		for _, tag := range tags {
-			if !strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) {
-				continue
-			}
+		res = append(res, getManagedImagesFromRepoTags(tags)...)
+	}
 
-			managedImageName := unslugDockerImageTagAsImageName(strings.TrimPrefix(tag, RepoManagedImageRecord_ImageTagPrefix))
+	if artifact != nil {
+		res = util.UniqStrings(res)
+	}
 
-			if validateImageName(managedImageName) != nil {
-				continue
-			}
+	return res, nil
+}
+
+func getManagedImagesFromRepoTags(tags []string) []string {
+	var res []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoManagedImageRecord_ImageTagPrefix) {
+			continue
+		}
 
-			res = append(res, managedImageName)
+		managedImageName := unslugDockerImageTagAsImageName(strings.TrimPrefix(tag, RepoManagedImageRecord_ImageTagPrefix))
+
+		if validateImageName(managedImageName) != nil {
+			continue
 		}
+
+		res = append(res, managedImageName)
 	}
 
-	return res, nil
+	return res
 }
 
 func (storage *RepoStagesStorage) FetchImage(ctx context.Context, img container_runtime.Image) error {
-	switch containerRuntime := storage.ContainerRuntime.(type) {
-	case *container_runtime.LocalDockerServerRuntime:
-		return containerRuntime.PullImageFromRegistry(ctx, img)
-	default:
-		// TODO: case *container_runtime.LocalHostRuntime:
-		panic("not implemented")
-	}
+	return storage.ContainerRuntime.PullImageFromRegistry(ctx, img)
 }
 
-func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
-	switch containerRuntime := storage.ContainerRuntime.(type) {
-	case *container_runtime.LocalDockerServerRuntime:
-		dockerImage := img.(*container_runtime.DockerImage)
+func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image, opts StoreImageOptions) (*StoreImageReport, error) {
+	dockerImage := img.(*container_runtime.DockerImage)
 
-		if dockerImage.Image.GetBuiltId() != "" {
-			return containerRuntime.PushBuiltImage(ctx, img)
-		} else {
-			return containerRuntime.PushImage(ctx, img)
-		}
+	pushOpts := container_runtime.PushImageOptions{MountFromRepos: opts.MountFromRepos}
 
-	default:
-		// TODO: case *container_runtime.LocalHostRuntime:
-		panic("not implemented")
+	var pushReport *container_runtime.PushImageReport
+	var err error
+	if dockerImage.Image.GetBuiltId() != "" {
+		pushReport, err = storage.ContainerRuntime.PushBuiltImage(ctx, img, pushOpts)
+	} else {
+		pushReport, err = storage.ContainerRuntime.PushImage(ctx, img, pushOpts)
+	}
+	if err != nil {
+		return nil, err
 	}
+
+	return &StoreImageReport{UploadedBytes: pushReport.UploadedBytes}, nil
 }
 
 func (storage *RepoStagesStorage) ShouldFetchImage(_ context.Context, img container_runtime.Image) (bool, error) {
-	switch storage.ContainerRuntime.(type) {
-	case *container_runtime.LocalDockerServerRuntime:
-		dockerImage := img.(*container_runtime.DockerImage)
-		return !dockerImage.Image.IsExistsLocally(), nil
-	default:
-		panic("not implemented")
-	}
+	dockerImage := img.(*container_runtime.DockerImage)
+	return !dockerImage.Image.IsExistsLocally(), nil
 }
 
 func (storage *RepoStagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImageMetadata %s %s %s %s\n", projectName, imageName, commit, stageID)
 
-	fullImageName := makeRepoImageMetadataName(storage.RepoAddress, imageName, commit, stageID)
-	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImageMetadata full image name: %s\n", fullImageName)
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		if err := storage.putMetadataArtifactImageMetadata(ctx, projectName, imageName, commit, stageID); err != nil {
+			return err
+		}
+	} else {
+		fullImageName := makeRepoImageMetadataName(storage.RepoAddress, imageName, commit, stageID)
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImageMetadata full image name: %s\n", fullImageName)
 
-	if err := storage.DockerRegistry.PushImage(ctx, fullImageName, nil); err != nil {
-		return fmt.Errorf("unable to push image %s: %s", fullImageName, err)
+		if err := storage.DockerRegistry.PushImage(ctx, fullImageName, nil); err != nil {
+			return fmt.Errorf("unable to push image %s: %s", fullImageName, err)
+		}
 	}
 	logboek.Context(ctx).Info().LogF("Put image %s commit %s stage ID %s\n", imageName, commit, stageID)
 
@@ -298,6 +395,19 @@ func (storage *RepoStagesStorage) PutImageMetadata(ctx context.Context, projectN
 func (storage *RepoStagesStorage) RmImageMetadata(ctx context.Context, projectName, imageNameOrID, commit, stageID string) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmImageMetadata %s %s %s %s\n", projectName, imageNameOrID, commit, stageID)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		if err := storage.rmMetadataArtifactImageMetadata(ctx, projectName, artifact, imageNameOrID, commit, stageID); err != nil {
+			return err
+		}
+
+		if artifact.legacyTagsRemoved {
+			logboek.Context(ctx).Info().LogF("Removed image %s commit %s stage ID %s\n", imageNameOrID, commit, stageID)
+			return nil
+		}
+	}
+
 	img, err := storage.selectMetadataNameImage(ctx, imageNameOrID, commit, stageID)
 	if err != nil {
 		return err
@@ -335,6 +445,16 @@ func (storage *RepoStagesStorage) selectMetadataNameImage(ctx context.Context, i
 func (storage *RepoStagesStorage) IsImageMetadataExist(ctx context.Context, projectName, imageName, commit, stageID string) (bool, error) {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.IsImageMetadataExist %s %s %s %s\n", projectName, imageName, commit, stageID)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return false, err
+	} else if artifact != nil {
+		if exist, err := storage.isMetadataArtifactImageMetadataExist(artifact, imageName, commit, stageID); err != nil {
+			return false, err
+		} else if exist || artifact.legacyTagsRemoved {
+			return exist, nil
+		}
+	}
+
 	fullImageName := makeRepoImageMetadataName(storage.RepoAddress, imageName, commit, stageID)
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.IsImageMetadataExist full image name: %s\n", fullImageName)
 
@@ -345,9 +465,26 @@ func (storage *RepoStagesStorage) IsImageMetadataExist(ctx context.Context, proj
 func (storage *RepoStagesStorage) GetAllAndGroupImageMetadataByImageName(ctx context.Context, projectName string, imageNameList []string) (map[string]map[string][]string, map[string]map[string][]string, error) {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetImageNameStageIDCommitList %s %s\n", projectName)
 
-	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	artifact, err := storage.getMetadataArtifact(ctx)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+		return nil, nil, err
+	}
+
+	var tags []string
+	if artifact == nil || !artifact.legacyTagsRemoved {
+		tags, err = storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+		}
+	}
+
+	if artifact != nil {
+		artifactTags, err := storage.getMetadataArtifactImageMetadataTags(artifact)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tags = util.UniqStrings(append(artifactTags, tags...))
 	}
 
 	return groupImageMetadataTagsByImageName(ctx, imageNameList, tags, RepoImageMetadataByCommitRecord_ImageTagPrefix)
@@ -356,6 +493,24 @@ func (storage *RepoStagesStorage) GetAllAndGroupImageMetadataByImageName(ctx con
 func (storage *RepoStagesStorage) GetImportMetadata(ctx context.Context, _, id string) (*ImportMetadata, error) {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetImportMetadata %s\n", id)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return nil, err
+	} else if artifact != nil {
+		if importMetadataByID, err := storage.getMetadataArtifactImportMetadata(artifact); err != nil {
+			return nil, err
+		} else if metadata, ok := importMetadataByID[id]; ok {
+			return metadata, nil
+		}
+
+		if artifact.legacyTagsRemoved {
+			return nil, nil
+		}
+	}
+
+	return storage.getLegacyImportMetadata(ctx, id)
+}
+
+func (storage *RepoStagesStorage) getLegacyImportMetadata(ctx context.Context, id string) (*ImportMetadata, error) {
 	fullImageName := makeRepoImportMetadataName(storage.RepoAddress, id)
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetImportMetadata full image name: %s\n", fullImageName)
 
@@ -369,9 +524,15 @@ func (storage *RepoStagesStorage) GetImportMetadata(ctx context.Context, _, id s
 	}
 }
 
-func (storage *RepoStagesStorage) PutImportMetadata(ctx context.Context, _ string, metadata *ImportMetadata) error {
+func (storage *RepoStagesStorage) PutImportMetadata(ctx context.Context, projectName string, metadata *ImportMetadata) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImportMetadata %v\n", metadata)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		return storage.putMetadataArtifactImportMetadata(ctx, projectName, metadata)
+	}
+
 	fullImageName := makeRepoImportMetadataName(storage.RepoAddress, metadata.ImportSourceID)
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImportMetadata full image name: %s\n", fullImageName)
 
@@ -385,9 +546,21 @@ func (storage *RepoStagesStorage) PutImportMetadata(ctx context.Context, _ strin
 	return nil
 }
 
-func (storage *RepoStagesStorage) RmImportMetadata(ctx context.Context, _, id string) error {
+func (storage *RepoStagesStorage) RmImportMetadata(ctx context.Context, projectName, id string) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmImportMetadata %s\n", id)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		if err := storage.rmMetadataArtifactImportMetadata(ctx, projectName, id); err != nil {
+			return err
+		}
+
+		if artifact.legacyTagsRemoved {
+			return nil
+		}
+	}
+
 	fullImageName := makeRepoImportMetadataName(storage.RepoAddress, id)
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.RmImportMetadata full image name: %s\n", fullImageName)
 
@@ -408,11 +581,41 @@ func (storage *RepoStagesStorage) RmImportMetadata(ctx context.Context, _, id st
 func (storage *RepoStagesStorage) GetImportMetadataIDs(ctx context.Context, _ string) ([]string, error) {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetImportMetadataIDs\n")
 
+	var ids []string
+
+	artifact, err := storage.getMetadataArtifact(ctx)
+	if err != nil {
+		return nil, err
+	} else if artifact != nil {
+		importMetadataByID, err := storage.getMetadataArtifactImportMetadata(artifact)
+		if err != nil {
+			return nil, err
+		}
+
+		for id := range importMetadataByID {
+			ids = append(ids, id)
+		}
+
+		if artifact.legacyTagsRemoved {
+			return ids, nil
+		}
+	}
+
 	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
 	}
 
+	ids = append(ids, getImportMetadataIDsFromRepoTags(tags)...)
+
+	if artifact != nil {
+		ids = util.UniqStrings(ids)
+	}
+
+	return ids, nil
+}
+
+func getImportMetadataIDsFromRepoTags(tags []string) []string {
 	var ids []string
 	for _, tag := range tags {
 		if !strings.HasPrefix(tag, RepoImportMetadata_ImageTagPrefix) {
@@ -422,7 +625,7 @@ func (storage *RepoStagesStorage) GetImportMetadataIDs(ctx context.Context, _ st
 		ids = append(ids, getImportMetadataIDFromRepoTag(tag))
 	}
 
-	return ids, nil
+	return ids
 }
 
 func getImportMetadataIDFromRepoTag(tag string) string {
@@ -550,40 +753,76 @@ func (storage *RepoStagesStorage) GetClientIDRecords(ctx context.Context, projec
 
 	var res []*ClientIDRecord
 
+	artifact, err := storage.getMetadataArtifact(ctx)
+	if err != nil {
+		return nil, err
+	} else if artifact != nil {
+		if res, err = storage.getMetadataArtifactClientIDRecords(artifact); err != nil {
+			return nil, err
+		}
+
+		if artifact.legacyTagsRemoved {
+			return res, nil
+		}
+	}
+
 	if tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress); err != nil {
 		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
 	} else {
-		for _, tag := range tags {
-			if !strings.HasPrefix(tag, RepoClientIDRecrod_ImageTagPrefix) {
-				continue
-			}
+		res = append(res, getClientIDRecordsFromRepoTags(ctx, tags)...)
+	}
 
-			tagWithoutPrefix := strings.TrimPrefix(tag, RepoClientIDRecrod_ImageTagPrefix)
-			dataParts := strings.SplitN(util.Reverse(tagWithoutPrefix), "-", 2)
-			if len(dataParts) != 2 {
-				continue
-			}
+	if artifact != nil {
+		res = uniqClientIDRecords(res)
+	}
 
-			clientID, timestampMillisecStr := util.Reverse(dataParts[1]), util.Reverse(dataParts[0])
+	return res, nil
+}
 
-			timestampMillisec, err := strconv.ParseInt(timestampMillisecStr, 10, 64)
-			if err != nil {
-				continue
-			}
+func getClientIDRecordsFromRepoTags(ctx context.Context, tags []string) []*ClientIDRecord {
+	var res []*ClientIDRecord
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoClientIDRecrod_ImageTagPrefix) {
+			continue
+		}
+
+		tagWithoutPrefix := strings.TrimPrefix(tag, RepoClientIDRecrod_ImageTagPrefix)
+		dataParts := strings.SplitN(util.Reverse(tagWithoutPrefix), "-", 2)
+		if len(dataParts) != 2 {
+			continue
+		}
 
-			rec := &ClientIDRecord{ClientID: clientID, TimestampMillisec: timestampMillisec}
-			res = append(res, rec)
+		clientID, timestampMillisecStr := util.Reverse(dataParts[1]), util.Reverse(dataParts[0])
 
-			logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetClientIDRecords got clientID record: %s\n", rec)
+		timestampMillisec, err := strconv.ParseInt(timestampMillisecStr, 10, 64)
+		if err != nil {
+			continue
 		}
+
+		rec := &ClientIDRecord{ClientID: clientID, TimestampMillisec: timestampMillisec}
+		res = append(res, rec)
+
+		logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetClientIDRecords got clientID record: %s\n", rec)
 	}
 
-	return res, nil
+	return res
 }
 
 func (storage *RepoStagesStorage) PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostClientID %s for project %s\n", rec.ClientID, projectName)
 
+	if artifact, err := storage.getMetadataArtifact(ctx); err != nil {
+		return err
+	} else if artifact != nil {
+		if err := storage.postMetadataArtifactClientIDRecord(ctx, projectName, rec); err != nil {
+			return err
+		}
+
+		logboek.Context(ctx).Info().LogF("Posted new clientID %q for project %s\n", rec.ClientID, projectName)
+
+		return nil
+	}
+
 	fullImageName := fmt.Sprintf(RepoClientIDRecrod_ImageNameFormat, storage.RepoAddress, rec.ClientID, rec.TimestampMillisec)
 
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PostClientID full image name: %s\n", fullImageName)
@@ -603,3 +842,142 @@ func (storage *RepoStagesStorage) PostClientIDRecord(ctx context.Context, projec
 
 	return nil
 }
+
+func (storage *RepoStagesStorage) GetForeignTags(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetForeignTags %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var res []string
+	for _, tag := range tags {
+		if isRepoWerfTag(tag) {
+			continue
+		}
+
+		res = append(res, tag)
+	}
+
+	return res, nil
+}
+
+func (storage *RepoStagesStorage) GetForeignTagInfo(ctx context.Context, projectName, tag string) (*image.Info, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetForeignTagInfo %s %s\n", projectName, tag)
+
+	fullImageName := fmt.Sprintf("%s:%s", storage.RepoAddress, tag)
+	imgInfo, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo image %q info: %s", fullImageName, err)
+	}
+
+	return imgInfo, nil
+}
+
+func (storage *RepoStagesStorage) DeleteForeignTag(ctx context.Context, projectName string, info *image.Info) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.DeleteForeignTag %s %s\n", projectName, info.Name)
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, info); err != nil {
+		return fmt.Errorf("unable to delete image %q from repo: %s", info.Name, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) GetCleanupAuditRecordIDs(ctx context.Context, projectName string) ([]string, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetCleanupAuditRecordIDs %s\n", projectName)
+
+	tags, err := storage.DockerRegistry.Tags(ctx, storage.RepoAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get repo %s tags: %s", storage.RepoAddress, err)
+	}
+
+	var ids []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, RepoCleanupAuditRecord_ImageTagPrefix) {
+			continue
+		}
+
+		ids = append(ids, strings.TrimPrefix(tag, RepoCleanupAuditRecord_ImageTagPrefix))
+	}
+
+	return ids, nil
+}
+
+func (storage *RepoStagesStorage) GetCleanupAuditRecord(ctx context.Context, projectName, id string) (*CleanupAuditRecord, error) {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.GetCleanupAuditRecord %s %s\n", projectName, id)
+
+	fullImageName := fmt.Sprintf(RepoCleanupAuditRecord_ImageNameFormat, storage.RepoAddress, id)
+	img, err := docker_registry.API().GetRepoImageObject(ctx, fullImageName)
+	if err != nil {
+		if docker_registry.IsManifestUnknownError(err) || docker_registry.IsNameUnknownError(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	}
+
+	rec, err := newCleanupAuditRecordFromArtifactImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo image %s: %s", fullImageName, err)
+	}
+
+	return rec, nil
+}
+
+func (storage *RepoStagesStorage) PutCleanupAuditRecord(ctx context.Context, projectName string, rec *CleanupAuditRecord) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutCleanupAuditRecord %s %s\n", projectName, rec.ID)
+
+	img, err := rec.ToArtifact()
+	if err != nil {
+		return err
+	}
+
+	fullImageName := fmt.Sprintf(RepoCleanupAuditRecord_ImageNameFormat, storage.RepoAddress, rec.ID)
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutCleanupAuditRecord full image name: %s\n", fullImageName)
+
+	if err := docker_registry.API().WriteRepoImage(ctx, fullImageName, img); err != nil {
+		return fmt.Errorf("unable to write image %s: %s", fullImageName, err)
+	}
+
+	return nil
+}
+
+func (storage *RepoStagesStorage) DeleteCleanupAuditRecord(ctx context.Context, projectName, id string) error {
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.DeleteCleanupAuditRecord %s %s\n", projectName, id)
+
+	fullImageName := fmt.Sprintf(RepoCleanupAuditRecord_ImageNameFormat, storage.RepoAddress, id)
+	img, err := storage.DockerRegistry.TryGetRepoImage(ctx, fullImageName)
+	if err != nil {
+		return fmt.Errorf("unable to get repo image %s: %s", fullImageName, err)
+	} else if img == nil {
+		return nil
+	}
+
+	if err := storage.DockerRegistry.DeleteRepoImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to remove repo image %s: %s", img.Tag, err)
+	}
+
+	return nil
+}
+
+// isRepoWerfTag checks that the tag is either the stage or one of the werf records
+func isRepoWerfTag(tag string) bool {
+	for _, prefix := range []string{
+		RepoManagedImageRecord_ImageTagPrefix,
+		RepoImageMetadataByCommitRecord_ImageTagPrefix,
+		RepoImportMetadata_ImageTagPrefix,
+		RepoClientIDRecrod_ImageTagPrefix,
+		RepoImageIndex_ImageTagPrefix,
+		RepoCleanupAuditRecord_ImageTagPrefix,
+		RepoMetadataArtifact_ImageTag,
+	} {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+
+	_, _, err := getDigestAndUniqueIDFromRepoStageImageTag(tag)
+	return err == nil
+}
@@ -26,7 +26,7 @@ type StagesStorage interface {
 	// FetchImage will create a local image in the container-runtime
 	FetchImage(ctx context.Context, img container_runtime.Image) error
 	// StoreImage will store a local image into the container-runtime, local built image should exist prior running store
-	StoreImage(ctx context.Context, img container_runtime.Image) error
+	StoreImage(ctx context.Context, img container_runtime.Image, opts StoreImageOptions) (*StoreImageReport, error)
 	ShouldFetchImage(ctx context.Context, img container_runtime.Image) (bool, error)
 
 	CreateRepo(ctx context.Context) error
@@ -49,10 +49,30 @@ type StagesStorage interface {
 	GetClientIDRecords(ctx context.Context, projectName string) ([]*ClientIDRecord, error)
 	PostClientIDRecord(ctx context.Context, projectName string, rec *ClientIDRecord) error
 
+	// GetForeignTags returns the tags of the stages storage which are neither stages nor werf records (e.g. manually pushed images)
+	GetForeignTags(ctx context.Context, projectName string) ([]string, error)
+	GetForeignTagInfo(ctx context.Context, projectName, tag string) (*image.Info, error)
+	DeleteForeignTag(ctx context.Context, projectName string, info *image.Info) error
+
+	GetCleanupAuditRecordIDs(ctx context.Context, projectName string) ([]string, error)
+	GetCleanupAuditRecord(ctx context.Context, projectName, id string) (*CleanupAuditRecord, error)
+	PutCleanupAuditRecord(ctx context.Context, projectName string, rec *CleanupAuditRecord) error
+	DeleteCleanupAuditRecord(ctx context.Context, projectName, id string) error
+
 	String() string
 	Address() string
 }
 
+type StoreImageOptions struct {
+	// MountFromRepos are the repositories of the same registry to reuse the existing layers from (secondary stages storages)
+	MountFromRepos []string
+}
+
+type StoreImageReport struct {
+	// UploadedBytes is the size of the layers actually uploaded into the stages storage
+	UploadedBytes int64
+}
+
 type ClientIDRecord struct {
 	ClientID          string
 	TimestampMillisec int64
@@ -72,7 +92,19 @@ type StagesStorageOptions struct {
 
 func NewStagesStorage(stagesStorageAddress string, containerRuntime container_runtime.ContainerRuntime, options StagesStorageOptions) (StagesStorage, error) {
 	if stagesStorageAddress == LocalStorageAddress {
-		return NewLocalDockerServerStagesStorage(containerRuntime.(*container_runtime.LocalDockerServerRuntime)), nil
+		localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		if !ok {
+			return nil, fmt.Errorf("%s stages storage cannot be used with %s container runtime", LocalStorageAddress, containerRuntime.String())
+		}
+
+		return NewLocalDockerServerStagesStorage(localDockerServerRuntime), nil
+	} else if IsOCILayoutStorageAddress(stagesStorageAddress) {
+		localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		if !ok {
+			return nil, fmt.Errorf("%s stages storage cannot be used with %s container runtime", OCILayoutStorageAddressPrefix, containerRuntime.String())
+		}
+
+		return NewOCILayoutStagesStorage(stagesStorageAddress, localDockerServerRuntime)
 	} else { // Docker registry based stages storage
 		return NewRepoStagesStorage(stagesStorageAddress, containerRuntime, options.RepoStagesStorageOptions)
 	}
@@ -171,6 +171,64 @@ func CopyGitIndexEntryIntoTar(tw *tar.Writer, tarEntryName string, entry *index.
 	return nil
 }
 
+func ExtractArchive(archivePath, destinationDir string) error {
+	source, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %s", archivePath, err)
+	}
+	defer source.Close()
+
+	tr := tar.NewReader(source)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("unable to read archive %q: %s", archivePath, err)
+		}
+
+		path := filepath.Join(destinationDir, filepath.FromSlash(hdr.Name))
+		if !IsSubpathOfBasePath(destinationDir, path) {
+			return fmt.Errorf("bad archive %q entry %q: path is outside of the destination directory", archivePath, hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)|0700); err != nil {
+				return fmt.Errorf("unable to create dir %q: %s", path, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(path), err)
+			}
+
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return fmt.Errorf("unable to create symlink %q: %s", path, err)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(path), err)
+			}
+
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return fmt.Errorf("unable to create file %q: %s", path, err)
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return fmt.Errorf("unable to write file %q: %s", path, err)
+			}
+
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("unable to close file %q: %s", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
 func debugArchiveUtil() bool {
 	return os.Getenv("WERF_DEBUG_ARCHIVE_UTIL") == "1"
 }
@@ -25,7 +25,7 @@ esac
 for package_path in $package_paths; do
   test_binary_filename=$(basename -- "$package_path")$ext
 	test_binary_path="$tests_binaries_output_dirname"/"$package_path"/"$test_binary_filename"
-	go test -ldflags="-s -w" --tags "dfrunmount dfssh" "$package_path" -coverpkg=./... -c -o "$test_binary_path"
+	go test -ldflags="-s -w" --tags "dfrunmount dfssh dfsecrets" "$package_path" -coverpkg=./... -c -o "$test_binary_path"
 
   if [[ ! -f $test_binary_path ]]; then # cmd/werf/main_test.go
      continue